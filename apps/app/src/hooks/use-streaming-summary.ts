@@ -1,19 +1,26 @@
 import config from '@/config';
-import { useCallback, useState } from 'react';
+import { useCallback, useRef, useState } from 'react';
 
 interface StreamingChunk {
-  word: string;
+  content: string;
   index: number;
-  total: number;
+}
+
+interface StreamingUsage {
+  prompt_tokens: number;
+  completion_tokens: number;
+  total_tokens: number;
+  estimated?: boolean;
 }
 
 interface StreamingComplete {
   summary: string;
   video_id: string;
   video_title: string;
-  note_count: number;
   generated_at: string;
   cached: boolean;
+  finish_reason?: string;
+  usage?: StreamingUsage;
 }
 
 export const useStreamingSummary = () => {
@@ -21,6 +28,7 @@ export const useStreamingSummary = () => {
   const [streamedText, setStreamedText] = useState('');
   const [progress, setProgress] = useState(0);
   const [finalSummary, setFinalSummary] = useState('');
+  const abortControllerRef = useRef<AbortController | null>(null);
 
   const generateStreamingSummary = useCallback(
     async (
@@ -34,6 +42,10 @@ export const useStreamingSummary = () => {
       setProgress(0);
       setFinalSummary('');
 
+      abortControllerRef.current?.abort();
+      const abortController = new AbortController();
+      abortControllerRef.current = abortController;
+
       try {
         const response = await fetch(
           `${config.apiUrl}/api/v1/timestamps/full-summary?stream=true`,
@@ -47,6 +59,7 @@ export const useStreamingSummary = () => {
               refresh,
             }),
             credentials: 'include',
+            signal: abortController.signal,
           },
         );
 
@@ -61,6 +74,10 @@ export const useStreamingSummary = () => {
 
         const decoder = new TextDecoder();
         let buffer = '';
+        // Event state lives across reads: token streams often split an
+        // event's lines over several network chunks
+        let currentEvent = '';
+        let currentData = '';
 
         while (true) {
           const { done, value } = await reader.read();
@@ -74,9 +91,6 @@ export const useStreamingSummary = () => {
           const lines = buffer.split('\n');
           buffer = lines.pop() || '';
 
-          let currentEvent = '';
-          let currentData = '';
-
           for (const line of lines) {
             if (line.startsWith('event: ')) {
               currentEvent = line.slice(7);
@@ -93,13 +107,11 @@ export const useStreamingSummary = () => {
 
                   if (currentEvent === 'chunk') {
                     const chunkData: StreamingChunk = parsed;
-                    setStreamedText(
-                      (prev) => prev + (prev ? ' ' : '') + chunkData.word,
-                    );
-                    setProgress((chunkData.index / chunkData.total) * 100);
+                    setStreamedText((prev) => prev + chunkData.content);
                   } else if (currentEvent === 'complete') {
                     const completeData: StreamingComplete = parsed;
                     setFinalSummary(completeData.summary);
+                    setProgress(100);
                     onComplete?.(completeData);
                     setIsStreaming(false);
                     return;
@@ -119,6 +131,9 @@ export const useStreamingSummary = () => {
           }
         }
       } catch (error) {
+        if (abortController.signal.aborted) {
+          return;
+        }
         console.error('Streaming error:', error);
         onError?.(error instanceof Error ? error.message : 'Unknown error');
         setIsStreaming(false);
@@ -128,6 +143,8 @@ export const useStreamingSummary = () => {
   );
 
   const stopStreaming = useCallback(() => {
+    abortControllerRef.current?.abort();
+    abortControllerRef.current = null;
     setIsStreaming(false);
     setStreamedText('');
     setProgress(0);
@@ -0,0 +1,401 @@
+// Package aicache is a content-addressed cache for embeddings and
+// completions. Entries are keyed by a hash of the model and input, so
+// identical requests share an entry across users. Entries live in Postgres,
+// optionally fronted by an in-process LRU.
+package aicache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/shubhamku044/ytclipper/internal/config"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/llm"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+)
+
+// Features group cache traffic for metrics and can be opted out of
+// individually with AI_CACHE_DISABLED_FEATURES
+const (
+	FeatureEmbedding = "embedding" // Note and transcript embeddings
+	FeatureQuery     = "query"     // Search, question and chat query embeddings
+	FeatureSummary   = "summary"
+	FeatureQuestion  = "question"
+	FeatureChat      = "chat"
+	FeatureSuggest   = "suggest" // Chapter and key moment suggestions
+)
+
+const (
+	kindEmbedding  = "embedding"
+	kindCompletion = "completion"
+
+	pruneInterval = time.Hour
+)
+
+// FeatureStats counts cache traffic of one feature since the process started
+type FeatureStats struct {
+	Feature    string `json:"feature"`
+	Enabled    bool   `json:"enabled"`
+	MemoryHits int64  `json:"memory_hits"`
+	DBHits     int64  `json:"db_hits"`
+	Misses     int64  `json:"misses"`
+	Errors     int64  `json:"errors"`
+	// SavedTokens estimates the tokens that hits kept from being sent
+	SavedTokens int64   `json:"saved_tokens"`
+	HitRate     float64 `json:"hit_rate"`
+}
+
+// EntryStats summarizes the stored entries of one kind
+type EntryStats struct {
+	Kind    string `json:"kind" bun:"kind"`
+	Entries int    `json:"entries" bun:"entries"`
+	Expired int    `json:"expired" bun:"expired"`
+	Hits    int    `json:"hits" bun:"hits"`
+}
+
+type Stats struct {
+	Enabled  bool           `json:"enabled"`
+	Features []FeatureStats `json:"features"`
+	Entries  []EntryStats   `json:"entries"`
+}
+
+type Cache struct {
+	db       *database.Database
+	cfg      config.AICacheConfig
+	disabled map[string]bool
+	memory   *lru
+
+	mu    sync.Mutex
+	stats map[string]*FeatureStats
+
+	pruning   atomic.Bool
+	lastPrune atomic.Int64
+}
+
+func New(db *database.Database, cfg *config.AICacheConfig) *Cache {
+	disabled := make(map[string]bool, len(cfg.DisabledFeatures))
+	for _, feature := range cfg.DisabledFeatures {
+		disabled[feature] = true
+	}
+
+	return &Cache{
+		db:       db,
+		cfg:      *cfg,
+		disabled: disabled,
+		memory:   newLRU(cfg.MemoryEntries),
+		stats:    make(map[string]*FeatureStats),
+	}
+}
+
+// Enabled reports whether feature reads and writes the cache. A nil Cache
+// is always disabled.
+func (c *Cache) Enabled(feature string) bool {
+	return c != nil && c.cfg.Enabled && c.db != nil && !c.disabled[feature]
+}
+
+func embeddingKey(model, text string) string {
+	return hashKey(kindEmbedding, model, text)
+}
+
+// completionKey covers everything that shapes the completion
+func completionKey(model string, req llm.CompletionRequest) string {
+	data, _ := json.Marshal(req)
+	return hashKey(kindCompletion, model, string(data))
+}
+
+func hashKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Embeddings returns the cached vectors among texts, keyed by text
+func (c *Cache) Embeddings(ctx context.Context, feature, model string, texts []string) map[string][]float32 {
+	if !c.Enabled(feature) || len(texts) == 0 {
+		return nil
+	}
+
+	found := make(map[string][]float32)
+	keys := make(map[string]string) // key -> text
+	for _, text := range texts {
+		if _, seen := found[text]; seen {
+			continue
+		}
+		key := embeddingKey(model, text)
+		if value, ok := c.memory.get(key); ok {
+			found[text] = value.([]float32)
+			c.record(feature, func(s *FeatureStats) {
+				s.MemoryHits++
+				s.SavedTokens += int64(llm.EstimateTokens(text))
+			})
+			continue
+		}
+		keys[key] = text
+	}
+	if len(keys) == 0 {
+		return found
+	}
+
+	lookup := make([]string, 0, len(keys))
+	for key := range keys {
+		lookup = append(lookup, key)
+	}
+
+	entries, err := c.load(ctx, kindEmbedding, lookup)
+	if err != nil {
+		log.Printf("Failed to read cached embeddings: %v", err)
+		c.record(feature, func(s *FeatureStats) { s.Errors++ })
+	}
+
+	for _, entry := range entries {
+		if entry.Embedding == nil {
+			continue
+		}
+		text := keys[entry.Key]
+		embedding := entry.Embedding.Slice()
+		found[text] = embedding
+		c.memory.add(entry.Key, embedding, entry.ExpiresAt)
+		c.record(feature, func(s *FeatureStats) {
+			s.DBHits++
+			s.SavedTokens += int64(llm.EstimateTokens(text))
+		})
+	}
+	c.record(feature, func(s *FeatureStats) { s.Misses += int64(len(keys) - len(entries)) })
+
+	return found
+}
+
+// StoreEmbeddings caches vectors keyed by the text they embed
+func (c *Cache) StoreEmbeddings(ctx context.Context, feature, model string, embeddings map[string][]float32) {
+	if !c.Enabled(feature) || len(embeddings) == 0 {
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(c.cfg.EmbeddingTTL)
+	entries := make([]models.AICacheEntry, 0, len(embeddings))
+	for text, embedding := range embeddings {
+		key := embeddingKey(model, text)
+		vector := pgvector.NewVector(embedding)
+		entries = append(entries, models.AICacheEntry{
+			Key:       key,
+			Kind:      kindEmbedding,
+			Model:     model,
+			Feature:   feature,
+			Embedding: &vector,
+			CreatedAt: now,
+			ExpiresAt: expiresAt,
+		})
+		c.memory.add(key, embedding, expiresAt)
+	}
+
+	c.store(ctx, feature, entries)
+}
+
+// Completion returns the cached completion for req, if any
+func (c *Cache) Completion(ctx context.Context, feature, model string, req llm.CompletionRequest) (string, bool) {
+	if !c.Enabled(feature) {
+		return "", false
+	}
+
+	key := completionKey(model, req)
+	saved := func(content string) int64 {
+		return int64(llm.EstimateUsage(req, content).TotalTokens)
+	}
+
+	if value, ok := c.memory.get(key); ok {
+		content := value.(string)
+		c.record(feature, func(s *FeatureStats) {
+			s.MemoryHits++
+			s.SavedTokens += saved(content)
+		})
+		return content, true
+	}
+
+	entries, err := c.load(ctx, kindCompletion, []string{key})
+	if err != nil {
+		log.Printf("Failed to read cached completion: %v", err)
+		c.record(feature, func(s *FeatureStats) { s.Errors++ })
+	}
+	if len(entries) == 0 || entries[0].Content == nil {
+		c.record(feature, func(s *FeatureStats) { s.Misses++ })
+		return "", false
+	}
+
+	content := *entries[0].Content
+	c.memory.add(key, content, entries[0].ExpiresAt)
+	c.record(feature, func(s *FeatureStats) {
+		s.DBHits++
+		s.SavedTokens += saved(content)
+	})
+	return content, true
+}
+
+// StoreCompletion caches the completion generated for req
+func (c *Cache) StoreCompletion(ctx context.Context, feature, model string, req llm.CompletionRequest, content string) {
+	if !c.Enabled(feature) || content == "" {
+		return
+	}
+
+	now := time.Now()
+	key := completionKey(model, req)
+	entry := models.AICacheEntry{
+		Key:       key,
+		Kind:      kindCompletion,
+		Model:     model,
+		Feature:   feature,
+		Content:   &content,
+		CreatedAt: now,
+		ExpiresAt: now.Add(c.cfg.CompletionTTL),
+	}
+	c.memory.add(key, content, entry.ExpiresAt)
+
+	c.store(ctx, feature, []models.AICacheEntry{entry})
+}
+
+// load reads unexpired entries and bumps their hit counters
+func (c *Cache) load(ctx context.Context, kind string, keys []string) ([]models.AICacheEntry, error) {
+	var entries []models.AICacheEntry
+	err := c.db.DB.NewSelect().
+		Model(&entries).
+		Where("key IN (?) AND kind = ? AND expires_at > ?", bun.In(keys), kind, time.Now()).
+		Scan(ctx)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	hit := make([]string, len(entries))
+	for i, entry := range entries {
+		hit[i] = entry.Key
+	}
+	go func() {
+		_, err := c.db.DB.NewUpdate().
+			Model((*models.AICacheEntry)(nil)).
+			Set("hits = hits + 1").
+			Set("last_hit_at = ?", time.Now()).
+			Where("key IN (?)", bun.In(hit)).
+			Exec(context.Background())
+		if err != nil {
+			log.Printf("Failed to record AI cache hits: %v", err)
+		}
+	}()
+
+	return entries, nil
+}
+
+func (c *Cache) store(ctx context.Context, feature string, entries []models.AICacheEntry) {
+	_, err := c.db.DB.NewInsert().
+		Model(&entries).
+		On("CONFLICT (key) DO UPDATE").
+		Set("content = EXCLUDED.content").
+		Set("embedding = EXCLUDED.embedding").
+		Set("feature = EXCLUDED.feature").
+		Set("expires_at = EXCLUDED.expires_at").
+		Exec(ctx)
+	if err != nil {
+		log.Printf("Failed to store AI cache entries: %v", err)
+		c.record(feature, func(s *FeatureStats) { s.Errors++ })
+		return
+	}
+
+	c.maybePrune()
+}
+
+// maybePrune deletes expired entries in the background at most once per
+// pruneInterval
+func (c *Cache) maybePrune() {
+	last := time.Unix(0, c.lastPrune.Load())
+	if time.Since(last) < pruneInterval || !c.pruning.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer c.pruning.Store(false)
+		c.lastPrune.Store(time.Now().UnixNano())
+
+		result, err := c.db.DB.NewDelete().
+			Model((*models.AICacheEntry)(nil)).
+			Where("expires_at <= ?", time.Now()).
+			Exec(context.Background())
+		if err != nil {
+			log.Printf("Failed to prune AI cache: %v", err)
+			return
+		}
+		if rows, _ := result.RowsAffected(); rows > 0 {
+			log.Printf("Pruned %d expired AI cache entries", rows)
+		}
+	}()
+}
+
+func (c *Cache) record(feature string, update func(s *FeatureStats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats, ok := c.stats[feature]
+	if !ok {
+		stats = &FeatureStats{Feature: feature}
+		c.stats[feature] = stats
+	}
+	update(stats)
+}
+
+// Stats reports per-feature hit and miss counts of this process and the
+// stored entries per kind
+func (c *Cache) Stats(ctx context.Context) (*Stats, error) {
+	if c == nil {
+		return &Stats{}, nil
+	}
+
+	stats := &Stats{Enabled: c.cfg.Enabled && c.db != nil}
+
+	c.mu.Lock()
+	for _, feature := range []string{FeatureEmbedding, FeatureQuery, FeatureSummary, FeatureQuestion, FeatureChat, FeatureSuggest} {
+		if _, ok := c.stats[feature]; !ok {
+			c.stats[feature] = &FeatureStats{Feature: feature}
+		}
+	}
+	for feature, s := range c.stats {
+		featureStats := *s
+		featureStats.Enabled = c.Enabled(feature)
+		if lookups := s.MemoryHits + s.DBHits + s.Misses; lookups > 0 {
+			featureStats.HitRate = float64(s.MemoryHits+s.DBHits) / float64(lookups)
+		}
+		stats.Features = append(stats.Features, featureStats)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(stats.Features, func(i, j int) bool {
+		return stats.Features[i].Feature < stats.Features[j].Feature
+	})
+
+	if c.db == nil {
+		return stats, nil
+	}
+
+	err := c.db.DB.NewSelect().
+		Model((*models.AICacheEntry)(nil)).
+		ColumnExpr("kind").
+		ColumnExpr("COUNT(*) AS entries").
+		ColumnExpr("COUNT(*) FILTER (WHERE expires_at <= ?) AS expired", time.Now()).
+		ColumnExpr("COALESCE(SUM(hits), 0) AS hits").
+		Group("kind").
+		Order("kind").
+		Scan(ctx, &stats.Entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
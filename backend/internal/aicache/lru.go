@@ -0,0 +1,81 @@
+package aicache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru is a fixed-size in-process cache in front of Postgres. A nil lru
+// stores nothing.
+type lru struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]*list.Element
+	order *list.List // Most recently used first
+}
+
+type lruEntry struct {
+	key       string
+	value     any
+	expiresAt time.Time
+}
+
+func newLRU(size int) *lru {
+	if size <= 0 {
+		return nil
+	}
+	return &lru{
+		size:  size,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+func (l *lru) get(key string) (any, bool) {
+	if l == nil {
+		return nil, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.order.Remove(element)
+		delete(l.items, key)
+		return nil, false
+	}
+
+	l.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (l *lru) add(key string, value any, expiresAt time.Time) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.items[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		l.order.MoveToFront(element)
+		return
+	}
+
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
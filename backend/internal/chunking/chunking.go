@@ -0,0 +1,126 @@
+// Package chunking splits transcripts into the windows that are embedded
+// for retrieval. Every strategy carries a version in its name, which is
+// stored with each chunk so a video can be re-chunked and the results
+// compared side by side.
+package chunking
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+const (
+	// StrategySegment embeds every caption segment on its own
+	StrategySegment = "segment-v1"
+	// StrategyWindow groups segments into overlapping token windows that
+	// prefer to end on sentence boundaries
+	StrategyWindow = "window-v1"
+
+	DefaultStrategy = StrategyWindow
+)
+
+const (
+	defaultTargetTokens  = 256
+	defaultOverlapTokens = 48
+	defaultMaxDuration   = 2 * time.Minute
+)
+
+// Chunk is a contiguous span of a transcript with exact start and end times
+type Chunk struct {
+	Index      int     `json:"chunk_index"`
+	StartTime  float64 `json:"start_time"`
+	EndTime    float64 `json:"end_time"`
+	Text       string  `json:"text"`
+	TokenCount int     `json:"token_count"`
+}
+
+// Options tunes window based strategies. Zero values use the defaults.
+type Options struct {
+	TargetTokens  int
+	OverlapTokens int
+	MaxDuration   time.Duration
+}
+
+// Strategy turns transcript segments into chunks. Name includes the version,
+// so any change to how chunks are cut must be registered under a new name.
+type Strategy interface {
+	Name() string
+	Chunk(segments []models.TranscriptSegment) []Chunk
+}
+
+var strategies = map[string]func(Options) Strategy{
+	StrategySegment: func(Options) Strategy { return segmentStrategy{} },
+	StrategyWindow:  func(opts Options) Strategy { return newWindowStrategy(opts) },
+}
+
+// New returns the named strategy configured with opts. An empty name
+// selects DefaultStrategy.
+func New(name string, opts Options) (Strategy, error) {
+	if name == "" {
+		name = DefaultStrategy
+	}
+	factory, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown chunking strategy %q", name)
+	}
+	return factory(opts), nil
+}
+
+// Strategies lists the registered strategy names
+func Strategies() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EstimateTokens approximates the embedding model's token count for text,
+// using roughly four characters per token with a floor of one per word
+func EstimateTokens(text string) int {
+	words := len(strings.Fields(text))
+	chars := (utf8.RuneCountInString(text) + 3) / 4
+	return max(words, chars)
+}
+
+// SegmentEnd returns when segment i stops: its own duration if known,
+// otherwise the start of the next segment
+func SegmentEnd(segments []models.TranscriptSegment, i int) float64 {
+	if segments[i].Duration > 0 {
+		return segments[i].Start + segments[i].Duration
+	}
+	if i+1 < len(segments) {
+		return max(segments[i+1].Start, segments[i].Start)
+	}
+	return segments[i].Start
+}
+
+type segmentStrategy struct{}
+
+func (segmentStrategy) Name() string {
+	return StrategySegment
+}
+
+func (segmentStrategy) Chunk(segments []models.TranscriptSegment) []Chunk {
+	chunks := make([]Chunk, 0, len(segments))
+	for i, segment := range segments {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+		chunks = append(chunks, Chunk{
+			Index:      len(chunks),
+			StartTime:  segment.Start,
+			EndTime:    SegmentEnd(segments, i),
+			Text:       text,
+			TokenCount: EstimateTokens(text),
+		})
+	}
+	return chunks
+}
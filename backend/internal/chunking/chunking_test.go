@@ -0,0 +1,104 @@
+package chunking
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"", DefaultStrategy, false},
+		{StrategySegment, StrategySegment, false},
+		{StrategyWindow, StrategyWindow, false},
+		{"window-v0", "", true},
+	}
+	for _, tt := range tests {
+		strategy, err := New(tt.name, Options{})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("New(%q) succeeded, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("New(%q) error: %v", tt.name, err)
+		}
+		if got := strategy.Name(); got != tt.want {
+			t.Errorf("New(%q).Name() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	want := []string{StrategySegment, StrategyWindow}
+	if got := Strategies(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Strategies() = %v, want %v", got, want)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 2},
+		{"a b c d e", 5},
+		{"transformers", 3},
+		{"übergrößenträger", 4},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentEnd(t *testing.T) {
+	segments := []models.TranscriptSegment{
+		{Start: 0, Duration: 2},
+		{Start: 3},
+		{Start: 5},
+	}
+	tests := []struct {
+		i    int
+		want float64
+	}{
+		{0, 2}, // own duration
+		{1, 5}, // start of the next segment
+		{2, 5}, // last segment without a duration
+	}
+	for _, tt := range tests {
+		if got := SegmentEnd(segments, tt.i); got != tt.want {
+			t.Errorf("SegmentEnd(%d) = %v, want %v", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentStrategy(t *testing.T) {
+	segments := []models.TranscriptSegment{
+		{Start: 0, Duration: 2, Text: " first "},
+		{Start: 2, Duration: 1, Text: "  "},
+		{Start: 3, Text: "second"},
+		{Start: 6, Duration: 2, Text: "third"},
+	}
+	want := []Chunk{
+		{Index: 0, StartTime: 0, EndTime: 2, Text: "first", TokenCount: 2},
+		{Index: 1, StartTime: 3, EndTime: 6, Text: "second", TokenCount: 2},
+		{Index: 2, StartTime: 6, EndTime: 8, Text: "third", TokenCount: 2},
+	}
+
+	strategy, err := New(StrategySegment, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strategy.Chunk(segments); !reflect.DeepEqual(got, want) {
+		t.Errorf("Chunk() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,198 @@
+package chunking
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// sentenceEndPattern matches text ending a sentence, allowing trailing quotes or brackets
+var sentenceEndPattern = regexp.MustCompile(`[.!?…]["'”’)\]]*$`)
+
+// windowStrategy packs consecutive segments into windows of about
+// TargetTokens that never span more than MaxDuration. A window that has to
+// be cut early ends at the last sentence boundary past half its target, and
+// the next window repeats up to OverlapTokens of the previous one.
+type windowStrategy struct {
+	opts Options
+}
+
+// unit is a piece of a segment small enough to place in a window
+type unit struct {
+	start       float64
+	end         float64
+	text        string
+	tokens      int
+	sentenceEnd bool
+}
+
+func newWindowStrategy(opts Options) windowStrategy {
+	if opts.TargetTokens <= 0 {
+		opts.TargetTokens = defaultTargetTokens
+	}
+	if opts.OverlapTokens < 0 {
+		opts.OverlapTokens = 0
+	}
+	if opts.OverlapTokens >= opts.TargetTokens {
+		opts.OverlapTokens = opts.TargetTokens / 4
+	}
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = defaultMaxDuration
+	}
+	return windowStrategy{opts: opts}
+}
+
+func (windowStrategy) Name() string {
+	return StrategyWindow
+}
+
+func (w windowStrategy) Chunk(segments []models.TranscriptSegment) []Chunk {
+	units := w.units(segments)
+	maxDuration := w.opts.MaxDuration.Seconds()
+
+	var chunks []Chunk
+	for start := 0; start < len(units); {
+		tokens := 0
+		cut := start
+		boundary := -1
+		for i := start; i < len(units); i++ {
+			if i > start && (tokens+units[i].tokens > w.opts.TargetTokens || units[i].end-units[start].start > maxDuration) {
+				break
+			}
+			tokens += units[i].tokens
+			cut = i
+			if units[i].sentenceEnd && tokens >= w.opts.TargetTokens/2 {
+				boundary = i
+			}
+		}
+
+		last := cut == len(units)-1
+		if !last && boundary >= start {
+			cut = boundary
+		}
+
+		chunks = append(chunks, newChunk(len(chunks), units[start:cut+1]))
+		if last {
+			break
+		}
+
+		start = w.overlapStart(units, start, cut)
+	}
+
+	return chunks
+}
+
+// overlapStart picks where the window after units[start:cut+1] begins,
+// stepping back over at most OverlapTokens and preferring a sentence start.
+// The overlap always leaves room for the first new unit.
+func (w windowStrategy) overlapStart(units []unit, start, cut int) int {
+	next := cut + 1
+	budget := min(w.opts.OverlapTokens, w.opts.TargetTokens-units[next].tokens)
+	overlap := 0
+	for next-1 > start && overlap+units[next-1].tokens <= budget {
+		next--
+		overlap += units[next].tokens
+	}
+
+	for i := next; i <= cut; i++ {
+		if units[i-1].sentenceEnd {
+			return i
+		}
+	}
+	return next
+}
+
+// units drops empty segments and splits any segment longer than the target
+// into sentences, or word runs when a sentence alone is too long, spreading
+// the segment's time across the pieces by length
+func (w windowStrategy) units(segments []models.TranscriptSegment) []unit {
+	var units []unit
+	for i, segment := range segments {
+		text := strings.Join(strings.Fields(segment.Text), " ")
+		if text == "" {
+			continue
+		}
+
+		end := SegmentEnd(segments, i)
+		pieces := []string{text}
+		if EstimateTokens(text) > w.opts.TargetTokens {
+			pieces = splitText(text, w.opts.TargetTokens)
+		}
+
+		total := utf8.RuneCountInString(text)
+		offset := 0
+		for _, piece := range pieces {
+			// Pieces are separated by one space, which counts towards the
+			// piece before it so the last piece ends with the segment
+			next := min(offset+utf8.RuneCountInString(piece)+1, total)
+			units = append(units, unit{
+				start:       segment.Start + (end-segment.Start)*float64(offset)/float64(total),
+				end:         segment.Start + (end-segment.Start)*float64(next)/float64(total),
+				text:        piece,
+				tokens:      EstimateTokens(piece),
+				sentenceEnd: sentenceEndPattern.MatchString(piece),
+			})
+			offset = next
+		}
+	}
+	return units
+}
+
+// splitText breaks text into sentences, then splits sentences that exceed
+// maxTokens into runs of words
+func splitText(text string, maxTokens int) []string {
+	var pieces []string
+	for _, sentence := range splitSentences(text) {
+		if EstimateTokens(sentence) <= maxTokens {
+			pieces = append(pieces, sentence)
+			continue
+		}
+
+		var run []string
+		for _, word := range strings.Fields(sentence) {
+			if len(run) > 0 && EstimateTokens(strings.Join(append(run, word), " ")) > maxTokens {
+				pieces = append(pieces, strings.Join(run, " "))
+				run = run[:0]
+			}
+			run = append(run, word)
+		}
+		if len(run) > 0 {
+			pieces = append(pieces, strings.Join(run, " "))
+		}
+	}
+	return pieces
+}
+
+func splitSentences(text string) []string {
+	var sentences []string
+	var current []string
+	for _, word := range strings.Fields(text) {
+		current = append(current, word)
+		if sentenceEndPattern.MatchString(word) {
+			sentences = append(sentences, strings.Join(current, " "))
+			current = current[:0]
+		}
+	}
+	if len(current) > 0 {
+		sentences = append(sentences, strings.Join(current, " "))
+	}
+	return sentences
+}
+
+func newChunk(index int, units []unit) Chunk {
+	texts := make([]string, len(units))
+	for i, u := range units {
+		texts[i] = u.text
+	}
+	text := strings.Join(texts, " ")
+
+	return Chunk{
+		Index:      index,
+		StartTime:  units[0].start,
+		EndTime:    max(units[len(units)-1].end, units[0].start),
+		Text:       text,
+		TokenCount: EstimateTokens(text),
+	}
+}
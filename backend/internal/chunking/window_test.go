@@ -0,0 +1,134 @@
+package chunking
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// words returns n segments of one word each, a second apart
+func words(n int) []models.TranscriptSegment {
+	segments := make([]models.TranscriptSegment, n)
+	for i := range segments {
+		segments[i] = models.TranscriptSegment{Start: float64(i), Duration: 1, Text: fmt.Sprintf("w%d", i)}
+	}
+	return segments
+}
+
+func TestWindowSingleChunk(t *testing.T) {
+	segments := []models.TranscriptSegment{
+		{Start: 1, Duration: 2, Text: "Hello  there."},
+		{Start: 3, Duration: 2, Text: ""},
+		{Start: 5, Duration: 2, Text: "General Kenobi."},
+	}
+	want := []Chunk{{Index: 0, StartTime: 1, EndTime: 7, Text: "Hello there. General Kenobi.", TokenCount: 7}}
+
+	if got := newWindowStrategy(Options{}).Chunk(segments); !reflect.DeepEqual(got, want) {
+		t.Errorf("Chunk() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWindowEmpty(t *testing.T) {
+	if got := newWindowStrategy(Options{}).Chunk(nil); len(got) != 0 {
+		t.Errorf("Chunk(nil) = %+v, want no chunks", got)
+	}
+}
+
+func TestWindowTargetAndOverlap(t *testing.T) {
+	w := newWindowStrategy(Options{TargetTokens: 8, OverlapTokens: 2, MaxDuration: time.Hour})
+	chunks := w.Chunk(words(20))
+
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			t.Errorf("chunk %d has index %d", i, chunk.Index)
+		}
+		if chunk.TokenCount > 8 {
+			t.Errorf("chunk %d has %d tokens, want at most 8", i, chunk.TokenCount)
+		}
+		if i == 0 {
+			continue
+		}
+		// Every window after the first repeats the last two words of the
+		// previous one
+		prev := strings.Fields(chunks[i-1].Text)
+		if !strings.HasPrefix(chunk.Text, strings.Join(prev[len(prev)-2:], " ")+" ") {
+			t.Errorf("chunk %d = %q doesn't overlap the end of %q", i, chunk.Text, chunks[i-1].Text)
+		}
+		if chunk.StartTime >= chunks[i-1].EndTime {
+			t.Errorf("chunk %d starts at %v, after the previous ends at %v", i, chunk.StartTime, chunks[i-1].EndTime)
+		}
+	}
+
+	last := chunks[len(chunks)-1]
+	if !strings.HasSuffix(last.Text, "w19") || last.EndTime != 20 {
+		t.Errorf("last chunk = %+v, want it to end with w19 at 20s", last)
+	}
+}
+
+func TestWindowMaxDuration(t *testing.T) {
+	w := newWindowStrategy(Options{TargetTokens: 1000, OverlapTokens: 0, MaxDuration: 5 * time.Second})
+	chunks := w.Chunk(words(12))
+
+	want := []string{"w0 w1 w2 w3 w4", "w5 w6 w7 w8 w9", "w10 w11"}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(want), chunks)
+	}
+	for i, chunk := range chunks {
+		if chunk.Text != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunk.Text, want[i])
+		}
+		if chunk.EndTime-chunk.StartTime > 5 {
+			t.Errorf("chunk %d spans %vs, want at most 5s", i, chunk.EndTime-chunk.StartTime)
+		}
+	}
+}
+
+func TestWindowEndsOnSentence(t *testing.T) {
+	segments := []models.TranscriptSegment{
+		{Start: 0, Duration: 1, Text: "one two three."},
+		{Start: 1, Duration: 1, Text: "four five"},
+		{Start: 2, Duration: 1, Text: "six seven"},
+	}
+	w := newWindowStrategy(Options{TargetTokens: 6, OverlapTokens: 0, MaxDuration: time.Hour})
+	chunks := w.Chunk(segments)
+
+	if len(chunks) != 2 || chunks[0].Text != "one two three." {
+		t.Fatalf("Chunk() = %+v, want the first window to end after %q", chunks, "one two three.")
+	}
+	if chunks[1].Text != "four five six seven" {
+		t.Errorf("second chunk = %q, want %q", chunks[1].Text, "four five six seven")
+	}
+}
+
+func TestWindowSplitsLongSegment(t *testing.T) {
+	text := "This is the first sentence. This is the second sentence. " + strings.Repeat("word ", 20)
+	segments := []models.TranscriptSegment{{Start: 10, Duration: 30, Text: text}}
+
+	w := newWindowStrategy(Options{TargetTokens: 8, OverlapTokens: 0, MaxDuration: time.Hour})
+	units := w.units(segments)
+
+	if len(units) < 4 {
+		t.Fatalf("got %d units, want the segment split into sentences and word runs", len(units))
+	}
+	if units[0].text != "This is the first sentence." || !units[0].sentenceEnd {
+		t.Errorf("first unit = %+v, want the first sentence", units[0])
+	}
+	if units[0].start != 10 || units[len(units)-1].end != 40 {
+		t.Errorf("units span %v to %v, want 10 to 40", units[0].start, units[len(units)-1].end)
+	}
+	for i, u := range units {
+		if u.tokens > 8 {
+			t.Errorf("unit %d = %q has %d tokens, want at most 8", i, u.text, u.tokens)
+		}
+		if i > 0 && u.start != units[i-1].end {
+			t.Errorf("unit %d starts at %v, want %v", i, u.start, units[i-1].end)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package clock parses the clock times used by transcripts and notes
+package clock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses "hh:mm:ss,mmm", "mm:ss.mmm" and similar into seconds.
+// Every part after the first must be below 60.
+func Parse(value string) (float64, error) {
+	value = strings.ReplaceAll(value, ",", ".")
+	parts := strings.Split(value, ":")
+
+	var seconds float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || (i > 0 && (n < 0 || n >= 60)) {
+			return 0, fmt.Errorf("invalid timestamp %q", value)
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds, nil
+}
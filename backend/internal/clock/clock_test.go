@@ -0,0 +1,36 @@
+package clock
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "00:01:02,500", want: 62.5},
+		{value: "01:02.5", want: 62.5},
+		{value: "1:02:03", want: 3723},
+		{value: "0:07", want: 7},
+		{value: "42", want: 42},
+		{value: "", wantErr: true},
+		{value: "1:xx", wantErr: true},
+		{value: "1::02", wantErr: true},
+		{value: "00:01,5,0", wantErr: true},
+		{value: "01:02 ", wantErr: true},
+		{value: "1:75", wantErr: true},
+		{value: "1:60:00", wantErr: true},
+		{value: "1:-5", wantErr: true},
+		{value: "90:59.9", want: 5459.9},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
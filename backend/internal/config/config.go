@@ -20,6 +20,11 @@ type Config struct {
 	API        APIConfig
 	Monitoring MonitoringConfig
 	OpenAI     OpenAIConfig
+	AICache    AICacheConfig
+	Prompts    PromptConfig
+	Transcript TranscriptConfig
+	Jobs       JobsConfig
+	Storage    StorageConfig
 	Email      EmailConfig
 }
 
@@ -35,9 +40,69 @@ type EmailConfig struct {
 }
 
 type OpenAIConfig struct {
-	APIKey         string
-	EmbeddingModel string
+	Provider            string // openai, azure, compatible or fake
+	APIKey              string
+	BaseURL             string // Azure resource endpoint or OpenAI-compatible server URL
+	APIVersion          string // Azure OpenAI api-version
+	EmbeddingModel      string // Deployment name when using Azure
+	ChatModel           string // Deployment name when using Azure
+	EmbeddingDimensions int
+	Timeout             time.Duration
+	// ChatContextTokens is the context window of ChatModel. Transcripts that
+	// don't fit are summarized section by section.
+	ChatContextTokens int
+	// Embedding requests are batched and paced to stay under the provider's limits
+	EmbeddingBatchSize         int // Inputs per request
+	EmbeddingBatchTokens       int // Estimated tokens per request
+	EmbeddingRequestsPerMinute int // 0 disables the limit
+	EmbeddingTokensPerMinute   int // 0 disables the limit
+	MaxRetries                 int // Retries after 429 and 5xx responses
+	// ReembedModels are the other embedding models notes may be re-embedded
+	// with ahead of switching EmbeddingModel
+	ReembedModels []string
+	// ModelPrices override the USD per million token prices used to
+	// estimate AI spend, as model=input/output, e.g. gpt-4o=2.5/10
+	ModelPrices []string
 }
+
+type AICacheConfig struct {
+	Enabled          bool
+	EmbeddingTTL     time.Duration
+	CompletionTTL    time.Duration
+	MemoryEntries    int      // In-process LRU in front of Postgres; 0 disables it
+	DisabledFeatures []string // Features that always call the API, e.g. chat,question
+}
+
+type PromptConfig struct {
+	Assignment      string        // user keeps a user on one prompt version, request draws one per request
+	RefreshInterval time.Duration // How often prompt_templates is reloaded; 0 loads it once
+}
+
+type TranscriptConfig struct {
+	HTTPSourceURL      string // Optional external transcript service, tried after YouTube captions
+	HTTPSourceTimeout  time.Duration
+	ChunkStrategy      string // Versioned chunking strategy used for embeddings and search, e.g. window-v1
+	ChunkTargetTokens  int
+	ChunkOverlapTokens int
+	ChunkMaxDuration   time.Duration
+}
+
+type JobsConfig struct {
+	Workers            int // Background job workers per process; 0 disables processing
+	PollInterval       time.Duration
+	Timeout            time.Duration // Maximum run time of a single job
+	LockTimeout        time.Duration // Running jobs whose lock is older than this are reclaimed
+	MaxAttempts        int
+	RetryBackoff       time.Duration // Delay before the first retry, doubled per attempt
+	TagCleanupInterval time.Duration // How often unused tags are removed; 0 disables the cleanup
+}
+
+type StorageConfig struct {
+	Driver             string // local
+	LocalPath          string // Directory blobs are stored in by the local driver
+	MaxAttachmentBytes int64  // Largest image that can be attached to a note
+}
+
 type GoogleOAuthConfig struct {
 	ClientID     string
 	ClientSecret string
@@ -81,6 +146,9 @@ type AuthConfig struct {
 	CookieDomain        string
 	CookieSecure        bool
 	CookieHTTPOnly      bool
+	// OperatorUserIDs may run maintenance spanning every user's data, such
+	// as re-embedding the whole corpus
+	OperatorUserIDs []string
 }
 
 type APIConfig struct {
@@ -98,7 +166,7 @@ func Load() *Config {
 		log.Warn().Err(err).Msg("Error loading .env file, using environment variables")
 	}
 
-	return &Config{
+	cfg := &Config{
 		Google: GoogleOAuthConfig{
 			ClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 			ClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
@@ -137,6 +205,7 @@ func Load() *Config {
 			CookieDomain:        getEnv("COOKIE_DOMAIN", ""),
 			CookieSecure:        getBoolEnv("COOKIE_SECURE", false),
 			CookieHTTPOnly:      getBoolEnv("COOKIE_HTTP_ONLY", true),
+			OperatorUserIDs:     getListEnv("OPERATOR_USER_IDS", nil),
 		},
 		API: APIConfig{
 			Timeout:   getDurationEnv("API_TIMEOUT", 30*time.Second),
@@ -147,8 +216,56 @@ func Load() *Config {
 			TracingEnabled: getBoolEnv("TRACING_ENABLED", false),
 		},
 		OpenAI: OpenAIConfig{
-			APIKey:         getEnv("OPENAI_API_KEY", ""),
-			EmbeddingModel: getEnv("OPENAI_EMBEDDING_MODEL", "text-embedding-3-small"),
+			Provider:            getEnv("OPENAI_PROVIDER", "openai"),
+			APIKey:              getEnv("OPENAI_API_KEY", ""),
+			BaseURL:             getEnv("OPENAI_BASE_URL", ""),
+			APIVersion:          getEnv("OPENAI_API_VERSION", "2024-02-01"),
+			EmbeddingModel:      getEnv("OPENAI_EMBEDDING_MODEL", "text-embedding-3-small"),
+			ChatModel:           getEnv("OPENAI_CHAT_MODEL", "gpt-3.5-turbo"),
+			EmbeddingDimensions: getIntEnv("OPENAI_EMBEDDING_DIMENSIONS", 1536),
+			ChatContextTokens:   getIntEnv("OPENAI_CHAT_CONTEXT_TOKENS", 16385),
+			Timeout:             getDurationEnv("OPENAI_TIMEOUT", 60*time.Second),
+
+			EmbeddingBatchSize:         getIntEnv("OPENAI_EMBEDDING_BATCH_SIZE", 256),
+			EmbeddingBatchTokens:       getIntEnv("OPENAI_EMBEDDING_BATCH_TOKENS", 100000),
+			EmbeddingRequestsPerMinute: getIntEnv("OPENAI_EMBEDDING_RPM", 3000),
+			EmbeddingTokensPerMinute:   getIntEnv("OPENAI_EMBEDDING_TPM", 1000000),
+			MaxRetries:                 getIntEnv("OPENAI_MAX_RETRIES", 5),
+			ReembedModels:              getListEnv("OPENAI_REEMBED_MODELS", nil),
+			ModelPrices:                getListEnv("OPENAI_MODEL_PRICES", nil),
+		},
+		AICache: AICacheConfig{
+			Enabled:          getBoolEnv("AI_CACHE_ENABLED", true),
+			EmbeddingTTL:     getDurationEnv("AI_CACHE_EMBEDDING_TTL", 30*24*time.Hour),
+			CompletionTTL:    getDurationEnv("AI_CACHE_COMPLETION_TTL", 7*24*time.Hour),
+			MemoryEntries:    getIntEnv("AI_CACHE_MEMORY_ENTRIES", 2000),
+			DisabledFeatures: getListEnv("AI_CACHE_DISABLED_FEATURES", nil),
+		},
+		Prompts: PromptConfig{
+			Assignment:      getEnv("PROMPT_ASSIGNMENT", "user"),
+			RefreshInterval: getDurationEnv("PROMPT_REFRESH_INTERVAL", time.Minute),
+		},
+		Transcript: TranscriptConfig{
+			HTTPSourceURL:      getEnv("TRANSCRIPT_HTTP_SOURCE_URL", ""),
+			HTTPSourceTimeout:  getDurationEnv("TRANSCRIPT_HTTP_SOURCE_TIMEOUT", 30*time.Second),
+			ChunkStrategy:      getEnv("TRANSCRIPT_CHUNK_STRATEGY", "window-v1"),
+			ChunkTargetTokens:  getIntEnv("TRANSCRIPT_CHUNK_TARGET_TOKENS", 256),
+			ChunkOverlapTokens: getIntEnv("TRANSCRIPT_CHUNK_OVERLAP_TOKENS", 48),
+			ChunkMaxDuration:   getDurationEnv("TRANSCRIPT_CHUNK_MAX_DURATION", 2*time.Minute),
+		},
+		Jobs: JobsConfig{
+			Workers:            getIntEnv("JOBS_WORKERS", 2),
+			PollInterval:       getDurationEnv("JOBS_POLL_INTERVAL", 2*time.Second),
+			Timeout:            getDurationEnv("JOBS_TIMEOUT", 10*time.Minute),
+			LockTimeout:        getDurationEnv("JOBS_LOCK_TIMEOUT", 15*time.Minute),
+			MaxAttempts:        getIntEnv("JOBS_MAX_ATTEMPTS", 5),
+			RetryBackoff:       getDurationEnv("JOBS_RETRY_BACKOFF", 30*time.Second),
+			TagCleanupInterval: getDurationEnv("JOBS_TAG_CLEANUP_INTERVAL", 24*time.Hour),
+		},
+		Storage: StorageConfig{
+			Driver:             getEnv("STORAGE_DRIVER", "local"),
+			LocalPath:          getEnv("STORAGE_LOCAL_PATH", "./data/blobs"),
+			MaxAttachmentBytes: int64(getIntEnv("ATTACHMENT_MAX_BYTES", 2<<20)),
 		},
 		Email: EmailConfig{
 			SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -161,6 +278,16 @@ func Load() *Config {
 			UseSSL:       getBoolEnv("SMTP_USE_SSL", false),
 		},
 	}
+
+	// A job still running when its lock expires is claimed by another worker
+	if cfg.Jobs.Timeout >= cfg.Jobs.LockTimeout {
+		log.Fatal().
+			Dur("timeout", cfg.Jobs.Timeout).
+			Dur("lock_timeout", cfg.Jobs.LockTimeout).
+			Msg("JOBS_TIMEOUT must be shorter than JOBS_LOCK_TIMEOUT")
+	}
+
+	return cfg
 }
 
 func getEnv(key, defaultValue string) string {
@@ -199,6 +326,22 @@ func getIntEnv(key string, defaultValue int) int {
 	return parsedValue
 }
 
+// getListEnv splits a comma-separated value, dropping empty items
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	value := os.Getenv(key)
 	if value == "" {
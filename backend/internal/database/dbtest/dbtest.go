@@ -0,0 +1,55 @@
+// Package dbtest connects tests to a migrated Postgres database
+package dbtest
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+var (
+	migrateOnce sync.Once
+	migrateErr  error
+)
+
+// Open connects to the Postgres database at TEST_DATABASE_URL, which needs
+// the pgvector extension, and migrates it. Tests using it are skipped when
+// the variable is not set.
+func Open(t *testing.T) *database.Database {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn)))
+	t.Cleanup(func() { sqldb.Close() })
+
+	migrateOnce.Do(func() {
+		if migrateErr = goose.SetDialect("postgres"); migrateErr == nil {
+			migrateErr = goose.Up(sqldb, migrationsDir())
+		}
+	})
+	if migrateErr != nil {
+		t.Fatalf("failed to migrate test database: %v", migrateErr)
+	}
+
+	return &database.Database{DB: bun.NewDB(sqldb, pgdialect.New())}
+}
+
+// migrationsDir is the backend's migrations directory, wherever the test
+// runs from
+func migrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "..", "migrations")
+}
@@ -156,7 +156,7 @@ func (d *DashboardHandlers) getMostUsedTags(ctx context.Context, userID uuid.UUI
 		ColumnExpr("tag.name, COUNT(*) as count").
 		Join("JOIN timestamp_tags tt ON tag.id = tt.tag_id").
 		Join("JOIN timestamps ts ON tt.timestamp_id = ts.id").
-		Where("ts.user_id = ? AND ts.deleted_at IS NULL AND tag.deleted_at IS NULL", userID).
+		Where("ts.user_id = ? AND ts.deleted_at IS NULL", userID).
 		Group("tag.id", "tag.name").
 		Order("count DESC").
 		Limit(10).
@@ -246,7 +246,7 @@ func (d *DashboardHandlers) getRecentNotes(ctx context.Context, userID uuid.UUID
 		ColumnExpr("STRING_AGG(DISTINCT tags.name, ',') AS tag_names").
 		Join("INNER JOIN videos v ON t.video_id = v.video_id AND t.user_id = v.user_id AND v.deleted_at IS NULL").
 		Join("LEFT JOIN timestamp_tags tt ON t.id = tt.timestamp_id").
-		Join("LEFT JOIN tags ON tt.tag_id = tags.id AND tags.deleted_at IS NULL").
+		Join("LEFT JOIN tags ON tt.tag_id = tags.id").
 		Where("t.user_id = ? AND t.deleted_at IS NULL", userID).
 		Group("t.id", "t.title", "t.created_at", "v.title").
 		OrderExpr("t.created_at DESC").
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	authhandlers "github.com/shubhamku044/ytclipper/internal/handlers/auth"
+	jobqueue "github.com/shubhamku044/ytclipper/internal/jobs"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// JobHandlers exposes the status of the caller's background jobs
+type JobHandlers struct {
+	queue *jobqueue.Queue
+}
+
+func NewJobHandlers(queue *jobqueue.Queue) *JobHandlers {
+	return &JobHandlers{
+		queue: queue,
+	}
+}
+
+func SetupJobRoutes(router *gin.RouterGroup, handlers *JobHandlers, authMiddleware *authhandlers.AuthMiddleware) {
+	jobRoutes := router.Group("/jobs")
+	jobRoutes.Use(authMiddleware.RequireAuth())
+	{
+		jobRoutes.GET("", handlers.ListJobs)
+		jobRoutes.GET("/:id", handlers.GetJob)
+		jobRoutes.POST("/:id/retry", handlers.RetryJob)
+	}
+}
+
+// ListJobs returns the caller's recent jobs, optionally filtered by ?type= and ?status=
+func (h *JobHandlers) ListJobs(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	filter := jobqueue.ListFilter{
+		Type:   c.Query("type"),
+		Status: models.JobStatus(c.Query("status")),
+		Limit:  50,
+	}
+	if param := c.Query("limit"); param != "" {
+		if parsed, err := strconv.Atoi(param); err == nil && parsed > 0 && parsed <= 200 {
+			filter.Limit = parsed
+		}
+	}
+
+	jobs, err := h.queue.List(c.Request.Context(), userID, filter)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch jobs", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
+
+func (h *JobHandlers) GetJob(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.queue.Get(c.Request.Context(), userID, jobID)
+	if errors.Is(err, jobqueue.ErrJobNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "JOB_NOT_FOUND", "Job not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch job", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"job": job,
+	})
+}
+
+// RetryJob requeues a dead-lettered job
+func (h *JobHandlers) RetryJob(c *gin.Context) {
+	userID, ok := userIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	jobID, ok := parseJobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.queue.Retry(c.Request.Context(), userID, jobID)
+	switch {
+	case errors.Is(err, jobqueue.ErrJobNotFound):
+		middleware.RespondWithError(c, http.StatusNotFound, "JOB_NOT_FOUND", "Job not found", nil)
+		return
+	case errors.Is(err, jobqueue.ErrJobNotRetryable):
+		middleware.RespondWithError(c, http.StatusConflict, "JOB_NOT_RETRYABLE", "Only dead jobs can be retried", nil)
+		return
+	case errors.Is(err, jobqueue.ErrDuplicateJob):
+		middleware.RespondWithError(c, http.StatusConflict, "JOB_ALREADY_QUEUED", "An identical job is already queued", nil)
+		return
+	case err != nil:
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to retry job", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"job":     job,
+		"message": "Job queued for retry",
+	})
+}
+
+func userIDFromContext(c *gin.Context) (uuid.UUID, bool) {
+	userIDStr, exists := authhandlers.GetUserID(c)
+	if !exists {
+		middleware.RespondWithError(c, http.StatusUnauthorized, "NO_USER_ID", "User ID not found", nil)
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID format", gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func parseJobID(c *gin.Context) (uuid.UUID, bool) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_JOB_ID", "Invalid job ID format", gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return jobID, true
+}
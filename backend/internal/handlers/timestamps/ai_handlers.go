@@ -6,18 +6,21 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"sort"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/kkdai/youtube/v2"
 	"github.com/pgvector/pgvector-go"
+	"github.com/shubhamku044/ytclipper/internal/aicache"
 	authhandlers "github.com/shubhamku044/ytclipper/internal/handlers/auth"
+	"github.com/shubhamku044/ytclipper/internal/jobs"
 	"github.com/shubhamku044/ytclipper/internal/middleware"
 	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
 )
 
 func (t *TimestampsHandlers) SearchTimestamps(c *gin.Context) {
@@ -42,8 +45,11 @@ func (t *TimestampsHandlers) SearchTimestamps(c *gin.Context) {
 		})
 		return
 	}
+	if !t.requireTokenBudget(c, userID) {
+		return
+	}
 
-	queryEmbedding, err := t.aiService.GenerateEmbedding(req.Query)
+	queryEmbedding, err := t.aiService.GenerateEmbedding(c.Request.Context(), req.Query)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "EMBEDDING_ERROR", "Failed to generate query embedding", gin.H{
 			"error": err.Error(),
@@ -51,16 +57,12 @@ func (t *TimestampsHandlers) SearchTimestamps(c *gin.Context) {
 		return
 	}
 
-	query := t.db.DB.NewSelect().
-		Model((*models.Timestamp)(nil)).
-		Where("user_id = ? AND deleted_at IS NULL", userID)
-
-	if req.VideoID != "" {
-		query = query.Where("video_id = ?", req.VideoID)
+	limit := req.Limit
+	if limit <= 0 || limit > 50 {
+		limit = 10
 	}
 
-	var timestamps []models.Timestamp
-	err = query.Scan(context.Background(), &timestamps)
+	scoredResults, err := t.searchService.SearchTimestamps(c.Request.Context(), userID, queryEmbedding, req.filter(), limit)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch timestamps", gin.H{
 			"error": err.Error(),
@@ -68,29 +70,6 @@ func (t *TimestampsHandlers) SearchTimestamps(c *gin.Context) {
 		return
 	}
 
-	var scoredResults []ScoredTimestamp
-	for _, ts := range timestamps {
-		if len(ts.Embedding) > 0 {
-			score := CosineSimilarity(queryEmbedding, ts.Embedding)
-			scoredResults = append(scoredResults, ScoredTimestamp{
-				Timestamp: ts,
-				Score:     score,
-			})
-		}
-	}
-
-	sort.Slice(scoredResults, func(i, j int) bool {
-		return scoredResults[i].Score > scoredResults[j].Score
-	})
-
-	limit := req.Limit
-	if limit == 0 || limit > 50 {
-		limit = 10
-	}
-	if len(scoredResults) > limit {
-		scoredResults = scoredResults[:limit]
-	}
-
 	middleware.RespondWithOK(c, gin.H{
 		"results": scoredResults,
 		"query":   req.Query,
@@ -120,8 +99,11 @@ func (t *TimestampsHandlers) SearchTranscriptEmbeddings(c *gin.Context) {
 		})
 		return
 	}
+	if !t.requireTokenBudget(c, userID) {
+		return
+	}
 
-	queryEmbedding, err := t.aiService.GenerateEmbedding(req.Query)
+	queryEmbedding, err := t.aiService.GenerateEmbedding(c.Request.Context(), req.Query)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "EMBEDDING_ERROR", "Failed to generate query embedding", gin.H{
 			"error": err.Error(),
@@ -129,16 +111,12 @@ func (t *TimestampsHandlers) SearchTranscriptEmbeddings(c *gin.Context) {
 		return
 	}
 
-	query := t.db.DB.NewSelect().
-		Model((*models.TranscriptEmbedding)(nil)).
-		Where("user_id = ? AND deleted_at IS NULL", userID)
-
-	if req.VideoID != "" {
-		query = query.Where("video_id = ?", req.VideoID)
+	limit := req.Limit
+	if limit <= 0 || limit > 50 {
+		limit = 10
 	}
 
-	var embeddings []models.TranscriptEmbedding
-	err = query.Scan(context.Background(), &embeddings)
+	scoredResults, err := t.searchService.SearchTranscripts(c.Request.Context(), userID, queryEmbedding, req.filter(), limit)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch transcript embeddings", gin.H{
 			"error": err.Error(),
@@ -146,35 +124,6 @@ func (t *TimestampsHandlers) SearchTranscriptEmbeddings(c *gin.Context) {
 		return
 	}
 
-	type ScoredTranscriptEmbedding struct {
-		Embedding models.TranscriptEmbedding `json:"embedding"`
-		Score     float64                    `json:"score"`
-	}
-
-	var scoredResults []ScoredTranscriptEmbedding
-	for _, emb := range embeddings {
-		embeddingSlice := emb.Embedding.Slice()
-		if len(embeddingSlice) > 0 {
-			score := CosineSimilarity(queryEmbedding, embeddingSlice)
-			scoredResults = append(scoredResults, ScoredTranscriptEmbedding{
-				Embedding: emb,
-				Score:     float64(score),
-			})
-		}
-	}
-
-	sort.Slice(scoredResults, func(i, j int) bool {
-		return scoredResults[i].Score > scoredResults[j].Score
-	})
-
-	limit := req.Limit
-	if limit == 0 || limit > 50 {
-		limit = 10
-	}
-	if len(scoredResults) > limit {
-		scoredResults = scoredResults[:limit]
-	}
-
 	middleware.RespondWithOK(c, gin.H{
 		"results": scoredResults,
 		"query":   req.Query,
@@ -223,8 +172,18 @@ func (t *TimestampsHandlers) GetVideoSummary(c *gin.Context) {
 		aiSummary = ""
 	}
 
+	sections, err := t.getVideoSummarySections(c.Request.Context(), userID, videoID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to fetch summary sections", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	middleware.RespondWithOK(c, gin.H{
 		"summary":      aiSummary,
+		"sections":     sections,
+		"insight_id":   t.latestInsightID(c.Request.Context(), userID, videoID, models.AIInsightTypeSummary),
 		"video_id":     videoID,
 		"cached":       aiSummary != "",
 		"generated_at": video.AISummaryGeneratedAt,
@@ -301,8 +260,18 @@ func (t *TimestampsHandlers) GenerateFullVideoSummary(c *gin.Context) {
 	}
 
 	if !req.Refresh && video.AISummary != "" && video.AISummaryGeneratedAt != nil {
+		sections, err := t.getVideoSummarySections(c.Request.Context(), userID, req.VideoID)
+		if err != nil {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to fetch summary sections", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
 		middleware.RespondWithOK(c, gin.H{
 			"summary":      video.AISummary,
+			"sections":     sections,
+			"insight_id":   t.latestInsightID(c.Request.Context(), userID, req.VideoID, models.AIInsightTypeSummary),
 			"video_id":     req.VideoID,
 			"video_title":  video.Title,
 			"generated_at": video.AISummaryGeneratedAt,
@@ -324,39 +293,34 @@ func (t *TimestampsHandlers) GenerateFullVideoSummary(c *gin.Context) {
 		})
 		return
 	}
-
-	var transcriptEmbedding []models.TranscriptEmbedding
-	err = t.db.DB.NewSelect().
-		Model(&transcriptEmbedding).
-		Where("video_id = ?", req.VideoID).
-		Scan(context.Background())
-	if err != nil {
-		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch transcript embedding", gin.H{
-			"error": err.Error(),
-		})
+	if !t.requireTokenBudget(c, userID) {
 		return
 	}
 
-	var transcript string
-	if len(transcriptEmbedding) == 0 {
-		transcript, err = t.generateYouTubeTranscript(req.VideoID)
-		if err == nil {
-			t.ProcessEmbeddingInBackground(userIDStr, req.VideoID, transcript)
-		}
-	} else {
-		var texts []string
-		for _, embedding := range transcriptEmbedding {
-			if embedding.StartTime != nil && embedding.EndTime != nil {
-				startTime := formatTimestamp(*embedding.StartTime)
-				endTime := formatTimestamp(*embedding.EndTime)
-				texts = append(texts, fmt.Sprintf("%s-%s: %s", startTime, endTime, embedding.Text))
-			} else {
-				texts = append(texts, embedding.Text)
-			}
+	if c.Query("async") == "true" {
+		job, err := t.jobQueue.Enqueue(c.Request.Context(), JobVideoSummary, videoSummaryPayload{
+			VideoID:  req.VideoID,
+			Language: req.Language,
+		}, jobs.EnqueueOptions{
+			UserID:    &userID,
+			DedupeKey: fmt.Sprintf("%s:%s:%s", JobVideoSummary, userID, req.VideoID),
+		})
+		if err != nil {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "JOB_ERROR", "Failed to queue summary generation", gin.H{
+				"error": err.Error(),
+			})
+			return
 		}
-		transcript = strings.Join(texts, "\n")
+
+		middleware.RespondWithOK(c, gin.H{
+			"job":      job,
+			"video_id": req.VideoID,
+			"message":  "Summary generation queued",
+		})
+		return
 	}
 
+	storedTranscript, err := t.transcriptService.GetTranscript(context.Background(), userID, req.VideoID, req.Language, false)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "TRANSCRIPT_ERROR", "Failed to generate transcript", gin.H{
 			"error": err.Error(),
@@ -364,7 +328,15 @@ func (t *TimestampsHandlers) GenerateFullVideoSummary(c *gin.Context) {
 		return
 	}
 
-	summary, err := t.generateFullVideoSummary(&video, transcript)
+	t.ensureTranscriptEmbeddings(userID, storedTranscript)
+
+	if c.Query("stream") == "true" {
+		t.streamFullVideoSummary(c, userID, &video, storedTranscript)
+		return
+	}
+
+	started := time.Now()
+	summary, sections, used, err := t.generateFullVideoSummary(c.Request.Context(), &video, storedTranscript, summaryEvents{})
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "AI_ERROR", "Failed to generate full video summary", gin.H{
 			"error": err.Error(),
@@ -372,113 +344,172 @@ func (t *TimestampsHandlers) GenerateFullVideoSummary(c *gin.Context) {
 		return
 	}
 
-	stream := c.Query("stream") == "true"
-
-	if stream {
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		c.Header("Connection", "keep-alive")
+	now := time.Now().UTC()
+	insightID := t.saveVideoSummary(userID, &video, summary, sections, used, now, started)
 
-		allowedOrigins := []string{
-			"http://localhost:5173",             // Local development
-			"http://localhost:3000",             // Alternative local port
-			"https://ytclipper.com",             // Production domain
-			"https://app.ytclipper.com",         // Production with www
-			"https://app-staging.ytclipper.com", // Staging environment
-		}
+	middleware.RespondWithOK(c, gin.H{
+		"summary":      summary,
+		"sections":     sections,
+		"insight_id":   insightID,
+		"video_id":     req.VideoID,
+		"video_title":  video.Title,
+		"generated_at": now,
+		"cached":       false,
+	})
+}
 
-		origin := c.GetHeader("Origin")
-		for _, allowedOrigin := range allowedOrigins {
-			if origin == allowedOrigin {
-				c.Header("Access-Control-Allow-Origin", origin)
-				break
+// streamFullVideoSummary relays the completion as SSE chunk events while it is
+// generated. Long videos are summarized in sections first, reported as
+// progress and section events. A client disconnect cancels the request
+// context, which aborts the upstream LLM calls; nothing is persisted or
+// counted unless the stream finishes.
+func (t *TimestampsHandlers) streamFullVideoSummary(c *gin.Context, userID uuid.UUID, video *models.Video, transcript *models.VideoTranscript) {
+	ctx := c.Request.Context()
+	started := time.Now()
+	startEventStream(c)
+
+	prompt, sections, used, err := t.prepareVideoSummary(ctx, video, transcript, summaryEvents{
+		Progress: func(level, done, total int) {
+			stage := "sections"
+			if level > 0 {
+				stage = "combining"
 			}
+			writeEvent(c, "progress", gin.H{
+				"stage": stage,
+				"level": level,
+				"done":  done,
+				"total": total,
+			})
+		},
+		Section: func(section *models.VideoSummarySection) {
+			writeEvent(c, "section", section)
+		},
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("Summary stream for video %s cancelled by client", video.VideoID)
+			return
 		}
-
-		c.Header("Access-Control-Allow-Headers", "Cache-Control")
-		c.Header("Access-Control-Allow-Credentials", "true")
-
-		c.Writer.Flush()
-
-		words := strings.Fields(summary)
-
-		for i, word := range words {
-			chunkData := gin.H{
-				"word":  word,
-				"index": i,
-				"total": len(words),
-			}
-
-			jsonData, err := json.Marshal(chunkData)
-			if err != nil {
-				continue
-			}
-
-			eventData := fmt.Sprintf("event: chunk\ndata: %s\n\n", string(jsonData))
-			c.Writer.WriteString(eventData)
-			c.Writer.Flush()
-			time.Sleep(50 * time.Millisecond)
+		if writeErr := writeEvent(c, "error", gin.H{"error": err.Error()}); writeErr != nil {
+			log.Printf("Failed to send summary stream error: %v", writeErr)
 		}
+		return
+	}
+	if len(sections) > 0 {
+		writeEvent(c, "progress", gin.H{"stage": "final"})
+	}
 
-		completeData := gin.H{
-			"summary":      summary,
-			"video_id":     req.VideoID,
-			"video_title":  video.Title,
-			"generated_at": time.Now().UTC(),
-			"cached":       false,
+	index := 0
+	completion, err := t.aiService.StreamTextCompletion(ctx, aicache.FeatureSummary, prompt, func(delta string) error {
+		err := writeEvent(c, "chunk", gin.H{
+			"content": delta,
+			"index":   index,
+		})
+		index++
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("Summary stream for video %s cancelled by client", video.VideoID)
+			return
 		}
-
-		completeJsonData, err := json.Marshal(completeData)
-		if err != nil {
-			log.Printf("Failed to marshal complete data: %v", err)
-		} else {
-			completeEventData := fmt.Sprintf("event: complete\ndata: %s\n\n", string(completeJsonData))
-			c.Writer.WriteString(completeEventData)
-			c.Writer.Flush()
+		if writeErr := writeEvent(c, "error", gin.H{"error": err.Error()}); writeErr != nil {
+			log.Printf("Failed to send summary stream error: %v", writeErr)
 		}
+		return
+	}
 
-		now := time.Now().UTC()
-		_, err = t.db.DB.NewUpdate().
-			Model(&video).
-			Set("ai_summary = ?", summary).
-			Set("ai_summary_generated_at = ?", now).
-			Where("user_id = ? AND video_id = ?", userID, req.VideoID).
-			Exec(context.Background())
+	if strings.TrimSpace(completion.Content) == "" {
+		writeEvent(c, "error", gin.H{"error": "AI returned an empty summary"})
+		return
+	}
 
-		if err != nil {
-			log.Printf("Failed to save AI summary to database: %v", err)
-		}
+	now := time.Now().UTC()
+	insightID := t.saveVideoSummary(userID, video, completion.Content, sections, used, now, started)
 
-		err = t.featureUsageService.IncrementUsage(context.Background(), userID, "ai_summaries")
+	if err := writeEvent(c, "complete", gin.H{
+		"summary":       completion.Content,
+		"sections":      sections,
+		"insight_id":    insightID,
+		"video_id":      video.VideoID,
+		"video_title":   video.Title,
+		"generated_at":  now,
+		"cached":        false,
+		"finish_reason": completion.FinishReason,
+		"usage":         completion.Usage,
+	}); err != nil {
+		log.Printf("Failed to send summary complete event: %v", err)
+	}
+}
+
+// saveVideoSummary stores the summary and replaces the video's section
+// summaries with sections, which is empty for videos summarized in one pass.
+// The summary is also recorded as an insight, whose ID is returned.
+func (t *TimestampsHandlers) saveVideoSummary(userID uuid.UUID, video *models.Video, summary string, sections []models.VideoSummarySection, used prompts.Versions, generatedAt, started time.Time) *uuid.UUID {
+	ctx := context.Background()
+	err := t.db.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewUpdate().
+			Model(video).
+			Set("ai_summary = ?", summary).
+			Set("ai_summary_generated_at = ?", generatedAt).
+			Where("user_id = ? AND video_id = ?", userID, video.VideoID).
+			Exec(ctx)
 		if err != nil {
-			log.Printf("Warning: Failed to increment AI summary usage: %v", err)
+			return err
 		}
-	} else {
-		now := time.Now().UTC()
-		_, err = t.db.DB.NewUpdate().
-			Model(&video).
-			Set("ai_summary = ?", summary).
-			Set("ai_summary_generated_at = ?", now).
-			Where("user_id = ? AND video_id = ?", userID, req.VideoID).
-			Exec(context.Background())
 
-		if err != nil {
-			log.Printf("Failed to save AI summary to database: %v", err)
+		_, err = tx.NewDelete().
+			Model((*models.VideoSummarySection)(nil)).
+			Where("user_id = ? AND video_id = ?", userID, video.VideoID).
+			Exec(ctx)
+		if err != nil || len(sections) == 0 {
+			return err
 		}
 
-		err = t.featureUsageService.IncrementUsage(context.Background(), userID, "ai_summaries")
-		if err != nil {
-			log.Printf("Warning: Failed to increment AI summary usage: %v", err)
+		for i := range sections {
+			sections[i].UserID = userID
+			sections[i].VideoID = video.VideoID
+			sections[i].CreatedAt = generatedAt
 		}
+		_, err = tx.NewInsert().
+			Model(&sections).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to save AI summary to database: %v", err)
+	}
 
-		middleware.RespondWithOK(c, gin.H{
-			"summary":      summary,
-			"video_id":     req.VideoID,
-			"video_title":  video.Title,
-			"generated_at": now,
-			"cached":       false,
-		})
+	err = t.featureUsageService.IncrementUsage(context.Background(), userID, "ai_summaries")
+	if err != nil {
+		log.Printf("Warning: Failed to increment AI summary usage: %v", err)
+	}
+
+	return t.recordInsight(userID, insightRecord{
+		VideoID:  video.VideoID,
+		Type:     models.AIInsightTypeSummary,
+		Title:    "Summary of " + video.Title,
+		Content:  summary,
+		Started:  started,
+		Metadata: gin.H{"sections": len(sections)},
+		Prompt:   prompts.Summary,
+		Prompts:  used,
+	})
+}
+
+// getVideoSummarySections returns the stored section summaries of a video,
+// level by level in video order
+func (t *TimestampsHandlers) getVideoSummarySections(ctx context.Context, userID uuid.UUID, videoID string) ([]models.VideoSummarySection, error) {
+	sections := []models.VideoSummarySection{}
+	err := t.db.DB.NewSelect().
+		Model(&sections).
+		Where("user_id = ? AND video_id = ?", userID, videoID).
+		Order("level ASC", "section_index ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return sections, nil
 }
 
 func (t *TimestampsHandlers) TestStreaming(c *gin.Context) {
@@ -546,12 +577,6 @@ func (t *TimestampsHandlers) AnswerQuestion(c *gin.Context) {
 		return
 	}
 
-	type QuestionRequest struct {
-		Question string `json:"question" binding:"required"`
-		VideoID  string `json:"video_id,omitempty"`
-		Context  int    `json:"context,omitempty"`
-	}
-
 	var req QuestionRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
@@ -559,6 +584,14 @@ func (t *TimestampsHandlers) AnswerQuestion(c *gin.Context) {
 		})
 		return
 	}
+	if req.Library && req.VideoID != "" {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "video_id cannot be combined with library; use video_ids to limit a library question", nil)
+		return
+	}
+	if len(req.PlaylistIDs) > 0 {
+		middleware.RespondWithError(c, http.StatusNotImplemented, "PLAYLIST_FILTER_UNAVAILABLE", "Filtering by playlist is not supported yet; use video_ids or tags to limit a library question", nil)
+		return
+	}
 
 	canAsk, err := t.featureUsageService.CheckUsageLimit(context.Background(), userID, "ai_questions")
 	if err != nil {
@@ -573,8 +606,11 @@ func (t *TimestampsHandlers) AnswerQuestion(c *gin.Context) {
 		})
 		return
 	}
+	if !t.requireTokenBudget(c, userID) {
+		return
+	}
 
-	queryEmbedding, err := t.aiService.GenerateEmbedding(req.Question)
+	queryEmbedding, err := t.aiService.GenerateEmbedding(c.Request.Context(), req.Question)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "EMBEDDING_ERROR", "Failed to generate question embedding", gin.H{
 			"error": err.Error(),
@@ -582,214 +618,33 @@ func (t *TimestampsHandlers) AnswerQuestion(c *gin.Context) {
 		return
 	}
 
-	var contextBuilder strings.Builder
-	contextBuilder.WriteString("# Video Context\n\n")
-
-	if req.VideoID != "" {
-		var transcriptEmbeddings []models.TranscriptEmbedding
-		err = t.db.DB.NewSelect().
-			Model(&transcriptEmbeddings).
-			Where("video_id = ?", req.VideoID).
-			Scan(context.Background())
-
-		if err == nil && len(transcriptEmbeddings) > 0 {
-			log.Printf("Found %d transcript embeddings for video %s", len(transcriptEmbeddings), req.VideoID)
-
-			type ScoredTranscriptChunk struct {
-				Embedding models.TranscriptEmbedding
-				Score     float64
-			}
-
-			var scoredChunks []ScoredTranscriptChunk
-			for _, emb := range transcriptEmbeddings {
-				embeddingSlice := emb.Embedding.Slice()
-				if len(embeddingSlice) > 0 {
-					score := CosineSimilarity(queryEmbedding, embeddingSlice)
-					scoredChunks = append(scoredChunks, ScoredTranscriptChunk{
-						Embedding: emb,
-						Score:     float64(score),
-					})
-				}
-			}
-
-			sort.Slice(scoredChunks, func(i, j int) bool {
-				return scoredChunks[i].Score > scoredChunks[j].Score
-			})
-
-			contextLimit := req.Context
-			if contextLimit == 0 {
-				contextLimit = 10
-			}
-			if len(scoredChunks) > contextLimit {
-				scoredChunks = scoredChunks[:contextLimit]
-			}
-
-			if len(scoredChunks) > 0 {
-				contextBuilder.WriteString("## Relevant Video Transcript Segments\n\n")
-
-				for i, scored := range scoredChunks {
-					emb := scored.Embedding
-					startTime := formatTimestamp(*emb.StartTime)
-					endTime := formatTimestamp(*emb.EndTime)
-
-					contextBuilder.WriteString(fmt.Sprintf("### Segment %d (%s-%s, Relevance: %.3f)\n\n",
-						i+1, startTime, endTime, scored.Score))
-					contextBuilder.WriteString(fmt.Sprintf("**Content:**\n%s\n\n", emb.Text))
-					contextBuilder.WriteString("---\n\n")
-				}
-			}
-		} else {
-			transcript, err := t.generateYouTubeTranscript(req.VideoID)
-			if err == nil {
-				contextBuilder.WriteString("## Video Transcript\n\n")
-				contextBuilder.WriteString(transcript)
-				contextBuilder.WriteString("\n\n")
-
-				t.ProcessEmbeddingInBackground(userIDStr, req.VideoID, transcript)
-			}
-		}
-
-		// Get relevant user notes for this video
-		searchReq := SearchRequest{
-			Query:   req.Question,
-			VideoID: req.VideoID,
-			Limit:   req.Context,
-		}
-		if searchReq.Limit == 0 {
-			searchReq.Limit = 5
-		}
-
-		query := t.db.DB.NewSelect().
-			Model((*models.Timestamp)(nil)).
-			Where("user_id = ? AND video_id = ? AND deleted_at IS NULL", userID, req.VideoID)
-
-		var timestamps []models.Timestamp
-		err = query.Scan(context.Background(), &timestamps)
-		if err != nil {
-			middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch timestamps", gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-
-		var scoredResults []ScoredTimestamp
-		for _, ts := range timestamps {
-			if len(ts.Embedding) > 0 {
-				score := CosineSimilarity(queryEmbedding, ts.Embedding)
-				scoredResults = append(scoredResults, ScoredTimestamp{
-					Timestamp: ts,
-					Score:     score,
-				})
-			}
-		}
-
-		sort.Slice(scoredResults, func(i, j int) bool {
-			return scoredResults[i].Score > scoredResults[j].Score
+	qc, err := t.buildQuestionContext(context.Background(), userID, req, queryEmbedding)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch timestamps", gin.H{
+			"error": err.Error(),
 		})
+		return
+	}
 
-		if len(scoredResults) > searchReq.Limit {
-			scoredResults = scoredResults[:searchReq.Limit]
-		}
-
-		if len(scoredResults) > 0 {
-			contextBuilder.WriteString("## Relevant User Notes\n\n")
-
-			for i, scored := range scoredResults {
-				ts := scored.Timestamp.(models.Timestamp)
-				contextBuilder.WriteString(fmt.Sprintf("### Note %d (Timestamp: %.2f seconds, Relevance: %.3f)\n\n", i+1, ts.Timestamp, scored.Score))
-				if ts.Title != "" {
-					contextBuilder.WriteString(fmt.Sprintf("**Title:** %s\n\n", ts.Title))
-				}
-				if ts.Note != "" {
-					contextBuilder.WriteString(fmt.Sprintf("**Content:**\n%s\n\n", ts.Note))
-				}
-				if len(ts.Tags) > 0 {
-					var tagNames []string
-					for _, tag := range ts.Tags {
-						tagNames = append(tagNames, tag.Name)
-					}
-					contextBuilder.WriteString(fmt.Sprintf("**Tags:** %s\n\n", strings.Join(tagNames, ", ")))
-				}
-				contextBuilder.WriteString("---\n\n")
-			}
-		}
-	} else {
-		searchReq := SearchRequest{
-			Query: req.Question,
-			Limit: req.Context,
-		}
-		if searchReq.Limit == 0 {
-			searchReq.Limit = 5
-		}
-
-		query := t.db.DB.NewSelect().
-			Model((*models.Timestamp)(nil)).
-			Where("user_id = ? AND deleted_at IS NULL", userID)
-
-		var timestamps []models.Timestamp
-		err = query.Scan(context.Background(), &timestamps)
-		if err != nil {
-			middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch timestamps", gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-
-		var scoredResults []ScoredTimestamp
-		for _, ts := range timestamps {
-			if len(ts.Embedding) > 0 {
-				score := CosineSimilarity(queryEmbedding, ts.Embedding)
-				scoredResults = append(scoredResults, ScoredTimestamp{
-					Timestamp: ts,
-					Score:     score,
-				})
-			}
-		}
-
-		sort.Slice(scoredResults, func(i, j int) bool {
-			return scoredResults[i].Score > scoredResults[j].Score
+	used := prompts.Versions{}
+	prompt, err := t.prompts.Render(c.Request.Context(), req.prompt(), userID, questionPromptData{
+		Question: req.Question,
+		Context:  qc.Context,
+	}, used)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "PROMPT_ERROR", "Failed to build prompt", gin.H{
+			"error": err.Error(),
 		})
-
-		if len(scoredResults) > searchReq.Limit {
-			scoredResults = scoredResults[:searchReq.Limit]
-		}
-
-		if len(scoredResults) > 0 {
-			contextBuilder.WriteString("## Relevant User Notes\n\n")
-
-			for i, scored := range scoredResults {
-				ts := scored.Timestamp.(models.Timestamp)
-				contextBuilder.WriteString(fmt.Sprintf("### Note %d (Video: %s, Timestamp: %.2f seconds, Relevance: %.3f)\n\n", i+1, ts.VideoID, ts.Timestamp, scored.Score))
-				if ts.Title != "" {
-					contextBuilder.WriteString(fmt.Sprintf("**Title:** %s\n\n", ts.Title))
-				}
-				if ts.Note != "" {
-					contextBuilder.WriteString(fmt.Sprintf("**Content:**\n%s\n\n", ts.Note))
-				}
-				if len(ts.Tags) > 0 {
-					var tagNames []string
-					for _, tag := range ts.Tags {
-						tagNames = append(tagNames, tag.Name)
-					}
-					contextBuilder.WriteString(fmt.Sprintf("**Tags:** %s\n\n", strings.Join(tagNames, ", ")))
-				}
-				contextBuilder.WriteString("---\n\n")
-			}
-		}
+		return
 	}
 
-	prompt := fmt.Sprintf(`You are an AI assistant helping answer questions about video content. 
-
-Question: "%s"
-
-%s
-
-Please provide a comprehensive answer based on the context above. If the question is about a specific video and you have access to the video transcript, use that information to provide a more complete answer. If the notes don't contain enough information to answer the question, please say so clearly. You can reference specific timestamps in your answer.
-
-Make your answer helpful, accurate, and well-structured.`,
-		req.Question, contextBuilder.String())
+	if c.Query("stream") == "true" {
+		t.streamAnswer(c, userID, req, qc, prompt, used)
+		return
+	}
 
-	answer, err := t.aiService.GenerateTextCompletion(prompt)
+	started := time.Now()
+	answer, err := t.aiService.GenerateTextCompletion(c.Request.Context(), aicache.FeatureQuestion, prompt)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "AI_ERROR", "Failed to generate answer", gin.H{
 			"error": err.Error(),
@@ -798,28 +653,17 @@ Make your answer helpful, accurate, and well-structured.`,
 	}
 
 	var relevantNotes []gin.H
-	if req.VideoID != "" {
-		query := t.db.DB.NewSelect().
-			Model((*models.Timestamp)(nil)).
-			Where("user_id = ? AND video_id = ? AND deleted_at IS NULL", userID, req.VideoID)
-
-		var timestamps []models.Timestamp
-		err = query.Scan(context.Background(), &timestamps)
-		if err == nil {
-			for _, ts := range timestamps {
-				if len(ts.Embedding) > 0 {
-					score := CosineSimilarity(queryEmbedding, ts.Embedding)
-					relevantNotes = append(relevantNotes, gin.H{
-						"id":        ts.ID,
-						"timestamp": ts.Timestamp,
-						"title":     ts.Title,
-						"note":      ts.Note,
-						"tags":      ts.Tags,
-						"score":     score,
-					})
-				}
-			}
+	for _, citation := range qc.Citations {
+		if citation.Type != CitationTypeNote {
+			continue
 		}
+		relevantNotes = append(relevantNotes, gin.H{
+			"id":        citation.ID,
+			"timestamp": citation.Timestamp,
+			"title":     citation.Title,
+			"note":      citation.Text,
+			"score":     citation.Score,
+		})
 	}
 
 	err = t.featureUsageService.IncrementUsage(context.Background(), userID, "ai_questions")
@@ -827,303 +671,221 @@ Make your answer helpful, accurate, and well-structured.`,
 		log.Printf("Warning: Failed to increment AI question usage: %v", err)
 	}
 
-	middleware.RespondWithOK(c, gin.H{
+	citations := markCitations(answer, qc.Citations)
+	insightID := t.recordAnswerInsight(userID, req.VideoID, req.Question, answer, citations, started, nil, used)
+
+	response := gin.H{
 		"answer":         answer,
 		"question":       req.Question,
 		"relevant_notes": relevantNotes,
 		"context_count":  len(relevantNotes),
+		"citations":      citations,
+		"insight_id":     insightID,
 		"generated_at":   time.Now().UTC(),
-	})
-}
-
-func formatTimestamp(duration float64) string {
-	hours := int(duration) / 3600
-	minutes := (int(duration) % 3600) / 60
-	seconds := int(duration) % 60
-
-	return fmt.Sprintf("[%02d:%02d:%02d]", hours, minutes, seconds)
-}
-
-func (t *TimestampsHandlers) generateYouTubeTranscript(videoID string) (string, error) {
-	client := youtube.Client{}
-
-	video, err := client.GetVideo(videoID)
-	if err != nil {
-		if strings.Contains(err.Error(), "400") {
-			return "", fmt.Errorf("video not accessible (400 error) - possible reasons: private video, region-restricted, or transcript disabled for video %s", videoID)
-		}
-		return "", fmt.Errorf("failed to get video info for video %s: %w", videoID, err)
 	}
-
-	if len(video.CaptionTracks) == 0 {
-		return "", fmt.Errorf("no captions available for video %s", videoID)
-	}
-
-	var transcript youtube.VideoTranscript
-	var transcriptErr error
-	for _, captionTrack := range video.CaptionTracks {
-		transcript, transcriptErr = client.GetTranscript(video, captionTrack.LanguageCode)
-		if transcriptErr == nil {
-			break
-		}
+	if req.Library {
+		response["videos"] = groupCitationsByVideo(citations, qc.Videos)
 	}
+	middleware.RespondWithOK(c, response)
+}
 
-	if transcriptErr != nil {
-		return "", fmt.Errorf("failed to get transcript for video %s: %w", videoID, transcriptErr)
+// GetAICacheStats reports cache hits and misses per feature since the
+// process started, and the stored entries per kind. The stats cover every
+// user, so only operators may read them.
+func (t *TimestampsHandlers) GetAICacheStats(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
 	}
-
-	var transcriptText strings.Builder
-	transcriptText.WriteString("TRANSCRIPT WITH TIMESTAMPS:\n\n")
-	for _, entry := range transcript {
-		startTimeSeconds := float64(entry.StartMs) / 1000.0
-		timestamp := formatTimestamp(startTimeSeconds)
-		transcriptText.WriteString(fmt.Sprintf("%s %s\n", timestamp, entry.Text))
+	if !slices.Contains(t.operators, userID) {
+		middleware.RespondWithError(c, http.StatusForbidden, "OPERATOR_ONLY", "Only operators can read AI cache stats", nil)
+		return
 	}
 
-	result := strings.TrimSpace(transcriptText.String())
-	if result == "" {
-		return "", fmt.Errorf("transcript is empty for video %s", videoID)
+	stats, err := t.aiService.CacheStats(c.Request.Context())
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to read AI cache stats", gin.H{
+			"error": err.Error(),
+		})
+		return
 	}
 
-	return result, nil
+	middleware.RespondWithOK(c, stats)
 }
 
-func (t *TimestampsHandlers) generateFullVideoSummary(video *models.Video, transcript string) (string, error) {
-	var content strings.Builder
-	content.WriteString(fmt.Sprintf("# Full Video Summary: %s\n\n", video.Title))
-
-	if video.Description != "" {
-		content.WriteString(fmt.Sprintf("## Video Description\n%s\n\n", video.Description))
-	}
-
-	if transcript != "" {
-		content.WriteString(fmt.Sprintf("## Video Transcript\n%s\n\n", transcript))
-	}
-
-	prompt := `Act as an expert content analyst. Create a comprehensive, well-organized summary of this YouTube video using the provided information and transcript analysis.
-
-Structure your response like this:
-
-# 📺 [Video Title]
-
-## 🎯 Overview
-[2-3 sentence summary of main topic and purpose]
-
-## 🔑 Key Points
-
-Analyze the video content and identify the actual main topics/concepts discussed. Create 3-5 relevant topic sections based on what's actually taught in the video. Use descriptive, specific topic names that reflect the actual content.
-
-Examples of good topic names:
-- "Database Indexing Fundamentals"
-- "B-Tree Index Performance"
-- "Index Optimization Strategies"
-- "Query Performance Analysis"
-- "Real-world Indexing Examples"
-
-For each topic, include:
-- **Important detail:** explanation
-- **Key insight:** explanation
-- **Supporting point:** explanation (when relevant)
-
-## ⏰ Key Moments (Clickable Timestamps)
-Include ONLY the most important timestamps where viewers should jump to. Format as:
-- **[00:15]** - Brief description of what happens at this moment
-- **[02:30]** - Brief description of what happens at this moment
-- **[05:45]** - Brief description of what happens at this moment
-
-**Guidelines for timestamps:**
-- Only include 3-5 most critical moments
-- Focus on key insights, demonstrations, or important announcements
-- Avoid timestamps for introductions, transitions, or minor details
-- Make descriptions concise but informative
-
-## 💡 Main Takeaways
-1. **Primary insight:** Detailed explanation
-2. **Secondary insight:** Detailed explanation
-3. **Action item:** What viewers should do
-
-## 🎯 Bottom Line
-[One paragraph conclusion summarizing the core message]
+func formatTimestamp(duration float64) string {
+	hours := int(duration) / 3600
+	minutes := (int(duration) % 3600) / 60
+	seconds := int(duration) % 60
 
----
+	return fmt.Sprintf("[%02d:%02d:%02d]", hours, minutes, seconds)
+}
 
-**Important:** 
-- Format the response exactly as shown above with proper markdown syntax
-- Use bold text for emphasis, proper headings
-- Ensure timestamps are in the format [MM:SS] for clickable functionality
-- Create topic names that are specific and descriptive based on the actual video content
-- Don't use generic names like "Main Topic 1" - use actual topic names
+// summaryPromptData are the variables of the summary prompt
+type summaryPromptData struct {
+	Title       string
+	Description string
+	SourceTitle string // Heading of Source
+	Source      string
+}
 
-Now analyze this content and identify the most important moments for timestamps:
-` + content.String()
+func (t *TimestampsHandlers) buildFullVideoSummaryPrompt(ctx context.Context, video *models.Video, transcript string, used prompts.Versions) (string, error) {
+	return t.buildVideoSummaryPrompt(ctx, video, "Video Transcript", transcript, used)
+}
 
-	return t.aiService.GenerateTextCompletion(prompt)
+// buildFullVideoSummaryPromptFromSections summarizes a long video from the
+// summaries of its sections instead of the transcript
+func (t *TimestampsHandlers) buildFullVideoSummaryPromptFromSections(ctx context.Context, video *models.Video, sections []models.VideoSummarySection, used prompts.Versions) (string, error) {
+	return t.buildVideoSummaryPrompt(ctx, video, "Section Summaries (in order, with time ranges)", formatSummarySections(sections), used)
 }
 
-func (t *TimestampsHandlers) ProcessEmbeddingInBackground(userIdStr, videoID, transcript string) {
-	go func() {
-		err := t.generateAndSaveTranscriptEmbedding(userIdStr, videoID, transcript)
-		if err != nil {
-			log.Printf("Failed to process embedding for video %s: %v", videoID, err)
-		}
-	}()
+func (t *TimestampsHandlers) buildVideoSummaryPrompt(ctx context.Context, video *models.Video, sourceTitle, source string, used prompts.Versions) (string, error) {
+	return t.prompts.Render(ctx, prompts.Summary, video.UserID, summaryPromptData{
+		Title:       video.Title,
+		Description: video.Description,
+		SourceTitle: sourceTitle,
+		Source:      source,
+	}, used)
 }
 
-func (t *TimestampsHandlers) generateAndSaveTranscriptEmbedding(userIDStr, videoID, transcript string) error {
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		return fmt.Errorf("invalid user ID: %w", err)
-	}
+// ensureTranscriptEmbeddings queues embedding of the transcript for the user
+// unless chunks from the active strategy and model exist or a job is
+// already pending
+func (t *TimestampsHandlers) ensureTranscriptEmbeddings(userID uuid.UUID, transcript *models.VideoTranscript) {
+	ctx := context.Background()
+	strategy := t.transcriptService.ChunkStrategy()
 
-	chunks, err := t.parseTranscriptIntoChunks(transcript)
+	count, err := t.db.DB.NewSelect().
+		Model((*models.TranscriptEmbedding)(nil)).
+		Where("video_id = ? AND user_id = ? AND chunk_strategy = ?", transcript.VideoID, userID, strategy).
+		Where("embedding_model = ?", t.aiService.EmbeddingModel()).
+		Count(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to parse transcript: %w", err)
+		log.Printf("Failed to check transcript embeddings for video %s: %v", transcript.VideoID, err)
+		return
+	}
+	if count > 0 {
+		return
 	}
 
-	_, err = t.db.DB.NewDelete().
-		Model((*models.TranscriptEmbedding)(nil)).
-		Where("video_id = ? AND user_id = ?", videoID, userID).
-		Exec(context.Background())
+	pending, err := t.jobQueue.Pending(ctx, transcriptEmbeddingsKey(userID, transcript.VideoID, transcript.Language, strategy))
 	if err != nil {
-		log.Printf("Warning: failed to delete existing embeddings for video %s: %v", videoID, err)
+		log.Printf("Failed to check transcript embedding jobs for video %s: %v", transcript.VideoID, err)
+		return
 	}
-
-	for i, chunk := range chunks {
-		embedding, err := t.aiService.GenerateEmbedding(chunk.Text)
-		if err != nil {
-			log.Printf("Failed to generate embedding for chunk %d of video %s: %v", i, videoID, err)
-			continue
-		}
-
-		transcriptEmbedding := &models.TranscriptEmbedding{
-			UserID:     userID,
-			VideoID:    videoID,
-			ChunkIndex: i,
-			StartTime:  &chunk.StartTime,
-			EndTime:    &chunk.EndTime,
-			Text:       chunk.Text,
-			Embedding:  pgvector.NewVector(embedding),
-		}
-
-		_, err = t.db.DB.NewInsert().
-			Model(transcriptEmbedding).
-			Exec(context.Background())
-		if err != nil {
-			log.Printf("Failed to save embedding for chunk %d of video %s: %v", i, videoID, err)
-			continue
-		}
+	if pending {
+		return
 	}
 
-	log.Printf("Successfully processed %d transcript chunks for video %s", len(chunks), videoID)
-	return nil
-}
-
-type TranscriptChunk struct {
-	StartTime float64
-	EndTime   float64
-	Text      string
+	if _, err := t.enqueueTranscriptEmbeddings(ctx, userID, transcript, strategy); err != nil {
+		log.Printf("Failed to queue transcript embeddings for video %s: %v", transcript.VideoID, err)
+	}
 }
 
-func (t *TimestampsHandlers) parseTranscriptIntoChunks(transcript string) ([]TranscriptChunk, error) {
-	lines := strings.Split(transcript, "\n")
-
-	var chunks []TranscriptChunk
-	var currentChunk strings.Builder
-	var chunkStartTime float64
-	var chunkEndTime float64
-	var chunkIndex int
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		if strings.Contains(line, "TRANSCRIPT WITH TIMESTAMPS:") {
-			continue
-		}
-
-		if strings.HasPrefix(line, "[") {
-			if currentChunk.Len() > 0 {
-				chunks = append(chunks, TranscriptChunk{
-					StartTime: chunkStartTime,
-					EndTime:   chunkEndTime,
-					Text:      strings.TrimSpace(currentChunk.String()),
-				})
-				chunkIndex++
-			}
-
-			endBracket := strings.Index(line, "]")
-			if endBracket == -1 {
-				continue
-			}
-
-			timestampStr := line[1:endBracket]
-			timeInSeconds := parseTimestampToSeconds(timestampStr)
-
-			chunkStartTime = timeInSeconds
-			currentChunk.Reset()
-
-			text := strings.TrimSpace(line[endBracket+1:])
-			if text != "" {
-				currentChunk.WriteString(text)
-				currentChunk.WriteString(" ")
-			}
-		} else {
-			if currentChunk.Len() > 0 {
-				currentChunk.WriteString(" ")
-			}
-			currentChunk.WriteString(line)
-		}
+// generateAndSaveTranscriptEmbedding replaces the user's chunks for the
+// video, strategy and active model once every chunk has been embedded,
+// returning how many were stored. Chunks from other strategies are kept for
+// comparison. Other models' chunks are kept until the corpus is migrated,
+// unless the transcript changed and they no longer match a new chunk.
+func (t *TimestampsHandlers) generateAndSaveTranscriptEmbedding(ctx context.Context, userID uuid.UUID, transcript *models.VideoTranscript, strategy string, progress jobs.ProgressFunc) (int, error) {
+	chunker, err := t.transcriptService.Chunker(strategy)
+	if err != nil {
+		return 0, jobs.Permanent(err)
 	}
 
-	if currentChunk.Len() > 0 {
-		chunks = append(chunks, TranscriptChunk{
-			StartTime: chunkStartTime,
-			EndTime:   chunkEndTime,
-			Text:      strings.TrimSpace(currentChunk.String()),
-		})
+	videoID := transcript.VideoID
+	chunks := chunker.Chunk(transcript.Segments)
+	if len(chunks) == 0 {
+		return 0, jobs.Permanent(fmt.Errorf("transcript for video %s produced no chunks", videoID))
 	}
 
-	for i := 0; i < len(chunks)-1; i++ {
-		chunks[i].EndTime = chunks[i+1].StartTime
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
 	}
-
-	if len(chunks) > 0 {
-		lastChunk := &chunks[len(chunks)-1]
-		if lastChunk.EndTime == 0 {
-			lastChunk.EndTime = lastChunk.StartTime + 60.0
-		}
+	results := t.aiService.GenerateEmbeddings(ctx, texts, func(done int) {
+		progress(done, len(chunks))
+	})
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
 
-	return chunks, nil
-}
-
-func parseTimestampToSeconds(timestamp string) float64 {
-	parts := strings.Split(timestamp, ":")
-
-	if len(parts) == 3 {
-		hours, err1 := strconv.Atoi(parts[0])
-		minutes, err2 := strconv.Atoi(parts[1])
-		seconds, err3 := strconv.Atoi(parts[2])
-
-		if err1 != nil || err2 != nil || err3 != nil {
-			return 0.0
+	// Chunks are replaced as a set, so any failed chunk fails the whole run
+	failed := 0
+	var lastErr error
+	for i, result := range results {
+		if result.Err != nil {
+			failed++
+			lastErr = fmt.Errorf("chunk %d: %w", chunks[i].Index, result.Err)
 		}
-
-		return float64(hours*3600 + minutes*60 + seconds)
+	}
+	if failed > 0 {
+		return 0, fmt.Errorf("failed to embed %d of %d chunks of video %s, last error: %w", failed, len(chunks), videoID, lastErr)
 	}
 
-	if len(parts) == 2 {
-		minutes, err1 := strconv.Atoi(parts[0])
-		seconds, err2 := strconv.Atoi(parts[1])
+	model := t.aiService.EmbeddingModel()
+	now := time.Now()
+	rows := make([]models.TranscriptEmbedding, 0, len(chunks))
+	indexes := make([]int, len(chunks))
+	starts := make([]float64, len(chunks))
+	ends := make([]float64, len(chunks))
+	for i, chunk := range chunks {
+		startTime, endTime := chunk.StartTime, chunk.EndTime
+		indexes[i], starts[i], ends[i] = chunk.Index, startTime, endTime
+		rows = append(rows, models.TranscriptEmbedding{
+			UserID:              userID,
+			VideoID:             videoID,
+			ChunkStrategy:       chunker.Name(),
+			ChunkIndex:          chunk.Index,
+			StartTime:           &startTime,
+			EndTime:             &endTime,
+			Text:                chunk.Text,
+			TokenCount:          chunk.TokenCount,
+			EmbeddingModel:      model,
+			EmbeddingDimensions: len(results[i].Embedding),
+			Embedding:           pgvector.NewVector(results[i].Embedding),
+			CreatedAt:           now,
+			UpdatedAt:           now,
+		})
+	}
 
-		if err1 != nil || err2 != nil {
-			return 0.0
+	err = t.db.DB.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*models.TranscriptEmbedding)(nil)).
+			Where("video_id = ? AND user_id = ? AND chunk_strategy = ?", videoID, userID, chunker.Name()).
+			Where("embedding_model = ?", model).
+			ForceDelete().
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete existing embeddings: %w", err)
+		}
+
+		_, err = tx.NewDelete().
+			Model((*models.TranscriptEmbedding)(nil)).
+			Where("video_id = ? AND user_id = ? AND chunk_strategy = ?", videoID, userID, chunker.Name()).
+			Where("embedding_model <> ?", model).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM unnest(?::int[], ?::text[], ?::float8[], ?::float8[]) AS c(chunk_index, text, start_time, end_time)
+				WHERE c.chunk_index = ?TableAlias.chunk_index
+				AND c.text = ?TableAlias.text
+				AND c.start_time = ?TableAlias.start_time
+				AND c.end_time = ?TableAlias.end_time
+			)`, pgdialect.Array(indexes), pgdialect.Array(texts), pgdialect.Array(starts), pgdialect.Array(ends)).
+			ForceDelete().
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete outdated embeddings: %w", err)
 		}
 
-		return float64(minutes*60 + seconds)
+		if _, err := tx.NewInsert().Model(&rows).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to save embeddings: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return 0.0
+	log.Printf("Successfully processed %d %s transcript chunks for video %s", len(rows), chunker.Name(), videoID)
+	return len(rows), nil
 }
@@ -1,140 +1,324 @@
 package timestamps
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+	zlog "github.com/rs/zerolog/log"
+	"github.com/shubhamku044/ytclipper/internal/aicache"
 	"github.com/shubhamku044/ytclipper/internal/config"
 	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/llm"
+	"github.com/shubhamku044/ytclipper/internal/markdown"
 	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/services"
+	"github.com/uptrace/bun"
 )
 
 type AIService struct {
-	openaiAPIKey   *config.OpenAIConfig
-	embeddingModel string
-	db             *database.Database
+	provider llm.Provider
+	embedder *llm.BatchEmbedder
+	db       *database.Database
+
+	// config and limiter build embedders for other models when re-embedding;
+	// both are nil for services wired with NewAIServiceWithProvider
+	config    *config.OpenAIConfig
+	limiter   *llm.RateLimiter
+	mu        sync.Mutex
+	embedders map[string]*llm.BatchEmbedder
+
+	// cache is nil, and so disabled, for NewAIServiceWithProvider
+	cache *aicache.Cache
+	// usage records the tokens of provider calls; nil for
+	// NewAIServiceWithProvider
+	usage *services.TokenUsageService
 }
 
-func NewAIService(openaiAPIKey *config.OpenAIConfig, db *database.Database) *AIService {
+func NewAIService(openaiConfig *config.OpenAIConfig, cacheConfig *config.AICacheConfig, db *database.Database) *AIService {
+	provider, err := llm.NewProvider(openaiConfig)
+	if err != nil {
+		zlog.Fatal().Err(err).Str("provider", openaiConfig.Provider).Msg("Failed to configure AI provider")
+	}
+	pricing, err := llm.NewPricing(openaiConfig.ModelPrices)
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("Failed to parse OPENAI_MODEL_PRICES")
+	}
+
+	ai := NewAIServiceWithProvider(provider, db)
+	ai.config = openaiConfig
+	ai.limiter = llm.NewRateLimiter(openaiConfig.EmbeddingRequestsPerMinute, openaiConfig.EmbeddingTokensPerMinute)
+	ai.embedder = ai.newEmbedder(provider)
+	ai.cache = aicache.New(db, cacheConfig)
+	ai.usage = services.NewTokenUsageService(db, pricing)
+	return ai
+}
+
+// TokenUsage tracks the tokens spent by each user's AI calls
+func (ai *AIService) TokenUsage() *services.TokenUsageService {
+	return ai.usage
+}
+
+// CacheStats reports the AI cache's hit and miss counts
+func (ai *AIService) CacheStats(ctx context.Context) (*aicache.Stats, error) {
+	return ai.cache.Stats(ctx)
+}
+
+// NewAIServiceWithProvider wires an already constructed provider, e.g. the fake one in CI
+func NewAIServiceWithProvider(provider llm.Provider, db *database.Database) *AIService {
 	return &AIService{
-		openaiAPIKey:   openaiAPIKey,
-		embeddingModel: openaiAPIKey.EmbeddingModel,
-		db:             db,
+		provider: provider,
+		embedder: llm.NewBatchEmbedder(provider, nil, llm.BatchOptions{}),
+		db:       db,
 	}
 }
 
-func (ai *AIService) GenerateEmbedding(text string) ([]float32, error) {
-	if text == "" {
-		return nil, fmt.Errorf("text cannot be empty")
+func (ai *AIService) Provider() llm.Provider {
+	return ai.provider
+}
+
+// EmbeddingModel is the active model; stored vectors from other models are
+// ignored by search
+func (ai *AIService) EmbeddingModel() string {
+	return ai.provider.EmbeddingModel()
+}
+
+// defaultChatContextTokens is gpt-3.5-turbo's context window, assumed when
+// OPENAI_CHAT_CONTEXT_TOKENS is not configured
+const defaultChatContextTokens = 16385
+
+// ChatContextTokens is the context window of the chat model
+func (ai *AIService) ChatContextTokens() int {
+	if ai.config == nil || ai.config.ChatContextTokens <= 0 {
+		return defaultChatContextTokens
 	}
+	return ai.config.ChatContextTokens
+}
+
+func (ai *AIService) newEmbedder(provider llm.Provider) *llm.BatchEmbedder {
+	return llm.NewBatchEmbedder(provider, ai.limiter, llm.BatchOptions{
+		MaxInputs:  ai.config.EmbeddingBatchSize,
+		MaxTokens:  ai.config.EmbeddingBatchTokens,
+		MaxRetries: ai.config.MaxRetries,
+	})
+}
 
-	reqBody := EmbeddingRequest{
-		Input:          []string{text},
-		Model:          ai.embeddingModel,
-		EncodingFormat: "float",
+// embedderFor returns an embedder for model, sharing the active provider's
+// credentials and rate limits. Models other than the active one must be
+// listed in OPENAI_REEMBED_MODELS.
+func (ai *AIService) embedderFor(model string) (*llm.BatchEmbedder, error) {
+	if model == ai.EmbeddingModel() {
+		return ai.embedder, nil
+	}
+	if ai.config == nil {
+		return nil, fmt.Errorf("embedding model %q is not available from the %s provider", model, ai.provider.Name())
+	}
+	if !slices.Contains(ai.config.ReembedModels, model) {
+		return nil, fmt.Errorf("embedding model %q is not listed in OPENAI_REEMBED_MODELS", model)
 	}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+
+	if embedder, ok := ai.embedders[model]; ok {
+		return embedder, nil
 	}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/embeddings", bytes.NewBuffer(jsonData))
+	cfg := *ai.config
+	cfg.EmbeddingModel = model
+	provider, err := llm.NewProvider(&cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+ai.openaiAPIKey.APIKey)
+	if ai.embedders == nil {
+		ai.embedders = make(map[string]*llm.BatchEmbedder)
+	}
+	ai.embedders[model] = ai.newEmbedder(provider)
+	return ai.embedders[model], nil
+}
+
+// GenerateEmbedding embeds a search query or question
+func (ai *AIService) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+
+	results := ai.embed(ctx, aicache.FeatureQuery, ai.embedder, ai.EmbeddingModel(), []string{text}, nil)
+	if results[0].Err != nil {
+		return nil, results[0].Err
+	}
+
+	return results[0].Embedding, nil
+}
+
+// GenerateEmbeddings embeds texts in batches, returning one result per text.
+// A failed text does not fail the others; check each result's Err.
+func (ai *AIService) GenerateEmbeddings(ctx context.Context, texts []string, progress func(done int)) []llm.EmbedResult {
+	return ai.embed(ctx, aicache.FeatureEmbedding, ai.embedder, ai.EmbeddingModel(), texts, progress)
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+// GenerateEmbeddingsWith embeds texts with a model other than the active
+// one, e.g. while re-embedding the corpus for a model switch
+func (ai *AIService) GenerateEmbeddingsWith(ctx context.Context, model string, texts []string, progress func(done int)) ([]llm.EmbedResult, error) {
+	embedder, err := ai.embedderFor(model)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return ai.embed(ctx, aicache.FeatureEmbedding, embedder, model, texts, progress), nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenAI API error: status %d", resp.StatusCode)
+// embed serves what it can from the cache and sends only the remaining
+// texts to the provider, recording their tokens against the user of ctx
+func (ai *AIService) embed(ctx context.Context, feature string, embedder *llm.BatchEmbedder, model string, texts []string, progress func(done int)) []llm.EmbedResult {
+	cached := ai.cache.Embeddings(ctx, feature, model, texts)
+
+	results := make([]llm.EmbedResult, len(texts))
+	var missing []int
+	var missingTexts []string
+	for i, text := range texts {
+		if embedding, ok := cached[text]; ok {
+			results[i].Embedding = embedding
+			continue
+		}
+		missing = append(missing, i)
+		missingTexts = append(missingTexts, text)
 	}
 
-	var embeddingResp EmbeddingResponse
-	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
-		return nil, err
+	hits := len(texts) - len(missing)
+	if progress != nil && hits > 0 {
+		progress(hits)
+	}
+	if len(missing) == 0 {
+		return results
 	}
 
-	if len(embeddingResp.Data) == 0 {
-		return nil, fmt.Errorf("no embedding data received")
+	embedded, usage := embedder.Embed(ctx, missingTexts, func(done int) {
+		if progress != nil {
+			progress(hits + done)
+		}
+	})
+	ai.usage.Record(ctx, feature, model, usage)
+
+	fresh := make(map[string][]float32, len(embedded))
+	for i, result := range embedded {
+		results[missing[i]] = result
+		if result.Err == nil {
+			fresh[missingTexts[i]] = result.Embedding
+		}
 	}
+	ai.cache.StoreEmbeddings(ctx, feature, model, fresh)
 
-	return embeddingResp.Data[0].Embedding, nil
+	return results
 }
 
-func (ai *AIService) GenerateTextCompletion(prompt string) (string, error) {
-	reqBody := ChatRequest{
-		Model: "gpt-3.5-turbo",
-		Messages: []struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		}{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
+// GenerateTextCompletion answers a single prompt. feature names the cache
+// feature the completion is stored under.
+func (ai *AIService) GenerateTextCompletion(ctx context.Context, feature, prompt string) (string, error) {
+	completion, err := ai.complete(ctx, feature, llm.CompletionRequest{
+		Messages:    llm.UserMessage(prompt),
 		MaxTokens:   1000,
 		Temperature: 0.7,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
+	})
 	if err != nil {
 		return "", err
 	}
+	return completion.Content, nil
+}
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
+// StreamTextCompletion streams the completion for prompt, invoking onDelta per chunk
+func (ai *AIService) StreamTextCompletion(ctx context.Context, feature, prompt string, onDelta llm.StreamFunc) (*llm.Completion, error) {
+	return ai.stream(ctx, feature, llm.CompletionRequest{
+		Messages:    llm.UserMessage(prompt),
+		MaxTokens:   1000,
+		Temperature: 0.7,
+	}, onDelta)
+}
+
+// GenerateJSONCompletion answers a prompt that asks for a JSON object
+func (ai *AIService) GenerateJSONCompletion(ctx context.Context, feature, prompt string) (string, error) {
+	completion, err := ai.complete(ctx, feature, llm.CompletionRequest{
+		Messages:    llm.UserMessage(prompt),
+		MaxTokens:   1000,
+		Temperature: 0.3,
+		JSON:        true,
+	})
 	if err != nil {
 		return "", err
 	}
+	return completion.Content, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+ai.openaiAPIKey.APIKey)
+// GenerateChatCompletion answers a multi-turn conversation. A cached answer
+// reports no usage.
+func (ai *AIService) GenerateChatCompletion(ctx context.Context, feature string, messages []llm.Message, maxTokens int) (*llm.Completion, error) {
+	return ai.complete(ctx, feature, llm.CompletionRequest{
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: 0.7,
+	})
+}
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+// StreamChatCompletion streams the answer to a multi-turn conversation
+func (ai *AIService) StreamChatCompletion(ctx context.Context, feature string, messages []llm.Message, onDelta llm.StreamFunc) (*llm.Completion, error) {
+	return ai.stream(ctx, feature, llm.CompletionRequest{
+		Messages:    messages,
+		MaxTokens:   1000,
+		Temperature: 0.7,
+	}, onDelta)
+}
+
+func (ai *AIService) complete(ctx context.Context, feature string, req llm.CompletionRequest) (*llm.Completion, error) {
+	model := ai.provider.ChatModel()
+	if content, ok := ai.cache.Completion(ctx, feature, model, req); ok {
+		return &llm.Completion{Content: content, FinishReason: "stop"}, nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("OpenAI API error: status %d", resp.StatusCode)
+	completion, err := ai.provider.Complete(ctx, req)
+	if err != nil {
+		return nil, err
 	}
+	ai.usage.Record(ctx, feature, model, completion.Usage)
 
-	var chatResp ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", err
+	ai.cache.StoreCompletion(ctx, feature, model, req, completion.Content)
+	return completion, nil
+}
+
+// stream replays a cached completion as a single delta. Only streams that
+// finish are cached, so an aborted stream is generated again next time.
+func (ai *AIService) stream(ctx context.Context, feature string, req llm.CompletionRequest, onDelta llm.StreamFunc) (*llm.Completion, error) {
+	model := ai.provider.ChatModel()
+	if content, ok := ai.cache.Completion(ctx, feature, model, req); ok {
+		if err := onDelta(content); err != nil {
+			return nil, err
+		}
+		return &llm.Completion{Content: content, FinishReason: "stop"}, nil
 	}
 
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no response from AI")
+	completion, err := ai.provider.Stream(ctx, req, onDelta)
+	if err != nil {
+		return nil, err
 	}
+	ai.usage.Record(ctx, feature, model, completion.Usage)
 
-	return chatResp.Choices[0].Message.Content, nil
+	ai.cache.StoreCompletion(ctx, feature, model, req, completion.Content)
+	return completion, nil
 }
 
+// CreateEmbeddingText builds the text a note is embedded from. The note's
+// Markdown is flattened so markup doesn't dilute the embedding.
 func (ai *AIService) CreateEmbeddingText(title, note string, tags []string) string {
 	var parts []string
 
 	if title != "" {
 		parts = append(parts, "Title: "+title)
 	}
-	if note != "" {
+	if note = markdown.PlainText(note); note != "" {
 		parts = append(parts, "Note: "+note)
 	}
 	if len(tags) > 0 {
@@ -144,12 +328,14 @@ func (ai *AIService) CreateEmbeddingText(title, note string, tags []string) stri
 	return strings.Join(parts, "\n")
 }
 
-func (ai *AIService) ProcessEmbeddingForTimestamp(timestampID string) error {
-	ctx := context.Background()
+// errNothingToEmbed is returned for notes without a title, note or tags
+var errNothingToEmbed = errors.New("no content to embed")
 
+func (ai *AIService) ProcessEmbeddingForTimestamp(ctx context.Context, timestampID string) error {
 	var timestamp models.Timestamp
 	err := ai.db.DB.NewSelect().
 		Model(&timestamp).
+		Relation("Tags").
 		Where("id = ?", timestampID).
 		Scan(ctx)
 	if err != nil {
@@ -163,75 +349,49 @@ func (ai *AIService) ProcessEmbeddingForTimestamp(timestampID string) error {
 
 	embeddingText := ai.CreateEmbeddingText(timestamp.Title, timestamp.Note, tagNames)
 	if embeddingText == "" {
-		return fmt.Errorf("no content to embed for timestamp %s", timestampID)
+		return fmt.Errorf("%w for timestamp %s", errNothingToEmbed, timestampID)
 	}
 
-	embedding, err := ai.GenerateEmbedding(embeddingText)
-	if err != nil {
-		return fmt.Errorf("failed to generate embedding: %w", err)
+	result := ai.GenerateEmbeddings(ctx, []string{embeddingText}, nil)[0]
+	if result.Err != nil {
+		return fmt.Errorf("failed to generate embedding: %w", result.Err)
 	}
+	embedding := result.Embedding
 
-	_, err = ai.db.DB.NewUpdate().
-		Model((*models.Timestamp)(nil)).
-		Set("embedding = ?", embedding).
-		Set("updated_at = ?", time.Now().UTC()).
-		Where("id = ?", timestampID).
-		Exec(ctx)
-
-	return err
+	return saveTimestampEmbeddings(ctx, ai.db.DB, []models.TimestampEmbedding{
+		newTimestampEmbedding(&timestamp, ai.EmbeddingModel(), embedding),
+	})
 }
 
-func (ai *AIService) ProcessMissingEmbeddingsForUser(userID string, batchSize int) error {
-	ctx := context.Background()
-
-	userUUID, err := uuid.Parse(userID)
-	if err != nil {
-		return fmt.Errorf("invalid user ID: %w", err)
-	}
-
-	var timestamps []models.Timestamp
-	err = ai.db.DB.NewSelect().
-		Model(&timestamps).
-		Where("user_id = ? AND deleted_at IS NULL AND embedding IS NULL", userUUID).
-		Limit(batchSize).
-		Scan(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to fetch timestamps: %w", err)
-	}
-
-	processed := 0
-	for _, ts := range timestamps {
-		if err := ai.ProcessEmbeddingForTimestamp(ts.ID.String()); err != nil {
-			continue
-		}
-		processed++
-		time.Sleep(1 * time.Second) // Rate limiting
+func newTimestampEmbedding(timestamp *models.Timestamp, model string, embedding []float32) models.TimestampEmbedding {
+	now := time.Now().UTC()
+	return models.TimestampEmbedding{
+		TimestampID: timestamp.ID,
+		UserID:      timestamp.UserID,
+		Model:       model,
+		Dimensions:  len(embedding),
+		Embedding:   pgvector.NewVector(embedding),
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
-
-	return nil
 }
 
-func (ai *AIService) ProcessAllMissingEmbeddings(batchSize int) error {
-	ctx := context.Background()
-
-	var timestamps []models.Timestamp
-	err := ai.db.DB.NewSelect().
-		Model(&timestamps).
-		Where("deleted_at IS NULL AND embedding IS NULL").
-		Limit(batchSize).
-		Scan(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to fetch timestamps: %w", err)
+// saveTimestampEmbeddings stores note vectors, replacing earlier vectors
+// from the same model
+func saveTimestampEmbeddings(ctx context.Context, db bun.IDB, rows []models.TimestampEmbedding) error {
+	if len(rows) == 0 {
+		return nil
 	}
 
-	processed := 0
-	for _, ts := range timestamps {
-		if err := ai.ProcessEmbeddingForTimestamp(ts.ID.String()); err != nil {
-			continue
-		}
-		processed++
-		time.Sleep(1 * time.Second) // Rate limiting
+	_, err := db.NewInsert().
+		Model(&rows).
+		On("CONFLICT (timestamp_id, model) DO UPDATE").
+		Set("dimensions = EXCLUDED.dimensions").
+		Set("embedding = EXCLUDED.embedding").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save note embeddings: %w", err)
 	}
-
 	return nil
 }
@@ -0,0 +1,196 @@
+package timestamps
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+)
+
+// UploadTimestampAttachment attaches an image, sent as the multipart field
+// file, to a note. The response includes the Markdown that embeds it.
+func (t *TimestampsHandlers) UploadTimestampAttachment(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	timestampID, ok := parseTimestampID(c)
+	if !ok {
+		return
+	}
+
+	// Leave room for the multipart envelope around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, t.attachmentService.MaxBytes()+64<<10)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondAttachmentTooLarge(c, t.attachmentService.MaxBytes())
+			return
+		}
+		middleware.RespondWithError(c, http.StatusBadRequest, "MISSING_FILE", "An image file is required", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if fileHeader.Size > t.attachmentService.MaxBytes() {
+		respondAttachmentTooLarge(c, t.attachmentService.MaxBytes())
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_FILE", "Failed to read uploaded file", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := t.attachmentService.Upload(c.Request.Context(), userID, timestampID, fileHeader.Filename, file)
+	switch {
+	case errors.Is(err, errNotFound):
+		middleware.RespondWithError(c, http.StatusNotFound, "TIMESTAMP_NOT_FOUND", "Timestamp not found", nil)
+		return
+	case errors.Is(err, errAttachmentTooLarge):
+		respondAttachmentTooLarge(c, t.attachmentService.MaxBytes())
+		return
+	case errors.Is(err, errAttachmentUnsupported):
+		middleware.RespondWithError(c, http.StatusUnsupportedMediaType, "UNSUPPORTED_ATTACHMENT", "Attachments must be PNG, JPEG, GIF or WebP images", nil)
+		return
+	case errors.Is(err, errTooManyAttachments):
+		middleware.RespondWithError(c, http.StatusConflict, "TOO_MANY_ATTACHMENTS", "Note has too many attachments", gin.H{
+			"max_attachments": maxAttachmentsPerNote,
+		})
+		return
+	case err != nil:
+		middleware.RespondWithError(c, http.StatusInternalServerError, "ATTACHMENT_ERROR", "Failed to store attachment", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"attachment": attachment,
+		"markdown":   attachmentMarkdown(attachment),
+		"message":    "Attachment uploaded successfully",
+	})
+}
+
+// ListTimestampAttachments lists the images attached to a note
+func (t *TimestampsHandlers) ListTimestampAttachments(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	timestampID, ok := parseTimestampID(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := t.attachmentService.List(c.Request.Context(), userID, timestampID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch attachments", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"timestamp_id": timestampID,
+		"attachments":  attachments,
+		"count":        len(attachments),
+	})
+}
+
+// GetTimestampAttachment serves the content of an attachment
+func (t *TimestampsHandlers) GetTimestampAttachment(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	attachmentID, ok := parseAttachmentID(c)
+	if !ok {
+		return
+	}
+
+	attachment, content, err := t.attachmentService.Open(c.Request.Context(), userID, attachmentID)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "ATTACHMENT_NOT_FOUND", "Attachment not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "ATTACHMENT_ERROR", "Failed to read attachment", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.Filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	// Attachments never change, but are only visible to their owner
+	c.Header("Cache-Control", "private, max-age=86400, immutable")
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		log.Printf("Failed to send attachment %s: %v", attachmentID, err)
+	}
+}
+
+// DeleteTimestampAttachment removes an attachment and its content
+func (t *TimestampsHandlers) DeleteTimestampAttachment(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	attachmentID, ok := parseAttachmentID(c)
+	if !ok {
+		return
+	}
+
+	err := t.attachmentService.Delete(c.Request.Context(), userID, attachmentID)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "ATTACHMENT_NOT_FOUND", "Attachment not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to delete attachment", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"message": "Attachment deleted successfully",
+	})
+}
+
+func respondAttachmentTooLarge(c *gin.Context, maxBytes int64) {
+	middleware.RespondWithError(c, http.StatusRequestEntityTooLarge, "ATTACHMENT_TOO_LARGE", "Attachment is too large", gin.H{
+		"max_bytes": maxBytes,
+	})
+}
+
+func parseAttachmentID(c *gin.Context) (uuid.UUID, bool) {
+	attachmentID, err := uuid.Parse(c.Param("attachmentId"))
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_ATTACHMENT_ID", "Invalid attachment ID format", gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return attachmentID, true
+}
@@ -0,0 +1,255 @@
+package timestamps
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/markdown"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/storage"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// attachmentURLPrefix is where attachments are served, followed by
+	// their ID
+	attachmentURLPrefix = "/api/v1/timestamps/attachments/"
+	// maxAttachmentsPerNote bounds the images attached to one note
+	maxAttachmentsPerNote = 20
+	// maxImagePixels bounds the dimensions of attached images, so small
+	// files can't claim huge canvases
+	maxImagePixels = 40_000_000
+)
+
+// attachmentContentTypes are the image types that can be attached, by the
+// type sniffed from their content
+var attachmentContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+var (
+	errAttachmentTooLarge    = errors.New("attachment is too large")
+	errAttachmentUnsupported = errors.New("attachment is not a supported image")
+	errTooManyAttachments    = errors.New("note has too many attachments")
+)
+
+// AttachmentService stores images attached to notes. Metadata is kept in
+// timestamp_attachments and the content in blob storage.
+type AttachmentService struct {
+	db       *database.Database
+	store    storage.BlobStore
+	maxBytes int64
+}
+
+func NewAttachmentService(db *database.Database, store storage.BlobStore, maxBytes int64) *AttachmentService {
+	return &AttachmentService{db: db, store: store, maxBytes: maxBytes}
+}
+
+// MaxBytes is the largest attachment accepted
+func (s *AttachmentService) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Upload attaches the image read from r to one of the user's notes
+func (s *AttachmentService) Upload(ctx context.Context, userID, timestampID uuid.UUID, filename string, r io.Reader) (*models.TimestampAttachment, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if int64(len(data)) > s.maxBytes {
+		return nil, errAttachmentTooLarge
+	}
+
+	contentType, width, height, err := inspectImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.db.DB.NewSelect().
+		Model((*models.Timestamp)(nil)).
+		Where("id = ? AND user_id = ?", timestampID, userID).
+		Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch timestamp: %w", err)
+	}
+	if !exists {
+		return nil, errNotFound
+	}
+
+	count, err := s.db.DB.NewSelect().
+		Model((*models.TimestampAttachment)(nil)).
+		Where("timestamp_id = ?", timestampID).
+		Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count attachments: %w", err)
+	}
+	if count >= maxAttachmentsPerNote {
+		return nil, errTooManyAttachments
+	}
+
+	attachment := &models.TimestampAttachment{
+		ID:          uuid.New(),
+		TimestampID: timestampID,
+		UserID:      userID,
+		Filename:    cleanAttachmentFilename(filename),
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+		Width:       width,
+		Height:      height,
+		CreatedAt:   time.Now().UTC(),
+	}
+	attachment.StorageKey = path.Join("attachments", userID.String(), attachment.ID.String())
+
+	if err := s.store.Put(ctx, attachment.StorageKey, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.DB.NewInsert().Model(attachment).Exec(ctx); err != nil {
+		if err := s.store.Delete(context.Background(), attachment.StorageKey); err != nil {
+			log.Printf("Failed to remove blob %s of unsaved attachment: %v", attachment.StorageKey, err)
+		}
+		return nil, fmt.Errorf("failed to save attachment: %w", err)
+	}
+
+	attachment.URL = attachmentURLPrefix + attachment.ID.String()
+	return attachment, nil
+}
+
+// List returns the attachments of one of the user's notes, oldest first
+func (s *AttachmentService) List(ctx context.Context, userID, timestampID uuid.UUID) ([]models.TimestampAttachment, error) {
+	attachments := []models.TimestampAttachment{}
+	err := s.db.DB.NewSelect().
+		Model(&attachments).
+		Where("timestamp_id = ? AND user_id = ?", timestampID, userID).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attachments: %w", err)
+	}
+	for i := range attachments {
+		attachments[i].URL = attachmentURLPrefix + attachments[i].ID.String()
+	}
+	return attachments, nil
+}
+
+// Open returns one of the user's attachments with its content, which the
+// caller closes
+func (s *AttachmentService) Open(ctx context.Context, userID, attachmentID uuid.UUID) (*models.TimestampAttachment, io.ReadCloser, error) {
+	attachment, err := s.get(ctx, userID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := s.store.Open(ctx, attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, errNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+// Delete removes one of the user's attachments. Notes still referencing it
+// render a broken image.
+func (s *AttachmentService) Delete(ctx context.Context, userID, attachmentID uuid.UUID) error {
+	attachment, err := s.get(ctx, userID, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.DB.NewDelete().
+		Model((*models.TimestampAttachment)(nil)).
+		Where("id = ? AND user_id = ?", attachmentID, userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return err
+	}
+
+	if err := s.store.Delete(ctx, attachment.StorageKey); err != nil {
+		log.Printf("Failed to remove blob %s of deleted attachment: %v", attachment.StorageKey, err)
+	}
+	return nil
+}
+
+func (s *AttachmentService) get(ctx context.Context, userID, attachmentID uuid.UUID) (*models.TimestampAttachment, error) {
+	var attachment models.TimestampAttachment
+	err := s.db.DB.NewSelect().
+		Model(&attachment).
+		Where("id = ? AND user_id = ?", attachmentID, userID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch attachment: %w", err)
+	}
+	attachment.URL = attachmentURLPrefix + attachment.ID.String()
+	return &attachment, nil
+}
+
+// inspectImage sniffs the type of an image from its content, ignoring the
+// type the client claims, and reads its dimensions
+func inspectImage(data []byte) (string, int, int, error) {
+	contentType := http.DetectContentType(data)
+	if !attachmentContentTypes[contentType] {
+		return "", 0, 0, errAttachmentUnsupported
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", 0, 0, errAttachmentUnsupported
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return "", 0, 0, errAttachmentUnsupported
+	}
+	return contentType, config.Width, config.Height, nil
+}
+
+// cleanAttachmentFilename keeps the base name of an uploaded file, without
+// characters that would break the Markdown it is quoted in
+func cleanAttachmentFilename(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	filename = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, r == 0x7f:
+			return -1
+		case strings.ContainsRune("[]()<>\"`", r):
+			return '_'
+		}
+		return r
+	}, filename)
+	if filename == "" || filename == "." || filename == "/" {
+		return "image"
+	}
+	if len(filename) > 255 {
+		filename = filename[:255]
+	}
+	return strings.ToValidUTF8(filename, "")
+}
+
+// attachmentMarkdown is the Markdown that embeds an attachment in a note
+func attachmentMarkdown(attachment *models.TimestampAttachment) string {
+	alt := strings.TrimSuffix(attachment.Filename, path.Ext(attachment.Filename))
+	return fmt.Sprintf("![%s](%s%s)", alt, markdown.AttachmentScheme, attachment.ID)
+}
@@ -0,0 +1,84 @@
+package timestamps
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+)
+
+// BulkTimestamps applies operations to many notes in one transaction. With
+// dry_run it only reports what would change. If any note would be left
+// invalid, nothing is changed and the per-note results explain why.
+func (t *TimestampsHandlers) BulkTimestamps(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req BulkTimestampsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := req.validate(); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_OPERATION", "Invalid bulk operation", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(req.IDs))
+	for _, idStr := range req.IDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_TIMESTAMP_ID", "Invalid timestamp ID format", gin.H{
+				"error": err.Error(),
+				"id":    idStr,
+			})
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	ctx := c.Request.Context()
+	result, err := t.bulkService.Apply(ctx, userID, ids, req)
+	if errors.Is(err, errBulkTooManyNotes) {
+		middleware.RespondWithError(c, http.StatusBadRequest, "TOO_MANY_NOTES", "Filter matches too many notes, narrow it down", gin.H{
+			"max_notes": maxBulkNotes,
+		})
+		return
+	}
+	if errors.Is(err, errBulkVideoNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "VIDEO_NOT_FOUND", "Target video not found", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to apply bulk operation", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if !req.DryRun && !result.Applied {
+		middleware.RespondWithError(c, http.StatusUnprocessableEntity, "INVALID_NOTES", "Some notes can't be changed this way, nothing was applied", gin.H{
+			"result": result,
+		})
+		return
+	}
+
+	for _, id := range result.reembed {
+		if _, err := t.enqueueTimestampEmbedding(ctx, userID, id); err != nil {
+			log.Printf("Failed to queue embedding for timestamp %s: %v", id, err)
+		}
+	}
+
+	middleware.RespondWithOK(c, result)
+}
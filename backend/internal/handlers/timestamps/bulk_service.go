@@ -0,0 +1,469 @@
+package timestamps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+)
+
+// Bulk operations and the outcome of a bulk request for each note
+const (
+	BulkOpAddTags    = "add_tags"
+	BulkOpRemoveTags = "remove_tags"
+	BulkOpMove       = "move"
+	BulkOpShift      = "shift"
+	BulkOpSetType    = "set_type"
+	BulkOpDelete     = "delete"
+
+	BulkStatusUpdated   = "updated"
+	BulkStatusDeleted   = "deleted"
+	BulkStatusUnchanged = "unchanged"
+	BulkStatusNotFound  = "not_found"
+	BulkStatusInvalid   = "invalid"
+
+	// maxBulkNotes bounds the notes one bulk request can change
+	maxBulkNotes = 1000
+)
+
+var (
+	errBulkTooManyNotes  = fmt.Errorf("filter matches more than %d notes", maxBulkNotes)
+	errBulkVideoNotFound = errors.New("target video not found")
+)
+
+// BulkNoteState is the part of a note bulk operations change
+type BulkNoteState struct {
+	VideoID   string          `json:"video_id"`
+	Timestamp float64         `json:"timestamp"`
+	EndTime   *float64        `json:"end_time,omitempty"`
+	Type      models.ClipType `json:"type"`
+	Tags      []string        `json:"tags"`
+}
+
+// BulkNoteResult is what a bulk request did, or would do, to one note.
+// Changes lists the fields that change, named like revision fields.
+type BulkNoteResult struct {
+	ID       uuid.UUID      `json:"id"`
+	Status   string         `json:"status"`
+	Changes  []string       `json:"changes,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Before   *BulkNoteState `json:"before,omitempty"`
+	After    *BulkNoteState `json:"after,omitempty"`
+	Revision int            `json:"revision,omitempty"`
+}
+
+// BulkResult reports a bulk request note by note. Applied is false for dry
+// runs and for requests that would leave a note invalid, which change
+// nothing.
+type BulkResult struct {
+	DryRun  bool             `json:"dry_run"`
+	Applied bool             `json:"applied"`
+	Matched int              `json:"matched"`
+	Counts  map[string]int   `json:"counts"`
+	Results []BulkNoteResult `json:"results"`
+
+	// reembed are the notes whose embedding text changed
+	reembed []uuid.UUID
+}
+
+// BulkService applies one set of operations to many notes at once. Each
+// changed note gets a revision, as if it had been edited on its own.
+type BulkService struct {
+	db              *database.Database
+	tagService      *TagService
+	revisionService *RevisionService
+}
+
+func NewBulkService(db *database.Database, tagService *TagService, revisionService *RevisionService) *BulkService {
+	return &BulkService{
+		db:              db,
+		tagService:      tagService,
+		revisionService: revisionService,
+	}
+}
+
+// validate checks what the binding tags can't: that notes are selected one
+// way and that every operation has what it needs
+func (r *BulkTimestampsRequest) validate() error {
+	if (len(r.IDs) > 0) == (r.Filter != nil) {
+		return errors.New("exactly one of ids and filter is required")
+	}
+	if r.Filter != nil && r.Filter.empty() {
+		return errors.New("filter needs at least one condition")
+	}
+
+	for i, op := range r.Operations {
+		switch op.Op {
+		case BulkOpAddTags, BulkOpRemoveTags:
+			names := normalizeTagNames(op.Tags)
+			if len(names) == 0 {
+				return fmt.Errorf("operation %d (%s) needs tags", i+1, op.Op)
+			}
+			for _, name := range names {
+				if err := validateTagName(name); err != nil {
+					return fmt.Errorf("operation %d (%s): %w", i+1, op.Op, err)
+				}
+			}
+		case BulkOpMove:
+			if op.VideoID == "" {
+				return fmt.Errorf("operation %d (%s) needs a video_id", i+1, op.Op)
+			}
+		case BulkOpShift:
+			if op.Seconds == 0 {
+				return fmt.Errorf("operation %d (%s) needs non-zero seconds", i+1, op.Op)
+			}
+		case BulkOpSetType:
+			if op.Type == "" {
+				return fmt.Errorf("operation %d (%s) needs a type", i+1, op.Op)
+			}
+		case BulkOpDelete:
+			if len(r.Operations) > 1 {
+				return errors.New("delete can't be combined with other operations")
+			}
+		}
+	}
+	return nil
+}
+
+func (f *BulkFilter) empty() bool {
+	return strings.TrimSpace(f.Query) == "" && f.NoteFilter.empty()
+}
+
+// Apply runs a bulk request on the user's notes selected by ids, or by the
+// request's filter when ids is empty. Nothing is written for a dry run or
+// when any note would be left invalid.
+func (bs *BulkService) Apply(ctx context.Context, userID uuid.UUID, ids []uuid.UUID, req BulkTimestampsRequest) (*BulkResult, error) {
+	tx, err := bs.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	notes, err := bs.selectNotes(ctx, tx, userID, ids, req.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	videoIDs := make([]string, 0, len(notes)+1)
+	for _, note := range notes {
+		videoIDs = append(videoIDs, note.VideoID)
+	}
+	for _, op := range req.Operations {
+		if op.Op == BulkOpMove {
+			videoIDs = append(videoIDs, op.VideoID)
+		}
+	}
+	durations, err := videoDurations(ctx, tx, userID, videoIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range req.Operations {
+		if _, ok := durations[op.VideoID]; op.Op == BulkOpMove && !ok {
+			return nil, fmt.Errorf("%w: %s", errBulkVideoNotFound, op.VideoID)
+		}
+	}
+
+	result := &BulkResult{
+		DryRun:  req.DryRun,
+		Matched: len(notes),
+		Counts:  make(map[string]int),
+		Results: make([]BulkNoteResult, 0, max(len(ids), len(notes))),
+	}
+
+	byID := make(map[uuid.UUID]*models.Timestamp, len(notes))
+	for i := range notes {
+		byID[notes[i].ID] = &notes[i]
+	}
+	order := ids
+	if len(order) == 0 {
+		order = make([]uuid.UUID, len(notes))
+		for i, note := range notes {
+			order[i] = note.ID
+		}
+	}
+
+	seen := make(map[uuid.UUID]bool, len(order))
+	for _, id := range order {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		note, ok := byID[id]
+		if !ok {
+			result.Results = append(result.Results, BulkNoteResult{ID: id, Status: BulkStatusNotFound})
+			continue
+		}
+		result.Results = append(result.Results, planBulkChange(note, req.Operations, durations))
+	}
+	for _, res := range result.Results {
+		result.Counts[res.Status]++
+	}
+
+	if req.DryRun || result.Counts[BulkStatusInvalid] > 0 {
+		return result, nil
+	}
+
+	deleted := false
+	for i := range result.Results {
+		res := &result.Results[i]
+		if res.Status != BulkStatusUpdated && res.Status != BulkStatusDeleted {
+			continue
+		}
+		id := res.ID
+
+		if err := bs.revisionService.EnsureBaseline(ctx, tx, userID, id); err != nil {
+			return nil, err
+		}
+
+		action := models.RevisionActionUpdated
+		if res.Status == BulkStatusDeleted {
+			action = models.RevisionActionDeleted
+			deleted = true
+			_, err = tx.NewUpdate().
+				Model((*models.Timestamp)(nil)).
+				Set("deleted_at = ?", time.Now().UTC()).
+				Where("id = ? AND user_id = ?", id, userID).
+				Exec(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete timestamp %s: %w", id, err)
+			}
+		} else {
+			if err := bs.update(ctx, tx, userID, id, res); err != nil {
+				return nil, err
+			}
+			if slices.Contains(res.Changes, RevisionFieldTags) {
+				result.reembed = append(result.reembed, id)
+			}
+		}
+
+		revision, err := bs.revisionService.Record(ctx, tx, userID, id, action, nil)
+		if err != nil {
+			return nil, err
+		}
+		if revision != nil {
+			res.Revision = revision.Revision
+		}
+	}
+
+	// Record before the tag relations are cleaned up so undelete gets them back
+	if deleted {
+		if err := bs.tagService.CleanupOrphanedTagRelationsWithTx(ctx, tx); err != nil {
+			return nil, fmt.Errorf("failed to clean up tag relations: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bulk operation: %w", err)
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// update writes the planned state of one note
+func (bs *BulkService) update(ctx context.Context, tx bun.Tx, userID, id uuid.UUID, res *BulkNoteResult) error {
+	after := res.After
+	_, err := tx.NewUpdate().
+		Model((*models.Timestamp)(nil)).
+		Set("video_id = ?", after.VideoID).
+		Set("timestamp = ?", after.Timestamp).
+		Set("end_time = ?", after.EndTime).
+		Set("type = ?", after.Type).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ? AND user_id = ?", id, userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update timestamp %s: %w", id, err)
+	}
+
+	if slices.Contains(res.Changes, RevisionFieldTags) {
+		if err := bs.tagService.ReplaceTimestampTagsWithTx(ctx, tx, userID, id, after.Tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectNotes loads the selected notes with their tags. A filter matching
+// more than maxBulkNotes notes is rejected rather than cut short.
+func (bs *BulkService) selectNotes(ctx context.Context, tx bun.Tx, userID uuid.UUID, ids []uuid.UUID, filter *BulkFilter) ([]models.Timestamp, error) {
+	var notes []models.Timestamp
+	query := tx.NewSelect().
+		Model(&notes).
+		Relation("Tags").
+		Where("?TableAlias.user_id = ?", userID)
+
+	if len(ids) > 0 {
+		query = query.Where("?TableAlias.id IN (?)", bun.In(ids))
+	} else {
+		search := filter.filter()
+		query = applySearchFilter(query, search)
+		query = applyTagFilter(query, search.Tags)
+		query = applyNoteFilter(query, search)
+		if text := strings.TrimSpace(filter.Query); text != "" {
+			pattern := "%" + escapeLike(text) + "%"
+			query = query.Where("(?TableAlias.title ILIKE ? OR ?TableAlias.note ILIKE ?)", pattern, pattern)
+		}
+		query = query.Limit(maxBulkNotes + 1)
+	}
+
+	err := query.
+		OrderExpr("?TableAlias.video_id ASC, ?TableAlias.timestamp ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch timestamps: %w", err)
+	}
+	if len(notes) > maxBulkNotes {
+		return nil, errBulkTooManyNotes
+	}
+	return notes, nil
+}
+
+// videoDurations returns the duration in seconds of each of the user's
+// videos among videoIDs, 0 when unknown
+func videoDurations(ctx context.Context, tx bun.Tx, userID uuid.UUID, videoIDs []string) (map[string]float64, error) {
+	durations := make(map[string]float64)
+	if len(videoIDs) == 0 {
+		return durations, nil
+	}
+
+	var videos []models.Video
+	err := tx.NewSelect().
+		Model(&videos).
+		Column("video_id", "duration").
+		Where("user_id = ? AND video_id IN (?)", userID, bun.In(videoIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch videos: %w", err)
+	}
+	for _, video := range videos {
+		durations[video.VideoID] = float64(video.Duration)
+	}
+	return durations, nil
+}
+
+// planBulkChange works out what the operations do to one note
+func planBulkChange(note *models.Timestamp, ops []BulkOperation, durations map[string]float64) BulkNoteResult {
+	before := bulkStateOf(note)
+	res := BulkNoteResult{ID: note.ID, Before: &before}
+
+	after := before
+	after.Tags = slices.Clone(before.Tags)
+	if before.EndTime != nil {
+		end := *before.EndTime
+		after.EndTime = &end
+	}
+
+	for _, op := range ops {
+		switch op.Op {
+		case BulkOpDelete:
+			res.Status = BulkStatusDeleted
+			return res
+		case BulkOpAddTags:
+			for _, tag := range normalizeTagNames(op.Tags) {
+				if !slices.Contains(after.Tags, tag) {
+					after.Tags = append(after.Tags, tag)
+				}
+			}
+			sort.Strings(after.Tags)
+		case BulkOpRemoveTags:
+			remove := normalizeTagNames(op.Tags)
+			after.Tags = slices.DeleteFunc(after.Tags, func(tag string) bool {
+				return slices.Contains(remove, tag)
+			})
+		case BulkOpMove:
+			after.VideoID = op.VideoID
+		case BulkOpShift:
+			after.Timestamp += op.Seconds
+			if after.EndTime != nil {
+				*after.EndTime += op.Seconds
+			}
+		case BulkOpSetType:
+			after.Type = models.ClipType(op.Type)
+		}
+	}
+
+	if err := validateBulkState(after, durations[after.VideoID]); err != nil {
+		res.Status = BulkStatusInvalid
+		res.Error = err.Error()
+		res.After = &after
+		return res
+	}
+
+	res.Changes = before.changes(after)
+	if len(res.Changes) == 0 {
+		res.Status = BulkStatusUnchanged
+		return res
+	}
+	res.Status = BulkStatusUpdated
+	res.After = &after
+	return res
+}
+
+// validateBulkState checks that a shifted or moved note still lies within
+// its video. A duration of 0 means the video's duration is unknown, as for
+// videos whose metadata was never fetched; such notes are only checked
+// against the start of the video.
+func validateBulkState(state BulkNoteState, duration float64) error {
+	if state.Timestamp < 0 {
+		return fmt.Errorf("timestamp %.2f would be before the start of the video", state.Timestamp)
+	}
+	end := state.Timestamp
+	if state.EndTime != nil {
+		end = *state.EndTime
+	}
+	if duration > 0 && end > duration {
+		return fmt.Errorf("note would end at %.2f, after the video ends at %.0f", end, duration)
+	}
+	return nil
+}
+
+func bulkStateOf(note *models.Timestamp) BulkNoteState {
+	tags := make([]string, 0, len(note.Tags))
+	for _, tag := range note.Tags {
+		tags = append(tags, tag.Name)
+	}
+	sort.Strings(tags)
+
+	return BulkNoteState{
+		VideoID:   note.VideoID,
+		Timestamp: note.Timestamp,
+		EndTime:   note.EndTime,
+		Type:      note.Type,
+		Tags:      tags,
+	}
+}
+
+// changes lists the fields that differ from s to other
+func (s BulkNoteState) changes(other BulkNoteState) []string {
+	var fields []string
+	if s.VideoID != other.VideoID {
+		fields = append(fields, RevisionFieldVideoID)
+	}
+	if s.Timestamp != other.Timestamp {
+		fields = append(fields, RevisionFieldTimestamp)
+	}
+	if !equalOptionalFloat(s.EndTime, other.EndTime) {
+		fields = append(fields, RevisionFieldEndTime)
+	}
+	if s.Type != other.Type {
+		fields = append(fields, RevisionFieldType)
+	}
+	if !slices.Equal(s.Tags, other.Tags) {
+		fields = append(fields, RevisionFieldTags)
+	}
+	return fields
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,263 @@
+package timestamps
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+func TestBulkRequestValidate(t *testing.T) {
+	ids := []string{uuid.NewString()}
+	tests := []struct {
+		name    string
+		req     BulkTimestampsRequest
+		wantErr string
+	}{
+		{
+			name: "ids",
+			req:  BulkTimestampsRequest{IDs: ids, Operations: []BulkOperation{{Op: BulkOpAddTags, Tags: []string{"ml"}}}},
+		},
+		{
+			name: "filter",
+			req:  BulkTimestampsRequest{Filter: &BulkFilter{Query: "loss"}, Operations: []BulkOperation{{Op: BulkOpShift, Seconds: -5}}},
+		},
+		{
+			name:    "ids and filter",
+			req:     BulkTimestampsRequest{IDs: ids, Filter: &BulkFilter{Query: "loss"}, Operations: []BulkOperation{{Op: BulkOpDelete}}},
+			wantErr: "exactly one of ids and filter",
+		},
+		{
+			name:    "neither ids nor filter",
+			req:     BulkTimestampsRequest{Operations: []BulkOperation{{Op: BulkOpDelete}}},
+			wantErr: "exactly one of ids and filter",
+		},
+		{
+			name:    "empty filter",
+			req:     BulkTimestampsRequest{Filter: &BulkFilter{Query: " "}, Operations: []BulkOperation{{Op: BulkOpDelete}}},
+			wantErr: "at least one condition",
+		},
+		{
+			name:    "add tags without tags",
+			req:     BulkTimestampsRequest{IDs: ids, Operations: []BulkOperation{{Op: BulkOpAddTags, Tags: []string{" "}}}},
+			wantErr: "needs tags",
+		},
+		{
+			name:    "move without video",
+			req:     BulkTimestampsRequest{IDs: ids, Operations: []BulkOperation{{Op: BulkOpMove}}},
+			wantErr: "needs a video_id",
+		},
+		{
+			name:    "shift by nothing",
+			req:     BulkTimestampsRequest{IDs: ids, Operations: []BulkOperation{{Op: BulkOpShift}}},
+			wantErr: "non-zero seconds",
+		},
+		{
+			name:    "delete with other operations",
+			req:     BulkTimestampsRequest{IDs: ids, Operations: []BulkOperation{{Op: BulkOpAddTags, Tags: []string{"ml"}}, {Op: BulkOpDelete}}},
+			wantErr: "delete can't be combined",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanBulkChange(t *testing.T) {
+	end := 20.0
+	note := &models.Timestamp{
+		ID:        uuid.New(),
+		VideoID:   "vid-a",
+		Timestamp: 10,
+		EndTime:   &end,
+		Type:      models.ClipTypeNote,
+		Tags:      []models.Tag{{Name: "ml"}, {Name: "basics"}},
+	}
+	durations := map[string]float64{"vid-a": 60, "vid-b": 25}
+	float := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name        string
+		ops         []BulkOperation
+		wantStatus  string
+		wantChanges []string
+		wantAfter   *BulkNoteState
+	}{
+		{
+			name:        "add tags",
+			ops:         []BulkOperation{{Op: BulkOpAddTags, Tags: []string{"#Deep  Learning", "ml"}}},
+			wantStatus:  BulkStatusUpdated,
+			wantChanges: []string{RevisionFieldTags},
+			wantAfter:   &BulkNoteState{VideoID: "vid-a", Timestamp: 10, EndTime: float(20), Type: models.ClipTypeNote, Tags: []string{"basics", "deep learning", "ml"}},
+		},
+		{
+			name:        "remove tags",
+			ops:         []BulkOperation{{Op: BulkOpRemoveTags, Tags: []string{"ML"}}},
+			wantStatus:  BulkStatusUpdated,
+			wantChanges: []string{RevisionFieldTags},
+			wantAfter:   &BulkNoteState{VideoID: "vid-a", Timestamp: 10, EndTime: float(20), Type: models.ClipTypeNote, Tags: []string{"basics"}},
+		},
+		{
+			name:       "add and remove the same tag",
+			ops:        []BulkOperation{{Op: BulkOpAddTags, Tags: []string{"new"}}, {Op: BulkOpRemoveTags, Tags: []string{"new"}}},
+			wantStatus: BulkStatusUnchanged,
+		},
+		{
+			name:        "shift",
+			ops:         []BulkOperation{{Op: BulkOpShift, Seconds: -10}},
+			wantStatus:  BulkStatusUpdated,
+			wantChanges: []string{RevisionFieldTimestamp, RevisionFieldEndTime},
+			wantAfter:   &BulkNoteState{VideoID: "vid-a", Timestamp: 0, EndTime: float(10), Type: models.ClipTypeNote, Tags: []string{"basics", "ml"}},
+		},
+		{
+			name:       "shift before the start",
+			ops:        []BulkOperation{{Op: BulkOpShift, Seconds: -11}},
+			wantStatus: BulkStatusInvalid,
+		},
+		{
+			name:       "shift past the end",
+			ops:        []BulkOperation{{Op: BulkOpShift, Seconds: 41}},
+			wantStatus: BulkStatusInvalid,
+		},
+		{
+			name:        "move",
+			ops:         []BulkOperation{{Op: BulkOpMove, VideoID: "vid-b"}},
+			wantStatus:  BulkStatusUpdated,
+			wantChanges: []string{RevisionFieldVideoID},
+			wantAfter:   &BulkNoteState{VideoID: "vid-b", Timestamp: 10, EndTime: float(20), Type: models.ClipTypeNote, Tags: []string{"basics", "ml"}},
+		},
+		{
+			name:       "move and shift past the target's end",
+			ops:        []BulkOperation{{Op: BulkOpMove, VideoID: "vid-b"}, {Op: BulkOpShift, Seconds: 10}},
+			wantStatus: BulkStatusInvalid,
+		},
+		{
+			name:        "move to a video of unknown duration",
+			ops:         []BulkOperation{{Op: BulkOpMove, VideoID: "vid-c"}, {Op: BulkOpShift, Seconds: 1000}},
+			wantStatus:  BulkStatusUpdated,
+			wantChanges: []string{RevisionFieldVideoID, RevisionFieldTimestamp, RevisionFieldEndTime},
+			wantAfter:   &BulkNoteState{VideoID: "vid-c", Timestamp: 1010, EndTime: float(1020), Type: models.ClipTypeNote, Tags: []string{"basics", "ml"}},
+		},
+		{
+			name:        "set type",
+			ops:         []BulkOperation{{Op: BulkOpSetType, Type: "highlight"}},
+			wantStatus:  BulkStatusUpdated,
+			wantChanges: []string{RevisionFieldType},
+			wantAfter:   &BulkNoteState{VideoID: "vid-a", Timestamp: 10, EndTime: float(20), Type: models.ClipTypeHighlight, Tags: []string{"basics", "ml"}},
+		},
+		{
+			name:       "delete",
+			ops:        []BulkOperation{{Op: BulkOpDelete}},
+			wantStatus: BulkStatusDeleted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := planBulkChange(note, tt.ops, durations)
+			if res.Status != tt.wantStatus {
+				t.Fatalf("status = %s (%s), want %s", res.Status, res.Error, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(res.Changes, tt.wantChanges) {
+				t.Errorf("changes = %v, want %v", res.Changes, tt.wantChanges)
+			}
+			if tt.wantAfter != nil && !reflect.DeepEqual(res.After, tt.wantAfter) {
+				t.Errorf("after = %+v, want %+v", res.After, tt.wantAfter)
+			}
+		})
+	}
+
+	if *note.EndTime != 20 || len(note.Tags) != 2 {
+		t.Errorf("planning changed the note: %+v", note)
+	}
+}
+
+func TestValidateBulkState(t *testing.T) {
+	end := 30.0
+	tests := []struct {
+		name     string
+		state    BulkNoteState
+		duration float64
+		wantErr  bool
+	}{
+		{"within the video", BulkNoteState{Timestamp: 10, EndTime: &end}, 30, false},
+		{"before the start", BulkNoteState{Timestamp: -1}, 30, true},
+		{"range past the end", BulkNoteState{Timestamp: 10, EndTime: &end}, 29, true},
+		{"instant past the end", BulkNoteState{Timestamp: 31}, 30, true},
+		{"unknown duration", BulkNoteState{Timestamp: 10000}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateBulkState(tt.state, tt.duration); (err != nil) != tt.wantErr {
+				t.Errorf("validateBulkState() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBulkApplyDryRun(t *testing.T) {
+	db := testDatabase(t)
+	userID := testUser(t, db)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	note := &models.Timestamp{
+		ID:         uuid.New(),
+		VideoID:    "vid-bulk",
+		UserID:     userID,
+		Timestamp:  10,
+		Type:       models.ClipTypeNote,
+		Importance: models.DefaultTimestampImportance,
+		Title:      "dry run",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if _, err := db.DB.NewInsert().Model(note).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed note: %v", err)
+	}
+
+	tagService := NewTagService(db)
+	bs := NewBulkService(db, tagService, NewRevisionService(db, tagService))
+	req := BulkTimestampsRequest{
+		IDs:        []string{note.ID.String()},
+		Operations: []BulkOperation{{Op: BulkOpAddTags, Tags: []string{"ml"}}, {Op: BulkOpShift, Seconds: 5}},
+		DryRun:     true,
+	}
+
+	result, err := bs.Apply(ctx, userID, []uuid.UUID{note.ID}, req)
+	if err != nil {
+		t.Fatalf("Apply error: %v", err)
+	}
+	if result.Applied || result.Counts[BulkStatusUpdated] != 1 {
+		t.Errorf("result = %+v, want one planned update that isn't applied", result)
+	}
+
+	var stored models.Timestamp
+	if err := db.DB.NewSelect().Model(&stored).Relation("Tags").Where("?TableAlias.id = ?", note.ID).Scan(ctx); err != nil {
+		t.Fatalf("failed to fetch note: %v", err)
+	}
+	if stored.Timestamp != 10 || len(stored.Tags) != 0 {
+		t.Errorf("dry run changed the note to %+v", stored)
+	}
+	revisions, err := db.DB.NewSelect().Model((*models.TimestampRevision)(nil)).Where("timestamp_id = ?", note.ID).Count(ctx)
+	if err != nil {
+		t.Fatalf("failed to count revisions: %v", err)
+	}
+	if revisions != 0 {
+		t.Errorf("dry run recorded %d revisions", revisions)
+	}
+}
@@ -0,0 +1,381 @@
+package timestamps
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/aicache"
+	authhandlers "github.com/shubhamku044/ytclipper/internal/handlers/auth"
+	"github.com/shubhamku044/ytclipper/internal/llm"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
+	"github.com/shubhamku044/ytclipper/internal/services"
+)
+
+func (t *TimestampsHandlers) CreateChatSession(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateChatSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	session, err := t.chatService.CreateSession(c.Request.Context(), userID, req.VideoID, req.Title)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to create chat session", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"session": session,
+	})
+}
+
+func (t *TimestampsHandlers) ListChatSessions(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := t.chatService.ListSessions(c.Request.Context(), userID, c.Query("video_id"))
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch chat sessions", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+func (t *TimestampsHandlers) GetChatSession(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	session, ok := t.loadChatSession(c, userID)
+	if !ok {
+		return
+	}
+
+	messages, err := t.chatService.GetMessages(c.Request.Context(), session.ID, 0)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch chat messages", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	session.Messages = messages
+
+	middleware.RespondWithOK(c, gin.H{
+		"session": session,
+	})
+}
+
+func (t *TimestampsHandlers) RenameChatSession(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID, ok := parseChatSessionID(c)
+	if !ok {
+		return
+	}
+
+	var req RenameChatSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	err := t.chatService.RenameSession(c.Request.Context(), userID, sessionID, req.Title)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "CHAT_SESSION_NOT_FOUND", "Chat session not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to rename chat session", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"message": "Chat session renamed successfully",
+	})
+}
+
+func (t *TimestampsHandlers) DeleteChatSession(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	sessionID, ok := parseChatSessionID(c)
+	if !ok {
+		return
+	}
+
+	err := t.chatService.DeleteSession(c.Request.Context(), userID, sessionID)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "CHAT_SESSION_NOT_FOUND", "Chat session not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to delete chat session", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"message": "Chat session deleted successfully",
+	})
+}
+
+// SendChatMessage continues a session with a new question. With ?stream=true
+// the answer is streamed as SSE like AnswerQuestion.
+func (t *TimestampsHandlers) SendChatMessage(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req ChatMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	session, ok := t.loadChatSession(c, userID)
+	if !ok {
+		return
+	}
+
+	ctx := services.WithTokenUser(c.Request.Context(), userID)
+	started := time.Now()
+
+	canAsk, err := t.featureUsageService.CheckUsageLimit(ctx, userID, "ai_questions")
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "USAGE_CHECK_ERROR", "Failed to check usage limit", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !canAsk {
+		middleware.RespondWithError(c, http.StatusForbidden, "USAGE_LIMIT_EXCEEDED", "AI question limit exceeded for your current plan", gin.H{
+			"feature": "ai_questions",
+		})
+		return
+	}
+	if !t.requireTokenBudget(c, userID) {
+		return
+	}
+
+	history, err := t.chatService.GetMessages(ctx, session.ID, session.SummarizedCount)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch chat messages", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	used := prompts.Versions{}
+	history, err = t.chatService.CompactHistory(ctx, session, history, used)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "AI_ERROR", "Failed to summarize chat history", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	queryEmbedding, err := t.aiService.GenerateEmbedding(ctx, t.chatService.RetrievalQuery(history, req.Message))
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "EMBEDDING_ERROR", "Failed to generate question embedding", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	qc, err := t.buildQuestionContext(ctx, userID, QuestionRequest{
+		Question: req.Message,
+		VideoID:  session.VideoID,
+		Context:  req.Context,
+	}, queryEmbedding)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch timestamps", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	messages, err := t.chatService.BuildMessages(ctx, session, history, qc.Context, req.Message, used)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "PROMPT_ERROR", "Failed to build prompt", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if c.Query("stream") == "true" {
+		t.streamChatAnswer(c, userID, session, req.Message, qc, messages, started, used)
+		return
+	}
+
+	completion, err := t.aiService.GenerateChatCompletion(ctx, aicache.FeatureChat, messages, 1000)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "AI_ERROR", "Failed to generate answer", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	answer := completion.Content
+	citations := markCitations(answer, qc.Citations)
+	saved, err := t.chatService.AppendExchange(ctx, session, req.Message, answer, citations, completion.Usage)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to save chat messages", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	err = t.featureUsageService.IncrementUsage(ctx, userID, "ai_questions")
+	if err != nil {
+		log.Printf("Warning: Failed to increment AI question usage: %v", err)
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"session":    session,
+		"messages":   saved,
+		"answer":     answer,
+		"citations":  citations,
+		"insight_id": t.recordAnswerInsight(userID, session.VideoID, req.Message, answer, citations, started, &session.ID, used),
+	})
+}
+
+func (t *TimestampsHandlers) streamChatAnswer(c *gin.Context, userID uuid.UUID, session *models.ChatSession, question string, qc *questionContext, messages []llm.Message, started time.Time, used prompts.Versions) {
+	ctx := c.Request.Context()
+	startEventStream(c)
+
+	index := 0
+	completion, err := t.aiService.StreamChatCompletion(ctx, aicache.FeatureChat, messages, func(delta string) error {
+		err := writeEvent(c, "chunk", gin.H{
+			"content": delta,
+			"index":   index,
+		})
+		index++
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("Chat stream for session %s cancelled by client", session.ID)
+			return
+		}
+		if writeErr := writeEvent(c, "error", gin.H{"error": err.Error()}); writeErr != nil {
+			log.Printf("Failed to send chat stream error: %v", writeErr)
+		}
+		return
+	}
+
+	citations := markCitations(completion.Content, qc.Citations)
+	saved, err := t.chatService.AppendExchange(context.Background(), session, question, completion.Content, citations, completion.Usage)
+	if err != nil {
+		if writeErr := writeEvent(c, "error", gin.H{"error": err.Error()}); writeErr != nil {
+			log.Printf("Failed to send chat stream error: %v", writeErr)
+		}
+		return
+	}
+
+	err = t.featureUsageService.IncrementUsage(context.Background(), userID, "ai_questions")
+	if err != nil {
+		log.Printf("Warning: Failed to increment AI question usage: %v", err)
+	}
+
+	if err := writeEvent(c, "complete", gin.H{
+		"session":       session,
+		"messages":      saved,
+		"answer":        completion.Content,
+		"citations":     citations,
+		"insight_id":    t.recordAnswerInsight(userID, session.VideoID, question, completion.Content, citations, started, &session.ID, used),
+		"finish_reason": completion.FinishReason,
+		"usage":         completion.Usage,
+	}); err != nil {
+		log.Printf("Failed to send chat complete event: %v", err)
+	}
+}
+
+func (t *TimestampsHandlers) loadChatSession(c *gin.Context, userID uuid.UUID) (*models.ChatSession, bool) {
+	sessionID, ok := parseChatSessionID(c)
+	if !ok {
+		return nil, false
+	}
+
+	session, err := t.chatService.GetSession(c.Request.Context(), userID, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		middleware.RespondWithError(c, http.StatusNotFound, "CHAT_SESSION_NOT_FOUND", "Chat session not found", nil)
+		return nil, false
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch chat session", gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+
+	return session, true
+}
+
+func parseChatSessionID(c *gin.Context) (uuid.UUID, bool) {
+	sessionID, err := uuid.Parse(c.Param("sessionId"))
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_SESSION_ID", "Invalid chat session ID format", gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return sessionID, true
+}
+
+// requireUserID resolves the authenticated user's UUID, responding with an error if it is missing or malformed
+func requireUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDStr, exists := authhandlers.GetUserID(c)
+	if !exists {
+		middleware.RespondWithError(c, http.StatusUnauthorized, "NO_USER_ID", "User ID not found", nil)
+		return uuid.Nil, false
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID format", gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
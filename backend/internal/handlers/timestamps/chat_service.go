@@ -0,0 +1,352 @@
+package timestamps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/aicache"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/llm"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// chatHistoryTokenBudget bounds the verbatim history sent with each turn
+	chatHistoryTokenBudget = 3000
+	// chatSummaryMaxTokens bounds the rolling summary of older turns
+	chatSummaryMaxTokens = 400
+	chatTitleMaxLength   = 80
+)
+
+var errNotFound = errors.New("not found")
+
+type ChatService struct {
+	db        *database.Database
+	aiService *AIService
+	prompts   *prompts.Registry
+}
+
+func NewChatService(db *database.Database, aiService *AIService, registry *prompts.Registry) *ChatService {
+	return &ChatService{
+		db:        db,
+		aiService: aiService,
+		prompts:   registry,
+	}
+}
+
+func (cs *ChatService) CreateSession(ctx context.Context, userID uuid.UUID, videoID, title string) (*models.ChatSession, error) {
+	session := &models.ChatSession{
+		UserID:  userID,
+		VideoID: videoID,
+		Title:   strings.TrimSpace(title),
+	}
+	if session.Title == "" {
+		session.Title = "New conversation"
+	}
+
+	if _, err := cs.db.DB.NewInsert().Model(session).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create chat session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (cs *ChatService) GetSession(ctx context.Context, userID, sessionID uuid.UUID) (*models.ChatSession, error) {
+	var session models.ChatSession
+	err := cs.db.DB.NewSelect().
+		Model(&session).
+		Where("id = ? AND user_id = ? AND deleted_at IS NULL", sessionID, userID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (cs *ChatService) ListSessions(ctx context.Context, userID uuid.UUID, videoID string) ([]models.ChatSession, error) {
+	var sessions []models.ChatSession
+	query := cs.db.DB.NewSelect().
+		Model(&sessions).
+		Where("user_id = ? AND deleted_at IS NULL", userID)
+
+	if videoID != "" {
+		query = query.Where("video_id = ?", videoID)
+	}
+
+	err := query.
+		OrderExpr("COALESCE(last_message_at, created_at) DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chat sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+func (cs *ChatService) GetMessages(ctx context.Context, sessionID uuid.UUID, fromPosition int) ([]models.ChatMessage, error) {
+	var messages []models.ChatMessage
+	err := cs.db.DB.NewSelect().
+		Model(&messages).
+		Where("session_id = ? AND position >= ?", sessionID, fromPosition).
+		Order("position ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch chat messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (cs *ChatService) RenameSession(ctx context.Context, userID, sessionID uuid.UUID, title string) error {
+	result, err := cs.db.DB.NewUpdate().
+		Model((*models.ChatSession)(nil)).
+		Set("title = ?", title).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ? AND user_id = ? AND deleted_at IS NULL", sessionID, userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to rename chat session: %w", err)
+	}
+
+	return requireRowsAffected(result)
+}
+
+func (cs *ChatService) DeleteSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	result, err := cs.db.DB.NewUpdate().
+		Model((*models.ChatSession)(nil)).
+		Set("deleted_at = ?", time.Now().UTC()).
+		Where("id = ? AND user_id = ? AND deleted_at IS NULL", sessionID, userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+
+	return requireRowsAffected(result)
+}
+
+// CompactHistory keeps the newest messages that fit chatHistoryTokenBudget and
+// folds everything older into the session's rolling summary. The version of
+// the summary prompt is added to used when a summary is written.
+func (cs *ChatService) CompactHistory(ctx context.Context, session *models.ChatSession, history []models.ChatMessage, used prompts.Versions) ([]models.ChatMessage, error) {
+	kept := 0
+	keepFrom := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		tokens := history[i].TokenCount
+		if tokens == 0 {
+			tokens = llm.EstimateTokens(history[i].Content)
+		}
+		if kept+tokens > chatHistoryTokenBudget {
+			break
+		}
+		kept += tokens
+		keepFrom = i
+	}
+
+	// Never split a question from its answer
+	if keepFrom < len(history) && history[keepFrom].Role == models.ChatMessageRoleAssistant {
+		keepFrom++
+	}
+
+	if keepFrom == 0 {
+		return history, nil
+	}
+
+	overflow := history[:keepFrom]
+	summary, err := cs.summarizeHistory(ctx, session, overflow, used)
+	if err != nil {
+		return nil, err
+	}
+
+	summarizedCount := overflow[len(overflow)-1].Position + 1
+	_, err = cs.db.DB.NewUpdate().
+		Model((*models.ChatSession)(nil)).
+		Set("summary = ?", summary).
+		Set("summarized_count = ?", summarizedCount).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ?", session.ID).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save chat summary: %w", err)
+	}
+
+	session.Summary = summary
+	session.SummarizedCount = summarizedCount
+
+	return history[keepFrom:], nil
+}
+
+// chatSummaryPromptData are the variables of the chat summary prompt
+type chatSummaryPromptData struct {
+	Summary  string // Summary of the turns folded in earlier, may be empty
+	Messages string // Turns to fold into the summary
+}
+
+func (cs *ChatService) summarizeHistory(ctx context.Context, session *models.ChatSession, messages []models.ChatMessage, used prompts.Versions) (string, error) {
+	var transcript strings.Builder
+	for _, message := range messages {
+		transcript.WriteString(fmt.Sprintf("%s: %s\n\n", strings.ToUpper(string(message.Role)), message.Content))
+	}
+
+	prompt, err := cs.prompts.Render(ctx, prompts.ChatSummary, session.UserID, chatSummaryPromptData{
+		Summary:  session.Summary,
+		Messages: transcript.String(),
+	}, used)
+	if err != nil {
+		return "", err
+	}
+
+	summary, err := cs.aiService.GenerateChatCompletion(ctx, aicache.FeatureChat, llm.UserMessage(prompt), chatSummaryMaxTokens)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize chat history: %w", err)
+	}
+
+	return strings.TrimSpace(summary.Content), nil
+}
+
+// chatPromptData are the variables of the chat system prompt
+type chatPromptData struct {
+	Context string // Labeled notes and transcript segments
+}
+
+// BuildMessages assembles the prompt: retrieved context and the rolling
+// summary as system messages, then the recent turns and the new question.
+// The version of the system prompt is added to used.
+func (cs *ChatService) BuildMessages(ctx context.Context, session *models.ChatSession, history []models.ChatMessage, retrievedContext, question string, used prompts.Versions) ([]llm.Message, error) {
+	system, err := cs.prompts.Render(ctx, prompts.Chat, session.UserID, chatPromptData{Context: retrievedContext}, used)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []llm.Message{{Role: "system", Content: system}}
+
+	if session.Summary != "" {
+		messages = append(messages, llm.Message{
+			Role:    "system",
+			Content: "Summary of the earlier conversation:\n" + session.Summary,
+		})
+	}
+
+	for _, message := range history {
+		messages = append(messages, llm.Message{
+			Role:    string(message.Role),
+			Content: message.Content,
+		})
+	}
+
+	return append(messages, llm.Message{Role: "user", Content: question}), nil
+}
+
+// RetrievalQuery widens a follow-up with the previous question so that
+// "what did he mean by that?" still retrieves the right segments
+func (cs *ChatService) RetrievalQuery(history []models.ChatMessage, question string) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == models.ChatMessageRoleUser {
+			return history[i].Content + "\n" + question
+		}
+	}
+	return question
+}
+
+// AppendExchange stores a question and its answer as the next two messages
+func (cs *ChatService) AppendExchange(ctx context.Context, session *models.ChatSession, question, answer string, citations []Citation, usage llm.Usage) ([]models.ChatMessage, error) {
+	citationsJSON, err := json.Marshal(citations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode citations: %w", err)
+	}
+
+	now := time.Now().UTC()
+	messages := []models.ChatMessage{
+		{
+			SessionID:  session.ID,
+			Role:       models.ChatMessageRoleUser,
+			Content:    question,
+			TokenCount: llm.EstimateTokens(question),
+			CreatedAt:  now,
+		},
+		{
+			SessionID:  session.ID,
+			Role:       models.ChatMessageRoleAssistant,
+			Content:    answer,
+			Citations:  citationsJSON,
+			TokenCount: usage.CompletionTokens,
+			CreatedAt:  now,
+		},
+	}
+	if messages[1].TokenCount == 0 {
+		messages[1].TokenCount = llm.EstimateTokens(answer)
+	}
+
+	err = cs.db.RunInTransaction(ctx, func(ctx context.Context, tx bun.Tx) error {
+		// Lock the session row so concurrent turns get distinct positions
+		var current models.ChatSession
+		if err := tx.NewSelect().
+			Model(&current).
+			Where("id = ?", session.ID).
+			For("UPDATE").
+			Scan(ctx); err != nil {
+			return err
+		}
+
+		for i := range messages {
+			messages[i].Position = current.MessageCount + i
+		}
+
+		if _, err := tx.NewInsert().Model(&messages).Exec(ctx); err != nil {
+			return err
+		}
+
+		title := current.Title
+		if current.MessageCount == 0 && title == "New conversation" {
+			title = chatTitleFromQuestion(question)
+		}
+
+		_, err := tx.NewUpdate().
+			Model((*models.ChatSession)(nil)).
+			Set("message_count = message_count + ?", len(messages)).
+			Set("last_message_at = ?", now).
+			Set("title = ?", title).
+			Set("updated_at = ?", now).
+			Where("id = ?", session.ID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		session.Title = title
+		session.MessageCount = current.MessageCount + len(messages)
+		session.LastMessageAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save chat messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+func chatTitleFromQuestion(question string) string {
+	title := strings.Join(strings.Fields(question), " ")
+	if len([]rune(title)) > chatTitleMaxLength {
+		title = string([]rune(title)[:chatTitleMaxLength-3]) + "..."
+	}
+	return title
+}
+
+func requireRowsAffected(result interface{ RowsAffected() (int64, error) }) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errNotFound
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package timestamps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/database/dbtest"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// testDatabase connects to the migrated test database with the models the
+// timestamp handlers use registered. Tests using it are skipped when
+// TEST_DATABASE_URL is not set.
+func testDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	db := dbtest.Open(t)
+	db.DB.RegisterModel(
+		(*models.TimestampTag)(nil),
+		(*models.Tag)(nil),
+		(*models.Timestamp)(nil),
+	)
+	return db
+}
+
+// testUser creates a user whose data is deleted when the test ends
+func testUser(t *testing.T, db *database.Database) uuid.UUID {
+	t.Helper()
+
+	userID := uuid.New()
+	ctx := context.Background()
+	_, err := db.DB.ExecContext(ctx, "INSERT INTO users (id, email, name) VALUES (?, ?, 'Test')", userID, userID.String()+"@example.com")
+	if err != nil {
+		t.Fatalf("failed to create test user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.DB.ExecContext(ctx, "DELETE FROM users WHERE id = ?", userID)
+	})
+	return userID
+}
@@ -2,14 +2,15 @@ package timestamps
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	authhandlers "github.com/shubhamku044/ytclipper/internal/handlers/auth"
+	"github.com/shubhamku044/ytclipper/internal/jobs"
 	"github.com/shubhamku044/ytclipper/internal/middleware"
 	"github.com/shubhamku044/ytclipper/internal/models"
 )
@@ -29,81 +30,22 @@ func (t *TimestampsHandlers) BackfillEmbeddingsAsync(c *gin.Context) {
 		return
 	}
 
-	go func() {
-		log.Printf("Starting async embedding backfill for user %s", userID)
-		t.processEmbeddingsBackground(userID.String())
-	}()
-
-	middleware.RespondWithOK(c, gin.H{
-		"message": "Embedding generation started in background",
-		"user_id": userID.String(),
+	job, err := t.jobQueue.Enqueue(c.Request.Context(), JobBackfillEmbeddings, backfillEmbeddingsPayload{}, jobs.EnqueueOptions{
+		UserID:    &userID,
+		DedupeKey: fmt.Sprintf("%s:%s", JobBackfillEmbeddings, userID),
 	})
-}
-
-func (t *TimestampsHandlers) processEmbeddingsBackground(userID string) {
-	ctx := context.Background()
-	batchSize := 5
-
-	userUUID, err := uuid.Parse(userID)
-	if err != nil {
-		log.Printf("Error parsing user ID for background processing: %v", err)
-		return
-	}
-
-	var timestamps []models.Timestamp
-	err = t.db.DB.NewSelect().
-		Model(&timestamps).
-		Where("user_id = ? AND deleted_at IS NULL", userUUID).
-		Where("embedding IS NULL").
-		Scan(ctx)
-
 	if err != nil {
-		log.Printf("Error fetching timestamps for background processing: %v", err)
+		middleware.RespondWithError(c, http.StatusInternalServerError, "JOB_ERROR", "Failed to queue embedding backfill", gin.H{
+			"error": err.Error(),
+		})
 		return
 	}
 
-	processed := 0
-	for i := 0; i < len(timestamps); i += batchSize {
-		end := i + batchSize
-		if end > len(timestamps) {
-			end = len(timestamps)
-		}
-
-		batch := timestamps[i:end]
-
-		for _, ts := range batch {
-			var tagNames []string
-			for _, tag := range ts.Tags {
-				tagNames = append(tagNames, tag.Name)
-			}
-			embeddingText := t.aiService.CreateEmbeddingText(ts.Title, ts.Note, tagNames)
-			if embeddingText == "" {
-				continue
-			}
-
-			embedding, err := t.aiService.GenerateEmbedding(embeddingText)
-			if err != nil {
-				time.Sleep(5 * time.Second)
-				continue
-			}
-
-			_, err = t.db.DB.NewUpdate().
-				Model((*models.Timestamp)(nil)).
-				Set("embedding = ?", embedding).
-				Set("updated_at = ?", time.Now().UTC()).
-				Where("id = ?", ts.ID).
-				Exec(ctx)
-
-			if err != nil {
-				log.Printf("Failed to update timestamp %s: %v", ts.ID, err)
-				continue
-			}
-
-			processed++
-		}
-
-		time.Sleep(2 * time.Second)
-	}
+	middleware.RespondWithOK(c, gin.H{
+		"message": "Embedding generation queued",
+		"user_id": userID.String(),
+		"job":     job,
+	})
 }
 
 func (t *TimestampsHandlers) GetEmbeddingStatus(c *gin.Context) {
@@ -137,7 +79,7 @@ func (t *TimestampsHandlers) GetEmbeddingStatus(c *gin.Context) {
 	withEmbeddingsCount, err := t.db.DB.NewSelect().
 		Model((*models.Timestamp)(nil)).
 		Where("user_id = ? AND deleted_at IS NULL", userID).
-		Where("embedding IS NOT NULL").
+		Where("EXISTS (SELECT 1 FROM timestamp_embeddings AS tse WHERE tse.timestamp_id = ?TableAlias.id AND tse.model = ?)", t.aiService.EmbeddingModel()).
 		Count(ctx)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to count timestamps with embeddings", gin.H{
@@ -153,7 +95,26 @@ func (t *TimestampsHandlers) GetEmbeddingStatus(c *gin.Context) {
 		completionPercentage = float64(withEmbeddingsCount) / float64(totalCount) * 100
 	}
 
+	backfillJob, err := t.jobQueue.Latest(ctx, userID, JobBackfillEmbeddings)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch backfill job", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	pendingJobs, err := t.jobQueue.PendingCount(ctx, userID, embeddingJobTypes...)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to count pending jobs", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	middleware.RespondWithOK(c, gin.H{
+		"embedding_model":       t.aiService.EmbeddingModel(),
+		"backfill_job":          backfillJob,
+		"pending_jobs":          pendingJobs,
 		"total_timestamps":      totalCount,
 		"with_embeddings":       withEmbeddingsCount,
 		"without_embeddings":    withoutEmbeddingsCount,
@@ -170,6 +131,14 @@ func (t *TimestampsHandlers) ProcessMissingEmbeddingsForUser(c *gin.Context) {
 		return
 	}
 
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_USER_ID", "Invalid user ID format", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	batchSize := 10
 	if param := c.Query("batch_size"); param != "" {
 		if parsed, err := strconv.Atoi(param); err == nil && parsed > 0 && parsed <= 50 {
@@ -177,20 +146,31 @@ func (t *TimestampsHandlers) ProcessMissingEmbeddingsForUser(c *gin.Context) {
 		}
 	}
 
-	go func() {
-		if err := t.aiService.ProcessMissingEmbeddingsForUser(userIDStr, batchSize); err != nil {
-			log.Printf("Failed to process missing embeddings for user %s: %v", userIDStr, err)
-		}
-	}()
+	job, err := t.jobQueue.Enqueue(c.Request.Context(), JobBackfillEmbeddings, backfillEmbeddingsPayload{Limit: batchSize}, jobs.EnqueueOptions{
+		UserID:    &userID,
+		DedupeKey: fmt.Sprintf("%s:%s", JobBackfillEmbeddings, userID),
+	})
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "JOB_ERROR", "Failed to queue embedding processing", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	middleware.RespondWithOK(c, gin.H{
-		"message":    "Processing missing embeddings in background",
+		"message":    "Processing missing embeddings queued",
 		"user_id":    userIDStr,
 		"batch_size": batchSize,
+		"job":        job,
 	})
 }
 
 func (t *TimestampsHandlers) ProcessAllMissingEmbeddings(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
 	batchSize := 20
 	if param := c.Query("batch_size"); param != "" {
 		if parsed, err := strconv.Atoi(param); err == nil && parsed > 0 && parsed <= 100 {
@@ -198,14 +178,83 @@ func (t *TimestampsHandlers) ProcessAllMissingEmbeddings(c *gin.Context) {
 		}
 	}
 
-	go func() {
-		if err := t.aiService.ProcessAllMissingEmbeddings(batchSize); err != nil {
-			log.Printf("Failed to process all missing embeddings: %v", err)
-		}
-	}()
+	job, err := t.jobQueue.Enqueue(c.Request.Context(), JobBackfillEmbeddings, backfillEmbeddingsPayload{Limit: batchSize, AllUsers: true}, jobs.EnqueueOptions{
+		UserID:    &userID,
+		DedupeKey: JobBackfillEmbeddings + ":all",
+	})
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "JOB_ERROR", "Failed to queue embedding processing", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	middleware.RespondWithOK(c, gin.H{
-		"message":    "Processing all missing embeddings in background",
+		"message":    "Processing all missing embeddings queued",
 		"batch_size": batchSize,
+		"job":        job,
+	})
+}
+
+// ReembedEmbeddings queues re-embedding of the user's notes and transcript
+// chunks with another model, or of every user's with all_users, which only
+// operators may set. Search keeps using the active model's vectors until
+// OPENAI_EMBEDDING_MODEL is switched to the new model after the job succeeds.
+func (t *TimestampsHandlers) ReembedEmbeddings(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req ReembedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if req.AllUsers && !slices.Contains(t.operators, userID) {
+		middleware.RespondWithError(c, http.StatusForbidden, "OPERATOR_ONLY", "Only operators can re-embed every user's notes", nil)
+		return
+	}
+	if !req.AllUsers && !t.requireTokenBudget(c, userID) {
+		return
+	}
+
+	if _, err := t.aiService.embedderFor(req.Model); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_MODEL", "Embedding model is not available", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	payload := reembedPayload{
+		Model:    req.Model,
+		From:     t.aiService.EmbeddingModel(),
+		AllUsers: req.AllUsers,
+	}
+	scope := &userID
+	if req.AllUsers {
+		scope = nil
+	}
+
+	job, err := t.jobQueue.Enqueue(c.Request.Context(), JobReembed, payload, jobs.EnqueueOptions{
+		UserID:    &userID,
+		DedupeKey: reembedKey(req.Model, scope),
+	})
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "JOB_ERROR", "Failed to queue re-embedding", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"message":      "Re-embedding queued",
+		"model":        req.Model,
+		"active_model": payload.From,
+		"all_users":    req.AllUsers,
+		"job":          job,
 	})
 }
@@ -2,6 +2,9 @@ package timestamps
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -9,37 +12,91 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	zlog "github.com/rs/zerolog/log"
 	"github.com/shubhamku044/ytclipper/internal/config"
 	"github.com/shubhamku044/ytclipper/internal/database"
 	authhandlers "github.com/shubhamku044/ytclipper/internal/handlers/auth"
 	"github.com/shubhamku044/ytclipper/internal/handlers/videos"
+	"github.com/shubhamku044/ytclipper/internal/jobs"
+	"github.com/shubhamku044/ytclipper/internal/markdown"
 	"github.com/shubhamku044/ytclipper/internal/middleware"
 	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
 	"github.com/shubhamku044/ytclipper/internal/services"
+	"github.com/shubhamku044/ytclipper/internal/storage"
+	"github.com/uptrace/bun"
 )
 
 type TimestampsHandlers struct {
 	db                  *database.Database
 	aiService           *AIService
 	tagService          *TagService
+	revisionService     *RevisionService
+	bulkService         *BulkService
+	attachmentService   *AttachmentService
+	noteRenderer        *markdown.Renderer
 	videoHandlers       *videos.VideoHandlers
 	featureUsageService *services.FeatureUsageService
+	tokenUsageService   *services.TokenUsageService
+	chatService         *ChatService
+	insightService      *InsightService
+	prompts             *prompts.Registry
+	searchService       *SearchService
+	transcriptService   *TranscriptService
+	jobQueue            *jobs.Queue
+	tagCleanupInterval  time.Duration
+	// operators may queue maintenance jobs over every user's data
+	operators []uuid.UUID
 }
 
-func NewTimestampsHandlers(db *database.Database, openaiAPIKey *config.OpenAIConfig) *TimestampsHandlers {
-	return &TimestampsHandlers{
+func NewTimestampsHandlers(db *database.Database, openaiConfig *config.OpenAIConfig, aiCacheConfig *config.AICacheConfig, promptConfig *config.PromptConfig, transcriptConfig *config.TranscriptConfig, storageConfig *config.StorageConfig, jobsConfig *config.JobsConfig, authConfig *config.AuthConfig, jobQueue *jobs.Queue) *TimestampsHandlers {
+	operators := make([]uuid.UUID, 0, len(authConfig.OperatorUserIDs))
+	for _, id := range authConfig.OperatorUserIDs {
+		operatorID, err := uuid.Parse(id)
+		if err != nil {
+			zlog.Fatal().Err(err).Str("user_id", id).Msg("Invalid operator user ID")
+		}
+		operators = append(operators, operatorID)
+	}
+	aiService := NewAIService(openaiConfig, aiCacheConfig, db)
+	registry, err := prompts.NewRegistry(db, promptConfig)
+	if err != nil {
+		zlog.Fatal().Err(err).Msg("Failed to load prompt templates")
+	}
+	blobStore, err := storage.NewBlobStore(storageConfig)
+	if err != nil {
+		zlog.Fatal().Err(err).Str("driver", storageConfig.Driver).Msg("Failed to configure blob storage")
+	}
+	transcriptService := NewTranscriptService(db, transcriptConfig, aiService.EmbeddingModel())
+	tagService := NewTagService(db)
+	revisionService := NewRevisionService(db, tagService)
+	t := &TimestampsHandlers{
 		db:                  db,
-		aiService:           NewAIService(openaiAPIKey, db),
-		tagService:          NewTagService(db),
+		aiService:           aiService,
+		tagService:          tagService,
+		revisionService:     revisionService,
+		bulkService:         NewBulkService(db, tagService, revisionService),
+		attachmentService:   NewAttachmentService(db, blobStore, storageConfig.MaxAttachmentBytes),
+		noteRenderer:        markdown.NewRenderer(attachmentURLPrefix),
 		videoHandlers:       videos.NewVideoHandlers(db),
 		featureUsageService: services.NewFeatureUsageService(db),
-	}
+		tokenUsageService:   aiService.TokenUsage(),
+		chatService:         NewChatService(db, aiService, registry),
+		insightService:      NewInsightService(db),
+		prompts:             registry,
+		searchService:       NewSearchService(db, transcriptService.ChunkStrategy(), aiService.EmbeddingModel()),
+		transcriptService:   transcriptService,
+		jobQueue:            jobQueue,
+		tagCleanupInterval:  jobsConfig.TagCleanupInterval,
+		operators:           operators,
+	}
+	t.registerJobs(jobQueue)
+	return t
 }
 
 func (t *TimestampsHandlers) GetAllTags(c *gin.Context) {
-	userID, exists := authhandlers.GetUserID(c)
-	if !exists {
-		middleware.RespondWithError(c, http.StatusUnauthorized, "NO_USER_ID", "User ID not found", nil)
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
@@ -75,9 +132,8 @@ func (t *TimestampsHandlers) SearchTags(c *gin.Context) {
 		req.Limit = 10
 	}
 
-	userID, exists := authhandlers.GetUserID(c)
-	if !exists {
-		middleware.RespondWithError(c, http.StatusUnauthorized, "NO_USER_ID", "User ID not found", nil)
+	userID, ok := requireUserID(c)
+	if !ok {
 		return
 	}
 
@@ -117,113 +173,165 @@ func (t *TimestampsHandlers) CreateTimestamp(c *gin.Context) {
 		return
 	}
 
+	timestamps, ok := t.createTimestamps(c, userID, req.VideoID, []CreateTimestampRequest{req})
+	if !ok {
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"timestamp": timestamps[0],
+		"message":   "Timestamp created successfully",
+	})
+}
+
+// createTimestamps creates notes with their tags on one video in a single
+// transaction, adding the video if needed and checking that the user's note
+// limit covers all of them. Failures are written to c.
+func (t *TimestampsHandlers) createTimestamps(c *gin.Context, userID uuid.UUID, videoID string, reqs []CreateTimestampRequest) ([]models.Timestamp, bool) {
 	ctx := context.Background()
 
+	for _, req := range reqs {
+		if err := validateNoteRange(req.Timestamp, req.EndTime); err != nil {
+			middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_RANGE", "Invalid note range", gin.H{
+				"error": err.Error(),
+			})
+			return nil, false
+		}
+	}
+
 	tx, err := t.db.DB.BeginTx(ctx, nil)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_TRANSACTION_ERROR", "Failed to start database transaction", gin.H{
 			"error": err.Error(),
 		})
-		return
+		return nil, false
 	}
 	defer tx.Rollback()
 
-	videoExists, err := t.videoHandlers.VideoExists(ctx, userID, req.VideoID)
+	videoExists, err := t.videoHandlers.VideoExists(ctx, userID, videoID)
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "VIDEO_CHECK_ERROR", "Failed to check video existence", gin.H{
 			"error": err.Error(),
 		})
-		return
+		return nil, false
 	}
 
 	if !videoExists {
-		placeholderTitle := "Video " + req.VideoID
-		placeholderURL := "https://youtube.com/watch?v=" + req.VideoID
+		placeholderTitle := "Video " + videoID
+		placeholderURL := "https://youtube.com/watch?v=" + videoID
 
-		if err := t.videoHandlers.CreateVideoIfNotExists(ctx, userID, req.VideoID, placeholderURL, placeholderTitle); err != nil {
+		if err := t.videoHandlers.CreateVideoIfNotExists(ctx, userID, videoID, placeholderURL, placeholderTitle); err != nil {
 			middleware.RespondWithError(c, http.StatusInternalServerError, "VIDEO_ERROR", "Failed to create video", gin.H{
 				"error": err.Error(),
 			})
-			return
+			return nil, false
 		}
 	}
 
-	// Check note usage limit before creating timestamp
-	canAdd, err := t.featureUsageService.CheckUsageLimit(ctx, userID, "notes")
+	// Check note usage limit before creating timestamps
+	canAdd, err := t.featureUsageService.CheckUsageAvailable(ctx, userID, "notes", len(reqs))
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "USAGE_CHECK_ERROR", "Failed to check usage limit", gin.H{
 			"error": err.Error(),
 		})
-		return
+		return nil, false
 	}
 	if !canAdd {
 		middleware.RespondWithError(c, http.StatusForbidden, "USAGE_LIMIT_EXCEEDED", "Note limit exceeded for your current plan", gin.H{
 			"feature": "notes",
 		})
-		return
-	}
-
-	timestamp := models.Timestamp{
-		UserID:    userID,
-		VideoID:   req.VideoID,
-		Title:     req.Title,
-		Note:      req.Note,
-		Timestamp: req.Timestamp,
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-	}
-
-	if err := t.db.CreateWithTx(ctx, tx, &timestamp); err != nil {
-		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to create timestamp", gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
+		return nil, false
+	}
+
+	timestamps := make([]models.Timestamp, 0, len(reqs))
+	for _, req := range reqs {
+		timestamp := models.Timestamp{
+			UserID:     userID,
+			VideoID:    videoID,
+			Title:      req.Title,
+			Note:       markdown.Sanitize(req.Note),
+			Timestamp:  req.Timestamp,
+			EndTime:    req.EndTime,
+			Type:       models.ClipTypeNote,
+			Importance: models.DefaultTimestampImportance,
+			CreatedAt:  time.Now().UTC(),
+			UpdatedAt:  time.Now().UTC(),
+		}
+		if req.Type != "" {
+			timestamp.Type = models.ClipType(req.Type)
+		}
+		if req.Importance != 0 {
+			timestamp.Importance = req.Importance
+		}
 
-	if len(req.Tags) > 0 {
-		tagIDs, err := t.tagService.ProcessTagsForTimestampWithTx(ctx, tx, req.Tags)
-		if err != nil {
-			middleware.RespondWithError(c, http.StatusInternalServerError, "TAG_ERROR", "Failed to process tags", gin.H{
+		if err := t.db.CreateWithTx(ctx, tx, &timestamp); err != nil {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to create timestamp", gin.H{
 				"error": err.Error(),
 			})
-			return
+			return nil, false
 		}
 
-		if err := t.tagService.CreateTimestampTagRelationsWithTx(ctx, tx, timestamp.ID.String(), tagIDs); err != nil {
-			middleware.RespondWithError(c, http.StatusInternalServerError, "TAG_RELATION_ERROR", "Failed to create tag relations", gin.H{
+		if len(req.Tags) > 0 {
+			tagIDs, err := t.tagService.ProcessTagsForTimestampWithTx(ctx, tx, userID, req.Tags)
+			if err != nil {
+				respondTagError(c, err)
+				return nil, false
+			}
+
+			if err := t.tagService.CreateTimestampTagRelationsWithTx(ctx, tx, timestamp.ID.String(), tagIDs); err != nil {
+				middleware.RespondWithError(c, http.StatusInternalServerError, "TAG_RELATION_ERROR", "Failed to create tag relations", gin.H{
+					"error": err.Error(),
+				})
+				return nil, false
+			}
+		}
+
+		if _, err := t.revisionService.Record(ctx, tx, userID, timestamp.ID, models.RevisionActionCreated, nil); err != nil {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "REVISION_ERROR", "Failed to record revision", gin.H{
 				"error": err.Error(),
 			})
-			return
+			return nil, false
 		}
+
+		timestamps = append(timestamps, timestamp)
 	}
 
 	if err := tx.Commit(); err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_COMMIT_ERROR", "Failed to commit transaction", gin.H{
 			"error": err.Error(),
 		})
-		return
+		return nil, false
 	}
 
 	// Increment usage after successful timestamp creation
-	err = t.featureUsageService.IncrementUsage(ctx, userID, "notes")
+	err = t.featureUsageService.IncrementUsageBy(ctx, userID, "notes", len(timestamps))
 	if err != nil {
 		// Log error but don't fail the timestamp creation
 		log.Printf("Warning: Failed to increment note usage: %v", err)
 	}
 
-	if t.aiService != nil {
-		go func() {
-			time.Sleep(2 * time.Second) // Small delay to avoid immediate processing
-			if err := t.aiService.ProcessEmbeddingForTimestamp(timestamp.ID.String()); err != nil {
-				log.Printf("Failed to generate embedding for timestamp %s: %v", timestamp.ID, err)
-			}
-		}()
+	for _, timestamp := range timestamps {
+		if _, err := t.enqueueTimestampEmbedding(ctx, userID, timestamp.ID); err != nil {
+			log.Printf("Failed to queue embedding for timestamp %s: %v", timestamp.ID, err)
+		}
 	}
 
-	middleware.RespondWithOK(c, gin.H{
-		"timestamp": timestamp,
-		"message":   "Timestamp created successfully",
-	})
+	if err := t.transcriptService.AttachRangeContext(ctx, userID, timestamps); err != nil {
+		log.Printf("Failed to attach transcript context to timestamps: %v", err)
+	}
+	if err := t.renderNotes(ctx, userID, timestamps); err != nil {
+		log.Printf("Failed to render timestamp notes: %v", err)
+	}
+
+	return timestamps, true
+}
+
+// validateNoteRange checks that a range note ends after it starts
+func validateNoteRange(start float64, end *float64) error {
+	if end != nil && *end <= start {
+		return fmt.Errorf("end_time %.2f must be after timestamp %.2f", *end, start)
+	}
+	return nil
 }
 
 func (t *TimestampsHandlers) GetAllTimestamps(c *gin.Context) {
@@ -257,6 +365,10 @@ func (t *TimestampsHandlers) GetAllTimestamps(c *gin.Context) {
 		return
 	}
 
+	if err := t.renderNotes(ctx, userID, timestamps); err != nil {
+		log.Printf("Failed to render timestamp notes: %v", err)
+	}
+
 	middleware.RespondWithOK(c, gin.H{
 		"timestamps": timestamps,
 		"count":      len(timestamps),
@@ -300,6 +412,13 @@ func (t *TimestampsHandlers) GetTimestampsByVideoID(c *gin.Context) {
 		return
 	}
 
+	if err := t.transcriptService.AttachRangeContext(ctx, userID, timestamps); err != nil {
+		log.Printf("Failed to attach transcript context to timestamps of video %s: %v", videoID, err)
+	}
+	if err := t.renderNotes(ctx, userID, timestamps); err != nil {
+		log.Printf("Failed to render notes of video %s: %v", videoID, err)
+	}
+
 	middleware.RespondWithOK(c, gin.H{
 		"timestamps": timestamps,
 		"video_id":   videoID,
@@ -347,7 +466,11 @@ func (t *TimestampsHandlers) DeleteTimestamp(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	_, err = tx.NewUpdate().
+	if !t.ensureRevisionBaseline(c, ctx, tx, userID, timestampID) {
+		return
+	}
+
+	result, err := tx.NewUpdate().
 		Model((*models.Timestamp)(nil)).
 		Set("deleted_at = ?", time.Now().UTC()).
 		Where("id = ? AND user_id = ?", timestampID, userID).
@@ -359,6 +482,23 @@ func (t *TimestampsHandlers) DeleteTimestamp(c *gin.Context) {
 		})
 		return
 	}
+	if err := requireRowsAffected(result); errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "TIMESTAMP_NOT_FOUND", "Timestamp not found", nil)
+		return
+	} else if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to delete timestamp", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Record before the tag relations are cleaned up so undelete gets them back
+	if _, err := t.revisionService.Record(ctx, tx, userID, timestampID, models.RevisionActionDeleted, nil); err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "REVISION_ERROR", "Failed to record revision", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	if err := t.tagService.CleanupOrphanedTagRelationsWithTx(ctx, tx); err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "CLEANUP_ERROR", "Failed to cleanup orphaned tag relations", gin.H{
@@ -408,18 +548,17 @@ func (t *TimestampsHandlers) UpdateTimestamp(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		Title string   `json:"title"`
-		Note  string   `json:"note"`
-		Tags  []string `json:"tags"`
-	}
-
+	var req UpdateTimestampRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	if req.EndTime != nil && req.ClearEndTime {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_RANGE", "end_time and clear_end_time are mutually exclusive", nil)
+		return
+	}
 
 	ctx := context.Background()
 
@@ -432,13 +571,54 @@ func (t *TimestampsHandlers) UpdateTimestamp(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	_, err = tx.NewUpdate().
+	if !t.ensureRevisionBaseline(c, ctx, tx, userID, timestampID) {
+		return
+	}
+
+	if req.EndTime != nil {
+		var current models.Timestamp
+		err := tx.NewSelect().
+			Model(&current).
+			Column("timestamp").
+			Where("id = ? AND user_id = ?", timestampID, userID).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			middleware.RespondWithError(c, http.StatusNotFound, "TIMESTAMP_NOT_FOUND", "Timestamp not found", nil)
+			return
+		}
+		if err != nil {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch timestamp", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err := validateNoteRange(current.Timestamp, req.EndTime); err != nil {
+			middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_RANGE", "Invalid note range", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	update := tx.NewUpdate().
 		Model((*models.Timestamp)(nil)).
 		Set("title = ?", req.Title).
-		Set("note = ?", req.Note).
+		Set("note = ?", markdown.Sanitize(req.Note)).
 		Set("updated_at = ?", time.Now().UTC()).
-		Where("id = ? AND user_id = ?", timestampID, userID).
-		Exec(ctx)
+		Where("id = ? AND user_id = ?", timestampID, userID)
+	if req.EndTime != nil {
+		update = update.Set("end_time = ?", *req.EndTime)
+	}
+	if req.ClearEndTime {
+		update = update.Set("end_time = NULL")
+	}
+	if req.Type != nil {
+		update = update.Set("type = ?", *req.Type)
+	}
+	if req.Importance != nil {
+		update = update.Set("importance = ?", *req.Importance)
+	}
+	_, err = update.Exec(ctx)
 
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to update timestamp", gin.H{
@@ -447,16 +627,14 @@ func (t *TimestampsHandlers) UpdateTimestamp(c *gin.Context) {
 		return
 	}
 
-	tagIDs, err := t.tagService.ProcessTagsForTimestampWithTx(ctx, tx, req.Tags)
-	if err != nil {
-		middleware.RespondWithError(c, http.StatusInternalServerError, "TAG_ERROR", "Failed to process tags", gin.H{
-			"error": err.Error(),
-		})
+	if err := t.tagService.ReplaceTimestampTagsWithTx(ctx, tx, userID, timestampID, req.Tags); err != nil {
+		respondTagError(c, err)
 		return
 	}
 
-	if err := t.tagService.CreateTimestampTagRelationsWithTx(ctx, tx, timestampID.String(), tagIDs); err != nil {
-		middleware.RespondWithError(c, http.StatusInternalServerError, "TAG_RELATION_ERROR", "Failed to create tag relations", gin.H{
+	revision, err := t.revisionService.Record(ctx, tx, userID, timestampID, models.RevisionActionUpdated, nil)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "REVISION_ERROR", "Failed to record revision", gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -469,11 +647,35 @@ func (t *TimestampsHandlers) UpdateTimestamp(c *gin.Context) {
 		return
 	}
 
+	if revision != nil && changesEmbeddedText(revision.ChangedFields) {
+		if _, err := t.enqueueTimestampEmbedding(ctx, userID, timestampID); err != nil {
+			log.Printf("Failed to queue embedding for timestamp %s: %v", timestampID, err)
+		}
+	}
+
 	middleware.RespondWithOK(c, gin.H{
-		"message": "Timestamp updated successfully",
+		"message":  "Timestamp updated successfully",
+		"revision": revision,
 	})
 }
 
+// ensureRevisionBaseline records the note's state before a tracked change
+// if it has no revisions yet. Failures are written to c.
+func (t *TimestampsHandlers) ensureRevisionBaseline(c *gin.Context, ctx context.Context, tx bun.Tx, userID, timestampID uuid.UUID) bool {
+	err := t.revisionService.EnsureBaseline(ctx, tx, userID, timestampID)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "TIMESTAMP_NOT_FOUND", "Timestamp not found", nil)
+		return false
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "REVISION_ERROR", "Failed to record revision", gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
 func (t *TimestampsHandlers) DeleteMultipleTimestamps(c *gin.Context) {
 	userIDStr, exists := authhandlers.GetUserID(c)
 	if !exists {
@@ -524,11 +726,25 @@ func (t *TimestampsHandlers) DeleteMultipleTimestamps(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
+	for _, timestampID := range timestampIDs {
+		err := t.revisionService.EnsureBaseline(ctx, tx, userID, timestampID)
+		if err != nil && !errors.Is(err, errNotFound) {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "REVISION_ERROR", "Failed to record revision", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	// Only notes deleted here get a revision, missing and already deleted
+	// ones are skipped
+	var deletedIDs []uuid.UUID
 	_, err = tx.NewUpdate().
 		Model((*models.Timestamp)(nil)).
 		Set("deleted_at = ?", time.Now().UTC()).
-		Where("id IN (?) AND user_id = ?", timestampIDs, userID).
-		Exec(ctx)
+		Where("id IN (?) AND user_id = ?", bun.In(timestampIDs), userID).
+		Returning("id").
+		Exec(ctx, &deletedIDs)
 
 	if err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to delete timestamps", gin.H{
@@ -537,6 +753,15 @@ func (t *TimestampsHandlers) DeleteMultipleTimestamps(c *gin.Context) {
 		return
 	}
 
+	for _, timestampID := range deletedIDs {
+		if _, err := t.revisionService.Record(ctx, tx, userID, timestampID, models.RevisionActionDeleted, nil); err != nil {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "REVISION_ERROR", "Failed to record revision", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
 	if err := t.tagService.CleanupOrphanedTagRelationsWithTx(ctx, tx); err != nil {
 		middleware.RespondWithError(c, http.StatusInternalServerError, "CLEANUP_ERROR", "Failed to cleanup orphaned tag relations", gin.H{
 			"error": err.Error(),
@@ -553,6 +778,6 @@ func (t *TimestampsHandlers) DeleteMultipleTimestamps(c *gin.Context) {
 
 	middleware.RespondWithOK(c, gin.H{
 		"message": "Timestamps deleted successfully",
-		"count":   len(timestampIDs),
+		"count":   len(deletedIDs),
 	})
 }
@@ -0,0 +1,208 @@
+package timestamps
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	SearchTypeNote       = "note"
+	SearchTypeTranscript = "transcript"
+	SearchTypeVideo      = "video"
+
+	// rrfK dampens the weight of top ranks so that a hit found by both
+	// retrievers beats one ranked first by only one of them
+	rrfK = 60
+
+	searchTextConfig = "english"
+	headlineStart    = "<mark>"
+	headlineStop     = "</mark>"
+	headlineOptions  = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+)
+
+var searchTypes = []string{SearchTypeNote, SearchTypeTranscript, SearchTypeVideo}
+
+// SearchHit is a single typed result of HybridSearch. Snippet is HTML-escaped
+// with matched terms wrapped in <mark>.
+type SearchHit struct {
+	Type         string   `json:"type"`
+	ID           string   `json:"id"`
+	VideoID      string   `json:"video_id"`
+	Title        string   `json:"title,omitempty"`
+	Snippet      string   `json:"snippet"`
+	Timestamp    *float64 `json:"timestamp,omitempty"`
+	StartTime    *float64 `json:"start_time,omitempty"`
+	EndTime      *float64 `json:"end_time,omitempty"`
+	NoteType     string   `json:"note_type,omitempty"`
+	Importance   int      `json:"importance,omitempty"`
+	Score        float64  `json:"score"`
+	LexicalRank  int      `json:"lexical_rank,omitempty"`
+	SemanticRank int      `json:"semantic_rank,omitempty"`
+}
+
+type searchRow struct {
+	ID         string   `bun:"id"`
+	VideoID    string   `bun:"video_id"`
+	Title      string   `bun:"title"`
+	Snippet    string   `bun:"snippet"`
+	Timestamp  *float64 `bun:"timestamp"`
+	StartTime  *float64 `bun:"start_time"`
+	EndTime    *float64 `bun:"end_time"`
+	NoteType   string   `bun:"type"`
+	Importance int      `bun:"importance"`
+	Distance   float64  `bun:"distance"`
+}
+
+// HybridSearch runs full-text and vector retrieval for each requested type
+// and merges the ranked lists with reciprocal-rank fusion. Videos have no
+// embedding and are matched on their title only.
+func (ss *SearchService) HybridSearch(ctx context.Context, userID uuid.UUID, query string, queryEmbedding []float32, types []string, filter SearchFilter, limit int) ([]SearchHit, error) {
+	candidates := limit * 3
+	fused := make(map[string]*SearchHit)
+
+	merge := func(hitType string, rows []searchRow, lexical bool) {
+		for i, row := range rows {
+			key := hitType + ":" + row.ID
+			hit, ok := fused[key]
+			if !ok {
+				hit = &SearchHit{
+					Type:       hitType,
+					ID:         row.ID,
+					VideoID:    row.VideoID,
+					Title:      row.Title,
+					Snippet:    escapeHeadline(row.Snippet),
+					Timestamp:  row.Timestamp,
+					StartTime:  row.StartTime,
+					EndTime:    row.EndTime,
+					NoteType:   row.NoteType,
+					Importance: row.Importance,
+				}
+				fused[key] = hit
+			}
+			hit.Score += 1.0 / float64(rrfK+i+1)
+			if lexical {
+				hit.LexicalRank = i + 1
+				// Prefer the headline from the lexical match, it has highlights
+				hit.Snippet = escapeHeadline(row.Snippet)
+			} else {
+				hit.SemanticRank = i + 1
+			}
+		}
+	}
+
+	for _, hitType := range types {
+		lexical, err := ss.searchRows(ctx, hitType, userID, query, nil, filter, candidates)
+		if err != nil {
+			return nil, err
+		}
+		merge(hitType, lexical, true)
+
+		if hitType == SearchTypeVideo || len(queryEmbedding) == 0 {
+			continue
+		}
+
+		semantic, err := ss.searchRows(ctx, hitType, userID, query, queryEmbedding, filter, candidates)
+		if err != nil {
+			return nil, err
+		}
+		merge(hitType, semantic, false)
+	}
+
+	hits := make([]SearchHit, 0, len(fused))
+	for _, hit := range fused {
+		hits = append(hits, *hit)
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].LexicalRank != 0 && (hits[j].LexicalRank == 0 || hits[i].LexicalRank < hits[j].LexicalRank)
+	})
+
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits, nil
+}
+
+// searchRows ranks one type either by text rank against the query or, when
+// queryEmbedding is set, by cosine distance to it
+func (ss *SearchService) searchRows(ctx context.Context, hitType string, userID uuid.UUID, query string, queryEmbedding []float32, filter SearchFilter, limit int) ([]searchRow, error) {
+	var q *bun.SelectQuery
+	switch hitType {
+	case SearchTypeNote:
+		q = ss.db.DB.NewSelect().
+			Model((*models.Timestamp)(nil)).
+			ColumnExpr("?TableAlias.id::text AS id, ?TableAlias.video_id, ?TableAlias.title, ?TableAlias.timestamp").
+			ColumnExpr("?TableAlias.end_time, ?TableAlias.type, ?TableAlias.importance").
+			ColumnExpr("ts_headline(?, COALESCE(NULLIF(?TableAlias.note, ''), ?TableAlias.title), websearch_to_tsquery(?, ?), ?) AS snippet",
+				searchTextConfig, searchTextConfig, query, headlineOptions).
+			Where("?TableAlias.user_id = ? AND ?TableAlias.deleted_at IS NULL", userID)
+		q = applyTagFilter(q, filter.Tags)
+		q = applyNoteFilter(q, filter)
+	case SearchTypeTranscript:
+		q = ss.db.DB.NewSelect().
+			Model((*models.TranscriptEmbedding)(nil)).
+			ColumnExpr("?TableAlias.id::text AS id, ?TableAlias.video_id, ?TableAlias.start_time, ?TableAlias.end_time").
+			ColumnExpr("(SELECT v.title FROM videos v WHERE v.video_id = ?TableAlias.video_id AND v.user_id = ?TableAlias.user_id LIMIT 1) AS title").
+			ColumnExpr("ts_headline(?, ?TableAlias.text, websearch_to_tsquery(?, ?), ?) AS snippet",
+				searchTextConfig, searchTextConfig, query, headlineOptions).
+			Where("?TableAlias.user_id = ? AND ?TableAlias.deleted_at IS NULL", userID).
+			Where("?TableAlias.chunk_strategy = ? AND ?TableAlias.embedding_model = ?", ss.chunkStrategy, ss.embeddingModel)
+	case SearchTypeVideo:
+		q = ss.db.DB.NewSelect().
+			Model((*models.Video)(nil)).
+			ColumnExpr("?TableAlias.id::text AS id, ?TableAlias.video_id, ?TableAlias.title").
+			ColumnExpr("ts_headline(?, ?TableAlias.title, websearch_to_tsquery(?, ?), ?) AS snippet",
+				searchTextConfig, searchTextConfig, query, headlineOptions).
+			Where("?TableAlias.user_id = ?", userID)
+	default:
+		return nil, fmt.Errorf("unknown search type %q", hitType)
+	}
+
+	q = applySearchFilter(q, filter)
+
+	var rows []searchRow
+	if queryEmbedding != nil {
+		q = ss.orderByDistance(q, hitType, queryEmbedding)
+		if err := ss.scanNearest(ctx, q.Limit(limit), &rows); err != nil {
+			return nil, fmt.Errorf("failed to search %ss: %w", hitType, err)
+		}
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].Distance < rows[j].Distance
+		})
+		return rows, nil
+	}
+
+	q = q.Where("?TableAlias.search_vector @@ websearch_to_tsquery(?, ?)", searchTextConfig, query).
+		OrderExpr("ts_rank_cd(?TableAlias.search_vector, websearch_to_tsquery(?, ?)) DESC", searchTextConfig, query)
+	if err := q.Limit(limit).Scan(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to search %ss: %w", hitType, err)
+	}
+
+	return rows, nil
+}
+
+// escapeHeadline HTML-escapes a ts_headline result while keeping its <mark> tags
+func escapeHeadline(headline string) string {
+	var b strings.Builder
+	for _, part := range strings.SplitAfter(headline, headlineStop) {
+		text, marked, found := strings.Cut(strings.TrimSuffix(part, headlineStop), headlineStart)
+		b.WriteString(html.EscapeString(text))
+		if found {
+			b.WriteString(headlineStart)
+			b.WriteString(html.EscapeString(marked))
+			b.WriteString(headlineStop)
+		}
+	}
+	return b.String()
+}
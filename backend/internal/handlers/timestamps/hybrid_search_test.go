@@ -0,0 +1,76 @@
+package timestamps
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+	"github.com/shubhamku044/ytclipper/internal/chunking"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// seedTranscriptChunk stores one chunk of a video's transcript embedded with
+// model
+func seedTranscriptChunk(t *testing.T, db *database.Database, userID uuid.UUID, videoID, model string, index int, text string) *models.TranscriptEmbedding {
+	t.Helper()
+
+	start, end := float64(index*10), float64(index*10+10)
+	now := time.Now().UTC()
+	chunk := &models.TranscriptEmbedding{
+		UserID:              userID,
+		VideoID:             videoID,
+		ChunkStrategy:       chunking.DefaultStrategy,
+		ChunkIndex:          index,
+		StartTime:           &start,
+		EndTime:             &end,
+		Text:                text,
+		TokenCount:          chunking.EstimateTokens(text),
+		EmbeddingModel:      model,
+		EmbeddingDimensions: 3,
+		Embedding:           pgvector.NewVector([]float32{1, 0, 0}),
+		CreatedAt:           now,
+		UpdatedAt:           now,
+	}
+	if _, err := db.DB.NewInsert().Model(chunk).Exec(context.Background()); err != nil {
+		t.Fatalf("failed to seed transcript chunk: %v", err)
+	}
+	return chunk
+}
+
+func TestHybridSearchTranscriptsUseActiveModel(t *testing.T) {
+	db := testDatabase(t)
+	userID := testUser(t, db)
+	ctx := context.Background()
+
+	const text = "gradient descent converges when the learning rate is small"
+	active := seedTranscriptChunk(t, db, userID, "vid-models", "model-new", 0, text)
+	seedTranscriptChunk(t, db, userID, "vid-models", "model-old", 0, text)
+
+	ss := NewSearchService(db, chunking.DefaultStrategy, "model-new")
+
+	tests := []struct {
+		name      string
+		embedding []float32
+	}{
+		{"lexical", nil},
+		{"hybrid", []float32{1, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := ss.HybridSearch(ctx, userID, "gradient descent", tt.embedding, []string{SearchTypeTranscript}, SearchFilter{}, 10)
+			if err != nil {
+				t.Fatalf("HybridSearch error: %v", err)
+			}
+			if len(hits) != 1 {
+				t.Fatalf("got %d hits, want only the active model's chunk: %+v", len(hits), hits)
+			}
+			if hits[0].ID != strconv.FormatInt(active.ID, 10) {
+				t.Errorf("hit %s, want the active model's chunk %d", hits[0].ID, active.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package timestamps
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
+)
+
+// insightTitleMaxLength matches the title column
+const insightTitleMaxLength = 255
+
+// insightRecord describes an AI output to record as an insight
+type insightRecord struct {
+	VideoID  string
+	Type     models.AIInsightType
+	Title    string
+	Input    string
+	Content  string
+	Started  time.Time
+	Metadata any
+	// Prompt is the name of the prompt that produced Content; Prompts holds
+	// its version and those of the prompts of intermediate steps
+	Prompt  string
+	Prompts prompts.Versions
+}
+
+// recordInsight stores an AI output shown to the user so it can be rated
+// later. A failure is logged and never fails the request; the returned ID
+// is nil then.
+func (t *TimestampsHandlers) recordInsight(userID uuid.UUID, record insightRecord) *uuid.UUID {
+	insight := &models.AIInsight{
+		UserID:         userID,
+		Type:           record.Type,
+		Title:          insightTitle(record.Title),
+		Input:          record.Input,
+		Content:        record.Content,
+		Model:          t.aiService.Provider().ChatModel(),
+		ProcessingTime: int(time.Since(record.Started).Milliseconds()),
+		PromptName:     record.Prompt,
+		PromptVersion:  record.Prompts[record.Prompt],
+		Prompts:        record.Prompts,
+	}
+	if record.VideoID != "" {
+		insight.VideoID = &record.VideoID
+	}
+	if record.Metadata != nil {
+		metadata, err := json.Marshal(record.Metadata)
+		if err != nil {
+			log.Printf("Warning: Failed to encode AI insight metadata: %v", err)
+		} else {
+			insight.Metadata = metadata
+		}
+	}
+
+	if err := t.insightService.Record(context.Background(), insight); err != nil {
+		log.Printf("Warning: %v", err)
+		return nil
+	}
+	return &insight.ID
+}
+
+// latestInsightID returns the ID of the most recent insight of a type for a
+// video, so stored outputs served again can still be rated
+func (t *TimestampsHandlers) latestInsightID(ctx context.Context, userID uuid.UUID, videoID string, insightType models.AIInsightType) *uuid.UUID {
+	insight, err := t.insightService.Latest(ctx, userID, videoID, insightType)
+	if err != nil {
+		log.Printf("Warning: Failed to fetch latest AI insight: %v", err)
+	}
+	if insight == nil {
+		return nil
+	}
+	return &insight.ID
+}
+
+// ListAIInsights returns the user's AI output history, optionally for one
+// video, type or only flagged insights
+func (t *TimestampsHandlers) ListAIInsights(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	filter := InsightFilter{
+		VideoID: c.Query("video_id"),
+		Type:    models.AIInsightType(c.Query("type")),
+		Flagged: c.Query("flagged") == "true",
+	}
+	if param := c.Query("limit"); param != "" {
+		if parsed, err := strconv.Atoi(param); err == nil {
+			filter.Limit = parsed
+		}
+	}
+
+	insights, err := t.insightService.List(c.Request.Context(), userID, filter)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch AI insights", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"insights": insights,
+		"count":    len(insights),
+	})
+}
+
+// GetAIInsightStats reports the user's feedback per insight type and prompt
+// version
+func (t *TimestampsHandlers) GetAIInsightStats(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	stats, err := t.insightService.Stats(c.Request.Context(), userID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch AI insight stats", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"stats": stats,
+	})
+}
+
+// ListPrompts returns the versions of every prompt with their weights, and
+// the versions the user is assigned. Under per-request assignment the
+// assigned versions are just one draw.
+func (t *TimestampsHandlers) ListPrompts(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	templates := t.prompts.List(ctx)
+	assigned := prompts.Versions{}
+	for _, template := range templates {
+		if _, done := assigned[template.Name]; done {
+			continue
+		}
+		selected, err := t.prompts.Select(ctx, template.Name, userID)
+		if err != nil {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "PROMPT_ERROR", "Failed to select prompt", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		assigned.Add(selected)
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"prompts":    templates,
+		"assignment": t.prompts.Assignment(),
+		"assigned":   assigned,
+	})
+}
+
+func (t *TimestampsHandlers) GetAIInsight(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	insightID, ok := parseInsightID(c)
+	if !ok {
+		return
+	}
+
+	insight, err := t.insightService.Get(c.Request.Context(), userID, insightID)
+	respondInsight(c, insight, err)
+}
+
+// UpdateAIInsightFeedback records whether an insight was useful, a 1-5
+// rating and free-form feedback
+func (t *TimestampsHandlers) UpdateAIInsightFeedback(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	insightID, ok := parseInsightID(c)
+	if !ok {
+		return
+	}
+
+	var req InsightFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if req.IsUseful == nil && req.Rating == nil && req.Feedback == nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "One of is_useful, rating or feedback is required", nil)
+		return
+	}
+	if req.Feedback != nil {
+		feedback := strings.TrimSpace(*req.Feedback)
+		req.Feedback = &feedback
+	}
+
+	insight, err := t.insightService.UpdateFeedback(c.Request.Context(), userID, insightID, req)
+	respondInsight(c, insight, err)
+}
+
+// FlagAIInsight marks an insight as wrong or inappropriate
+func (t *TimestampsHandlers) FlagAIInsight(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	insightID, ok := parseInsightID(c)
+	if !ok {
+		return
+	}
+
+	var req FlagInsightRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	insight, err := t.insightService.SetFlag(c.Request.Context(), userID, insightID, true, strings.TrimSpace(req.Reason))
+	respondInsight(c, insight, err)
+}
+
+func (t *TimestampsHandlers) UnflagAIInsight(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	insightID, ok := parseInsightID(c)
+	if !ok {
+		return
+	}
+
+	insight, err := t.insightService.SetFlag(c.Request.Context(), userID, insightID, false, "")
+	respondInsight(c, insight, err)
+}
+
+// respondInsight writes the insight or the error of loading it
+func respondInsight(c *gin.Context, insight *models.AIInsight, err error) {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "INSIGHT_NOT_FOUND", "AI insight not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to fetch AI insight", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"insight": insight,
+	})
+}
+
+func parseInsightID(c *gin.Context) (uuid.UUID, bool) {
+	insightID, err := uuid.Parse(c.Param("insightId"))
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_INSIGHT_ID", "Invalid AI insight ID format", gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return insightID, true
+}
+
+func insightTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if len([]rune(title)) > insightTitleMaxLength {
+		title = string([]rune(title)[:insightTitleMaxLength-3]) + "..."
+	}
+	return title
+}
@@ -0,0 +1,189 @@
+package timestamps
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultInsightLimit = 50
+	maxInsightLimit     = 200
+)
+
+// InsightFilter narrows a user's insight history; zero values match all
+type InsightFilter struct {
+	VideoID string
+	Type    models.AIInsightType
+	Flagged bool
+	Limit   int
+}
+
+// InsightStats aggregates the feedback on the insights of one type made
+// with one prompt version
+type InsightStats struct {
+	Type          models.AIInsightType `json:"type" bun:"type"`
+	PromptName    *string              `json:"prompt_name" bun:"prompt_name"`
+	PromptVersion *string              `json:"prompt_version" bun:"prompt_version"`
+	Count         int                  `json:"count" bun:"count"`
+	Rated         int                  `json:"rated" bun:"rated"`
+	AverageRating *float64             `json:"average_rating" bun:"average_rating"`
+	Useful        int                  `json:"useful" bun:"useful"`
+	NotUseful     int                  `json:"not_useful" bun:"not_useful"`
+	Flagged       int                  `json:"flagged" bun:"flagged"`
+	AvgProcessing float64              `json:"avg_processing_time" bun:"avg_processing_time"`
+}
+
+// InsightService stores AI outputs and the feedback users give on them
+type InsightService struct {
+	db *database.Database
+}
+
+func NewInsightService(db *database.Database) *InsightService {
+	return &InsightService{db: db}
+}
+
+func (is *InsightService) Record(ctx context.Context, insight *models.AIInsight) error {
+	now := time.Now()
+	insight.CreatedAt = now
+	insight.UpdatedAt = now
+
+	if _, err := is.db.DB.NewInsert().Model(insight).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record AI insight: %w", err)
+	}
+	return nil
+}
+
+func (is *InsightService) Get(ctx context.Context, userID, insightID uuid.UUID) (*models.AIInsight, error) {
+	var insight models.AIInsight
+	err := is.db.DB.NewSelect().
+		Model(&insight).
+		Where("id = ? AND user_id = ?", insightID, userID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &insight, nil
+}
+
+// List returns the user's insights, newest first
+func (is *InsightService) List(ctx context.Context, userID uuid.UUID, filter InsightFilter) ([]models.AIInsight, error) {
+	if filter.Limit <= 0 || filter.Limit > maxInsightLimit {
+		filter.Limit = defaultInsightLimit
+	}
+
+	insights := []models.AIInsight{}
+	query := is.db.DB.NewSelect().
+		Model(&insights).
+		Where("user_id = ?", userID)
+
+	if filter.VideoID != "" {
+		query = query.Where("video_id = ?", filter.VideoID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Flagged {
+		query = query.Where("flagged_at IS NOT NULL")
+	}
+
+	err := query.
+		Order("created_at DESC").
+		Limit(filter.Limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return insights, nil
+}
+
+// Latest returns the most recent insight of a type for a video, or nil
+func (is *InsightService) Latest(ctx context.Context, userID uuid.UUID, videoID string, insightType models.AIInsightType) (*models.AIInsight, error) {
+	insights, err := is.List(ctx, userID, InsightFilter{VideoID: videoID, Type: insightType, Limit: 1})
+	if err != nil || len(insights) == 0 {
+		return nil, err
+	}
+	return &insights[0], nil
+}
+
+// UpdateFeedback sets the fields of feedback that are not nil
+func (is *InsightService) UpdateFeedback(ctx context.Context, userID, insightID uuid.UUID, feedback InsightFeedbackRequest) (*models.AIInsight, error) {
+	query := is.db.DB.NewUpdate().
+		Model((*models.AIInsight)(nil)).
+		Set("updated_at = ?", time.Now())
+
+	if feedback.IsUseful != nil {
+		query = query.Set("is_useful = ?", *feedback.IsUseful)
+	}
+	if feedback.Rating != nil {
+		query = query.Set("user_rating = ?", *feedback.Rating)
+	}
+	if feedback.Feedback != nil {
+		query = query.Set("user_feedback = ?", *feedback.Feedback)
+	}
+
+	return is.update(ctx, userID, insightID, query)
+}
+
+// SetFlag flags an insight as wrong or inappropriate, or clears the flag
+func (is *InsightService) SetFlag(ctx context.Context, userID, insightID uuid.UUID, flagged bool, reason string) (*models.AIInsight, error) {
+	now := time.Now()
+	query := is.db.DB.NewUpdate().
+		Model((*models.AIInsight)(nil)).
+		Set("updated_at = ?", now)
+
+	if flagged {
+		query = query.Set("flagged_at = ?", now).Set("flag_reason = ?", reason)
+	} else {
+		query = query.Set("flagged_at = NULL").Set("flag_reason = NULL")
+	}
+
+	return is.update(ctx, userID, insightID, query)
+}
+
+// update runs query against the user's insight and returns the result
+func (is *InsightService) update(ctx context.Context, userID, insightID uuid.UUID, query *bun.UpdateQuery) (*models.AIInsight, error) {
+	result, err := query.
+		Where("id = ? AND user_id = ?", insightID, userID).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return nil, err
+	}
+
+	return is.Get(ctx, userID, insightID)
+}
+
+// Stats aggregates the user's feedback per insight type and prompt version,
+// so versions in rotation can be compared
+func (is *InsightService) Stats(ctx context.Context, userID uuid.UUID) ([]InsightStats, error) {
+	stats := []InsightStats{}
+	err := is.db.DB.NewSelect().
+		Model((*models.AIInsight)(nil)).
+		ColumnExpr("type, prompt_name, prompt_version").
+		ColumnExpr("COUNT(*) AS count").
+		ColumnExpr("COUNT(user_rating) AS rated").
+		ColumnExpr("AVG(user_rating)::float8 AS average_rating").
+		ColumnExpr("COUNT(*) FILTER (WHERE is_useful) AS useful").
+		ColumnExpr("COUNT(*) FILTER (WHERE NOT is_useful) AS not_useful").
+		ColumnExpr("COUNT(flagged_at) AS flagged").
+		ColumnExpr("COALESCE(AVG(processing_time), 0)::float8 AS avg_processing_time").
+		Where("user_id = ?", userID).
+		Group("type", "prompt_name", "prompt_version").
+		Order("type ASC", "prompt_name ASC", "prompt_version ASC").
+		Scan(ctx, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
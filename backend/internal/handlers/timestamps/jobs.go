@@ -0,0 +1,319 @@
+package timestamps
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/jobs"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/services"
+)
+
+// Background job types run by the job queue
+const (
+	JobEmbedTimestamp       = "embed_timestamp"
+	JobTranscriptEmbeddings = "transcript_embeddings"
+	JobBackfillEmbeddings   = "backfill_embeddings"
+	JobReembed              = "reembed"
+	JobVideoSummary         = "video_summary"
+	JobCleanupTags          = "cleanup_tags"
+)
+
+// embeddingJobTypes are reported by GetEmbeddingStatus
+var embeddingJobTypes = []string{JobEmbedTimestamp, JobTranscriptEmbeddings, JobBackfillEmbeddings, JobReembed}
+
+type embedTimestampPayload struct {
+	TimestampID uuid.UUID `json:"timestamp_id"`
+}
+
+type transcriptEmbeddingsPayload struct {
+	VideoID  string `json:"video_id"`
+	Language string `json:"language"`
+	Strategy string `json:"strategy"`
+}
+
+type backfillEmbeddingsPayload struct {
+	// Limit caps how many notes one run embeds; 0 embeds all of them
+	Limit int `json:"limit,omitempty"`
+	// AllUsers backfills every user's notes instead of only the job owner's
+	AllUsers bool `json:"all_users,omitempty"`
+}
+
+type videoSummaryPayload struct {
+	VideoID  string `json:"video_id"`
+	Language string `json:"language,omitempty"`
+}
+
+func (t *TimestampsHandlers) registerJobs(queue *jobs.Queue) {
+	queue.Register(JobEmbedTimestamp, attributeJobTokens(t.runEmbedTimestampJob))
+	queue.Register(JobTranscriptEmbeddings, attributeJobTokens(t.runTranscriptEmbeddingsJob))
+	queue.Register(JobBackfillEmbeddings, attributeJobTokens(t.runBackfillEmbeddingsJob))
+	queue.Register(JobReembed, attributeJobTokens(t.runReembedJob))
+	queue.Register(JobVideoSummary, attributeJobTokens(t.runVideoSummaryJob))
+	queue.Register(JobCleanupTags, t.runCleanupTagsJob)
+
+	if t.db != nil && t.tagCleanupInterval > 0 {
+		t.scheduleTagCleanup(context.Background(), time.Now())
+	}
+}
+
+// attributeJobTokens attributes the AI calls of a job to the user who
+// queued it. Jobs that work across every user's notes clear the user again.
+func attributeJobTokens(handler jobs.Handler) jobs.Handler {
+	return func(ctx context.Context, job *models.Job, progress jobs.ProgressFunc) (any, error) {
+		if job.UserID != nil {
+			ctx = services.WithTokenUser(ctx, *job.UserID)
+		}
+		return handler(ctx, job, progress)
+	}
+}
+
+func (t *TimestampsHandlers) enqueueTimestampEmbedding(ctx context.Context, userID, timestampID uuid.UUID) (*models.Job, error) {
+	return t.jobQueue.Enqueue(ctx, JobEmbedTimestamp, embedTimestampPayload{TimestampID: timestampID}, jobs.EnqueueOptions{
+		UserID:    &userID,
+		DedupeKey: fmt.Sprintf("%s:%s", JobEmbedTimestamp, timestampID),
+	})
+}
+
+// enqueueTranscriptEmbeddings queues chunking and embedding of the
+// transcript with the named strategy, or the active one when strategy is empty
+func (t *TimestampsHandlers) enqueueTranscriptEmbeddings(ctx context.Context, userID uuid.UUID, transcript *models.VideoTranscript, strategy string) (*models.Job, error) {
+	if strategy == "" {
+		strategy = t.transcriptService.ChunkStrategy()
+	}
+
+	payload := transcriptEmbeddingsPayload{
+		VideoID:  transcript.VideoID,
+		Language: transcript.Language,
+		Strategy: strategy,
+	}
+	return t.jobQueue.Enqueue(ctx, JobTranscriptEmbeddings, payload, jobs.EnqueueOptions{
+		UserID:    &userID,
+		DedupeKey: transcriptEmbeddingsKey(userID, transcript.VideoID, transcript.Language, strategy),
+	})
+}
+
+func transcriptEmbeddingsKey(userID uuid.UUID, videoID, language, strategy string) string {
+	return strings.Join([]string{JobTranscriptEmbeddings, userID.String(), videoID, language, strategy}, ":")
+}
+
+func (t *TimestampsHandlers) runEmbedTimestampJob(ctx context.Context, job *models.Job, progress jobs.ProgressFunc) (any, error) {
+	var payload embedTimestampPayload
+	if err := jobs.Decode(job, &payload); err != nil {
+		return nil, err
+	}
+
+	err := t.aiService.ProcessEmbeddingForTimestamp(ctx, payload.TimestampID.String())
+	if errors.Is(err, errNothingToEmbed) {
+		return map[string]any{"skipped": true}, nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (t *TimestampsHandlers) runTranscriptEmbeddingsJob(ctx context.Context, job *models.Job, progress jobs.ProgressFunc) (any, error) {
+	var payload transcriptEmbeddingsPayload
+	if err := jobs.Decode(job, &payload); err != nil {
+		return nil, err
+	}
+	if job.UserID == nil {
+		return nil, jobs.Permanent(errors.New("transcript embedding job has no user"))
+	}
+
+	transcript, err := t.transcriptService.GetTranscript(ctx, *job.UserID, payload.VideoID, payload.Language, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transcript: %w", err)
+	}
+
+	chunks, err := t.generateAndSaveTranscriptEmbedding(ctx, *job.UserID, transcript, payload.Strategy, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"video_id": payload.VideoID,
+		"language": transcript.Language,
+		"strategy": payload.Strategy,
+		"chunks":   chunks,
+	}, nil
+}
+
+// runBackfillEmbeddingsJob embeds notes that have no embedding from the
+// active model yet. Notes that fail stay without one, so a retry only
+// redoes those.
+func (t *TimestampsHandlers) runBackfillEmbeddingsJob(ctx context.Context, job *models.Job, progress jobs.ProgressFunc) (any, error) {
+	var payload backfillEmbeddingsPayload
+	if err := jobs.Decode(job, &payload); err != nil {
+		return nil, err
+	}
+
+	model := t.aiService.EmbeddingModel()
+
+	var timestamps []models.Timestamp
+	query := t.db.DB.NewSelect().
+		Model(&timestamps).
+		Relation("Tags").
+		Where("?TableAlias.deleted_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM timestamp_embeddings AS tse WHERE tse.timestamp_id = ?TableAlias.id AND tse.model = ?)", model).
+		Order("created_at ASC")
+	if payload.AllUsers {
+		// The notes belong to many users; don't charge the job owner
+		ctx = services.WithTokenUser(ctx, uuid.Nil)
+	} else {
+		if job.UserID == nil {
+			return nil, jobs.Permanent(errors.New("backfill job has no user"))
+		}
+		query = query.Where("?TableAlias.user_id = ?", *job.UserID)
+	}
+	if payload.Limit > 0 {
+		query = query.Limit(payload.Limit)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to fetch timestamps: %w", err)
+	}
+
+	// Notes without any text are skipped; the rest are embedded in batches
+	var pending []models.Timestamp
+	var texts []string
+	for _, ts := range timestamps {
+		var tagNames []string
+		for _, tag := range ts.Tags {
+			tagNames = append(tagNames, tag.Name)
+		}
+
+		if text := t.aiService.CreateEmbeddingText(ts.Title, ts.Note, tagNames); text != "" {
+			pending = append(pending, ts)
+			texts = append(texts, text)
+		}
+	}
+	skipped := len(timestamps) - len(pending)
+
+	progress(skipped, len(timestamps))
+	results := t.aiService.GenerateEmbeddings(ctx, texts, func(done int) {
+		progress(skipped+done, len(timestamps))
+	})
+
+	var rows []models.TimestampEmbedding
+	var lastErr error
+	for i, result := range results {
+		if result.Err != nil {
+			lastErr = fmt.Errorf("timestamp %s: %w", pending[i].ID, result.Err)
+			continue
+		}
+		rows = append(rows, newTimestampEmbedding(&pending[i], model, result.Embedding))
+	}
+	if err := saveTimestampEmbeddings(ctx, t.db.DB, rows); err != nil {
+		return nil, err
+	}
+	processed := len(rows)
+
+	failed := len(timestamps) - processed - skipped
+	if failed > 0 {
+		return nil, fmt.Errorf("%d of %d embeddings failed, last error: %w", failed, len(timestamps), lastErr)
+	}
+
+	return map[string]any{
+		"total":     len(timestamps),
+		"processed": processed,
+		"skipped":   skipped,
+	}, nil
+}
+
+func (t *TimestampsHandlers) runVideoSummaryJob(ctx context.Context, job *models.Job, progress jobs.ProgressFunc) (any, error) {
+	var payload videoSummaryPayload
+	if err := jobs.Decode(job, &payload); err != nil {
+		return nil, err
+	}
+	if job.UserID == nil {
+		return nil, jobs.Permanent(errors.New("summary job has no user"))
+	}
+	userID := *job.UserID
+
+	var video models.Video
+	err := t.db.DB.NewSelect().
+		Model(&video).
+		Where("user_id = ? AND video_id = ?", userID, payload.VideoID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, jobs.Permanent(fmt.Errorf("video %s not found", payload.VideoID))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch video: %w", err)
+	}
+
+	progress(0, 2)
+	transcript, err := t.transcriptService.GetTranscript(ctx, userID, payload.VideoID, payload.Language, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transcript: %w", err)
+	}
+	t.ensureTranscriptEmbeddings(userID, transcript)
+
+	progress(1, 2)
+	started := time.Now()
+	summary, sections, used, err := t.generateFullVideoSummary(ctx, &video, transcript, summaryEvents{
+		// Section summaries are reported between loading the transcript and
+		// the final summary; combining levels are too few to be worth a step
+		Progress: func(level, done, total int) {
+			if level == 0 {
+				progress(1+done, total+2)
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	insightID := t.saveVideoSummary(userID, &video, summary, sections, used, now, started)
+	progress(1, 1)
+
+	return map[string]any{
+		"video_id":     payload.VideoID,
+		"video_title":  video.Title,
+		"summary":      summary,
+		"sections":     sections,
+		"insight_id":   insightID,
+		"generated_at": now,
+	}, nil
+}
+
+// scheduleTagCleanup queues a tag cleanup to run at runAt. Every process
+// schedules one when it starts; the dedupe key keeps a single one queued.
+func (t *TimestampsHandlers) scheduleTagCleanup(ctx context.Context, runAt time.Time) {
+	_, err := t.jobQueue.Enqueue(ctx, JobCleanupTags, struct{}{}, jobs.EnqueueOptions{
+		DedupeKey: JobCleanupTags,
+		RunAt:     runAt,
+	})
+	if err != nil {
+		log.Printf("Failed to schedule tag cleanup: %v", err)
+	}
+}
+
+// runCleanupTagsJob removes tags no note uses, after queueing the next
+// cleanup one interval later
+func (t *TimestampsHandlers) runCleanupTagsJob(ctx context.Context, job *models.Job, progress jobs.ProgressFunc) (any, error) {
+	if t.tagCleanupInterval > 0 {
+		t.scheduleTagCleanup(ctx, time.Now().Add(t.tagCleanupInterval))
+	}
+
+	result, err := t.tagService.CleanupUnusedTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if result.Tags > 0 || result.Relations > 0 {
+		log.Printf("Tag cleanup removed %d unused tags and %d tag relations of deleted notes", result.Tags, result.Relations)
+	}
+	return result, nil
+}
@@ -0,0 +1,260 @@
+package timestamps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+const (
+	defaultLibraryChunks = 12
+	defaultLibraryNotes  = 8
+	// libraryCandidates is how many times more chunks are retrieved than
+	// kept, so enough remain after near-duplicates are dropped
+	libraryCandidates = 3
+	// nearDuplicateSimilarity is the cosine similarity above which two
+	// chunks, e.g. overlapping windows or a talk uploaded twice, count as
+	// the same passage
+	nearDuplicateSimilarity = 0.95
+)
+
+// LibraryVideo groups the citations of a library answer that come from one
+// video
+type LibraryVideo struct {
+	VideoID      string     `json:"video_id"`
+	Title        string     `json:"title"`
+	ChannelTitle string     `json:"channel_title,omitempty"`
+	Score        float64    `json:"score"` // Best score among the citations
+	Citations    []Citation `json:"citations"`
+}
+
+// buildLibraryContext retrieves the transcript chunks and notes most
+// relevant to the question across the user's videos and renders them
+// grouped by video
+func (t *TimestampsHandlers) buildLibraryContext(ctx context.Context, userID uuid.UUID, req QuestionRequest, queryEmbedding []float32) (*questionContext, error) {
+	qc := &questionContext{}
+
+	videoIDs, err := t.libraryScope(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	if videoIDs != nil && len(videoIDs) == 0 {
+		qc.Context = "# Library Context\n\nNo videos match the requested tags and videos.\n"
+		return qc, nil
+	}
+	filter := SearchFilter{VideoIDs: videoIDs}
+
+	chunkLimit, noteLimit := defaultLibraryChunks, defaultLibraryNotes
+	if req.Context > 0 {
+		chunkLimit, noteLimit = req.Context, req.Context
+	}
+
+	candidates, err := t.searchService.SearchTranscripts(ctx, userID, queryEmbedding, filter, chunkLimit*libraryCandidates)
+	if err != nil {
+		return nil, err
+	}
+	chunks := dropNearDuplicates(candidates, chunkLimit)
+
+	notes, err := t.searchService.SearchTimestamps(ctx, userID, queryEmbedding, filter, noteLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, scored := range chunks {
+		emb := scored.Embedding
+		qc.Citations = append(qc.Citations, Citation{
+			Label:     fmt.Sprintf("S%d", i+1),
+			Type:      CitationTypeTranscript,
+			ID:        strconv.FormatInt(emb.ID, 10),
+			VideoID:   emb.VideoID,
+			StartTime: emb.StartTime,
+			EndTime:   emb.EndTime,
+			Text:      emb.Text,
+			Score:     scored.Score,
+		})
+	}
+	notesByID := make(map[string]models.Timestamp, len(notes))
+	for i, ts := range t.withRangeContext(ctx, userID, notes) {
+		qc.Citations = append(qc.Citations, noteCitation(fmt.Sprintf("N%d", i+1), ts, float64(notes[i].Score)))
+		notesByID[ts.ID.String()] = ts
+	}
+
+	qc.Videos, err = t.libraryVideos(ctx, userID, qc.Citations)
+	if err != nil {
+		return nil, err
+	}
+
+	var contextBuilder strings.Builder
+	contextBuilder.WriteString("# Library Context\n\n")
+	for _, video := range groupCitationsByVideo(qc.Citations, qc.Videos) {
+		contextBuilder.WriteString(fmt.Sprintf("## Video: %s (%s)\n\n", video.Title, video.VideoID))
+		if video.ChannelTitle != "" {
+			contextBuilder.WriteString(fmt.Sprintf("**Channel:** %s\n\n", video.ChannelTitle))
+		}
+
+		for _, citation := range video.Citations {
+			if citation.Type == CitationTypeTranscript {
+				contextBuilder.WriteString(fmt.Sprintf("### [%s] Segment (%s-%s, Relevance: %.3f)\n\n",
+					citation.Label, formatOptionalTimestamp(citation.StartTime), formatOptionalTimestamp(citation.EndTime), citation.Score))
+				contextBuilder.WriteString(fmt.Sprintf("**Content:**\n%s\n\n", citation.Text))
+				contextBuilder.WriteString("---\n\n")
+				continue
+			}
+
+			ts := notesByID[citation.ID]
+			contextBuilder.WriteString(fmt.Sprintf("### [%s] Note (%s, Relevance: %.3f)\n\n", citation.Label, describeNoteTime(ts), citation.Score))
+			if citation.Title != "" {
+				contextBuilder.WriteString(fmt.Sprintf("**Title:** %s\n\n", citation.Title))
+			}
+			writeNoteKind(&contextBuilder, ts)
+			if citation.Text != "" {
+				contextBuilder.WriteString(fmt.Sprintf("**Content:**\n%s\n\n", citation.Text))
+			}
+			if len(ts.Tags) > 0 {
+				tags := make([]string, len(ts.Tags))
+				for i, tag := range ts.Tags {
+					tags[i] = tag.Name
+				}
+				contextBuilder.WriteString(fmt.Sprintf("**Tags:** %s\n\n", strings.Join(tags, ", ")))
+			}
+			writeNoteTranscript(&contextBuilder, ts)
+			contextBuilder.WriteString("---\n\n")
+		}
+	}
+
+	qc.Context = contextBuilder.String()
+	return qc, nil
+}
+
+// libraryScope returns the videos a library question is limited to: the
+// requested videos, narrowed to those with a note carrying one of the
+// requested tags or a tag nested under one. It returns nil when the whole
+// library is in scope.
+func (t *TimestampsHandlers) libraryScope(ctx context.Context, userID uuid.UUID, req QuestionRequest) ([]string, error) {
+	tagNames := normalizeTagNames(req.Tags)
+	if len(tagNames) == 0 {
+		if len(req.VideoIDs) == 0 {
+			return nil, nil
+		}
+		return req.VideoIDs, nil
+	}
+
+	videoIDs := []string{}
+	query := t.db.DB.NewSelect().
+		Model((*models.Timestamp)(nil)).
+		ColumnExpr("DISTINCT ?TableAlias.video_id").
+		Join("JOIN timestamp_tags AS tt ON tt.timestamp_id = ?TableAlias.id").
+		Join("JOIN tags AS tg ON tg.id = tt.tag_id").
+		Where("?TableAlias.user_id = ? AND ?TableAlias.deleted_at IS NULL", userID).
+		Where(tagPathMatch, pgdialect.Array(tagNames))
+	if len(req.VideoIDs) > 0 {
+		query = query.Where("?TableAlias.video_id IN (?)", bun.In(req.VideoIDs))
+	}
+	if err := query.Scan(ctx, &videoIDs); err != nil {
+		return nil, fmt.Errorf("failed to resolve tagged videos: %w", err)
+	}
+	return videoIDs, nil
+}
+
+// libraryVideos loads the videos the citations come from, by YouTube ID
+func (t *TimestampsHandlers) libraryVideos(ctx context.Context, userID uuid.UUID, citations []Citation) (map[string]models.Video, error) {
+	videos := make(map[string]models.Video)
+	if len(citations) == 0 {
+		return videos, nil
+	}
+
+	var ids []string
+	for _, citation := range citations {
+		if _, ok := videos[citation.VideoID]; !ok {
+			videos[citation.VideoID] = models.Video{VideoID: citation.VideoID}
+			ids = append(ids, citation.VideoID)
+		}
+	}
+
+	var rows []models.Video
+	err := t.db.DB.NewSelect().
+		Model(&rows).
+		Column("video_id", "title", "channel_title").
+		Where("user_id = ? AND video_id IN (?)", userID, bun.In(ids)).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch videos: %w", err)
+	}
+	for _, video := range rows {
+		videos[video.VideoID] = video
+	}
+	return videos, nil
+}
+
+// dropNearDuplicates keeps up to limit chunks in score order, skipping any
+// nearly identical to a higher scored chunk already kept
+func dropNearDuplicates(chunks []ScoredTranscriptEmbedding, limit int) []ScoredTranscriptEmbedding {
+	kept := make([]ScoredTranscriptEmbedding, 0, min(len(chunks), limit))
+	seen := make(map[string]bool, len(chunks))
+
+	for _, chunk := range chunks {
+		if len(kept) == limit {
+			break
+		}
+
+		text := strings.Join(strings.Fields(strings.ToLower(chunk.Embedding.Text)), " ")
+		if seen[text] {
+			continue
+		}
+
+		duplicate := false
+		vector := chunk.Embedding.Embedding.Slice()
+		for _, other := range kept {
+			if CosineSimilarity(vector, other.Embedding.Embedding.Slice()) >= nearDuplicateSimilarity {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		seen[text] = true
+		kept = append(kept, chunk)
+	}
+	return kept
+}
+
+// groupCitationsByVideo groups citations by the video they come from,
+// ordering videos by their best scoring citation and keeping the order of
+// citations within each video
+func groupCitationsByVideo(citations []Citation, videos map[string]models.Video) []LibraryVideo {
+	var grouped []LibraryVideo
+	index := make(map[string]int)
+	for _, citation := range citations {
+		i, ok := index[citation.VideoID]
+		if !ok {
+			video := videos[citation.VideoID]
+			if video.Title == "" {
+				video.Title = citation.VideoID
+			}
+			i = len(grouped)
+			index[citation.VideoID] = i
+			grouped = append(grouped, LibraryVideo{
+				VideoID:      citation.VideoID,
+				Title:        video.Title,
+				ChannelTitle: video.ChannelTitle,
+			})
+		}
+
+		grouped[i].Citations = append(grouped[i].Citations, citation)
+		grouped[i].Score = max(grouped[i].Score, citation.Score)
+	}
+
+	sort.SliceStable(grouped, func(i, j int) bool {
+		return grouped[i].Score > grouped[j].Score
+	})
+	return grouped
+}
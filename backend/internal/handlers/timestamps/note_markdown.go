@@ -0,0 +1,134 @@
+package timestamps
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/markdown"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+)
+
+// PreviewNoteRequest is a draft note to render before it is saved
+type PreviewNoteRequest struct {
+	VideoID string `json:"video_id"`
+	Note    string `json:"note"`
+}
+
+// renderNotes fills in NoteHTML, resolving links against the user's own
+// notes and videos. Links to anything else render as plain text.
+func (t *TimestampsHandlers) renderNotes(ctx context.Context, userID uuid.UUID, timestamps []models.Timestamp) error {
+	var noteIDs, videoIDs []string
+	for _, ts := range timestamps {
+		for _, link := range markdown.Links(ts.Note) {
+			switch link.Kind {
+			case markdown.LinkNote:
+				noteIDs = append(noteIDs, link.NoteID)
+			case markdown.LinkVideo:
+				videoIDs = append(videoIDs, link.VideoID)
+			}
+		}
+	}
+
+	noteTitles, videoTitles, err := t.linkTitles(ctx, userID, noteIDs, videoIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range timestamps {
+		if timestamps[i].Note == "" {
+			continue
+		}
+		html, err := t.noteRenderer.Render(timestamps[i].Note, markdown.RenderOptions{
+			VideoID:     timestamps[i].VideoID,
+			NoteTitles:  noteTitles,
+			VideoTitles: videoTitles,
+		})
+		if err != nil {
+			return err
+		}
+		timestamps[i].NoteHTML = html
+	}
+	return nil
+}
+
+// linkTitles loads the titles of the linked notes and videos the user owns.
+// Notes without a title are named after their time.
+func (t *TimestampsHandlers) linkTitles(ctx context.Context, userID uuid.UUID, noteIDs, videoIDs []string) (map[string]string, map[string]string, error) {
+	noteTitles := make(map[string]string)
+	videoTitles := make(map[string]string)
+
+	if len(noteIDs) > 0 {
+		var notes []models.Timestamp
+		err := t.db.DB.NewSelect().
+			Model(&notes).
+			Column("id", "title", "timestamp").
+			Where("user_id = ? AND id IN (?)", userID, bun.In(noteIDs)).
+			Scan(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch linked notes: %w", err)
+		}
+		for _, note := range notes {
+			title := note.Title
+			if title == "" {
+				title = "Note at " + markdown.FormatClock(note.Timestamp)
+			}
+			noteTitles[note.ID.String()] = title
+		}
+	}
+
+	if len(videoIDs) > 0 {
+		var videos []models.Video
+		err := t.db.DB.NewSelect().
+			Model(&videos).
+			Column("video_id", "title").
+			Where("user_id = ? AND video_id IN (?)", userID, bun.In(videoIDs)).
+			Scan(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch linked videos: %w", err)
+		}
+		for _, video := range videos {
+			videoTitles[video.VideoID] = video.Title
+		}
+	}
+
+	return noteTitles, videoTitles, nil
+}
+
+// PreviewNote renders a draft note as it will be shown once saved
+func (t *TimestampsHandlers) PreviewNote(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req PreviewNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	note := []models.Timestamp{{VideoID: req.VideoID, Note: markdown.Sanitize(req.Note)}}
+	if err := t.renderNotes(c.Request.Context(), userID, note); err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "RENDER_ERROR", "Failed to render note", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	links := markdown.Links(note[0].Note)
+	if links == nil {
+		links = []markdown.Link{}
+	}
+	middleware.RespondWithOK(c, gin.H{
+		"note":      note[0].Note,
+		"note_html": note[0].NoteHTML,
+		"links":     links,
+	})
+}
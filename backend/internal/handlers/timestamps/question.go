@@ -0,0 +1,338 @@
+package timestamps
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/aicache"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
+)
+
+const (
+	CitationTypeTranscript = "transcript"
+	CitationTypeNote       = "note"
+
+	// maxNoteTranscriptChars bounds the transcript quoted under a range note
+	maxNoteTranscriptChars = 2000
+)
+
+// Citation is a piece of retrieved context the answer may refer to by Label
+type Citation struct {
+	Label     string   `json:"label"`
+	Type      string   `json:"type"`
+	ID        string   `json:"id"`
+	VideoID   string   `json:"video_id"`
+	StartTime *float64 `json:"start_time,omitempty"`
+	EndTime   *float64 `json:"end_time,omitempty"`
+	Timestamp *float64 `json:"timestamp,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	Text      string   `json:"text"`
+	Score     float64  `json:"score"`
+	Cited     bool     `json:"cited"`
+}
+
+type questionContext struct {
+	Context   string
+	Citations []Citation
+	// Videos the citations of a library question come from, by YouTube ID
+	Videos map[string]models.Video
+}
+
+// prompt is the name of the prompt that answers the question
+func (req QuestionRequest) prompt() string {
+	if req.Library {
+		return prompts.Library
+	}
+	return prompts.Question
+}
+
+// buildQuestionContext retrieves the transcript segments and notes most relevant
+// to the question and renders them as labelled prompt context
+func (t *TimestampsHandlers) buildQuestionContext(ctx context.Context, userID uuid.UUID, req QuestionRequest, queryEmbedding []float32) (*questionContext, error) {
+	if req.Library {
+		return t.buildLibraryContext(ctx, userID, req, queryEmbedding)
+	}
+
+	qc := &questionContext{}
+
+	var contextBuilder strings.Builder
+	contextBuilder.WriteString("# Video Context\n\n")
+
+	if req.VideoID != "" {
+		contextLimit := req.Context
+		if contextLimit <= 0 {
+			contextLimit = 10
+		}
+
+		scoredChunks, err := t.searchService.SearchTranscripts(ctx, userID, queryEmbedding, SearchFilter{VideoID: req.VideoID}, contextLimit)
+		if err != nil {
+			log.Printf("Failed to search transcript embeddings for video %s: %v", req.VideoID, err)
+		}
+
+		if len(scoredChunks) > 0 {
+			log.Printf("Found %d relevant transcript segments for video %s", len(scoredChunks), req.VideoID)
+			contextBuilder.WriteString("## Relevant Video Transcript Segments\n\n")
+
+			for i, scored := range scoredChunks {
+				emb := scored.Embedding
+				label := fmt.Sprintf("S%d", i+1)
+
+				contextBuilder.WriteString(fmt.Sprintf("### [%s] Segment (%s-%s, Relevance: %.3f)\n\n",
+					label, formatOptionalTimestamp(emb.StartTime), formatOptionalTimestamp(emb.EndTime), scored.Score))
+				contextBuilder.WriteString(fmt.Sprintf("**Content:**\n%s\n\n", emb.Text))
+				contextBuilder.WriteString("---\n\n")
+
+				qc.Citations = append(qc.Citations, Citation{
+					Label:     label,
+					Type:      CitationTypeTranscript,
+					ID:        strconv.FormatInt(emb.ID, 10),
+					VideoID:   emb.VideoID,
+					StartTime: emb.StartTime,
+					EndTime:   emb.EndTime,
+					Text:      emb.Text,
+					Score:     scored.Score,
+				})
+			}
+		} else {
+			transcript, err := t.transcriptService.GetTranscript(ctx, userID, req.VideoID, req.Language, false)
+			if err == nil {
+				contextBuilder.WriteString("## Video Transcript\n\n")
+				contextBuilder.WriteString(formatTranscript(transcript))
+				contextBuilder.WriteString("\n\n")
+
+				t.ensureTranscriptEmbeddings(userID, transcript)
+			}
+		}
+	}
+
+	noteLimit := req.Context
+	if noteLimit <= 0 {
+		noteLimit = 5
+	}
+
+	scoredResults, err := t.searchService.SearchTimestamps(ctx, userID, queryEmbedding, SearchFilter{VideoID: req.VideoID}, noteLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(scoredResults) > 0 {
+		contextBuilder.WriteString("## Relevant User Notes\n\n")
+
+		notes := t.withRangeContext(ctx, userID, scoredResults)
+		for i, scored := range scoredResults {
+			ts := notes[i]
+			label := fmt.Sprintf("N%d", i+1)
+
+			if req.VideoID != "" {
+				contextBuilder.WriteString(fmt.Sprintf("### [%s] Note (%s, Relevance: %.3f)\n\n", label, describeNoteTime(ts), scored.Score))
+			} else {
+				contextBuilder.WriteString(fmt.Sprintf("### [%s] Note (Video: %s, %s, Relevance: %.3f)\n\n", label, ts.VideoID, describeNoteTime(ts), scored.Score))
+			}
+			if ts.Title != "" {
+				contextBuilder.WriteString(fmt.Sprintf("**Title:** %s\n\n", ts.Title))
+			}
+			writeNoteKind(&contextBuilder, ts)
+			if ts.Note != "" {
+				contextBuilder.WriteString(fmt.Sprintf("**Content:**\n%s\n\n", ts.Note))
+			}
+			if len(ts.Tags) > 0 {
+				var tagNames []string
+				for _, tag := range ts.Tags {
+					tagNames = append(tagNames, tag.Name)
+				}
+				contextBuilder.WriteString(fmt.Sprintf("**Tags:** %s\n\n", strings.Join(tagNames, ", ")))
+			}
+			writeNoteTranscript(&contextBuilder, ts)
+			contextBuilder.WriteString("---\n\n")
+
+			qc.Citations = append(qc.Citations, noteCitation(label, ts, float64(scored.Score)))
+		}
+	}
+
+	qc.Context = contextBuilder.String()
+	return qc, nil
+}
+
+// questionPromptData are the variables of the question prompt
+type questionPromptData struct {
+	Question string
+	Context  string // Labeled notes and transcript segments
+}
+
+// markCitations flags the citations whose label appears in the answer
+func markCitations(answer string, citations []Citation) []Citation {
+	marked := make([]Citation, len(citations))
+	for i, citation := range citations {
+		citation.Cited = strings.Contains(answer, "["+citation.Label+"]")
+		marked[i] = citation
+	}
+	return marked
+}
+
+// withRangeContext returns the scored notes with the transcript chunks
+// overlapping each range note attached
+func (t *TimestampsHandlers) withRangeContext(ctx context.Context, userID uuid.UUID, scored []ScoredTimestamp) []models.Timestamp {
+	notes := make([]models.Timestamp, len(scored))
+	for i, result := range scored {
+		notes[i] = result.Timestamp.(models.Timestamp)
+	}
+	if err := t.transcriptService.AttachRangeContext(ctx, userID, notes); err != nil {
+		log.Printf("Failed to attach transcript context to notes: %v", err)
+	}
+	return notes
+}
+
+func noteCitation(label string, ts models.Timestamp, score float64) Citation {
+	timestamp := ts.Timestamp
+	return Citation{
+		Label:     label,
+		Type:      CitationTypeNote,
+		ID:        ts.ID.String(),
+		VideoID:   ts.VideoID,
+		Timestamp: &timestamp,
+		EndTime:   ts.EndTime,
+		Title:     ts.Title,
+		Text:      ts.Note,
+		Score:     score,
+	}
+}
+
+// describeNoteTime is the point or segment a note is about
+func describeNoteTime(ts models.Timestamp) string {
+	if ts.EndTime != nil {
+		return fmt.Sprintf("Segment: %.2f-%.2f seconds", ts.Timestamp, *ts.EndTime)
+	}
+	return fmt.Sprintf("Timestamp: %.2f seconds", ts.Timestamp)
+}
+
+// writeNoteKind writes the type and importance of notes that aren't plain
+// notes of default importance
+func writeNoteKind(b *strings.Builder, ts models.Timestamp) {
+	if (ts.Type == "" || ts.Type == models.ClipTypeNote) && ts.Importance == models.DefaultTimestampImportance {
+		return
+	}
+	kind := ts.Type
+	if kind == "" {
+		kind = models.ClipTypeNote
+	}
+	b.WriteString(fmt.Sprintf("**Type:** %s (importance %d/5)\n\n", kind, ts.Importance))
+}
+
+// writeNoteTranscript writes what was said during a range note, up to
+// maxNoteTranscriptChars
+func writeNoteTranscript(b *strings.Builder, ts models.Timestamp) {
+	if len(ts.Context) == 0 {
+		return
+	}
+	texts := make([]string, len(ts.Context))
+	for i, chunk := range ts.Context {
+		texts[i] = chunk.Text
+	}
+	transcript := strings.Join(texts, " ")
+	if len(transcript) > maxNoteTranscriptChars {
+		transcript = strings.ToValidUTF8(transcript[:maxNoteTranscriptChars], "") + "..."
+	}
+	b.WriteString(fmt.Sprintf("**Transcript during this segment:**\n%s\n\n", transcript))
+}
+
+func formatOptionalTimestamp(seconds *float64) string {
+	if seconds == nil {
+		return "[--:--:--]"
+	}
+	return formatTimestamp(*seconds)
+}
+
+// streamAnswer streams the answer as SSE chunk events and finishes with a
+// complete event carrying the structured citations
+func (t *TimestampsHandlers) streamAnswer(c *gin.Context, userID uuid.UUID, req QuestionRequest, qc *questionContext, prompt string, used prompts.Versions) {
+	ctx := c.Request.Context()
+	started := time.Now()
+	startEventStream(c)
+
+	index := 0
+	completion, err := t.aiService.StreamTextCompletion(ctx, aicache.FeatureQuestion, prompt, func(delta string) error {
+		err := writeEvent(c, "chunk", gin.H{
+			"content": delta,
+			"index":   index,
+		})
+		index++
+		return err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Printf("Answer stream cancelled by client")
+			return
+		}
+		if writeErr := writeEvent(c, "error", gin.H{"error": err.Error()}); writeErr != nil {
+			log.Printf("Failed to send answer stream error: %v", writeErr)
+		}
+		return
+	}
+
+	err = t.featureUsageService.IncrementUsage(context.Background(), userID, "ai_questions")
+	if err != nil {
+		log.Printf("Warning: Failed to increment AI question usage: %v", err)
+	}
+
+	citations := markCitations(completion.Content, qc.Citations)
+	insightID := t.recordAnswerInsight(userID, req.VideoID, req.Question, completion.Content, citations, started, nil, used)
+
+	event := gin.H{
+		"answer":        completion.Content,
+		"question":      req.Question,
+		"video_id":      req.VideoID,
+		"citations":     citations,
+		"insight_id":    insightID,
+		"finish_reason": completion.FinishReason,
+		"usage":         completion.Usage,
+		"generated_at":  time.Now().UTC(),
+	}
+	if req.Library {
+		event["videos"] = groupCitationsByVideo(citations, qc.Videos)
+	}
+	if err := writeEvent(c, "complete", event); err != nil {
+		log.Printf("Failed to send answer complete event: %v", err)
+	}
+}
+
+// recordAnswerInsight records an answer with the labels of the sources it
+// cited. sessionID is set for answers within a chat session.
+func (t *TimestampsHandlers) recordAnswerInsight(userID uuid.UUID, videoID, question, answer string, citations []Citation, started time.Time, sessionID *uuid.UUID, used prompts.Versions) *uuid.UUID {
+	cited := []string{}
+	for _, citation := range citations {
+		if citation.Cited {
+			cited = append(cited, citation.Label)
+		}
+	}
+
+	prompt := prompts.Question
+	metadata := gin.H{
+		"sources": len(citations),
+		"cited":   cited,
+	}
+	if sessionID != nil {
+		prompt = prompts.Chat
+		metadata["session_id"] = sessionID
+	} else if _, ok := used[prompts.Library]; ok {
+		prompt = prompts.Library
+	}
+
+	return t.recordInsight(userID, insightRecord{
+		VideoID:  videoID,
+		Type:     models.AIInsightTypeAnswer,
+		Title:    question,
+		Input:    question,
+		Content:  answer,
+		Started:  started,
+		Metadata: metadata,
+		Prompt:   prompt,
+		Prompts:  used,
+	})
+}
@@ -0,0 +1,287 @@
+package timestamps
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+	"github.com/shubhamku044/ytclipper/internal/jobs"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/services"
+	"github.com/uptrace/bun"
+)
+
+// reembedPageSize is how many notes or chunks are embedded and committed at
+// a time, bounding the work lost when a re-embedding job is interrupted
+const reembedPageSize = 500
+
+// reembedPayload migrates vectors to another embedding model. The vectors of
+// the active model are left in place, so search keeps working until
+// OPENAI_EMBEDDING_MODEL is switched to Model.
+type reembedPayload struct {
+	Model string `json:"model"`
+	// From is the model whose transcript chunks are copied, the active model
+	// when the job was queued
+	From string `json:"from"`
+	// AllUsers migrates every user's vectors instead of only the job owner's
+	AllUsers bool `json:"all_users,omitempty"`
+}
+
+type reembedResult struct {
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions,omitempty"`
+	Notes      int    `json:"notes"`
+	Chunks     int    `json:"chunks"`
+	Skipped    int    `json:"skipped"`
+	Failed     int    `json:"failed"`
+}
+
+func reembedKey(model string, userID *uuid.UUID) string {
+	if userID == nil {
+		return fmt.Sprintf("%s:%s:all", JobReembed, model)
+	}
+	return fmt.Sprintf("%s:%s:%s", JobReembed, model, userID)
+}
+
+// runReembedJob embeds every note and transcript chunk that has no vector
+// from the target model yet. Pages are committed as they finish and done
+// rows are skipped, so a retried or restarted job resumes where it stopped.
+func (t *TimestampsHandlers) runReembedJob(ctx context.Context, job *models.Job, progress jobs.ProgressFunc) (any, error) {
+	var payload reembedPayload
+	if err := jobs.Decode(job, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Model == "" {
+		return nil, jobs.Permanent(errors.New("re-embedding job has no target model"))
+	}
+
+	var userID *uuid.UUID
+	if payload.AllUsers {
+		// The vectors belong to many users; don't charge the job owner
+		ctx = services.WithTokenUser(ctx, uuid.Nil)
+	} else {
+		if job.UserID == nil {
+			return nil, jobs.Permanent(errors.New("re-embedding job has no user"))
+		}
+		userID = job.UserID
+	}
+
+	if _, err := t.aiService.embedderFor(payload.Model); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+
+	notes, err := t.reembedNotesQuery(payload.Model, userID, uuid.Nil).Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count notes: %w", err)
+	}
+	chunks, err := t.reembedChunksQuery(payload.Model, payload.From, userID, 0).Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count transcript chunks: %w", err)
+	}
+
+	total, done := notes+chunks, 0
+	progress(done, total)
+	advance := func(n int) {
+		done += n
+		progress(done, total)
+	}
+
+	result := &reembedResult{Model: payload.Model}
+	var lastErr error
+	if err := t.reembedNotes(ctx, payload.Model, userID, result, &lastErr, advance); err != nil {
+		return nil, err
+	}
+	if err := t.reembedChunks(ctx, payload.Model, payload.From, userID, result, &lastErr, advance); err != nil {
+		return nil, err
+	}
+
+	if result.Failed > 0 {
+		return nil, fmt.Errorf("%d of %d embeddings failed, last error: %w", result.Failed, total, lastErr)
+	}
+
+	log.Printf("Re-embedded %d notes and %d transcript chunks with %s", result.Notes, result.Chunks, payload.Model)
+	return result, nil
+}
+
+func (t *TimestampsHandlers) reembedNotesQuery(model string, userID *uuid.UUID, after uuid.UUID) *bun.SelectQuery {
+	query := t.db.DB.NewSelect().
+		Model((*models.Timestamp)(nil)).
+		Where("?TableAlias.deleted_at IS NULL").
+		Where("NOT EXISTS (SELECT 1 FROM timestamp_embeddings AS tse WHERE tse.timestamp_id = ?TableAlias.id AND tse.model = ?)", model)
+	if userID != nil {
+		query = query.Where("?TableAlias.user_id = ?", *userID)
+	}
+	if after != uuid.Nil {
+		query = query.Where("?TableAlias.id > ?", after)
+	}
+	return query
+}
+
+func (t *TimestampsHandlers) reembedChunksQuery(model, from string, userID *uuid.UUID, after int64) *bun.SelectQuery {
+	query := t.db.DB.NewSelect().
+		Model((*models.TranscriptEmbedding)(nil)).
+		Where("?TableAlias.embedding_model = ?", from).
+		Where(`NOT EXISTS (
+			SELECT 1 FROM transcript_embeddings AS other
+			WHERE other.video_id = ?TableAlias.video_id
+			AND other.user_id = ?TableAlias.user_id
+			AND other.chunk_strategy = ?TableAlias.chunk_strategy
+			AND other.chunk_index = ?TableAlias.chunk_index
+			AND other.embedding_model = ?
+		)`, model)
+	if userID != nil {
+		query = query.Where("?TableAlias.user_id = ?", *userID)
+	}
+	if after > 0 {
+		query = query.Where("?TableAlias.id > ?", after)
+	}
+	return query
+}
+
+func (t *TimestampsHandlers) reembedNotes(ctx context.Context, model string, userID *uuid.UUID, result *reembedResult, lastErr *error, advance func(int)) error {
+	after := uuid.Nil
+	for {
+		var page []models.Timestamp
+		err := t.reembedNotesQuery(model, userID, after).
+			Model(&page).
+			Relation("Tags").
+			OrderExpr("?TableAlias.id ASC").
+			Limit(reembedPageSize).
+			Scan(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch notes: %w", err)
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		after = page[len(page)-1].ID
+
+		var pending []models.Timestamp
+		var texts []string
+		for _, ts := range page {
+			var tagNames []string
+			for _, tag := range ts.Tags {
+				tagNames = append(tagNames, tag.Name)
+			}
+			if text := t.aiService.CreateEmbeddingText(ts.Title, ts.Note, tagNames); text != "" {
+				pending = append(pending, ts)
+				texts = append(texts, text)
+			}
+		}
+		result.Skipped += len(page) - len(pending)
+
+		results, err := t.aiService.GenerateEmbeddingsWith(ctx, model, texts, nil)
+		if err != nil {
+			return err
+		}
+
+		var rows []models.TimestampEmbedding
+		for i, embedded := range results {
+			if embedded.Err != nil {
+				result.Failed++
+				*lastErr = fmt.Errorf("timestamp %s: %w", pending[i].ID, embedded.Err)
+				continue
+			}
+			if err := t.ensureReembedIndexes(ctx, model, len(embedded.Embedding), result); err != nil {
+				return err
+			}
+			rows = append(rows, newTimestampEmbedding(&pending[i], model, embedded.Embedding))
+		}
+		if err := saveTimestampEmbeddings(ctx, t.db.DB, rows); err != nil {
+			return err
+		}
+
+		result.Notes += len(rows)
+		advance(len(page))
+	}
+}
+
+func (t *TimestampsHandlers) reembedChunks(ctx context.Context, model, from string, userID *uuid.UUID, result *reembedResult, lastErr *error, advance func(int)) error {
+	var after int64
+	for {
+		var page []models.TranscriptEmbedding
+		err := t.reembedChunksQuery(model, from, userID, after).
+			Model(&page).
+			ExcludeColumn("embedding").
+			OrderExpr("?TableAlias.id ASC").
+			Limit(reembedPageSize).
+			Scan(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to fetch transcript chunks: %w", err)
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		after = page[len(page)-1].ID
+
+		texts := make([]string, len(page))
+		for i, chunk := range page {
+			texts[i] = chunk.Text
+		}
+
+		results, err := t.aiService.GenerateEmbeddingsWith(ctx, model, texts, nil)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var rows []models.TranscriptEmbedding
+		for i, embedded := range results {
+			if embedded.Err != nil {
+				result.Failed++
+				*lastErr = fmt.Errorf("transcript chunk %d: %w", page[i].ID, embedded.Err)
+				continue
+			}
+			if err := t.ensureReembedIndexes(ctx, model, len(embedded.Embedding), result); err != nil {
+				return err
+			}
+
+			row := page[i]
+			row.ID = 0
+			row.EmbeddingModel = model
+			row.EmbeddingDimensions = len(embedded.Embedding)
+			row.Embedding = pgvector.NewVector(embedded.Embedding)
+			row.CreatedAt = now
+			row.UpdatedAt = now
+			rows = append(rows, row)
+		}
+
+		if len(rows) > 0 {
+			_, err := t.db.DB.NewInsert().
+				Model(&rows).
+				ExcludeColumn("id").
+				On("CONFLICT (video_id, user_id, chunk_strategy, embedding_model, chunk_index) DO UPDATE").
+				Set("embedding = EXCLUDED.embedding").
+				Set("embedding_dimensions = EXCLUDED.embedding_dimensions").
+				Set("updated_at = EXCLUDED.updated_at").
+				Exec(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to save transcript chunks: %w", err)
+			}
+		}
+
+		result.Chunks += len(rows)
+		advance(len(page))
+	}
+}
+
+// ensureReembedIndexes creates the target model's vector indexes once its
+// dimension is known from the first vector, before any rows are stored
+func (t *TimestampsHandlers) ensureReembedIndexes(ctx context.Context, model string, dims int, result *reembedResult) error {
+	if result.Dimensions == dims {
+		return nil
+	}
+	if result.Dimensions != 0 {
+		return jobs.Permanent(fmt.Errorf("model %s returned %d-dimensional vectors after %d-dimensional ones", model, dims, result.Dimensions))
+	}
+
+	if err := t.searchService.EnsureVectorIndexes(ctx, model, dims); err != nil {
+		return err
+	}
+	result.Dimensions = dims
+	return nil
+}
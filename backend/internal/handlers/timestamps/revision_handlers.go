@@ -0,0 +1,242 @@
+package timestamps
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// ListTimestampRevisions lists the revisions of a note, newest first
+func (t *TimestampsHandlers) ListTimestampRevisions(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	timestampID, ok := parseTimestampID(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := t.revisionService.List(c.Request.Context(), userID, timestampID)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "TIMESTAMP_NOT_FOUND", "Timestamp not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch revisions", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"timestamp_id": timestampID,
+		"revisions":    revisions,
+		"count":        len(revisions),
+	})
+}
+
+// DiffTimestampRevisions compares two revisions of a note. to defaults to the
+// latest revision and from to the one before to.
+func (t *TimestampsHandlers) DiffTimestampRevisions(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	timestampID, ok := parseTimestampID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	to, ok := parseRevisionNumber(c, c.Query("to"))
+	if !ok {
+		return
+	}
+	if to == 0 {
+		revisions, err := t.revisionService.List(ctx, userID, timestampID)
+		if err != nil && !errors.Is(err, errNotFound) {
+			middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch revisions", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if len(revisions) == 0 {
+			middleware.RespondWithError(c, http.StatusNotFound, "REVISION_NOT_FOUND", "Timestamp has no revisions", nil)
+			return
+		}
+		to = revisions[0].Revision
+	}
+
+	from, ok := parseRevisionNumber(c, c.Query("from"))
+	if !ok {
+		return
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+
+	fromRevision, ok := t.loadRevision(c, userID, timestampID, from)
+	if !ok {
+		return
+	}
+	toRevision, ok := t.loadRevision(c, userID, timestampID, to)
+	if !ok {
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"timestamp_id": timestampID,
+		"from":         fromRevision,
+		"to":           toRevision,
+		"changes":      diffRevisions(fromRevision, toRevision),
+	})
+}
+
+// RestoreTimestampRevision sets a note back to one of its revisions
+func (t *TimestampsHandlers) RestoreTimestampRevision(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	timestampID, ok := parseTimestampID(c)
+	if !ok {
+		return
+	}
+
+	number, ok := parseRevisionNumber(c, c.Param("revision"))
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	revision, err := t.revisionService.Restore(ctx, userID, timestampID, number)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "REVISION_NOT_FOUND", "Revision not found", nil)
+		return
+	}
+	if errors.Is(err, errTimestampDeleted) {
+		middleware.RespondWithError(c, http.StatusConflict, "TIMESTAMP_DELETED", "Undelete the timestamp before restoring a revision", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to restore revision", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if _, err := t.enqueueTimestampEmbedding(ctx, userID, timestampID); err != nil {
+		log.Printf("Failed to queue embedding for timestamp %s: %v", timestampID, err)
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"revision": revision,
+		"message":  "Revision restored successfully",
+	})
+}
+
+// UndeleteTimestamp brings back a deleted note
+func (t *TimestampsHandlers) UndeleteTimestamp(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	timestampID, ok := parseTimestampID(c)
+	if !ok {
+		return
+	}
+
+	revision, err := t.revisionService.Undelete(c.Request.Context(), userID, timestampID)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "TIMESTAMP_NOT_FOUND", "Deleted timestamp not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to undelete timestamp", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"revision": revision,
+		"message":  "Timestamp undeleted successfully",
+	})
+}
+
+// ListDeletedTimestamps lists the user's deleted notes, which can be
+// undeleted
+func (t *TimestampsHandlers) ListDeletedTimestamps(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	timestamps, err := t.revisionService.ListDeleted(c.Request.Context(), userID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch deleted timestamps", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"timestamps": timestamps,
+		"count":      len(timestamps),
+	})
+}
+
+func (t *TimestampsHandlers) loadRevision(c *gin.Context, userID, timestampID uuid.UUID, number int) (*models.TimestampRevision, bool) {
+	revision, err := t.revisionService.Get(c.Request.Context(), userID, timestampID, number)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "REVISION_NOT_FOUND", "Revision not found", gin.H{
+			"revision": number,
+		})
+		return nil, false
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch revision", gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+	return revision, true
+}
+
+func parseTimestampID(c *gin.Context) (uuid.UUID, bool) {
+	timestampID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_TIMESTAMP_ID", "Invalid timestamp ID format", gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return timestampID, true
+}
+
+// parseRevisionNumber parses a revision number, returning 0 when it is empty
+func parseRevisionNumber(c *gin.Context, value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REVISION", "Revision must be a positive number", gin.H{
+			"revision": value,
+		})
+		return 0, false
+	}
+	return number, true
+}
@@ -0,0 +1,512 @@
+package timestamps
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+)
+
+// Fields of a note tracked by its revisions
+const (
+	RevisionFieldVideoID    = "video_id"
+	RevisionFieldTimestamp  = "timestamp"
+	RevisionFieldEndTime    = "end_time"
+	RevisionFieldType       = "type"
+	RevisionFieldImportance = "importance"
+	RevisionFieldTitle      = "title"
+	RevisionFieldNote       = "note"
+	RevisionFieldTags       = "tags"
+
+	DiffOpEqual  = "equal"
+	DiffOpInsert = "insert"
+	DiffOpDelete = "delete"
+
+	// maxDiffCells bounds the size of the line diff table, past which a
+	// changed note is shown as removed and re-added in full
+	maxDiffCells = 1_000_000
+)
+
+// errTimestampDeleted is returned when restoring a revision of a deleted
+// note, which has to be undeleted first
+var errTimestampDeleted = errors.New("timestamp is deleted")
+
+// RevisionChange is one field that differs between two revisions. Notes
+// come with a line diff and tags with the tags added and removed.
+type RevisionChange struct {
+	Field   string      `json:"field"`
+	From    interface{} `json:"from"`
+	To      interface{} `json:"to"`
+	Lines   []DiffLine  `json:"lines,omitempty"`
+	Added   []string    `json:"added,omitempty"`
+	Removed []string    `json:"removed,omitempty"`
+}
+
+// DiffLine is a line kept, inserted or deleted between two versions of a
+// note
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// RevisionService keeps the history of notes. Revisions are written in the
+// transaction that changes the note so both commit together.
+type RevisionService struct {
+	db         *database.Database
+	tagService *TagService
+}
+
+func NewRevisionService(db *database.Database, tagService *TagService) *RevisionService {
+	return &RevisionService{
+		db:         db,
+		tagService: tagService,
+	}
+}
+
+// EnsureBaseline records the note's current state as its first revision
+// when it has none, so notes made before revisions were kept can be
+// restored to how they were before their first tracked change. It returns
+// errNotFound for deleted notes.
+func (rs *RevisionService) EnsureBaseline(ctx context.Context, tx bun.Tx, userID, timestampID uuid.UUID) error {
+	latest, err := latestRevision(ctx, tx, userID, timestampID)
+	if err != nil || latest != nil {
+		return err
+	}
+
+	note, err := loadRevisedNote(ctx, tx, userID, timestampID)
+	if err != nil {
+		return err
+	}
+	if !note.DeletedAt.IsZero() {
+		return errNotFound
+	}
+
+	revision := revisionOf(note)
+	revision.Revision = 1
+	revision.UserID = userID
+	revision.Action = models.RevisionActionCreated
+	revision.CreatedAt = note.UpdatedAt
+	return insertRevision(ctx, tx, &revision)
+}
+
+// Record stores the note's current state as its next revision, listing the
+// fields changed since the previous one. Updates that change nothing are
+// not recorded and return nil.
+func (rs *RevisionService) Record(ctx context.Context, tx bun.Tx, userID, timestampID uuid.UUID, action models.RevisionAction, restoredFrom *int) (*models.TimestampRevision, error) {
+	note, err := loadRevisedNote(ctx, tx, userID, timestampID)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := latestRevision(ctx, tx, userID, timestampID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := revisionOf(note)
+	revision.Revision = 1
+	revision.UserID = userID
+	revision.Action = action
+	revision.RestoredFrom = restoredFrom
+	revision.CreatedAt = time.Now().UTC()
+	if latest != nil {
+		revision.Revision = latest.Revision + 1
+		revision.ChangedFields = changedFields(latest, &revision)
+		if action == models.RevisionActionUpdated && len(revision.ChangedFields) == 0 {
+			return nil, nil
+		}
+	}
+
+	if err := insertRevision(ctx, tx, &revision); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// List returns the revisions of one of the user's notes, newest first
+func (rs *RevisionService) List(ctx context.Context, userID, timestampID uuid.UUID) ([]models.TimestampRevision, error) {
+	revisions := []models.TimestampRevision{}
+	err := rs.db.DB.NewSelect().
+		Model(&revisions).
+		Where("timestamp_id = ? AND user_id = ?", timestampID, userID).
+		Order("revision DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+
+	if len(revisions) == 0 {
+		// Notes made before revisions were kept have none yet
+		exists, err := rs.db.DB.NewSelect().
+			Model((*models.Timestamp)(nil)).
+			WhereAllWithDeleted().
+			Where("id = ? AND user_id = ?", timestampID, userID).
+			Exists(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check timestamp: %w", err)
+		}
+		if !exists {
+			return nil, errNotFound
+		}
+	}
+
+	return revisions, nil
+}
+
+// Get returns one revision of one of the user's notes
+func (rs *RevisionService) Get(ctx context.Context, userID, timestampID uuid.UUID, number int) (*models.TimestampRevision, error) {
+	var revision models.TimestampRevision
+	err := rs.db.DB.NewSelect().
+		Model(&revision).
+		Where("timestamp_id = ? AND user_id = ? AND revision = ?", timestampID, userID, number).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revision: %w", err)
+	}
+	return &revision, nil
+}
+
+// Restore sets the note back to the state of one of its revisions,
+// recording that as a new revision
+func (rs *RevisionService) Restore(ctx context.Context, userID, timestampID uuid.UUID, number int) (*models.TimestampRevision, error) {
+	target, err := rs.Get(ctx, userID, timestampID, number)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := rs.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NewUpdate().
+		Model((*models.Timestamp)(nil)).
+		Set("video_id = ?", target.VideoID).
+		Set("timestamp = ?", target.Timestamp).
+		Set("end_time = ?", target.EndTime).
+		Set("type = ?", target.Type).
+		Set("importance = ?", target.Importance).
+		Set("title = ?", target.Title).
+		Set("note = ?", target.Note).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ? AND user_id = ?", timestampID, userID).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore timestamp: %w", err)
+	}
+	if err := requireRowsAffected(result); err != nil {
+		// The revision exists, so the note does too but is deleted
+		return nil, errTimestampDeleted
+	}
+
+	if err := rs.tagService.ReplaceTimestampTagsWithTx(ctx, tx, userID, timestampID, target.Tags); err != nil {
+		return nil, err
+	}
+
+	revision, err := rs.Record(ctx, tx, userID, timestampID, models.RevisionActionRestored, &target.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return revision, nil
+}
+
+// Undelete brings back one of the user's deleted notes with the tags it had
+// when it was deleted
+func (rs *RevisionService) Undelete(ctx context.Context, userID, timestampID uuid.UUID) (*models.TimestampRevision, error) {
+	tx, err := rs.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.NewUpdate().
+		Model((*models.Timestamp)(nil)).
+		WhereDeleted().
+		Set("deleted_at = NULL").
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ? AND user_id = ?", timestampID, userID).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to undelete timestamp: %w", err)
+	}
+	if err := requireRowsAffected(result); err != nil {
+		return nil, err
+	}
+
+	// Tag relations are removed on delete, the last revision has the tags
+	latest, err := latestRevision(ctx, tx, userID, timestampID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		if err := rs.tagService.ReplaceTimestampTagsWithTx(ctx, tx, userID, timestampID, latest.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	revision, err := rs.Record(ctx, tx, userID, timestampID, models.RevisionActionUndeleted, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit undelete: %w", err)
+	}
+	return revision, nil
+}
+
+// ListDeleted returns the user's deleted notes, most recently deleted first
+func (rs *RevisionService) ListDeleted(ctx context.Context, userID uuid.UUID) ([]models.Timestamp, error) {
+	timestamps := []models.Timestamp{}
+	err := rs.db.DB.NewSelect().
+		Model(&timestamps).
+		WhereDeleted().
+		Where("user_id = ?", userID).
+		Order("deleted_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted timestamps: %w", err)
+	}
+	return timestamps, nil
+}
+
+// loadRevisedNote loads one of the user's notes with its tags, deleted or
+// not
+func loadRevisedNote(ctx context.Context, tx bun.Tx, userID, timestampID uuid.UUID) (*models.Timestamp, error) {
+	var note models.Timestamp
+	err := tx.NewSelect().
+		Model(&note).
+		Relation("Tags").
+		WhereAllWithDeleted().
+		Where("?TableAlias.id = ? AND ?TableAlias.user_id = ?", timestampID, userID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch timestamp: %w", err)
+	}
+	return &note, nil
+}
+
+func latestRevision(ctx context.Context, tx bun.Tx, userID, timestampID uuid.UUID) (*models.TimestampRevision, error) {
+	var revision models.TimestampRevision
+	err := tx.NewSelect().
+		Model(&revision).
+		Where("timestamp_id = ? AND user_id = ?", timestampID, userID).
+		Order("revision DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest revision: %w", err)
+	}
+	return &revision, nil
+}
+
+func insertRevision(ctx context.Context, tx bun.Tx, revision *models.TimestampRevision) error {
+	if revision.ChangedFields == nil {
+		revision.ChangedFields = []string{}
+	}
+	if _, err := tx.NewInsert().Model(revision).Exec(ctx); err != nil {
+		return fmt.Errorf("failed to record revision: %w", err)
+	}
+	return nil
+}
+
+// revisionOf snapshots the note's fields, with its tag names sorted
+func revisionOf(note *models.Timestamp) models.TimestampRevision {
+	tags := make([]string, 0, len(note.Tags))
+	for _, tag := range note.Tags {
+		tags = append(tags, tag.Name)
+	}
+	sort.Strings(tags)
+
+	return models.TimestampRevision{
+		TimestampID: note.ID,
+		VideoID:     note.VideoID,
+		Timestamp:   note.Timestamp,
+		EndTime:     note.EndTime,
+		Type:        note.Type,
+		Importance:  note.Importance,
+		Title:       note.Title,
+		Note:        note.Note,
+		Tags:        tags,
+	}
+}
+
+// changedFields lists the tracked fields that differ between two revisions
+func changedFields(from, to *models.TimestampRevision) []string {
+	var fields []string
+	if from.VideoID != to.VideoID {
+		fields = append(fields, RevisionFieldVideoID)
+	}
+	if from.Timestamp != to.Timestamp {
+		fields = append(fields, RevisionFieldTimestamp)
+	}
+	if !equalOptionalFloat(from.EndTime, to.EndTime) {
+		fields = append(fields, RevisionFieldEndTime)
+	}
+	if from.Type != to.Type {
+		fields = append(fields, RevisionFieldType)
+	}
+	if from.Importance != to.Importance {
+		fields = append(fields, RevisionFieldImportance)
+	}
+	if from.Title != to.Title {
+		fields = append(fields, RevisionFieldTitle)
+	}
+	if from.Note != to.Note {
+		fields = append(fields, RevisionFieldNote)
+	}
+	if !slices.Equal(from.Tags, to.Tags) {
+		fields = append(fields, RevisionFieldTags)
+	}
+	return fields
+}
+
+// changesEmbeddedText reports whether any of the fields a note's embedding
+// is made from changed
+func changesEmbeddedText(fields []string) bool {
+	for _, field := range fields {
+		switch field {
+		case RevisionFieldTitle, RevisionFieldNote, RevisionFieldTags:
+			return true
+		}
+	}
+	return false
+}
+
+// diffRevisions describes how each changed field went from one revision to
+// the other
+func diffRevisions(from, to *models.TimestampRevision) []RevisionChange {
+	changes := []RevisionChange{}
+	for _, field := range changedFields(from, to) {
+		change := RevisionChange{Field: field}
+		switch field {
+		case RevisionFieldVideoID:
+			change.From, change.To = from.VideoID, to.VideoID
+		case RevisionFieldTimestamp:
+			change.From, change.To = from.Timestamp, to.Timestamp
+		case RevisionFieldEndTime:
+			change.From, change.To = from.EndTime, to.EndTime
+		case RevisionFieldType:
+			change.From, change.To = from.Type, to.Type
+		case RevisionFieldImportance:
+			change.From, change.To = from.Importance, to.Importance
+		case RevisionFieldTitle:
+			change.From, change.To = from.Title, to.Title
+		case RevisionFieldNote:
+			change.From, change.To = from.Note, to.Note
+			change.Lines = diffLines(from.Note, to.Note)
+		case RevisionFieldTags:
+			change.From, change.To = from.Tags, to.Tags
+			change.Added = subtractTags(to.Tags, from.Tags)
+			change.Removed = subtractTags(from.Tags, to.Tags)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// diffLines is a line diff of two texts from their longest common
+// subsequence of lines
+func diffLines(from, to string) []DiffLine {
+	a, b := splitLines(from), splitLines(to)
+
+	if len(a)*len(b) > maxDiffCells {
+		lines := make([]DiffLine, 0, len(a)+len(b))
+		for _, line := range a {
+			lines = append(lines, DiffLine{Op: DiffOpDelete, Text: line})
+		}
+		for _, line := range b {
+			lines = append(lines, DiffLine{Op: DiffOpInsert, Text: line})
+		}
+		return lines
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]DiffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, DiffLine{Op: DiffOpEqual, Text: a[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, DiffLine{Op: DiffOpDelete, Text: a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: DiffOpInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, DiffLine{Op: DiffOpDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, DiffLine{Op: DiffOpInsert, Text: b[j]})
+	}
+	return lines
+}
+
+// splitLines splits text into lines; empty text has none
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// subtractTags returns the tags in tags that aren't in other
+func subtractTags(tags, other []string) []string {
+	var result []string
+	for _, tag := range tags {
+		if !slices.Contains(other, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func equalOptionalFloat(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,85 @@
+package timestamps
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []DiffLine
+	}{
+		{
+			name: "unchanged",
+			from: "a\nb",
+			to:   "a\nb",
+			want: []DiffLine{{Op: DiffOpEqual, Text: "a"}, {Op: DiffOpEqual, Text: "b"}},
+		},
+		{
+			name: "line inserted",
+			from: "a\nc",
+			to:   "a\nb\nc",
+			want: []DiffLine{{Op: DiffOpEqual, Text: "a"}, {Op: DiffOpInsert, Text: "b"}, {Op: DiffOpEqual, Text: "c"}},
+		},
+		{
+			name: "line deleted",
+			from: "a\nb\nc",
+			to:   "a\nc",
+			want: []DiffLine{{Op: DiffOpEqual, Text: "a"}, {Op: DiffOpDelete, Text: "b"}, {Op: DiffOpEqual, Text: "c"}},
+		},
+		{
+			name: "line replaced",
+			from: "a\nb\nc",
+			to:   "a\nx\nc",
+			want: []DiffLine{{Op: DiffOpEqual, Text: "a"}, {Op: DiffOpDelete, Text: "b"}, {Op: DiffOpInsert, Text: "x"}, {Op: DiffOpEqual, Text: "c"}},
+		},
+		{
+			name: "appended",
+			from: "a",
+			to:   "a\nb\nc",
+			want: []DiffLine{{Op: DiffOpEqual, Text: "a"}, {Op: DiffOpInsert, Text: "b"}, {Op: DiffOpInsert, Text: "c"}},
+		},
+		{
+			name: "from empty",
+			from: "",
+			to:   "a",
+			want: []DiffLine{{Op: DiffOpInsert, Text: "a"}},
+		},
+		{
+			name: "to empty",
+			from: "a",
+			to:   "",
+			want: []DiffLine{{Op: DiffOpDelete, Text: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesTooLarge(t *testing.T) {
+	from := strings.Repeat("a\n", 1500) + "a"
+	to := strings.Repeat("b\n", 1500) + "b"
+
+	lines := diffLines(from, to)
+	if len(lines) != 3002 {
+		t.Fatalf("got %d lines, want 3002", len(lines))
+	}
+	for i, line := range lines {
+		want := DiffOpDelete
+		if i >= 1501 {
+			want = DiffOpInsert
+		}
+		if line.Op != want {
+			t.Fatalf("line %d is %q, want %q", i, line.Op, want)
+		}
+	}
+}
@@ -8,7 +8,7 @@ import (
 func SetupTimestampRoutes(router *gin.RouterGroup, handlers *TimestampsHandlers, authMiddleware *authhandlers.AuthMiddleware) {
 	timestampRoutes := router.Group("/timestamps")
 	{
-		timestampRoutes.Use(authMiddleware.RequireAuth())
+		timestampRoutes.Use(authMiddleware.RequireAuth(), attributeTokenUsage())
 
 		// Get all timestamps
 		timestampRoutes.GET("", handlers.GetAllTimestamps)
@@ -31,9 +31,30 @@ func SetupTimestampRoutes(router *gin.RouterGroup, handlers *TimestampsHandlers,
 		timestampRoutes.DELETE("", handlers.DeleteMultipleTimestamps)
 		timestampRoutes.DELETE("/", handlers.DeleteMultipleTimestamps)
 
+		// Tag, move, shift, retype or delete many notes at once
+		timestampRoutes.POST("/bulk", handlers.BulkTimestamps)
+
+		// Revision history, restore and undelete
+		timestampRoutes.GET("/deleted", handlers.ListDeletedTimestamps)
+		timestampRoutes.POST("/:id/undelete", handlers.UndeleteTimestamp)
+		timestampRoutes.GET("/:id/revisions", handlers.ListTimestampRevisions)
+		timestampRoutes.GET("/:id/revisions/diff", handlers.DiffTimestampRevisions)
+		timestampRoutes.POST("/:id/revisions/:revision/restore", handlers.RestoreTimestampRevision)
+
+		// Markdown preview and image attachments
+		timestampRoutes.POST("/preview", handlers.PreviewNote)
+		timestampRoutes.GET("/:id/attachments", handlers.ListTimestampAttachments)
+		timestampRoutes.POST("/:id/attachments", handlers.UploadTimestampAttachment)
+		timestampRoutes.GET("/attachments/:attachmentId", handlers.GetTimestampAttachment)
+		timestampRoutes.DELETE("/attachments/:attachmentId", handlers.DeleteTimestampAttachment)
+
 		// Tags management
 		timestampRoutes.GET("/tags", handlers.GetAllTags)
 		timestampRoutes.POST("/tags/search", handlers.SearchTags)
+		timestampRoutes.POST("/tags/suggest", handlers.SuggestTags)
+		timestampRoutes.GET("/tags/tree", handlers.GetTagTree)
+		timestampRoutes.PUT("/tags/:tagId", handlers.UpdateTag)
+		timestampRoutes.POST("/tags/merge", handlers.MergeTags)
 
 		// Search timestamps
 		timestampRoutes.POST("/search", handlers.SearchTimestamps)
@@ -45,6 +66,38 @@ func SetupTimestampRoutes(router *gin.RouterGroup, handlers *TimestampsHandlers,
 		timestampRoutes.GET("/summary/:id", handlers.GetVideoSummary)
 		timestampRoutes.POST("/full-summary", handlers.GenerateFullVideoSummary)
 		timestampRoutes.POST("/question", handlers.AnswerQuestion)
+		timestampRoutes.GET("/ai/cache/stats", handlers.GetAICacheStats)
+		timestampRoutes.GET("/ai/usage", handlers.GetAITokenUsage)
+
+		// AI output history and feedback
+		timestampRoutes.GET("/insights", handlers.ListAIInsights)
+		timestampRoutes.GET("/insights/stats", handlers.GetAIInsightStats)
+		timestampRoutes.GET("/insights/:insightId", handlers.GetAIInsight)
+		timestampRoutes.PUT("/insights/:insightId/feedback", handlers.UpdateAIInsightFeedback)
+		timestampRoutes.POST("/insights/:insightId/flag", handlers.FlagAIInsight)
+		timestampRoutes.DELETE("/insights/:insightId/flag", handlers.UnflagAIInsight)
+		timestampRoutes.GET("/prompts", handlers.ListPrompts)
+
+		// Suggested chapters and key moments
+		timestampRoutes.POST("/suggestions/:videoId", handlers.SuggestTimestamps)
+		timestampRoutes.POST("/suggestions/:videoId/accept", handlers.AcceptTimestampSuggestions)
+
+		// Stored video transcripts
+		timestampRoutes.GET("/transcripts/:videoId", handlers.GetTranscript)
+		timestampRoutes.GET("/transcripts/:videoId/languages", handlers.ListTranscriptLanguages)
+		timestampRoutes.POST("/transcripts/:videoId/refresh", handlers.RefreshTranscript)
+		timestampRoutes.POST("/transcripts/:videoId/upload", handlers.UploadTranscript)
+		timestampRoutes.DELETE("/transcripts/:videoId/upload", handlers.DeleteUploadedTranscript)
+		timestampRoutes.GET("/transcripts/:videoId/chunks", handlers.ListTranscriptChunks)
+		timestampRoutes.POST("/transcripts/:videoId/rechunk", handlers.RechunkTranscript)
+
+		// Multi-turn chat sessions
+		timestampRoutes.POST("/chat/sessions", handlers.CreateChatSession)
+		timestampRoutes.GET("/chat/sessions", handlers.ListChatSessions)
+		timestampRoutes.GET("/chat/sessions/:sessionId", handlers.GetChatSession)
+		timestampRoutes.PUT("/chat/sessions/:sessionId", handlers.RenameChatSession)
+		timestampRoutes.DELETE("/chat/sessions/:sessionId", handlers.DeleteChatSession)
+		timestampRoutes.POST("/chat/sessions/:sessionId/messages", handlers.SendChatMessage)
 
 		// Test streaming endpoint
 		timestampRoutes.GET("/test-stream", handlers.TestStreaming)
@@ -54,5 +107,17 @@ func SetupTimestampRoutes(router *gin.RouterGroup, handlers *TimestampsHandlers,
 		timestampRoutes.GET("/embeddings/status", handlers.GetEmbeddingStatus)
 		timestampRoutes.POST("/embeddings/process-user", handlers.ProcessMissingEmbeddingsForUser)
 		timestampRoutes.POST("/embeddings/process-all", handlers.ProcessAllMissingEmbeddings)
+		timestampRoutes.POST("/embeddings/reembed", handlers.ReembedEmbeddings)
+	}
+}
+
+func SetupSearchRoutes(router *gin.RouterGroup, handlers *TimestampsHandlers, authMiddleware *authhandlers.AuthMiddleware) {
+	searchRoutes := router.Group("/search")
+	{
+		searchRoutes.Use(authMiddleware.RequireAuth(), attributeTokenUsage())
+
+		// Hybrid search across notes, transcripts and video titles
+		searchRoutes.POST("", handlers.UnifiedSearch)
+		searchRoutes.POST("/", handlers.UnifiedSearch)
 	}
 }
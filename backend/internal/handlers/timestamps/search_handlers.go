@@ -0,0 +1,80 @@
+package timestamps
+
+import (
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+)
+
+// UnifiedSearch searches notes, transcripts and video titles at once, fusing
+// full-text and semantic rankings. If the query embedding cannot be generated
+// the search degrades to full-text only.
+func (t *TimestampsHandlers) UnifiedSearch(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req UnifiedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	types := req.Types
+	if len(types) == 0 {
+		types = searchTypes
+	}
+	for _, hitType := range types {
+		if !slices.Contains(searchTypes, hitType) {
+			middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_SEARCH_TYPE", "Invalid search type", gin.H{
+				"type":    hitType,
+				"allowed": searchTypes,
+			})
+			return
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > 50 {
+		limit = 20
+	}
+
+	// Embedding the query spends tokens, so users over their budget get
+	// full-text results only
+	semantic := false
+	var queryEmbedding []float32
+	budget, err := t.tokenUsageService.Budget(c.Request.Context(), userID)
+	switch {
+	case err != nil:
+		log.Printf("Falling back to full-text search, failed to check token budget: %v", err)
+	case budget.Exceeded():
+	default:
+		queryEmbedding, err = t.aiService.GenerateEmbedding(c.Request.Context(), req.Query)
+		if err != nil {
+			log.Printf("Falling back to full-text search, failed to embed query: %v", err)
+		}
+		semantic = err == nil
+	}
+
+	hits, err := t.searchService.HybridSearch(c.Request.Context(), userID, req.Query, queryEmbedding, types, req.filter(), limit)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "SEARCH_ERROR", "Failed to search", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"results":  hits,
+		"query":    req.Query,
+		"types":    types,
+		"semantic": semantic,
+		"count":    len(hits),
+	})
+}
@@ -0,0 +1,246 @@
+package timestamps
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+)
+
+// SearchFilter narrows a vector search. Tags only apply to notes.
+type SearchFilter struct {
+	VideoID  string
+	VideoIDs []string // Any of these videos, e.g. the scope of a library question
+	Tags     []string
+	// NoteTypes and MinImportance only apply to notes
+	NoteTypes     []string
+	MinImportance int
+	From          *time.Time
+	To            *time.Time
+}
+
+// SearchService ranks notes and transcript chunks by cosine distance in
+// Postgres. Only vectors from the active embedding model with the query's
+// dimension are compared, using that model's partial HNSW index. Transcript
+// searches only see chunks from the active chunking strategy.
+type SearchService struct {
+	db             *database.Database
+	chunkStrategy  string
+	embeddingModel string
+}
+
+func NewSearchService(db *database.Database, chunkStrategy, embeddingModel string) *SearchService {
+	return &SearchService{
+		db:             db,
+		chunkStrategy:  chunkStrategy,
+		embeddingModel: embeddingModel,
+	}
+}
+
+type timestampMatch struct {
+	models.Timestamp `bun:",extend"`
+	Distance         float64 `bun:"distance,scanonly"`
+}
+
+type transcriptMatch struct {
+	models.TranscriptEmbedding `bun:",extend"`
+	Distance                   float64 `bun:"distance,scanonly"`
+}
+
+func (ss *SearchService) SearchTimestamps(ctx context.Context, userID uuid.UUID, queryEmbedding []float32, filter SearchFilter, limit int) ([]ScoredTimestamp, error) {
+	var matches []timestampMatch
+	query := ss.db.DB.NewSelect().
+		Model(&matches).
+		ColumnExpr("?TableColumns").
+		Where("?TableAlias.user_id = ? AND ?TableAlias.deleted_at IS NULL", userID)
+
+	query = applySearchFilter(query, filter)
+	query = applyTagFilter(query, filter.Tags)
+	query = applyNoteFilter(query, filter)
+	query = ss.orderByDistance(query, SearchTypeNote, queryEmbedding)
+
+	if err := ss.scanNearest(ctx, query.Limit(limit)); err != nil {
+		return nil, fmt.Errorf("failed to search timestamps: %w", err)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Distance < matches[j].Distance
+	})
+
+	results := make([]ScoredTimestamp, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, ScoredTimestamp{
+			Timestamp: match.Timestamp,
+			Score:     float32(1 - match.Distance),
+		})
+	}
+
+	return results, nil
+}
+
+func (ss *SearchService) SearchTranscripts(ctx context.Context, userID uuid.UUID, queryEmbedding []float32, filter SearchFilter, limit int) ([]ScoredTranscriptEmbedding, error) {
+	var matches []transcriptMatch
+	query := ss.db.DB.NewSelect().
+		Model(&matches).
+		ColumnExpr("?TableColumns").
+		Where("?TableAlias.user_id = ? AND ?TableAlias.deleted_at IS NULL", userID).
+		Where("?TableAlias.chunk_strategy = ?", ss.chunkStrategy)
+
+	query = applySearchFilter(query, filter)
+	query = ss.orderByDistance(query, SearchTypeTranscript, queryEmbedding)
+
+	if err := ss.scanNearest(ctx, query.Limit(limit)); err != nil {
+		return nil, fmt.Errorf("failed to search transcript embeddings: %w", err)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Distance < matches[j].Distance
+	})
+
+	results := make([]ScoredTranscriptEmbedding, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, ScoredTranscriptEmbedding{
+			Embedding: match.TranscriptEmbedding,
+			Score:     1 - match.Distance,
+		})
+	}
+
+	return results, nil
+}
+
+// orderByDistance ranks notes or transcript chunks by cosine distance to the
+// query and selects it as distance. The vectors are cast to the query's
+// dimension so the expression matches the model's partial HNSW index.
+func (ss *SearchService) orderByDistance(query *bun.SelectQuery, hitType string, queryEmbedding []float32) *bun.SelectQuery {
+	vector := pgvector.NewVector(queryEmbedding)
+	dims := len(queryEmbedding)
+
+	if hitType == SearchTypeNote {
+		return query.
+			ColumnExpr("(tse.embedding::vector(?)) <=> ?::vector(?) AS distance", dims, vector, dims).
+			Join("JOIN timestamp_embeddings AS tse ON tse.timestamp_id = ?TableAlias.id").
+			Where("tse.model = ? AND tse.dimensions = ?", ss.embeddingModel, dims).
+			OrderExpr("(tse.embedding::vector(?)) <=> ?::vector(?)", dims, vector, dims)
+	}
+
+	return query.
+		ColumnExpr("(?TableAlias.embedding::vector(?)) <=> ?::vector(?) AS distance", dims, vector, dims).
+		Where("?TableAlias.embedding_model = ? AND ?TableAlias.embedding_dimensions = ?", ss.embeddingModel, dims).
+		OrderExpr("(?TableAlias.embedding::vector(?)) <=> ?::vector(?)", dims, vector, dims)
+}
+
+// scanNearest runs a query ordered by orderByDistance. The HNSW indexes hold
+// every user's vectors and the user and other filters only apply to the rows
+// a scan returns, so a single scan of hnsw.ef_search candidates can leave few
+// or none. Iterative scans keep reading the index until the limit is met,
+// but only roughly in order, so callers sort the rows by distance again.
+func (ss *SearchService) scanNearest(ctx context.Context, query *bun.SelectQuery, dest ...any) error {
+	return ss.db.DB.RunInTx(ctx, &sql.TxOptions{ReadOnly: true}, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL hnsw.iterative_scan = relaxed_order"); err != nil {
+			return err
+		}
+		return query.Conn(tx).Scan(ctx, dest...)
+	})
+}
+
+// EnsureVectorIndexes creates the partial HNSW indexes for a model's vectors.
+// Indexes are built concurrently so searches keep running meanwhile.
+func (ss *SearchService) EnsureVectorIndexes(ctx context.Context, model string, dims int) error {
+	indexes := []struct{ table, modelColumn, dimsColumn string }{
+		{"timestamp_embeddings", "model", "dimensions"},
+		{"transcript_embeddings", "embedding_model", "embedding_dimensions"},
+	}
+
+	for _, index := range indexes {
+		_, err := ss.db.DB.ExecContext(ctx,
+			"CREATE INDEX CONCURRENTLY IF NOT EXISTS ? ON ? USING hnsw ((embedding::vector(?)) vector_cosine_ops) WHERE ? = ? AND ? = ?",
+			bun.Ident(vectorIndexName(index.table, model, dims)), bun.Ident(index.table), dims,
+			bun.Ident(index.modelColumn), model, bun.Ident(index.dimsColumn), dims,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create %s vector index for %s: %w", index.table, model, err)
+		}
+	}
+
+	return nil
+}
+
+// vectorIndexName matches the names used by the migrations, e.g.
+// idx_timestamp_embeddings_text_embedding_3_small_1536
+func vectorIndexName(table, model string, dims int) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(model))
+
+	name := fmt.Sprintf("idx_%s_%s_%d", table, sanitized, dims)
+	if len(name) > 63 {
+		// Postgres truncates longer identifiers, so keep the dimension visible
+		suffix := fmt.Sprintf("_%d", dims)
+		name = name[:63-len(suffix)] + suffix
+	}
+	return name
+}
+
+func applySearchFilter(query *bun.SelectQuery, filter SearchFilter) *bun.SelectQuery {
+	if filter.VideoID != "" {
+		query = query.Where("?TableAlias.video_id = ?", filter.VideoID)
+	}
+	if len(filter.VideoIDs) > 0 {
+		query = query.Where("?TableAlias.video_id IN (?)", bun.In(filter.VideoIDs))
+	}
+	if filter.From != nil {
+		query = query.Where("?TableAlias.created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("?TableAlias.created_at <= ?", filter.To.UTC())
+	}
+	return query
+}
+
+// applyTagFilter keeps notes carrying at least one of the given tags or a
+// tag nested under one of them
+func applyTagFilter(query *bun.SelectQuery, tags []string) *bun.SelectQuery {
+	tagNames := normalizeTagNames(tags)
+	if len(tagNames) == 0 {
+		return query
+	}
+	return query.Where("?TableAlias.id IN (SELECT tt.timestamp_id FROM timestamp_tags tt JOIN tags tg ON tg.id = tt.tag_id WHERE "+tagPathMatch+")", pgdialect.Array(tagNames))
+}
+
+// applyNoteFilter keeps notes of the given types and at least the given
+// importance
+func applyNoteFilter(query *bun.SelectQuery, filter SearchFilter) *bun.SelectQuery {
+	if len(filter.NoteTypes) > 0 {
+		query = query.Where("?TableAlias.type IN (?)", bun.In(filter.NoteTypes))
+	}
+	if filter.MinImportance > 0 {
+		query = query.Where("?TableAlias.importance >= ?", filter.MinImportance)
+	}
+	return query
+}
+
+func (f NoteFilter) filter() SearchFilter {
+	return SearchFilter{
+		VideoID:       f.VideoID,
+		Tags:          f.Tags,
+		NoteTypes:     f.NoteTypes,
+		MinImportance: f.MinImportance,
+		From:          f.From,
+		To:            f.To,
+	}
+}
+
+func (f NoteFilter) empty() bool {
+	return f.VideoID == "" && len(normalizeTagNames(f.Tags)) == 0 && len(f.NoteTypes) == 0 &&
+		f.MinImportance == 0 && f.From == nil && f.To == nil
+}
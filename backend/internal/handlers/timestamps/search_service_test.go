@@ -0,0 +1,80 @@
+package timestamps
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/chunking"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// seedNoteEmbedding stores a note of the user with its vector from model
+func seedNoteEmbedding(t *testing.T, db *database.Database, userID uuid.UUID, model, title string, embedding []float32) *models.Timestamp {
+	t.Helper()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	note := &models.Timestamp{
+		ID:         uuid.New(),
+		VideoID:    "vid-search",
+		UserID:     userID,
+		Type:       models.ClipTypeNote,
+		Importance: models.DefaultTimestampImportance,
+		Title:      title,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if _, err := db.DB.NewInsert().Model(note).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed note: %v", err)
+	}
+	if err := saveTimestampEmbeddings(ctx, db.DB, []models.TimestampEmbedding{newTimestampEmbedding(note, model, embedding)}); err != nil {
+		t.Fatalf("failed to seed note embedding: %v", err)
+	}
+	return note
+}
+
+func TestSearchTimestampsFindsNotesBehindOtherUsers(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+
+	// One connection, so the planner settings below apply to the searches
+	db.DB.SetMaxOpenConns(1)
+
+	const model = "test-shared-index"
+	ss := NewSearchService(db, chunking.DefaultStrategy, model)
+	if err := ss.EnsureVectorIndexes(ctx, model, 3); err != nil {
+		t.Fatalf("EnsureVectorIndexes error: %v", err)
+	}
+	// The tables are small enough that a sequential scan would be cheaper
+	if _, err := db.DB.ExecContext(ctx, "SET enable_seqscan = off"); err != nil {
+		t.Fatalf("failed to disable sequential scans: %v", err)
+	}
+
+	// Another user's notes fill the nearest hnsw.ef_search candidates
+	crowd := testUser(t, db)
+	for i := 0; i < 200; i++ {
+		seedNoteEmbedding(t, db, crowd, model, "crowd", []float32{1, float32(i) * 0.001, 0})
+	}
+
+	owner := testUser(t, db)
+	near := seedNoteEmbedding(t, db, owner, model, "near", []float32{0.6, 0.4, 0})
+	far := seedNoteEmbedding(t, db, owner, model, "far", []float32{0.4, 0.6, 0})
+
+	results, err := ss.SearchTimestamps(ctx, owner, []float32{1, 0, 0}, SearchFilter{}, 10)
+	if err != nil {
+		t.Fatalf("SearchTimestamps error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want the owner's 2 notes: %+v", len(results), results)
+	}
+	var got []uuid.UUID
+	for _, result := range results {
+		got = append(got, result.Timestamp.(models.Timestamp).ID)
+	}
+	if got[0] != near.ID || got[1] != far.ID {
+		t.Errorf("results = %v, want %s, %s by distance", got, near.ID, far.ID)
+	}
+}
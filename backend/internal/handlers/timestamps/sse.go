@@ -0,0 +1,52 @@
+package timestamps
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+var streamAllowedOrigins = []string{
+	"http://localhost:5173",             // Local development
+	"http://localhost:3000",             // Alternative local port
+	"https://ytclipper.com",             // Production domain
+	"https://app.ytclipper.com",         // Production with www
+	"https://app-staging.ytclipper.com", // Staging environment
+}
+
+// startEventStream switches the response to Server-Sent Events
+func startEventStream(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	origin := c.GetHeader("Origin")
+	for _, allowedOrigin := range streamAllowedOrigins {
+		if origin == allowedOrigin {
+			c.Header("Access-Control-Allow-Origin", origin)
+			break
+		}
+	}
+
+	c.Header("Access-Control-Allow-Headers", "Cache-Control")
+	c.Header("Access-Control-Allow-Credentials", "true")
+
+	c.Writer.Flush()
+}
+
+// writeEvent sends a single SSE event with a JSON payload
+func writeEvent(c *gin.Context, event string, data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", event, err)
+	}
+
+	if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event, jsonData); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+
+	return nil
+}
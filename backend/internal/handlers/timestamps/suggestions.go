@@ -0,0 +1,319 @@
+package timestamps
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/aicache"
+	"github.com/shubhamku044/ytclipper/internal/chunking"
+	"github.com/shubhamku044/ytclipper/internal/clock"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
+)
+
+// Suggestions are typed with the insight types they correspond to
+const (
+	SuggestionChapter   = models.AIInsightTypeTopics
+	SuggestionKeyMoment = models.AIInsightTypeKeyPoints
+)
+
+// suggestionTitleMaxLength matches the max length of TimestampSuggestion.Title
+const suggestionTitleMaxLength = 255
+
+// suggestionDedupeWindow is how close in seconds two suggestions of a type
+// are taken to be the same one, as adjacent windows can place a moment near
+// their boundary a little apart
+const suggestionDedupeWindow = 5
+
+// suggestionTags are added to every suggestion of a type, so accepted
+// chapters and key moments can be told apart from hand-written notes
+var suggestionTags = map[models.AIInsightType]string{
+	SuggestionChapter:   "chapter",
+	SuggestionKeyMoment: "key moment",
+}
+
+// TimestampSuggestion is a chapter boundary or key moment proposed from a
+// video's transcript. Accepting it creates a note at Timestamp.
+type TimestampSuggestion struct {
+	Type      models.AIInsightType `json:"type" binding:"required,oneof=topics key_points"`
+	Title     string               `json:"title" binding:"required,max=255"`
+	Timestamp float64              `json:"timestamp" binding:"min=0"`
+	Rationale string               `json:"rationale"`
+	Tags      []string             `json:"tags,omitempty"`
+}
+
+// suggestionResponse is the JSON object the model is asked for
+type suggestionResponse struct {
+	Chapters   []suggestionItem `json:"chapters"`
+	KeyMoments []suggestionItem `json:"key_moments"`
+}
+
+type suggestionItem struct {
+	Title     string   `json:"title"`
+	Time      string   `json:"time"`
+	Rationale string   `json:"rationale"`
+	Tags      []string `json:"tags"`
+}
+
+// SuggestTimestamps proposes chapters and key moments for a video from its
+// transcript chunks. Nothing is stored until suggestions are accepted.
+func (t *TimestampsHandlers) SuggestTimestamps(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	var req SuggestTimestampsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !t.requireTokenBudget(c, userID) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var video models.Video
+	err := t.db.DB.NewSelect().
+		Model(&video).
+		Where("user_id = ? AND video_id = ? AND deleted_at IS NULL", userID, videoID).
+		Scan(ctx)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusNotFound, "VIDEO_NOT_FOUND", "Video not found or does not belong to user", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	transcript, err := t.transcriptService.GetTranscript(ctx, userID, videoID, req.Language, false)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadGateway, "TRANSCRIPT_ERROR", "Failed to fetch transcript", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	started := time.Now()
+	suggestions, used, err := t.suggestTimestamps(ctx, &video, transcript)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "AI_ERROR", "Failed to suggest timestamps", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var insightID *uuid.UUID
+	if content, err := json.Marshal(suggestions); err != nil {
+		log.Printf("Warning: Failed to encode timestamp suggestions: %v", err)
+	} else {
+		insightID = t.recordInsight(userID, insightRecord{
+			VideoID:  videoID,
+			Type:     models.AIInsightTypeSuggestions,
+			Title:    "Chapters and key moments of " + video.Title,
+			Content:  string(content),
+			Started:  started,
+			Metadata: gin.H{"language": transcript.Language, "count": len(suggestions)},
+			Prompt:   prompts.Suggestions,
+			Prompts:  used,
+		})
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"video_id":    videoID,
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+		"insight_id":  insightID,
+	})
+}
+
+// AcceptTimestampSuggestions creates notes from the suggestions the user
+// kept, possibly edited, all or none at once
+func (t *TimestampsHandlers) AcceptTimestampSuggestions(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	var req AcceptSuggestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	reqs := make([]CreateTimestampRequest, len(req.Suggestions))
+	for i, suggestion := range req.Suggestions {
+		reqs[i] = CreateTimestampRequest{
+			VideoID:   videoID,
+			Timestamp: suggestion.Timestamp,
+			Title:     suggestion.Title,
+			Note:      suggestion.Rationale,
+			Tags:      suggestion.Tags,
+		}
+		// Key moments are kept as highlights, chapters as plain notes
+		if suggestion.Type == models.AIInsightTypeKeyPoints {
+			reqs[i].Type = string(models.ClipTypeHighlight)
+		}
+	}
+
+	timestamps, ok := t.createTimestamps(c, userID, videoID, reqs)
+	if !ok {
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"timestamps": timestamps,
+		"count":      len(timestamps),
+		"message":    "Suggestions accepted successfully",
+	})
+}
+
+// suggestTimestamps asks the model for chapters and key moments. Long
+// transcripts are sent in windows of chunks that fit the chat model's
+// context, and the suggestions of all windows are merged.
+func (t *TimestampsHandlers) suggestTimestamps(ctx context.Context, video *models.Video, transcript *models.VideoTranscript) ([]TimestampSuggestion, prompts.Versions, error) {
+	chunker, err := t.transcriptService.Chunker("")
+	if err != nil {
+		return nil, nil, err
+	}
+	chunks := chunker.Chunk(transcript.Segments)
+	if len(chunks) == 0 {
+		return nil, nil, errors.New("transcript is empty")
+	}
+	end := chunks[len(chunks)-1].EndTime
+
+	used := prompts.Versions{}
+	var suggestions []TimestampSuggestion
+	windows := splitChunkWindows(chunks, t.summaryBudget())
+	for i, window := range windows {
+		prompt, err := t.prompts.Render(ctx, prompts.Suggestions, video.UserID, newSuggestionPromptData(video, window, i, len(windows)), used)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		content, err := t.aiService.GenerateJSONCompletion(ctx, aicache.FeatureSuggest, prompt)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var response suggestionResponse
+		if err := json.Unmarshal([]byte(content), &response); err != nil {
+			return nil, nil, fmt.Errorf("AI returned malformed suggestions: %w", err)
+		}
+
+		suggestions = append(suggestions, parseSuggestions(SuggestionChapter, response.Chapters, end)...)
+		suggestions = append(suggestions, parseSuggestions(SuggestionKeyMoment, response.KeyMoments, end)...)
+	}
+
+	return dedupeSuggestions(suggestions), used, nil
+}
+
+// parseSuggestions drops items without a title or with a time outside the
+// video, shortens long titles and tags the rest with their type
+func parseSuggestions(kind models.AIInsightType, items []suggestionItem, end float64) []TimestampSuggestion {
+	var suggestions []TimestampSuggestion
+	for _, item := range items {
+		title := strings.Join(strings.Fields(item.Title), " ")
+		if len([]rune(title)) > suggestionTitleMaxLength {
+			title = string([]rune(title)[:suggestionTitleMaxLength-3]) + "..."
+		}
+		if title == "" {
+			continue
+		}
+		seconds, err := clock.Parse(strings.Trim(strings.TrimSpace(item.Time), "[]"))
+		if err != nil || seconds < 0 || seconds > end {
+			continue
+		}
+
+		tags := []string{suggestionTags[kind]}
+		for _, tag := range item.Tags {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && tag != tags[0] {
+				tags = append(tags, tag)
+			}
+		}
+
+		suggestions = append(suggestions, TimestampSuggestion{
+			Type:      kind,
+			Title:     title,
+			Timestamp: math.Floor(seconds),
+			Rationale: strings.TrimSpace(item.Rationale),
+			Tags:      tags,
+		})
+	}
+	return suggestions
+}
+
+// dedupeSuggestions orders suggestions by time and drops repeats of a type
+// within suggestionDedupeWindow seconds of the one kept before them
+func dedupeSuggestions(suggestions []TimestampSuggestion) []TimestampSuggestion {
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Timestamp < suggestions[j].Timestamp
+	})
+
+	last := make(map[models.AIInsightType]float64)
+	kept := make([]TimestampSuggestion, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		if previous, ok := last[suggestion.Type]; ok && suggestion.Timestamp-previous <= suggestionDedupeWindow {
+			continue
+		}
+		last[suggestion.Type] = suggestion.Timestamp
+		kept = append(kept, suggestion)
+	}
+	return kept
+}
+
+// splitChunkWindows packs consecutive chunks into windows of at most budget
+// tokens; a chunk larger than budget gets a window of its own
+func splitChunkWindows(chunks []chunking.Chunk, budget int) [][]chunking.Chunk {
+	var windows [][]chunking.Chunk
+	start, tokens := 0, 0
+	for i, chunk := range chunks {
+		if i > start && tokens+chunk.TokenCount > budget {
+			windows = append(windows, chunks[start:i])
+			start, tokens = i, 0
+		}
+		tokens += chunk.TokenCount
+	}
+	return append(windows, chunks[start:])
+}
+
+// suggestionPromptData are the variables of the suggestions prompt
+type suggestionPromptData struct {
+	Title      string
+	Part       string // Which part of the transcript Transcript is
+	Transcript string
+}
+
+func newSuggestionPromptData(video *models.Video, chunks []chunking.Chunk, index, total int) suggestionPromptData {
+	var content strings.Builder
+	for _, chunk := range chunks {
+		content.WriteString(fmt.Sprintf("%s %s\n", formatTimestamp(chunk.StartTime), chunk.Text))
+	}
+
+	part := "the transcript"
+	if total > 1 {
+		part = fmt.Sprintf("part %d of %d of the transcript, from %s to %s", index+1, total, formatTimestamp(chunks[0].StartTime), formatTimestamp(chunks[len(chunks)-1].EndTime))
+	}
+
+	return suggestionPromptData{
+		Title:      video.Title,
+		Part:       part,
+		Transcript: strings.TrimSpace(content.String()),
+	}
+}
@@ -0,0 +1,300 @@
+package timestamps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/shubhamku044/ytclipper/internal/aicache"
+	"github.com/shubhamku044/ytclipper/internal/chunking"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
+)
+
+const (
+	// summaryOutputTokens is the MaxTokens of GenerateTextCompletion
+	summaryOutputTokens = 1000
+	// summaryInstructionTokens is reserved for the prompt around the content
+	summaryInstructionTokens = 1000
+	// summarySectionTokens caps a level 0 section, keeping sections chapter
+	// sized even when the chat model's context is much larger
+	summarySectionTokens = 3000
+	// summaryConcurrency bounds the summaries of a level generated at once
+	summaryConcurrency = 4
+)
+
+// summaryEvents observes a hierarchical summary as it is built. Both funcs
+// are optional and are never called concurrently.
+type summaryEvents struct {
+	// Progress reports done of total summaries of a level
+	Progress func(level, done, total int)
+	// Section receives every intermediate summary once it is generated
+	Section func(section *models.VideoSummarySection)
+}
+
+// summarySpan is the input of one intermediate summary
+type summarySpan struct {
+	StartTime float64
+	EndTime   float64
+	Content   string
+}
+
+// generateFullVideoSummary summarizes the whole video, returning the
+// intermediate summaries of long videos and the prompt versions used
+// alongside the final one
+func (t *TimestampsHandlers) generateFullVideoSummary(ctx context.Context, video *models.Video, transcript *models.VideoTranscript, events summaryEvents) (string, []models.VideoSummarySection, prompts.Versions, error) {
+	prompt, sections, used, err := t.prepareVideoSummary(ctx, video, transcript, events)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	summary, err := t.aiService.GenerateTextCompletion(ctx, aicache.FeatureSummary, prompt)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return summary, sections, used, nil
+}
+
+// prepareVideoSummary returns the prompt of the video's final summary. A
+// transcript that fits the chat model's context goes into the prompt as is.
+// A longer one is summarized hierarchically: the transcript is cut into
+// sections that are summarized on their own, and consecutive summaries are
+// combined level by level until they fit a single prompt. Intermediate
+// completions are cached, so a retried summary only pays for the summaries
+// that failed.
+func (t *TimestampsHandlers) prepareVideoSummary(ctx context.Context, video *models.Video, transcript *models.VideoTranscript, events summaryEvents) (string, []models.VideoSummarySection, prompts.Versions, error) {
+	used := prompts.Versions{}
+	budget := t.summaryBudget()
+	formatted := formatTranscript(transcript)
+	if chunking.EstimateTokens(formatted) <= budget {
+		prompt, err := t.buildFullVideoSummaryPrompt(ctx, video, formatted, used)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		return prompt, []models.VideoSummarySection{}, used, nil
+	}
+
+	spans := splitSummarySpans(transcript.Segments, min(summarySectionTokens, budget))
+	spanPrompts, err := t.renderSpanPrompts(ctx, prompts.SummarySection, video, spans, used)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	current, err := t.summarizeLevel(ctx, video, 0, spans, spanPrompts, events)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	sections := current
+
+	for level := 1; len(current) > 1 && summarySectionsTokens(current) > budget; level++ {
+		groups := groupSummarySections(current, budget)
+		spans := make([]summarySpan, len(groups))
+		for i, group := range groups {
+			spans[i] = summarySpan{
+				StartTime: group[0].StartTime,
+				EndTime:   group[len(group)-1].EndTime,
+				Content:   formatSummarySections(group),
+			}
+		}
+
+		spanPrompts, err := t.renderSpanPrompts(ctx, prompts.SummaryCombine, video, spans, used)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		current, err = t.summarizeLevel(ctx, video, level, spans, spanPrompts, events)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		sections = append(sections, current...)
+	}
+
+	prompt, err := t.buildFullVideoSummaryPromptFromSections(ctx, video, current, used)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return prompt, sections, used, nil
+}
+
+// summaryBudget is how many transcript or summary tokens fit one prompt
+func (t *TimestampsHandlers) summaryBudget() int {
+	budget := t.aiService.ChatContextTokens() - summaryOutputTokens - summaryInstructionTokens
+	return max(budget, 2*summaryOutputTokens)
+}
+
+// summarizeLevel generates one summary per span from the span's prompt, a
+// few at a time. The first failure cancels the summaries still running.
+func (t *TimestampsHandlers) summarizeLevel(ctx context.Context, video *models.Video, level int, spans []summarySpan, spanPrompts []string, events summaryEvents) ([]models.VideoSummarySection, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sections := make([]models.VideoSummarySection, len(spans))
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		done     int
+		firstErr error
+	)
+	if events.Progress != nil {
+		events.Progress(level, 0, len(spans))
+	}
+
+	limit := make(chan struct{}, summaryConcurrency)
+	for i := range spans {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case limit <- struct{}{}:
+				defer func() { <-limit }()
+			case <-ctx.Done():
+				return
+			}
+
+			summary, err := t.aiService.GenerateTextCompletion(ctx, aicache.FeatureSummary, spanPrompts[i])
+			if err == nil && strings.TrimSpace(summary) == "" {
+				err = fmt.Errorf("AI returned an empty summary")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to summarize %s-%s: %w", formatTimestamp(spans[i].StartTime), formatTimestamp(spans[i].EndTime), err)
+					cancel()
+				}
+				return
+			}
+
+			sections[i] = models.VideoSummarySection{
+				VideoID:      video.VideoID,
+				Level:        level,
+				SectionIndex: i,
+				StartTime:    spans[i].StartTime,
+				EndTime:      spans[i].EndTime,
+				Summary:      strings.TrimSpace(summary),
+			}
+			done++
+			if events.Section != nil {
+				events.Section(&sections[i])
+			}
+			if events.Progress != nil {
+				events.Progress(level, done, len(spans))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// splitSummarySpans cuts the transcript into consecutive spans of at most
+// maxTokens, breaking between caption segments. A segment longer than
+// maxTokens gets a span of its own.
+func splitSummarySpans(segments []models.TranscriptSegment, maxTokens int) []summarySpan {
+	var spans []summarySpan
+	var content strings.Builder
+	tokens := 0
+
+	flush := func(end float64) {
+		if content.Len() == 0 {
+			return
+		}
+		spans[len(spans)-1].EndTime = end
+		spans[len(spans)-1].Content = strings.TrimSpace(content.String())
+		content.Reset()
+		tokens = 0
+	}
+
+	for i, segment := range segments {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+
+		line := fmt.Sprintf("%s %s\n", formatTimestamp(segment.Start), text)
+		lineTokens := chunking.EstimateTokens(line)
+		if tokens > 0 && tokens+lineTokens > maxTokens {
+			flush(segment.Start)
+		}
+		if content.Len() == 0 {
+			spans = append(spans, summarySpan{StartTime: segment.Start})
+		}
+
+		content.WriteString(line)
+		tokens += lineTokens
+		spans[len(spans)-1].EndTime = chunking.SegmentEnd(segments, i)
+	}
+	if len(spans) > 0 {
+		flush(spans[len(spans)-1].EndTime)
+	}
+
+	return spans
+}
+
+// groupSummarySections packs consecutive sections into groups that fit
+// budget. Every group but a trailing single one holds at least two
+// sections, so each level is shorter than the one below even when the
+// budget is tight.
+func groupSummarySections(sections []models.VideoSummarySection, budget int) [][]models.VideoSummarySection {
+	var groups [][]models.VideoSummarySection
+	start, tokens := 0, 0
+	for i := range sections {
+		sectionTokens := summarySectionsTokens(sections[i : i+1])
+		if i-start >= 2 && tokens+sectionTokens > budget {
+			groups = append(groups, sections[start:i])
+			start, tokens = i, 0
+		}
+		tokens += sectionTokens
+	}
+	return append(groups, sections[start:])
+}
+
+func summarySectionsTokens(sections []models.VideoSummarySection) int {
+	return chunking.EstimateTokens(formatSummarySections(sections))
+}
+
+func formatSummarySections(sections []models.VideoSummarySection) string {
+	var content strings.Builder
+	for _, section := range sections {
+		content.WriteString(fmt.Sprintf("### %s - %s\n%s\n\n", formatTimestamp(section.StartTime), formatTimestamp(section.EndTime), section.Summary))
+	}
+	return strings.TrimSpace(content.String())
+}
+
+// summarySpanPromptData are the variables of the summary-section and
+// summary-combine prompts
+type summarySpanPromptData struct {
+	Title   string
+	Part    int // 1-based index of the span
+	Parts   int
+	Start   string
+	End     string
+	Content string
+}
+
+// renderSpanPrompts renders the named prompt for every span
+func (t *TimestampsHandlers) renderSpanPrompts(ctx context.Context, name string, video *models.Video, spans []summarySpan, used prompts.Versions) ([]string, error) {
+	rendered := make([]string, len(spans))
+	for i, span := range spans {
+		prompt, err := t.prompts.Render(ctx, name, video.UserID, summarySpanPromptData{
+			Title:   video.Title,
+			Part:    i + 1,
+			Parts:   len(spans),
+			Start:   formatTimestamp(span.StartTime),
+			End:     formatTimestamp(span.EndTime),
+			Content: span.Content,
+		}, used)
+		if err != nil {
+			return nil, err
+		}
+		rendered[i] = prompt
+	}
+	return rendered, nil
+}
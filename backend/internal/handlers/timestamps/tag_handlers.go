@@ -0,0 +1,146 @@
+package timestamps
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+)
+
+// GetTagTree lists the user's tags nested by path, with the number of notes
+// carrying each
+func (t *TimestampsHandlers) GetTagTree(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	tree, err := t.tagService.GetTagTree(c.Request.Context(), userID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "FAILED_TO_FETCH_TAGS", "Failed to fetch tags", gin.H{"error": err.Error()})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"tags": tree,
+	})
+}
+
+// UpdateTag renames a tag, moving its nested tags along, or sets its color
+// or description
+func (t *TimestampsHandlers) UpdateTag(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	tagID, ok := parseTagID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	change, err := t.tagService.UpdateTag(ctx, userID, tagID, req)
+	if errors.Is(err, errTagExists) {
+		middleware.RespondWithError(c, http.StatusConflict, "TAG_EXISTS", "A tag with that name already exists, merge the tags instead", nil)
+		return
+	}
+	if err != nil {
+		respondTagError(c, err)
+		return
+	}
+	t.enqueueTagChangeEmbeddings(ctx, userID, change)
+
+	middleware.RespondWithOK(c, gin.H{
+		"tag":           change.Tag,
+		"notes_updated": len(change.Notes),
+		"message":       "Tag updated successfully",
+	})
+}
+
+// MergeTags moves the notes of the source tags onto the target tag and
+// deletes the sources, fixing duplicated or misspelled tags
+func (t *TimestampsHandlers) MergeTags(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req MergeTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	change, err := t.tagService.MergeTags(ctx, userID, req)
+	if err != nil {
+		respondTagError(c, err)
+		return
+	}
+	t.enqueueTagChangeEmbeddings(ctx, userID, change)
+
+	middleware.RespondWithOK(c, gin.H{
+		"tag":           change.Tag,
+		"merged":        change.Merged,
+		"notes_updated": len(change.Notes),
+		"message":       "Tags merged successfully",
+	})
+}
+
+// enqueueTagChangeEmbeddings re-embeds the notes whose tag names changed,
+// since tags are part of a note's embedding
+func (t *TimestampsHandlers) enqueueTagChangeEmbeddings(ctx context.Context, userID uuid.UUID, change *TagChange) {
+	for _, id := range change.Notes {
+		if _, err := t.enqueueTimestampEmbedding(ctx, userID, id); err != nil {
+			log.Printf("Failed to queue embedding for timestamp %s: %v", id, err)
+		}
+	}
+}
+
+// respondTagError responds to a failure to create, rename or merge tags
+func respondTagError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errNotFound):
+		middleware.RespondWithError(c, http.StatusNotFound, "TAG_NOT_FOUND", "Tag not found", nil)
+	case errors.Is(err, errInvalidTagName):
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_TAG_NAME", "Invalid tag name", gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, errInvalidTagColor):
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_TAG_COLOR", "Invalid tag color", gin.H{
+			"error": err.Error(),
+		})
+	case errors.Is(err, errTagNested):
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_TAG_NESTING", "A tag can't be moved under itself", nil)
+	default:
+		middleware.RespondWithError(c, http.StatusInternalServerError, "TAG_ERROR", "Failed to process tags", gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+func parseTagID(c *gin.Context) (uuid.UUID, bool) {
+	tagID, err := uuid.Parse(c.Param("tagId"))
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_TAG_ID", "Invalid tag ID format", gin.H{
+			"error": err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return tagID, true
+}
@@ -2,14 +2,40 @@ package timestamps
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/shubhamku044/ytclipper/internal/database"
 	"github.com/shubhamku044/ytclipper/internal/models"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+const (
+	// maxTagNameLength is the longest tag path, the size of tags.name
+	maxTagNameLength = 255
+	// tagPathSeparator separates the segments of nested tags
+	tagPathSeparator = "/"
+)
+
+// tagPathMatch is an SQL condition matching tags, aliased tg, named any of
+// the given paths or nested under one of them
+const tagPathMatch = "EXISTS (SELECT 1 FROM unnest(?::text[]) AS path(name) WHERE tg.name = path.name OR starts_with(tg.name, path.name || '/'))"
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+var (
+	errInvalidTagName  = errors.New("tag names must be 1 to 255 bytes long")
+	errInvalidTagColor = errors.New("tag color must be a hex color like #1e90ff")
+	errTagExists       = errors.New("a tag with that name already exists")
+	errTagNested       = errors.New("a tag cannot be moved under itself")
 )
 
 type TagService struct {
@@ -22,113 +48,88 @@ func NewTagService(db *database.Database) *TagService {
 	}
 }
 
-func (ts *TagService) FindOrCreateTag(ctx context.Context, tagName string) (*models.Tag, error) {
-	tagName = strings.ToLower(strings.TrimSpace(tagName))
-	if tagName == "" {
-		return nil, fmt.Errorf("tag name cannot be empty")
-	}
-
-	var tag models.Tag
-	err := ts.db.DB.NewSelect().
-		Model(&tag).
-		Where("LOWER(name) = ?", tagName).
-		Scan(ctx)
-
-	if err == nil {
-		return &tag, nil
-	}
-
-	newTag := &models.Tag{
-		Name:      tagName,
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-	}
-
-	_, err = ts.db.DB.NewInsert().
-		Model(newTag).
-		Exec(ctx)
+func (ts *TagService) FindOrCreateTag(ctx context.Context, userID uuid.UUID, tagName string) (*models.Tag, error) {
+	return findOrCreateTag(ctx, ts.db.DB, userID, tagName)
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create tag '%s': %w", tagName, err)
-	}
+func (ts *TagService) ProcessTagsForTimestamp(ctx context.Context, userID uuid.UUID, tagNames []string) ([]uuid.UUID, error) {
+	return processTags(ctx, ts.db.DB, userID, tagNames)
+}
 
-	return newTag, nil
+// ProcessTagsForTimestampWithTx processes tags within a transaction
+func (ts *TagService) ProcessTagsForTimestampWithTx(ctx context.Context, tx bun.Tx, userID uuid.UUID, tagNames []string) ([]uuid.UUID, error) {
+	return processTags(ctx, tx, userID, tagNames)
 }
 
-func (ts *TagService) ProcessTagsForTimestamp(ctx context.Context, tagNames []string) ([]uuid.UUID, error) {
-	if len(tagNames) == 0 {
-		return nil, nil
-	}
+// FindOrCreateTagWithTx finds or creates a tag within a transaction
+func (ts *TagService) FindOrCreateTagWithTx(ctx context.Context, tx bun.Tx, userID uuid.UUID, tagName string) (*models.Tag, error) {
+	return findOrCreateTag(ctx, tx, userID, tagName)
+}
 
+// processTags returns the IDs of the named tags of the user, creating them
+// as needed. Names that normalize to the same tag are returned once.
+func processTags(ctx context.Context, db bun.IDB, userID uuid.UUID, tagNames []string) ([]uuid.UUID, error) {
 	var tagIDs []uuid.UUID
 	for _, tagName := range tagNames {
-		if tagName == "" {
+		if strings.TrimSpace(tagName) == "" {
 			continue
 		}
-
-		tag, err := ts.FindOrCreateTag(ctx, tagName)
+		tag, err := findOrCreateTag(ctx, db, userID, tagName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to process tag '%s': %w", tagName, err)
 		}
-
-		tagIDs = append(tagIDs, tag.ID)
+		if !slices.Contains(tagIDs, tag.ID) {
+			tagIDs = append(tagIDs, tag.ID)
+		}
 	}
-
 	return tagIDs, nil
 }
 
-// ProcessTagsForTimestampWithTx processes tags within a transaction
-func (ts *TagService) ProcessTagsForTimestampWithTx(ctx context.Context, tx bun.Tx, tagNames []string) ([]uuid.UUID, error) {
-	if len(tagNames) == 0 {
-		return nil, nil
+// findOrCreateTag returns the user's tag with the normalized name, creating
+// it and the tags it is nested under when missing
+func findOrCreateTag(ctx context.Context, db bun.IDB, userID uuid.UUID, tagName string) (*models.Tag, error) {
+	tagName = normalizeTagName(tagName)
+	if err := validateTagName(tagName); err != nil {
+		return nil, err
 	}
-	var tagIDs []uuid.UUID
-	for _, tagName := range tagNames {
-		if tagName == "" {
-			continue
-		}
-		tag, err := ts.FindOrCreateTagWithTx(ctx, tx, tagName)
-		if err != nil {
-			return nil, fmt.Errorf("failed to process tag '%s': %w", tagName, err)
+
+	var tag *models.Tag
+	for _, path := range tagPaths(tagName) {
+		var err error
+		if tag, err = ensureTag(ctx, db, userID, path); err != nil {
+			return nil, err
 		}
-		tagIDs = append(tagIDs, tag.ID)
 	}
-	return tagIDs, nil
+	return tag, nil
 }
 
-// FindOrCreateTagWithTx finds or creates a tag within a transaction
-func (ts *TagService) FindOrCreateTagWithTx(ctx context.Context, tx bun.Tx, tagName string) (*models.Tag, error) {
-	// Normalize tag name
-	tagName = strings.ToLower(strings.TrimSpace(tagName))
-	if tagName == "" {
-		return nil, fmt.Errorf("tag name cannot be empty")
-	}
-
-	// Try to find existing tag
-	var tag models.Tag
-	err := tx.NewSelect().
-		Model(&tag).
-		Where("LOWER(name) = ?", tagName).
-		Scan(ctx)
-
-	if err == nil {
-		return &tag, nil
-	}
-
-	// Create new tag if not found
-	newTag := &models.Tag{
-		Name: tagName,
+func ensureTag(ctx context.Context, db bun.IDB, userID uuid.UUID, name string) (*models.Tag, error) {
+	now := time.Now().UTC()
+	tag := &models.Tag{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
-
-	_, err = tx.NewInsert().
-		Model(newTag).
+	_, err := db.NewInsert().
+		Model(tag).
+		On("CONFLICT (user_id, name) DO NOTHING").
 		Exec(ctx)
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to create tag '%s': %w", tagName, err)
+		return nil, fmt.Errorf("failed to create tag '%s': %w", name, err)
 	}
 
-	return newTag, nil
+	// The insert does nothing when the tag exists, so read it back either way
+	existing := new(models.Tag)
+	err = db.NewSelect().
+		Model(existing).
+		Where("user_id = ? AND name = ?", userID, name).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tag '%s': %w", name, err)
+	}
+	return existing, nil
 }
 
 func (ts *TagService) CreateTimestampTagRelations(ctx context.Context, timestampID string, tagIDs []uuid.UUID) error {
@@ -184,103 +185,615 @@ func (ts *TagService) CreateTimestampTagRelationsWithTx(ctx context.Context, tx
 	return err
 }
 
-// CleanupOrphanedTagRelations removes tag relations for deleted timestamps
-func (ts *TagService) CleanupOrphanedTagRelations(ctx context.Context) error {
-	// Delete timestamp-tag relations where the timestamp has been soft deleted
-	_, err := ts.db.DB.NewDelete().
+// ReplaceTimestampTagsWithTx sets the note's tags to the user's tags named
+// tagNames, creating tags as needed, within a transaction
+func (ts *TagService) ReplaceTimestampTagsWithTx(ctx context.Context, tx bun.Tx, userID, timestampID uuid.UUID, tagNames []string) error {
+	_, err := tx.NewDelete().
 		Model((*models.TimestampTag)(nil)).
-		Where("timestamp_id IN (SELECT id FROM timestamps WHERE deleted_at IS NOT NULL)").
+		Where("timestamp_id = ?", timestampID).
 		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to remove tag relations: %w", err)
+	}
+
+	tagIDs, err := ts.ProcessTagsForTimestampWithTx(ctx, tx, userID, tagNames)
+	if err != nil {
+		return err
+	}
+
+	return ts.CreateTimestampTagRelationsWithTx(ctx, tx, timestampID.String(), tagIDs)
+}
 
+// CleanupOrphanedTagRelations removes tag relations for deleted timestamps
+func (ts *TagService) CleanupOrphanedTagRelations(ctx context.Context) error {
+	_, err := ts.cleanupOrphanedTagRelations(ctx, ts.db.DB)
 	return err
 }
 
 // CleanupOrphanedTagRelationsWithTx removes tag relations for deleted timestamps within a transaction
 func (ts *TagService) CleanupOrphanedTagRelationsWithTx(ctx context.Context, tx bun.Tx) error {
+	_, err := ts.cleanupOrphanedTagRelations(ctx, tx)
+	return err
+}
+
+func (ts *TagService) cleanupOrphanedTagRelations(ctx context.Context, db bun.IDB) (int64, error) {
 	// Delete timestamp-tag relations where the timestamp has been soft deleted
-	_, err := tx.NewDelete().
+	result, err := db.NewDelete().
 		Model((*models.TimestampTag)(nil)).
 		Where("timestamp_id IN (SELECT id FROM timestamps WHERE deleted_at IS NOT NULL)").
 		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
 
-	return err
+// TagCleanupResult counts what a tag cleanup removed
+type TagCleanupResult struct {
+	Relations int64 `json:"relations"`
+	Tags      int64 `json:"tags"`
 }
 
-func (ts *TagService) GetAllTags(ctx context.Context, userID string, limit int) ([]models.Tag, error) {
-	// Convert string user ID to UUID
-	userUUID, err := uuid.Parse(userID)
+// CleanupUnusedTags removes the tag relations of deleted notes, then every
+// tag no note carries. Tags with a color, a description or nested tags are
+// kept, since the user set them up on purpose.
+func (ts *TagService) CleanupUnusedTags(ctx context.Context) (*TagCleanupResult, error) {
+	relations, err := ts.cleanupOrphanedTagRelations(ctx, ts.db.DB)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID format: %w", err)
+		return nil, fmt.Errorf("failed to remove tag relations of deleted notes: %w", err)
 	}
 
-	// First, check if user has any timestamps
-	timestampCount, err := ts.db.DB.NewSelect().
-		Model((*models.Timestamp)(nil)).
-		Where("user_id = ? AND deleted_at IS NULL", userUUID).
-		Count(ctx)
+	result, err := ts.db.DB.NewDelete().
+		Model((*models.Tag)(nil)).
+		Where("?TableAlias.color IS NULL AND ?TableAlias.description = ''").
+		Where("NOT EXISTS (SELECT 1 FROM timestamp_tags AS tt WHERE tt.tag_id = ?TableAlias.id)").
+		Where("NOT EXISTS (SELECT 1 FROM tags AS child WHERE child.user_id = ?TableAlias.user_id AND starts_with(child.name, ?TableAlias.name || '/'))").
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove unused tags: %w", err)
+	}
+	tags, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
 
+	return &TagCleanupResult{Relations: relations, Tags: tags}, nil
+}
+
+// GetAllTags returns the user's tags by name
+func (ts *TagService) GetAllTags(ctx context.Context, userID uuid.UUID, limit int) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	err := ts.db.DB.NewSelect().
+		Model(&tags).
+		Where("user_id = ?", userID).
+		Order("name ASC").
+		Limit(limit).
+		Scan(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error checking timestamp count: %w", err)
+		return nil, fmt.Errorf("error fetching all tags: %w", err)
 	}
+	return tags, nil
+}
 
+// SearchTags returns the user's tags whose name contains query
+func (ts *TagService) SearchTags(ctx context.Context, userID uuid.UUID, query string, limit int) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	err := ts.db.DB.NewSelect().
+		Model(&tags).
+		Where("user_id = ?", userID).
+		Where("name ILIKE ?", "%"+escapeLike(strings.ToLower(strings.TrimSpace(query)))+"%").
+		Order("name ASC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error searching tags: %w", err)
+	}
+	return tags, nil
+}
+
+// TagNode is one of the user's tags in their tag tree
+type TagNode struct {
+	models.Tag
+	// Label is the name relative to the parent tag
+	Label string `json:"label"`
+	// Uses counts the notes carrying the tag itself, not its nested tags
+	Uses     int        `json:"uses"`
+	Children []*TagNode `json:"children"`
+}
+
+// GetTagTree returns the user's tags nested by path, each level by name.
+// A tag whose parent doesn't exist is placed under its nearest ancestor.
+func (ts *TagService) GetTagTree(ctx context.Context, userID uuid.UUID) ([]*TagNode, error) {
 	var tags []models.Tag
+	err := ts.db.DB.NewSelect().
+		Model(&tags).
+		Where("user_id = ?", userID).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tags: %w", err)
+	}
+	sortTagsByName(tags)
+
+	uses, err := scanTagCounts(ctx, ts.db.DB.NewSelect().
+		TableExpr("timestamp_tags AS tt").
+		ColumnExpr("tt.tag_id").
+		ColumnExpr("COUNT(*) AS count").
+		Join("JOIN timestamps AS ts ON ts.id = tt.timestamp_id").
+		Where("ts.user_id = ? AND ts.deleted_at IS NULL", userID).
+		GroupExpr("tt.tag_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	roots := []*TagNode{}
+	byName := make(map[string]*TagNode, len(tags))
+	for _, tag := range tags {
+		node := &TagNode{Tag: tag, Label: tag.Name, Uses: uses[tag.ID], Children: []*TagNode{}}
+		byName[tag.Name] = node
+
+		paths := tagPaths(tag.Name)
+		parent := (*TagNode)(nil)
+		for i := len(paths) - 2; i >= 0 && parent == nil; i-- {
+			parent = byName[paths[i]]
+		}
+		if parent == nil {
+			roots = append(roots, node)
+			continue
+		}
+		node.Label = strings.TrimPrefix(tag.Name, parent.Name+tagPathSeparator)
+		parent.Children = append(parent.Children, node)
+	}
+	return roots, nil
+}
+
+// TagChange is a renamed or merged tag with the notes whose tags changed,
+// which need new embeddings
+type TagChange struct {
+	Tag *models.Tag
+	// Merged counts the tags merged away
+	Merged int
+	Notes  []uuid.UUID
+}
+
+// UpdateTag renames one of the user's tags or changes its color or
+// description. Renaming moves its nested tags along, so renaming ml to
+// machine-learning also renames ml/transformers.
+func (ts *TagService) UpdateTag(ctx context.Context, userID, tagID uuid.UUID, req UpdateTagRequest) (*TagChange, error) {
+	var color *string
+	if req.Color != nil && *req.Color != "" {
+		if !tagColorPattern.MatchString(*req.Color) {
+			return nil, errInvalidTagColor
+		}
+		color = new(string)
+		*color = strings.ToLower(*req.Color)
+	}
+
+	tx, err := ts.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	tag, err := getTag(ctx, tx, userID, tagID)
+	if err != nil {
+		return nil, err
+	}
 
-	if timestampCount == 0 {
-		// User has no timestamps, return empty list
-		tags = []models.Tag{}
-	} else {
-		// Get all tags that are used by the current user using a subquery
-		query := ts.db.DB.NewSelect().
-			Model(&tags).
-			Where("id IN (SELECT DISTINCT tag_id FROM timestamp_tags WHERE timestamp_id IN (SELECT id FROM timestamps WHERE user_id = ? AND deleted_at IS NULL))", userUUID).
-			Order("name ASC").
-			Limit(limit)
-
-		err = query.Scan(ctx)
+	change := &TagChange{}
+	if req.Name != nil {
+		name := normalizeTagName(*req.Name)
+		if err := validateTagName(name); err != nil {
+			return nil, err
+		}
+		if name != tag.Name {
+			if change.Notes, err = renameTagTree(ctx, tx, userID, tag.Name, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	update := tx.NewUpdate().
+		Model((*models.Tag)(nil)).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ? AND user_id = ?", tagID, userID)
+	if req.Color != nil {
+		update = update.Set("color = ?", color)
+	}
+	if req.Description != nil {
+		update = update.Set("description = ?", strings.TrimSpace(*req.Description))
+	}
+	if _, err := update.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	if change.Tag, err = getTag(ctx, tx, userID, tagID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return change, nil
+}
+
+// MergeTags moves the notes of the source tags onto the target tag and
+// deletes the sources. Tags nested under a source move under the target,
+// merging into the target's nested tags of the same name.
+func (ts *TagService) MergeTags(ctx context.Context, userID uuid.UUID, req MergeTagsRequest) (*TagChange, error) {
+	tx, err := ts.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	target, err := getTag(ctx, tx, userID, req.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []models.Tag
+	err = tx.NewSelect().
+		Model(&sources).
+		Where("user_id = ? AND id IN (?)", userID, bun.In(req.SourceIDs)).
+		Where("id <> ?", target.ID).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
+	if len(sources) == 0 {
+		return nil, errNotFound
+	}
+	sortTagsByName(sources)
+
+	change := &TagChange{}
+	var merged []string
+	for _, source := range sources {
+		if isNestedTag(target.Name, source.Name) {
+			return nil, errTagNested
+		}
+		// A source nested under another already moved with it
+		if slices.ContainsFunc(merged, func(name string) bool { return isNestedTag(source.Name, name) }) {
+			continue
+		}
+
+		notes, removed, err := mergeTagTree(ctx, tx, userID, source.Name, target.Name)
 		if err != nil {
-			return nil, fmt.Errorf("error fetching all tags: %w", err)
+			return nil, err
 		}
+		change.Notes = append(change.Notes, notes...)
+		change.Merged += removed
+		merged = append(merged, source.Name)
 	}
 
-	return tags, nil
+	slices.SortFunc(change.Notes, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	change.Notes = slices.Compact(change.Notes)
+
+	if change.Tag, err = getTag(ctx, tx, userID, target.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return change, nil
 }
 
-func (ts *TagService) SearchTags(ctx context.Context, userID, query string, limit int) ([]models.Tag, error) {
-	// Convert string user ID to UUID
-	userUUID, err := uuid.Parse(userID)
+// renameTagTree renames the tag named from and the tags nested under it to
+// the path to, returning the notes carrying any of them
+func renameTagTree(ctx context.Context, tx bun.Tx, userID uuid.UUID, from, to string) ([]uuid.UUID, error) {
+	if isNestedTag(to, from) {
+		return nil, errTagNested
+	}
+
+	tree, err := tagTree(ctx, tx, userID, from)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID format: %w", err)
+		return nil, err
+	}
+	ids := make([]uuid.UUID, len(tree))
+	names := make([]string, len(tree))
+	for i, tag := range tree {
+		ids[i] = tag.ID
+		names[i] = to + strings.TrimPrefix(tag.Name, from)
+		if err := validateTagName(names[i]); err != nil {
+			return nil, err
+		}
 	}
 
-	// First, check if user has any timestamps
-	timestampCount, err := ts.db.DB.NewSelect().
-		Model((*models.Timestamp)(nil)).
-		Where("user_id = ? AND deleted_at IS NULL", userUUID).
-		Count(ctx)
+	taken, err := tx.NewSelect().
+		Model((*models.Tag)(nil)).
+		Where("user_id = ? AND name IN (?)", userID, bun.In(names)).
+		Where("id NOT IN (?)", bun.In(ids)).
+		Exists(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check tag names: %w", err)
+	}
+	if taken {
+		return nil, errTagExists
+	}
 
+	notes, err := taggedNotes(ctx, tx, ids)
 	if err != nil {
-		return nil, fmt.Errorf("error checking timestamp count: %w", err)
+		return nil, err
 	}
 
-	var tags []models.Tag
+	_, err = tx.NewUpdate().
+		Model((*models.Tag)(nil)).
+		Set("name = ? || substr(name, ?)", to, utf8.RuneCountInString(from)+1).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if isUniqueViolation(err) {
+		return nil, errTagExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename tags: %w", err)
+	}
+
+	// Moving a tag under a new parent creates the parent
+	if _, err := findOrCreateTag(ctx, tx, userID, to); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// mergeTagTree moves the tag named from and the tags nested under it to the
+// path into, merging each into the tag already named that way, if any. It
+// returns the notes carrying any of them and the number of tags merged away.
+func mergeTagTree(ctx context.Context, tx bun.Tx, userID uuid.UUID, from, into string) ([]uuid.UUID, int, error) {
+	tree, err := tagTree(ctx, tx, userID, from)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := make([]uuid.UUID, len(tree))
+	for i, tag := range tree {
+		ids[i] = tag.ID
+	}
+	notes, err := taggedNotes(ctx, tx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	merged := 0
+	for _, tag := range tree {
+		name := into + strings.TrimPrefix(tag.Name, from)
+		if err := validateTagName(name); err != nil {
+			return nil, 0, err
+		}
+
+		var existing models.Tag
+		err := tx.NewSelect().
+			Model(&existing).
+			Where("user_id = ? AND name = ?", userID, name).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			_, err = tx.NewUpdate().
+				Model((*models.Tag)(nil)).
+				Set("name = ?", name).
+				Set("updated_at = ?", time.Now().UTC()).
+				Where("id = ?", tag.ID).
+				Exec(ctx)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to rename tag: %w", err)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to fetch tag: %w", err)
+		}
 
-	if timestampCount == 0 {
-		// User has no timestamps, return empty list
-		tags = []models.Tag{}
-	} else {
-		// Search for tags that are used by the current user using a subquery
-		query := ts.db.DB.NewSelect().
-			Model(&tags).
-			Where("id IN (SELECT DISTINCT tag_id FROM timestamp_tags WHERE timestamp_id IN (SELECT id FROM timestamps WHERE user_id = ? AND deleted_at IS NULL))", userUUID).
-			Where("LOWER(name) ILIKE LOWER(?)", "%"+query+"%").
-			Order("name ASC").
-			Limit(limit)
-
-		err = query.Scan(ctx)
+		_, err = tx.NewRaw(
+			"INSERT INTO timestamp_tags (timestamp_id, tag_id) SELECT timestamp_id, ? FROM timestamp_tags WHERE tag_id = ? ON CONFLICT (timestamp_id, tag_id) DO NOTHING",
+			existing.ID, tag.ID,
+		).Exec(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("error searching tags: %w", err)
+			return nil, 0, fmt.Errorf("failed to move tag relations: %w", err)
 		}
+		// Deleting the tag deletes its relations
+		if _, err := tx.NewDelete().Model((*models.Tag)(nil)).Where("id = ?", tag.ID).Exec(ctx); err != nil {
+			return nil, 0, fmt.Errorf("failed to delete merged tag: %w", err)
+		}
+		merged++
 	}
+	return notes, merged, nil
+}
 
+func getTag(ctx context.Context, db bun.IDB, userID, tagID uuid.UUID) (*models.Tag, error) {
+	tag := new(models.Tag)
+	err := db.NewSelect().
+		Model(tag).
+		Where("id = ? AND user_id = ?", tagID, userID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tag: %w", err)
+	}
+	return tag, nil
+}
+
+// tagTree returns the user's tag named name and the tags nested under it,
+// by name
+func tagTree(ctx context.Context, db bun.IDB, userID uuid.UUID, name string) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := db.NewSelect().
+		Model(&tags).
+		Where("user_id = ?", userID).
+		Where("name = ? OR starts_with(name, ?)", name, name+tagPathSeparator).
+		Order("name ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %w", err)
+	}
 	return tags, nil
 }
+
+// taggedNotes returns the live notes carrying any of the tags
+func taggedNotes(ctx context.Context, db bun.IDB, tagIDs []uuid.UUID) ([]uuid.UUID, error) {
+	var notes []uuid.UUID
+	err := db.NewSelect().
+		TableExpr("timestamp_tags AS tt").
+		ColumnExpr("DISTINCT tt.timestamp_id").
+		Join("JOIN timestamps AS ts ON ts.id = tt.timestamp_id").
+		Where("tt.tag_id IN (?) AND ts.deleted_at IS NULL", bun.In(tagIDs)).
+		Scan(ctx, &notes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tagged notes: %w", err)
+	}
+	return notes, nil
+}
+
+// normalizeTagName stores tags lowercase with inner whitespace collapsed.
+// Nested tags keep a single "/" between their non-empty segments.
+func normalizeTagName(name string) string {
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimPrefix(strings.TrimSpace(name), "#"), tagPathSeparator) {
+		if segment = strings.Join(strings.Fields(strings.ToLower(segment)), " "); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, tagPathSeparator)
+}
+
+// normalizeTagNames normalizes tag names, dropping empty names and
+// duplicates
+func normalizeTagNames(tags []string) []string {
+	var names []string
+	for _, tag := range tags {
+		if name := normalizeTagName(tag); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func validateTagName(name string) error {
+	if name == "" || len(name) > maxTagNameLength {
+		return errInvalidTagName
+	}
+	return nil
+}
+
+// tagPaths returns the paths of a tag and the tags it is nested under,
+// outermost first, so ml/transformers gives ml and ml/transformers
+func tagPaths(name string) []string {
+	segments := strings.Split(name, tagPathSeparator)
+	paths := make([]string, len(segments))
+	for i := range segments {
+		paths[i] = strings.Join(segments[:i+1], tagPathSeparator)
+	}
+	return paths
+}
+
+// sortTagsByName sorts tags by name in byte order, which puts every tag
+// before the tags nested under it. Database collations may not.
+func sortTagsByName(tags []models.Tag) {
+	slices.SortFunc(tags, func(a, b models.Tag) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
+
+// isNestedTag reports whether the tag named name is parent or nested under it
+func isNestedTag(name, parent string) bool {
+	return name == parent || strings.HasPrefix(name, parent+tagPathSeparator)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr pgdriver.Error
+	return errors.As(err, &pgErr) && pgErr.Field('C') == "23505"
+}
+
+// TagUsage is a tag in the user's vocabulary with the number of their notes
+// carrying it
+type TagUsage struct {
+	ID   uuid.UUID `bun:"id" json:"id"`
+	Name string    `bun:"name" json:"name"`
+	Uses int       `bun:"uses" json:"uses"`
+}
+
+// GetTagUsage returns every tag on the user's notes, most used first
+func (ts *TagService) GetTagUsage(ctx context.Context, userID uuid.UUID) ([]TagUsage, error) {
+	var usage []TagUsage
+	err := ts.db.DB.NewSelect().
+		TableExpr("tags AS t").
+		ColumnExpr("t.id, t.name").
+		ColumnExpr("COUNT(DISTINCT tt.timestamp_id) AS uses").
+		Join("JOIN timestamp_tags AS tt ON tt.tag_id = t.id").
+		Join("JOIN timestamps AS ts ON ts.id = tt.timestamp_id").
+		Where("ts.user_id = ? AND ts.deleted_at IS NULL", userID).
+		GroupExpr("t.id, t.name").
+		OrderExpr("uses DESC, t.name ASC").
+		Scan(ctx, &usage)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching tag usage: %w", err)
+	}
+	return usage, nil
+}
+
+// GetTimestampTagIDs returns the tag IDs of each of the given notes
+func (ts *TagService) GetTimestampTagIDs(ctx context.Context, timestampIDs []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	tagIDs := make(map[uuid.UUID][]uuid.UUID)
+	if len(timestampIDs) == 0 {
+		return tagIDs, nil
+	}
+
+	var relations []models.TimestampTag
+	err := ts.db.DB.NewSelect().
+		Model(&relations).
+		Column("timestamp_id", "tag_id").
+		Where("timestamp_id IN (?)", bun.In(timestampIDs)).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching note tags: %w", err)
+	}
+
+	for _, relation := range relations {
+		tagIDs[relation.TimestampID] = append(tagIDs[relation.TimestampID], relation.TagID)
+	}
+	return tagIDs, nil
+}
+
+// GetCooccurringTags counts, per tag, the user's notes that carry it together
+// with any of tagIDs
+func (ts *TagService) GetCooccurringTags(ctx context.Context, userID uuid.UUID, tagIDs []uuid.UUID) (map[uuid.UUID]int, error) {
+	if len(tagIDs) == 0 {
+		return map[uuid.UUID]int{}, nil
+	}
+
+	query := ts.db.DB.NewSelect().
+		TableExpr("timestamp_tags AS other").
+		ColumnExpr("other.tag_id").
+		ColumnExpr("COUNT(DISTINCT other.timestamp_id) AS count").
+		Join("JOIN timestamp_tags AS given ON given.timestamp_id = other.timestamp_id AND given.tag_id <> other.tag_id").
+		Join("JOIN timestamps AS ts ON ts.id = other.timestamp_id").
+		Where("ts.user_id = ? AND ts.deleted_at IS NULL", userID).
+		Where("given.tag_id IN (?)", bun.In(tagIDs)).
+		GroupExpr("other.tag_id")
+	return scanTagCounts(ctx, query)
+}
+
+// GetVideoTagCounts counts, per tag, the user's notes on the video carrying it
+func (ts *TagService) GetVideoTagCounts(ctx context.Context, userID uuid.UUID, videoID string) (map[uuid.UUID]int, error) {
+	query := ts.db.DB.NewSelect().
+		TableExpr("timestamp_tags AS tt").
+		ColumnExpr("tt.tag_id").
+		ColumnExpr("COUNT(*) AS count").
+		Join("JOIN timestamps AS ts ON ts.id = tt.timestamp_id").
+		Where("ts.user_id = ? AND ts.video_id = ? AND ts.deleted_at IS NULL", userID, videoID).
+		GroupExpr("tt.tag_id")
+	return scanTagCounts(ctx, query)
+}
+
+func scanTagCounts(ctx context.Context, query *bun.SelectQuery) (map[uuid.UUID]int, error) {
+	var rows []struct {
+		TagID uuid.UUID `bun:"tag_id"`
+		Count int       `bun:"count"`
+	}
+	if err := query.Scan(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("error counting tags: %w", err)
+	}
+
+	counts := make(map[uuid.UUID]int, len(rows))
+	for _, row := range rows {
+		counts[row.TagID] = row.Count
+	}
+	return counts, nil
+}
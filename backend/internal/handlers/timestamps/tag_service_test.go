@@ -0,0 +1,188 @@
+package timestamps
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+func TestNormalizeTagName(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want string
+	}{
+		{"lower case", "ML", "ml"},
+		{"leading hash", " #ml", "ml"},
+		{"inner whitespace", "machine \t learning", "machine learning"},
+		{"trailing separator", "ml/", "ml"},
+		{"nested", " ML // Transformers / ", "ml/transformers"},
+		{"only a hash", "#", ""},
+		{"only separators", " / ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeTagName(tt.tag); got != tt.want {
+				t.Errorf("normalizeTagName(%q) = %q, want %q", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTagNames(t *testing.T) {
+	got := normalizeTagNames([]string{"ML", " ", "#ml", "ml/transformers", "ml/ transformers/"})
+	if want := []string{"ml", "ml/transformers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeTagNames() = %v, want %v", got, want)
+	}
+}
+
+func TestTagPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"ml", []string{"ml"}},
+		{"ml/transformers", []string{"ml", "ml/transformers"}},
+		{"ml/transformers/bert", []string{"ml", "ml/transformers", "ml/transformers/bert"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tagPaths(tt.name); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tagPaths(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsNestedTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent string
+		want   bool
+	}{
+		{"ml", "ml", true},
+		{"ml/transformers", "ml", true},
+		{"ml/transformers/bert", "ml", true},
+		{"mlops", "ml", false},
+		{"ml", "ml/transformers", false},
+		{"ai/ml", "ml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" in "+tt.parent, func(t *testing.T) {
+			if got := isNestedTag(tt.name, tt.parent); got != tt.want {
+				t.Errorf("isNestedTag(%q, %q) = %v, want %v", tt.name, tt.parent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortTagsByName(t *testing.T) {
+	tags := []models.Tag{{Name: "ml/transformers"}, {Name: "ai/ml"}, {Name: "ml"}, {Name: "ai"}}
+	sortTagsByName(tags)
+
+	var got []string
+	for _, tag := range tags {
+		got = append(got, tag.Name)
+	}
+	if want := []string{"ai", "ai/ml", "ml", "ml/transformers"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted tags = %v, want %v", got, want)
+	}
+}
+
+// seedTaggedNote stores a note of the user carrying the tags
+func seedTaggedNote(t *testing.T, db *database.Database, userID uuid.UUID, tags ...*models.Tag) *models.Timestamp {
+	t.Helper()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	note := &models.Timestamp{
+		ID:         uuid.New(),
+		VideoID:    "vid-tags",
+		UserID:     userID,
+		Type:       models.ClipTypeNote,
+		Importance: models.DefaultTimestampImportance,
+		Title:      "tagged",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if _, err := db.DB.NewInsert().Model(note).Exec(ctx); err != nil {
+		t.Fatalf("failed to seed note: %v", err)
+	}
+	var tagIDs []uuid.UUID
+	for _, tag := range tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	if err := NewTagService(db).CreateTimestampTagRelations(ctx, note.ID.String(), tagIDs); err != nil {
+		t.Fatalf("failed to tag note: %v", err)
+	}
+	return note
+}
+
+func TestMergeTagsIntoNestedTarget(t *testing.T) {
+	db := testDatabase(t)
+	userID := testUser(t, db)
+	ctx := context.Background()
+	ts := NewTagService(db)
+
+	tags := map[string]*models.Tag{}
+	for _, name := range []string{"dl", "dl/cnn", "dl/rnn", "ml", "ml/deep", "ml/deep/cnn"} {
+		tag, err := ts.FindOrCreateTag(ctx, userID, name)
+		if err != nil {
+			t.Fatalf("FindOrCreateTag(%q) error: %v", name, err)
+		}
+		tags[name] = tag
+	}
+	dl := seedTaggedNote(t, db, userID, tags["dl"])
+	cnn := seedTaggedNote(t, db, userID, tags["dl/cnn"], tags["ml/deep/cnn"])
+	rnn := seedTaggedNote(t, db, userID, tags["dl/rnn"])
+
+	change, err := ts.MergeTags(ctx, userID, MergeTagsRequest{SourceIDs: []uuid.UUID{tags["dl"].ID}, TargetID: tags["ml/deep"].ID})
+	if err != nil {
+		t.Fatalf("MergeTags error: %v", err)
+	}
+	// dl merges into ml/deep and dl/cnn into ml/deep/cnn, while dl/rnn is
+	// renamed to ml/deep/rnn
+	if change.Merged != 2 || change.Tag.ID != tags["ml/deep"].ID {
+		t.Errorf("merged %d tags into %+v, want 2 into ml/deep", change.Merged, change.Tag)
+	}
+	if len(change.Notes) != 3 {
+		t.Errorf("change has notes %v, want the 3 tagged ones", change.Notes)
+	}
+
+	var remaining []models.Tag
+	if err := db.DB.NewSelect().Model(&remaining).Where("user_id = ?", userID).Scan(ctx); err != nil {
+		t.Fatalf("failed to fetch tags: %v", err)
+	}
+	sortTagsByName(remaining)
+	var names []string
+	for _, tag := range remaining {
+		names = append(names, tag.Name)
+	}
+	if want := []string{"ml", "ml/deep", "ml/deep/cnn", "ml/deep/rnn"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("tags = %v, want %v", names, want)
+	}
+
+	wantTags := map[uuid.UUID][]string{
+		dl.ID:  {"ml/deep"},
+		cnn.ID: {"ml/deep/cnn"},
+		rnn.ID: {"ml/deep/rnn"},
+	}
+	for noteID, want := range wantTags {
+		var note models.Timestamp
+		if err := db.DB.NewSelect().Model(&note).Relation("Tags").Where("?TableAlias.id = ?", noteID).Scan(ctx); err != nil {
+			t.Fatalf("failed to fetch note: %v", err)
+		}
+		var got []string
+		for _, tag := range note.Tags {
+			got = append(got, tag.Name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("note %s has tags %v, want %v", noteID, got, want)
+		}
+	}
+}
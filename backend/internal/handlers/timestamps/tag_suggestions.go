@@ -0,0 +1,452 @@
+package timestamps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/aicache"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/prompts"
+)
+
+// Weights of the signals existing tags are ranked by. Each signal is scaled
+// to 0-1 across the user's vocabulary before weighting.
+const (
+	tagNeighborWeight = 0.45 // Tags of the user's notes most similar to the draft
+	tagNameWeight     = 0.25 // Similarity of the tag name to the draft
+	tagCooccurWeight  = 0.2  // Notes pairing the tag with the draft's tags
+	tagVideoWeight    = 0.1  // Notes on the same video carrying the tag
+)
+
+const (
+	// tagNeighborNotes is how many similar notes lend their tags
+	tagNeighborNotes = 20
+	// minTagScore drops existing tags that barely relate to the draft
+	minTagScore = 0.15
+	// maxNewTags bounds the new tags proposed per draft
+	maxNewTags = 3
+	// tagVocabularyPrompt is how many ranked existing tags the model sees
+	// when proposing new ones
+	tagVocabularyPrompt = 50
+	// tagDuplicateSimilarity is the name similarity above which a proposed
+	// tag is treated as a variant of an existing one
+	tagDuplicateSimilarity = 0.85
+)
+
+// Reasons a tag is suggested
+const (
+	TagReasonSimilarNotes = "similar_notes"
+	TagReasonName         = "name"
+	TagReasonCooccurrence = "co_occurrence"
+	TagReasonVideo        = "video"
+	TagReasonNew          = "new"
+	TagReasonVariant      = "variant" // A proposed new tag matched this existing one
+)
+
+// TagSuggestion is a tag proposed for a draft note. ID is nil for tags that
+// don't exist yet.
+type TagSuggestion struct {
+	ID      *uuid.UUID `json:"id,omitempty"`
+	Name    string     `json:"name"`
+	Score   float64    `json:"score"`
+	Uses    int        `json:"uses"`
+	Reasons []string   `json:"reasons"`
+}
+
+// SuggestTags ranks the user's existing tags for a draft note and proposes a
+// few new ones where nothing in their vocabulary fits
+func (t *TimestampsHandlers) SuggestTags(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req SuggestTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" && strings.TrimSpace(req.Note) == "" {
+		middleware.RespondWithError(c, http.StatusBadRequest, "EMPTY_DRAFT", "A title or note is required to suggest tags", nil)
+		return
+	}
+	if req.Limit <= 0 || req.Limit > 20 {
+		req.Limit = 5
+	}
+	if !t.requireTokenBudget(c, userID) {
+		return
+	}
+
+	used := prompts.Versions{}
+	existing, proposed, err := t.suggestTags(c.Request.Context(), userID, req, used)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "TAG_SUGGESTION_ERROR", "Failed to suggest tags", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"tags":     existing,
+		"new_tags": proposed,
+		"prompts":  used,
+	})
+}
+
+// suggestTags adds the version of the prompt behind the new tags to used
+func (t *TimestampsHandlers) suggestTags(ctx context.Context, userID uuid.UUID, req SuggestTagsRequest, used prompts.Versions) ([]TagSuggestion, []TagSuggestion, error) {
+	vocabulary, err := t.tagService.GetTagUsage(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var videoTitle string
+	if req.VideoID != "" {
+		var video models.Video
+		err := t.db.DB.NewSelect().
+			Model(&video).
+			Column("title").
+			Where("user_id = ? AND video_id = ? AND deleted_at IS NULL", userID, req.VideoID).
+			Scan(ctx)
+		if err == nil {
+			videoTitle = video.Title
+		}
+	}
+
+	draft := t.aiService.CreateEmbeddingText(req.Title, req.Note, req.Tags)
+	if videoTitle != "" {
+		draft = "Video: " + videoTitle + "\n" + draft
+	}
+	draftEmbedding, err := t.aiService.GenerateEmbedding(ctx, draft)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to embed draft: %w", err)
+	}
+
+	chosen := make(map[string]bool, len(req.Tags))
+	for _, tag := range req.Tags {
+		chosen[tagKey(tag)] = true
+	}
+
+	ranked, nameEmbeddings, err := t.rankExistingTags(ctx, userID, req.VideoID, vocabulary, chosen, draftEmbedding)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing := make([]TagSuggestion, 0, req.Limit)
+	for _, suggestion := range ranked {
+		if len(existing) == req.Limit || suggestion.Score < minTagScore {
+			break
+		}
+		existing = append(existing, suggestion)
+	}
+
+	proposed, variants, err := t.proposeNewTags(ctx, userID, draft, ranked, vocabulary, nameEmbeddings, chosen, used)
+	if err != nil {
+		// Ranked existing tags are still useful without new ones
+		log.Printf("Failed to propose new tags for user %s: %v", userID, err)
+		return existing, []TagSuggestion{}, nil
+	}
+
+	// Variants of existing tags the model asked for are suggested as the
+	// existing tag, even when they ranked low
+	for _, variant := range variants {
+		found := false
+		for i := range existing {
+			if existing[i].Name == variant.Name {
+				existing[i].Reasons = appendReason(existing[i].Reasons, TagReasonVariant)
+				found = true
+				break
+			}
+		}
+		if !found {
+			existing = append(existing, variant)
+		}
+	}
+
+	return existing, proposed, nil
+}
+
+// rankExistingTags scores every tag in the vocabulary that is not on the
+// draft yet, best first. It also returns the embeddings of the tag names.
+func (t *TimestampsHandlers) rankExistingTags(ctx context.Context, userID uuid.UUID, videoID string, vocabulary []TagUsage, chosen map[string]bool, draftEmbedding []float32) ([]TagSuggestion, map[string][]float32, error) {
+	if len(vocabulary) == 0 {
+		return nil, nil, nil
+	}
+
+	var chosenIDs []uuid.UUID
+	names := make([]string, len(vocabulary))
+	for i, tag := range vocabulary {
+		names[i] = tag.Name
+		if chosen[tagKey(tag.Name)] {
+			chosenIDs = append(chosenIDs, tag.ID)
+		}
+	}
+
+	neighbors := make(map[uuid.UUID]float64)
+	notes, err := t.searchService.SearchTimestamps(ctx, userID, draftEmbedding, SearchFilter{}, tagNeighborNotes)
+	if err != nil {
+		return nil, nil, err
+	}
+	noteIDs := make([]uuid.UUID, 0, len(notes))
+	noteScores := make(map[uuid.UUID]float64, len(notes))
+	for _, note := range notes {
+		if timestamp, ok := note.Timestamp.(models.Timestamp); ok {
+			noteIDs = append(noteIDs, timestamp.ID)
+			noteScores[timestamp.ID] = math.Max(float64(note.Score), 0)
+		}
+	}
+	noteTags, err := t.tagService.GetTimestampTagIDs(ctx, noteIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for noteID, tagIDs := range noteTags {
+		for _, tagID := range tagIDs {
+			neighbors[tagID] += noteScores[noteID]
+		}
+	}
+
+	cooccurring, err := t.tagService.GetCooccurringTags(ctx, userID, chosenIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	onVideo := map[uuid.UUID]int{}
+	if videoID != "" {
+		if onVideo, err = t.tagService.GetVideoTagCounts(ctx, userID, videoID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Tag names are short and shared across users, so their embeddings are
+	// usually served from the AI cache
+	nameEmbeddings := make(map[string][]float32, len(names))
+	nameSimilarity := make(map[uuid.UUID]float64, len(names))
+	for i, result := range t.aiService.GenerateEmbeddings(ctx, names, nil) {
+		if result.Err != nil {
+			continue
+		}
+		nameEmbeddings[names[i]] = result.Embedding
+		nameSimilarity[vocabulary[i].ID] = float64(CosineSimilarity(draftEmbedding, result.Embedding))
+	}
+
+	signals := []struct {
+		weight float64
+		reason string
+		values map[uuid.UUID]float64
+	}{
+		{tagNeighborWeight, TagReasonSimilarNotes, scaleByMax(neighbors)},
+		{tagNameWeight, TagReasonName, scaleByRange(nameSimilarity)},
+		{tagCooccurWeight, TagReasonCooccurrence, scaleCountsByMax(cooccurring)},
+		{tagVideoWeight, TagReasonVideo, scaleCountsByMax(onVideo)},
+	}
+
+	var ranked []TagSuggestion
+	for _, tag := range vocabulary {
+		if chosen[tagKey(tag.Name)] {
+			continue
+		}
+
+		suggestion := TagSuggestion{Name: tag.Name, Uses: tag.Uses, Reasons: []string{}}
+		for _, signal := range signals {
+			value := signal.values[tag.ID]
+			suggestion.Score += signal.weight * value
+			if value >= 0.5 {
+				suggestion.Reasons = append(suggestion.Reasons, signal.reason)
+			}
+		}
+		suggestion.Score = math.Round(suggestion.Score*1000) / 1000
+		id := tag.ID
+		suggestion.ID = &id
+		ranked = append(ranked, suggestion)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Uses > ranked[j].Uses
+	})
+
+	return ranked, nameEmbeddings, nil
+}
+
+// tagPromptData are the variables of the tag suggestion prompt
+type tagPromptData struct {
+	Vocabulary string // Existing tags, most relevant first
+	Limit      int    // Most new tags to propose
+	Note       string // The draft note
+}
+
+// proposeNewTags asks the model for tags the vocabulary lacks. Proposals that
+// are spelling or wording variants of an existing tag are returned as that
+// tag instead, which keeps "ml" and "machine-learning" from piling up.
+func (t *TimestampsHandlers) proposeNewTags(ctx context.Context, userID uuid.UUID, draft string, ranked []TagSuggestion, vocabulary []TagUsage, nameEmbeddings map[string][]float32, chosen map[string]bool, used prompts.Versions) ([]TagSuggestion, []TagSuggestion, error) {
+	var known []string
+	for _, suggestion := range ranked {
+		if len(known) == tagVocabularyPrompt {
+			break
+		}
+		known = append(known, suggestion.Name)
+	}
+
+	vocabularyList := "(none yet)"
+	if len(known) > 0 {
+		vocabularyList = strings.Join(known, ", ")
+	}
+	prompt, err := t.prompts.Render(ctx, prompts.Tags, userID, tagPromptData{
+		Vocabulary: vocabularyList,
+		Limit:      maxNewTags,
+		Note:       draft,
+	}, used)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := t.aiService.GenerateJSONCompletion(ctx, aicache.FeatureSuggest, prompt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var response struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.Unmarshal([]byte(content), &response); err != nil {
+		return nil, nil, fmt.Errorf("AI returned malformed tags: %w", err)
+	}
+
+	byKey := make(map[string]TagUsage, len(vocabulary))
+	for _, tag := range vocabulary {
+		byKey[tagKey(tag.Name)] = tag
+	}
+
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, name := range response.Tags {
+		name = normalizeTagName(name)
+		if name == "" || len(name) > 50 || chosen[tagKey(name)] || seen[tagKey(name)] {
+			continue
+		}
+		seen[tagKey(name)] = true
+		candidates = append(candidates, name)
+		if len(candidates) == maxNewTags {
+			break
+		}
+	}
+
+	var candidateEmbeddings [][]float32
+	if len(candidates) > 0 && len(nameEmbeddings) > 0 {
+		for _, result := range t.aiService.GenerateEmbeddings(ctx, candidates, nil) {
+			candidateEmbeddings = append(candidateEmbeddings, result.Embedding)
+		}
+	}
+
+	proposed := []TagSuggestion{}
+	var variants []TagSuggestion
+	for i, name := range candidates {
+		match, ok := byKey[tagKey(name)]
+		if !ok && candidateEmbeddings != nil && candidateEmbeddings[i] != nil {
+			best := 0.0
+			for _, tag := range vocabulary {
+				if embedding, found := nameEmbeddings[tag.Name]; found {
+					if similarity := float64(CosineSimilarity(candidateEmbeddings[i], embedding)); similarity > best {
+						best, match = similarity, tag
+					}
+				}
+			}
+			ok = best >= tagDuplicateSimilarity
+		}
+
+		if ok {
+			if chosen[tagKey(match.Name)] {
+				continue
+			}
+			id := match.ID
+			variants = append(variants, TagSuggestion{
+				ID:      &id,
+				Name:    match.Name,
+				Uses:    match.Uses,
+				Reasons: []string{TagReasonVariant},
+			})
+			continue
+		}
+
+		proposed = append(proposed, TagSuggestion{Name: name, Reasons: []string{TagReasonNew}})
+	}
+
+	return proposed, variants, nil
+}
+
+// tagKey matches tags that differ only in case, spacing or punctuation, so
+// "machine-learning" and "Machine Learning" share a key
+func tagKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+func scaleByMax(values map[uuid.UUID]float64) map[uuid.UUID]float64 {
+	var top float64
+	for _, value := range values {
+		top = math.Max(top, value)
+	}
+
+	scaled := make(map[uuid.UUID]float64, len(values))
+	if top <= 0 {
+		return scaled
+	}
+	for id, value := range values {
+		scaled[id] = value / top
+	}
+	return scaled
+}
+
+func scaleCountsByMax(counts map[uuid.UUID]int) map[uuid.UUID]float64 {
+	values := make(map[uuid.UUID]float64, len(counts))
+	for id, count := range counts {
+		values[id] = float64(count)
+	}
+	return scaleByMax(values)
+}
+
+// scaleByRange maps values onto 0-1 between their minimum and maximum, as
+// embedding similarities cluster in a narrow band
+func scaleByRange(values map[uuid.UUID]float64) map[uuid.UUID]float64 {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+
+	scaled := make(map[uuid.UUID]float64, len(values))
+	if high <= low {
+		return scaled
+	}
+	for id, value := range values {
+		scaled[id] = (value - low) / (high - low)
+	}
+	return scaled
+}
+
+func appendReason(reasons []string, reason string) []string {
+	for _, existing := range reasons {
+		if existing == reason {
+			return reasons
+		}
+	}
+	return append(reasons, reason)
+}
@@ -0,0 +1,116 @@
+package timestamps
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	authhandlers "github.com/shubhamku044/ytclipper/internal/handlers/auth"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/services"
+)
+
+// attributeTokenUsage attributes the AI calls made while serving a request
+// to the signed in user
+func attributeTokenUsage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userIDStr, exists := authhandlers.GetUserID(c); exists {
+			if userID, err := uuid.Parse(userIDStr); err == nil {
+				c.Request = c.Request.WithContext(services.WithTokenUser(c.Request.Context(), userID))
+			}
+		}
+		c.Next()
+	}
+}
+
+// requireTokenBudget responds 403 and returns false once the user has used
+// their plan's monthly AI tokens
+func (t *TimestampsHandlers) requireTokenBudget(c *gin.Context, userID uuid.UUID) bool {
+	budget, err := t.tokenUsageService.Budget(c.Request.Context(), userID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "USAGE_CHECK_ERROR", "Failed to check token budget", gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	if budget.Exceeded() {
+		middleware.RespondWithError(c, http.StatusForbidden, "TOKEN_BUDGET_EXCEEDED", "Monthly AI token budget exceeded for your current plan", gin.H{
+			"budget": budget,
+		})
+		return false
+	}
+	return true
+}
+
+// GetAITokenUsage reports the user's AI token spend per day or month over
+// the last days, optionally broken down by feature or model, and this
+// month's spend against the plan's budget
+func (t *TimestampsHandlers) GetAITokenUsage(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	days := 30
+	if param := c.Query("days"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 || parsed > 366 {
+			middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_DAYS", "days must be between 1 and 366", nil)
+			return
+		}
+		days = parsed
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	filter := services.TokenSpendFilter{
+		From:     today.AddDate(0, 0, 1-days),
+		To:       today.AddDate(0, 0, 1),
+		Interval: c.DefaultQuery("interval", services.SpendIntervalDay),
+		By:       c.Query("by"),
+	}
+	if filter.Interval != services.SpendIntervalDay && filter.Interval != services.SpendIntervalMonth {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_INTERVAL", "interval must be day or month", nil)
+		return
+	}
+	if filter.By != "" && filter.By != services.SpendByFeature && filter.By != services.SpendByModel {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_BREAKDOWN", "by must be feature or model", nil)
+		return
+	}
+
+	ctx := c.Request.Context()
+	spend, err := t.tokenUsageService.Spend(ctx, userID, filter)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to read AI token usage", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	budget, err := t.tokenUsageService.Budget(ctx, userID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to read AI token budget", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var totalTokens int64
+	var totalCost float64
+	for _, item := range spend {
+		totalTokens += item.TotalTokens
+		totalCost += item.CostUSD
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"from":         filter.From,
+		"to":           filter.To,
+		"interval":     filter.Interval,
+		"by":           filter.By,
+		"spend":        spend,
+		"total_tokens": totalTokens,
+		"cost_usd":     totalCost,
+		"budget":       budget,
+	})
+}
@@ -0,0 +1,321 @@
+package timestamps
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shubhamku044/ytclipper/internal/chunking"
+	"github.com/shubhamku044/ytclipper/internal/middleware"
+	"github.com/shubhamku044/ytclipper/internal/transcripts"
+)
+
+// maxTranscriptUploadSize bounds uploaded transcript files
+const maxTranscriptUploadSize = 5 << 20
+
+// GetTranscript returns the stored transcript for a video, fetching it from
+// the transcript sources on first use. ?language= selects a caption language.
+func (t *TimestampsHandlers) GetTranscript(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	if videoID == "" {
+		middleware.RespondWithError(c, http.StatusBadRequest, "MISSING_VIDEO_ID", "Video ID is required", nil)
+		return
+	}
+
+	transcript, err := t.transcriptService.GetTranscript(c.Request.Context(), userID, videoID, c.Query("language"), false)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadGateway, "TRANSCRIPT_ERROR", "Failed to fetch transcript", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"transcript": transcript,
+	})
+}
+
+// RefreshTranscript refetches a shared transcript and replaces the stored copy
+func (t *TimestampsHandlers) RefreshTranscript(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	if videoID == "" {
+		middleware.RespondWithError(c, http.StatusBadRequest, "MISSING_VIDEO_ID", "Video ID is required", nil)
+		return
+	}
+
+	var req RefreshTranscriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	transcript, err := t.transcriptService.GetTranscript(c.Request.Context(), userID, videoID, req.Language, true)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadGateway, "TRANSCRIPT_ERROR", "Failed to refresh transcript", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"transcript": transcript,
+		"message":    "Transcript refreshed successfully",
+	})
+}
+
+// UploadTranscript stores a user-supplied transcript for a video. It accepts
+// a multipart "file" (SRT, WebVTT or timestamped text) or a "content" field,
+// with optional "language" and "format" fields. The video's transcript
+// embeddings are rebuilt from the upload.
+func (t *TimestampsHandlers) UploadTranscript(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	if videoID == "" {
+		middleware.RespondWithError(c, http.StatusBadRequest, "MISSING_VIDEO_ID", "Video ID is required", nil)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTranscriptUploadSize)
+
+	var filename string
+	var data []byte
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_FILE", "Failed to read uploaded file", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		defer file.Close()
+
+		data, err = io.ReadAll(file)
+		if err != nil {
+			middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_FILE", "Failed to read uploaded file", gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		filename = fileHeader.Filename
+	} else {
+		data = []byte(c.PostForm("content"))
+	}
+
+	if len(data) == 0 {
+		middleware.RespondWithError(c, http.StatusBadRequest, "MISSING_TRANSCRIPT", "A transcript file or content is required", nil)
+		return
+	}
+
+	format := c.PostForm("format")
+	switch format {
+	case "", transcripts.FormatSRT, transcripts.FormatVTT, transcripts.FormatText:
+	default:
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_FORMAT", "Unsupported transcript format", gin.H{
+			"format":  format,
+			"allowed": []string{transcripts.FormatSRT, transcripts.FormatVTT, transcripts.FormatText},
+		})
+		return
+	}
+
+	source := transcripts.NewUploadSource(filename, format, c.PostForm("language"), data)
+	transcript, err := t.transcriptService.ImportTranscript(c.Request.Context(), userID, videoID, c.PostForm("language"), source)
+	if errors.Is(err, transcripts.ErrNoTranscript) {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_TRANSCRIPT", "Transcript contains no timestamped segments", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_TRANSCRIPT", "Failed to import transcript", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	job, err := t.enqueueTranscriptEmbeddings(c.Request.Context(), userID, transcript, "")
+	if err != nil {
+		log.Printf("Failed to queue embeddings for uploaded transcript of video %s: %v", videoID, err)
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"transcript":     transcript,
+		"format":         source.Format(),
+		"embeddings_job": job,
+		"message":        "Transcript uploaded successfully",
+	})
+}
+
+// DeleteUploadedTranscript removes the user's uploaded transcript for a language
+func (t *TimestampsHandlers) DeleteUploadedTranscript(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	language := c.Query("language")
+	if language == "" {
+		language = transcripts.LanguageUndetermined
+	}
+
+	err := t.transcriptService.DeleteUploadedTranscript(c.Request.Context(), userID, videoID, language)
+	if errors.Is(err, errNotFound) {
+		middleware.RespondWithError(c, http.StatusNotFound, "TRANSCRIPT_NOT_FOUND", "Uploaded transcript not found", nil)
+		return
+	}
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_ERROR", "Failed to delete transcript", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"message": "Transcript deleted successfully",
+	})
+}
+
+func (t *TimestampsHandlers) ListTranscriptLanguages(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	if videoID == "" {
+		middleware.RespondWithError(c, http.StatusBadRequest, "MISSING_VIDEO_ID", "Video ID is required", nil)
+		return
+	}
+
+	languages, err := t.transcriptService.ListLanguages(c.Request.Context(), userID, videoID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadGateway, "TRANSCRIPT_ERROR", "Failed to list transcript languages", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"video_id":  videoID,
+		"languages": languages,
+		"count":     len(languages),
+	})
+}
+
+// RechunkTranscript queues re-chunking and re-embedding of a video's
+// transcript with the given strategy. Chunks from other strategies are kept,
+// so strategies can be compared with ListTranscriptChunks.
+func (t *TimestampsHandlers) RechunkTranscript(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	if videoID == "" {
+		middleware.RespondWithError(c, http.StatusBadRequest, "MISSING_VIDEO_ID", "Video ID is required", nil)
+		return
+	}
+
+	var req RechunkTranscriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request body", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	chunker, err := t.transcriptService.Chunker(req.Strategy)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadRequest, "INVALID_STRATEGY", "Unknown chunking strategy", gin.H{
+			"strategy": req.Strategy,
+			"allowed":  chunking.Strategies(),
+		})
+		return
+	}
+
+	transcript, err := t.transcriptService.GetTranscript(c.Request.Context(), userID, videoID, req.Language, false)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusBadGateway, "TRANSCRIPT_ERROR", "Failed to fetch transcript", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	chunks := chunker.Chunk(transcript.Segments)
+	job, err := t.enqueueTranscriptEmbeddings(c.Request.Context(), userID, transcript, chunker.Name())
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "JOB_ERROR", "Failed to queue transcript re-chunking", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"video_id":    videoID,
+		"strategy":    chunker.Name(),
+		"chunk_count": len(chunks),
+		"active":      chunker.Name() == t.transcriptService.ChunkStrategy(),
+		"job":         job,
+		"message":     "Transcript re-chunking queued",
+	})
+}
+
+// ListTranscriptChunks returns the stored chunks of a video for one strategy
+// (?strategy=, defaulting to the active one) with per-strategy statistics
+func (t *TimestampsHandlers) ListTranscriptChunks(c *gin.Context) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	videoID := c.Param("videoId")
+	strategy := c.Query("strategy")
+	if strategy == "" {
+		strategy = t.transcriptService.ChunkStrategy()
+	}
+
+	chunks, err := t.transcriptService.ListChunks(c.Request.Context(), userID, videoID, strategy)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to fetch transcript chunks", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	stats, err := t.transcriptService.ChunkStats(c.Request.Context(), userID, videoID)
+	if err != nil {
+		middleware.RespondWithError(c, http.StatusInternalServerError, "DB_READ_ERROR", "Failed to summarize transcript chunks", gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	middleware.RespondWithOK(c, gin.H{
+		"video_id":        videoID,
+		"strategy":        strategy,
+		"active_strategy": t.transcriptService.ChunkStrategy(),
+		"chunks":          chunks,
+		"count":           len(chunks),
+		"strategies":      stats,
+		"available":       chunking.Strategies(),
+	})
+}
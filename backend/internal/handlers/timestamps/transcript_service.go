@@ -0,0 +1,344 @@
+package timestamps
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/chunking"
+	"github.com/shubhamku044/ytclipper/internal/config"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/shubhamku044/ytclipper/internal/transcripts"
+	"github.com/uptrace/bun"
+)
+
+// TranscriptLanguage describes a transcript language available for a video
+type TranscriptLanguage struct {
+	transcripts.Language
+	Source string `json:"source"`
+	Stored bool   `json:"stored"`
+}
+
+// TranscriptService stores raw transcripts once per video and language so
+// summaries, Q&A and embeddings don't refetch them. Shared transcripts come
+// from the configured sources; uploaded ones are private to the uploader.
+type TranscriptService struct {
+	db            *database.Database
+	sources       []transcripts.Source
+	chunkStrategy string
+	chunkOptions  chunking.Options
+	// embeddingModel selects which model's copy of each chunk is read
+	embeddingModel string
+}
+
+func NewTranscriptService(db *database.Database, transcriptConfig *config.TranscriptConfig, embeddingModel string) *TranscriptService {
+	ts := &TranscriptService{
+		db:             db,
+		sources:        transcripts.NewSources(transcriptConfig),
+		chunkStrategy:  chunking.DefaultStrategy,
+		embeddingModel: embeddingModel,
+	}
+
+	if transcriptConfig != nil {
+		ts.chunkOptions = chunking.Options{
+			TargetTokens:  transcriptConfig.ChunkTargetTokens,
+			OverlapTokens: transcriptConfig.ChunkOverlapTokens,
+			MaxDuration:   transcriptConfig.ChunkMaxDuration,
+		}
+		if _, err := chunking.New(transcriptConfig.ChunkStrategy, ts.chunkOptions); err != nil {
+			log.Printf("Warning: %v, falling back to %s", err, chunking.DefaultStrategy)
+		} else if transcriptConfig.ChunkStrategy != "" {
+			ts.chunkStrategy = transcriptConfig.ChunkStrategy
+		}
+	}
+
+	return ts
+}
+
+// ChunkStrategy names the strategy used for new transcript embeddings and
+// for transcript search
+func (ts *TranscriptService) ChunkStrategy() string {
+	return ts.chunkStrategy
+}
+
+// Chunker returns the named chunking strategy with the configured options,
+// or the active strategy when name is empty
+func (ts *TranscriptService) Chunker(name string) (chunking.Strategy, error) {
+	if name == "" {
+		name = ts.chunkStrategy
+	}
+	return chunking.New(name, ts.chunkOptions)
+}
+
+// GetTranscript returns the user's uploaded or the shared stored transcript,
+// fetching from the sources in order on a miss or when refresh is set. An
+// empty language prefers a manual track.
+func (ts *TranscriptService) GetTranscript(ctx context.Context, userID uuid.UUID, videoID, language string, refresh bool) (*models.VideoTranscript, error) {
+	if !refresh {
+		transcript, err := ts.findStored(ctx, userID, videoID, language)
+		if err == nil {
+			return transcript, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to read stored transcript: %w", err)
+		}
+	}
+
+	var errs []error
+	for _, source := range ts.sources {
+		transcript, err := source.Fetch(ctx, videoID, language)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			continue
+		}
+
+		if err := ts.store(ctx, transcript); err != nil {
+			return nil, err
+		}
+		return transcript, nil
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// ImportTranscript reads a transcript from source and stores it as the
+// user's own copy for the video, replacing any earlier upload in that language
+func (ts *TranscriptService) ImportTranscript(ctx context.Context, userID uuid.UUID, videoID, language string, source transcripts.Source) (*models.VideoTranscript, error) {
+	transcript, err := source.Fetch(ctx, videoID, language)
+	if err != nil {
+		return nil, err
+	}
+	transcript.UserID = &userID
+
+	if err := ts.store(ctx, transcript); err != nil {
+		return nil, err
+	}
+	return transcript, nil
+}
+
+// DeleteUploadedTranscript removes the user's own transcript so the shared one is used again
+func (ts *TranscriptService) DeleteUploadedTranscript(ctx context.Context, userID uuid.UUID, videoID, language string) error {
+	result, err := ts.db.DB.NewDelete().
+		Model((*models.VideoTranscript)(nil)).
+		Where("video_id = ? AND language = ? AND user_id = ?", videoID, language, userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete transcript: %w", err)
+	}
+
+	return requireRowsAffected(result)
+}
+
+// ListLanguages returns the languages the sources offer for a video plus
+// any stored transcripts, marking which ones are already stored
+func (ts *TranscriptService) ListLanguages(ctx context.Context, userID uuid.UUID, videoID string) ([]TranscriptLanguage, error) {
+	var stored []models.VideoTranscript
+	err := ts.db.DB.NewSelect().
+		Model(&stored).
+		Column("language", "language_name", "is_auto", "source").
+		Where("video_id = ? AND (user_id IS NULL OR user_id = ?)", videoID, userID).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored transcripts: %w", err)
+	}
+
+	var languages []TranscriptLanguage
+	seen := make(map[string]bool)
+	for _, transcript := range stored {
+		key := transcript.Source + ":" + transcript.Language
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		languages = append(languages, TranscriptLanguage{
+			Language: transcripts.Language{
+				Language: transcript.Language,
+				Name:     transcript.LanguageName,
+				IsAuto:   transcript.IsAuto,
+			},
+			Source: transcript.Source,
+			Stored: true,
+		})
+	}
+
+	var errs []error
+	for _, source := range ts.sources {
+		lister, ok := source.(transcripts.LanguageLister)
+		if !ok {
+			continue
+		}
+
+		available, err := lister.ListLanguages(ctx, videoID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			continue
+		}
+
+		for _, language := range available {
+			key := source.Name() + ":" + language.Language
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			languages = append(languages, TranscriptLanguage{
+				Language: language,
+				Source:   source.Name(),
+			})
+		}
+	}
+
+	if len(languages) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return languages, nil
+}
+
+// ListChunks returns the user's stored chunks of a video for one strategy,
+// as embedded by the active model
+func (ts *TranscriptService) ListChunks(ctx context.Context, userID uuid.UUID, videoID, strategy string) ([]models.TranscriptEmbedding, error) {
+	var chunks []models.TranscriptEmbedding
+	err := ts.db.DB.NewSelect().
+		Model(&chunks).
+		ExcludeColumn("embedding").
+		Where("video_id = ? AND user_id = ? AND chunk_strategy = ?", videoID, userID, strategy).
+		Where("embedding_model = ?", ts.embeddingModel).
+		Order("chunk_index ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript chunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// AttachRangeContext sets the Context of each range note to its video's
+// stored chunks of the active strategy and model that overlap the note's
+// range
+func (ts *TranscriptService) AttachRangeContext(ctx context.Context, userID uuid.UUID, timestamps []models.Timestamp) error {
+	var videoIDs []string
+	seen := make(map[string]bool)
+	for _, timestamp := range timestamps {
+		if timestamp.EndTime != nil && !seen[timestamp.VideoID] {
+			seen[timestamp.VideoID] = true
+			videoIDs = append(videoIDs, timestamp.VideoID)
+		}
+	}
+	if len(videoIDs) == 0 {
+		return nil
+	}
+
+	var chunks []models.TranscriptEmbedding
+	err := ts.db.DB.NewSelect().
+		Model(&chunks).
+		ExcludeColumn("embedding").
+		Where("user_id = ? AND chunk_strategy = ? AND embedding_model = ?", userID, ts.chunkStrategy, ts.embeddingModel).
+		Where("video_id IN (?)", bun.In(videoIDs)).
+		Where("start_time IS NOT NULL AND end_time IS NOT NULL").
+		Order("video_id", "chunk_index ASC").
+		Scan(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read transcript chunks: %w", err)
+	}
+
+	for i := range timestamps {
+		timestamp := &timestamps[i]
+		if timestamp.EndTime == nil {
+			continue
+		}
+		timestamp.Context = []models.TranscriptEmbedding{}
+		for _, chunk := range chunks {
+			if chunk.VideoID == timestamp.VideoID && timestamp.Overlaps(*chunk.StartTime, *chunk.EndTime) {
+				timestamp.Context = append(timestamp.Context, chunk)
+			}
+		}
+	}
+	return nil
+}
+
+// ChunkStats summarizes the user's stored chunks of a video per strategy
+func (ts *TranscriptService) ChunkStats(ctx context.Context, userID uuid.UUID, videoID string) ([]ChunkStrategyStats, error) {
+	var stats []ChunkStrategyStats
+	err := ts.db.DB.NewSelect().
+		Model((*models.TranscriptEmbedding)(nil)).
+		ColumnExpr("chunk_strategy").
+		ColumnExpr("COUNT(DISTINCT chunk_index) AS chunks").
+		ColumnExpr("AVG(token_count) AS avg_tokens").
+		ColumnExpr("MAX(token_count) AS max_tokens").
+		ColumnExpr("MAX(updated_at) AS updated_at").
+		Where("video_id = ? AND user_id = ?", videoID, userID).
+		Group("chunk_strategy").
+		Order("chunk_strategy").
+		Scan(ctx, &stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize transcript chunks: %w", err)
+	}
+
+	for i := range stats {
+		stats[i].Active = stats[i].Strategy == ts.chunkStrategy
+	}
+	return stats, nil
+}
+
+func (ts *TranscriptService) findStored(ctx context.Context, userID uuid.UUID, videoID, language string) (*models.VideoTranscript, error) {
+	var transcript models.VideoTranscript
+	query := ts.db.DB.NewSelect().
+		Model(&transcript).
+		Where("video_id = ? AND (user_id IS NULL OR user_id = ?)", videoID, userID)
+
+	if language != "" {
+		// "en" also matches regional tracks such as "en-US"
+		query = query.
+			Where("(language = ? OR language LIKE ?)", language, language+"-%").
+			OrderExpr("language = ? DESC", language)
+	}
+
+	err := query.
+		OrderExpr("user_id IS NULL ASC, is_auto ASC, created_at ASC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transcript, nil
+}
+
+func (ts *TranscriptService) store(ctx context.Context, transcript *models.VideoTranscript) error {
+	conflict := "CONFLICT (video_id, language) WHERE user_id IS NULL DO UPDATE"
+	if transcript.UserID != nil {
+		conflict = "CONFLICT (video_id, language, user_id) WHERE user_id IS NOT NULL DO UPDATE"
+	}
+
+	_, err := ts.db.DB.NewInsert().
+		Model(transcript).
+		On(conflict).
+		Set("language_name = EXCLUDED.language_name").
+		Set("is_auto = EXCLUDED.is_auto").
+		Set("source = EXCLUDED.source").
+		Set("segments = EXCLUDED.segments").
+		Set("fetched_at = EXCLUDED.fetched_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("id, created_at").
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to store transcript: %w", err)
+	}
+
+	return nil
+}
+
+// formatTranscript renders a transcript as timestamped lines for prompts
+func formatTranscript(transcript *models.VideoTranscript) string {
+	var transcriptText strings.Builder
+	transcriptText.WriteString("TRANSCRIPT WITH TIMESTAMPS:\n\n")
+	for _, segment := range transcript.Segments {
+		transcriptText.WriteString(fmt.Sprintf("%s %s\n", formatTimestamp(segment.Start), segment.Text))
+	}
+	return strings.TrimSpace(transcriptText.String())
+}
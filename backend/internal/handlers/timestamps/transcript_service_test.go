@@ -0,0 +1,40 @@
+package timestamps
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shubhamku044/ytclipper/internal/chunking"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+func TestTranscriptChunksUseActiveModel(t *testing.T) {
+	db := testDatabase(t)
+	userID := testUser(t, db)
+	ctx := context.Background()
+
+	// The old model still has chunks of a longer, since replaced transcript
+	for i, text := range []string{"old intro", "old middle", "old ending"} {
+		seedTranscriptChunk(t, db, userID, "vid-chunks", "model-old", i, text)
+	}
+	seedTranscriptChunk(t, db, userID, "vid-chunks", "model-new", 0, "new transcript")
+
+	ts := NewTranscriptService(db, nil, "model-new")
+
+	chunks, err := ts.ListChunks(ctx, userID, "vid-chunks", chunking.DefaultStrategy)
+	if err != nil {
+		t.Fatalf("ListChunks error: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].Text != "new transcript" {
+		t.Errorf("ListChunks = %+v, want only the active model's chunk", chunks)
+	}
+
+	end := 25.0
+	notes := []models.Timestamp{{VideoID: "vid-chunks", Timestamp: 0, EndTime: &end}}
+	if err := ts.AttachRangeContext(ctx, userID, notes); err != nil {
+		t.Fatalf("AttachRangeContext error: %v", err)
+	}
+	if got := notes[0].Context; len(got) != 1 || got[0].Text != "new transcript" {
+		t.Errorf("range context = %+v, want only the active model's chunk", got)
+	}
+}
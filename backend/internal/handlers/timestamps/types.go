@@ -1,64 +1,198 @@
 package timestamps
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
 
 type TagSearchRequest struct {
 	Query string `json:"query" binding:"required"`
 	Limit int    `json:"limit,omitempty"`
 }
 
+// UpdateTagRequest changes a tag. A nil field is left as it is.
+type UpdateTagRequest struct {
+	// Name renames the tag and the tags nested under it
+	Name *string `json:"name"`
+	// Color is a hex color such as #1e90ff, or empty to clear it
+	Color       *string `json:"color"`
+	Description *string `json:"description" binding:"omitempty,max=1000"`
+}
+
+// MergeTagsRequest merges tags into another tag
+type MergeTagsRequest struct {
+	SourceIDs []uuid.UUID `json:"source_ids" binding:"required,min=1,max=50"`
+	TargetID  uuid.UUID   `json:"target_id" binding:"required"`
+}
+
 type CreateTimestampRequest struct {
 	VideoID   string   `json:"video_id" binding:"required"`
 	Timestamp float64  `json:"timestamp" binding:"required"`
-	Title     string   `json:"title"`
-	Note      string   `json:"note"`
-	Tags      []string `json:"tags"`
+	EndTime   *float64 `json:"end_time,omitempty"` // Makes the note cover a segment
+	Type      string   `json:"type,omitempty" binding:"omitempty,oneof=note highlight question action"`
+	// Importance defaults to 3
+	Importance int      `json:"importance,omitempty" binding:"omitempty,min=1,max=5"`
+	Title      string   `json:"title"`
+	Note       string   `json:"note"`
+	Tags       []string `json:"tags"`
+}
+
+// UpdateTimestampRequest replaces the title, note and tags. EndTime, Type
+// and Importance are only changed when set, and ClearEndTime turns a range
+// note back into a note at an instant.
+type UpdateTimestampRequest struct {
+	Title        string   `json:"title"`
+	Note         string   `json:"note"`
+	Tags         []string `json:"tags"`
+	EndTime      *float64 `json:"end_time,omitempty"`
+	ClearEndTime bool     `json:"clear_end_time,omitempty"`
+	Type         *string  `json:"type,omitempty" binding:"omitempty,oneof=note highlight question action"`
+	Importance   *int     `json:"importance,omitempty" binding:"omitempty,min=1,max=5"`
+}
+
+// BulkTimestampsRequest applies Operations, in order, to the notes listed
+// in IDs or to those matching Filter, in one transaction. DryRun previews
+// the results without changing anything.
+type BulkTimestampsRequest struct {
+	IDs        []string        `json:"ids,omitempty" binding:"omitempty,max=1000"`
+	Filter     *BulkFilter     `json:"filter,omitempty"`
+	Operations []BulkOperation `json:"operations" binding:"required,min=1,max=10,dive"`
+	DryRun     bool            `json:"dry_run,omitempty"`
+}
+
+// BulkFilter selects notes like a search does, without ranking. Query
+// matches text in the title or note.
+type BulkFilter struct {
+	Query string `json:"query,omitempty"`
+	NoteFilter
+}
+
+// BulkOperation is one change made to every selected note. add_tags and
+// remove_tags use Tags, move uses VideoID, shift moves notes by Seconds and
+// set_type uses Type.
+type BulkOperation struct {
+	Op      string   `json:"op" binding:"required,oneof=add_tags remove_tags move shift set_type delete"`
+	Tags    []string `json:"tags,omitempty" binding:"omitempty,max=50"`
+	VideoID string   `json:"video_id,omitempty"`
+	Seconds float64  `json:"seconds,omitempty"`
+	Type    string   `json:"type,omitempty" binding:"omitempty,oneof=note highlight question action"`
+}
+
+type SuggestTagsRequest struct {
+	VideoID string   `json:"video_id,omitempty"`
+	Title   string   `json:"title"`
+	Note    string   `json:"note"`
+	Tags    []string `json:"tags,omitempty"` // Tags already on the draft
+	Limit   int      `json:"limit,omitempty"`
+}
+
+type SuggestTimestampsRequest struct {
+	Language string `json:"language,omitempty"`
+}
+
+type AcceptSuggestionsRequest struct {
+	Suggestions []TimestampSuggestion `json:"suggestions" binding:"required,min=1,max=100,dive"`
 }
 
 type FullVideoSummaryRequest struct {
+	VideoID  string `json:"video_id" binding:"required"`
+	Refresh  bool   `json:"refresh,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+type QuestionRequest struct {
+	Question string `json:"question" binding:"required"`
+	VideoID  string `json:"video_id,omitempty"`
+	Language string `json:"language,omitempty"`
+	Context  int    `json:"context,omitempty"`
+	// Library answers from transcripts and notes across all of the user's
+	// videos, optionally only VideoIDs and videos with notes tagged with
+	// one of Tags
+	Library  bool     `json:"library,omitempty"`
+	VideoIDs []string `json:"video_ids,omitempty" binding:"omitempty,max=200"`
+	Tags     []string `json:"tags,omitempty" binding:"omitempty,max=20"`
+	// PlaylistIDs is rejected until playlists are stored; the Playlist model
+	// has no table yet
+	PlaylistIDs []string `json:"playlist_ids,omitempty"`
+}
+
+type CreateChatSessionRequest struct {
 	VideoID string `json:"video_id" binding:"required"`
-	Refresh bool   `json:"refresh,omitempty"`
+	Title   string `json:"title,omitempty"`
+}
+
+type RenameChatSessionRequest struct {
+	Title string `json:"title" binding:"required"`
+}
+
+// InsightFeedbackRequest updates only the fields that are set
+type InsightFeedbackRequest struct {
+	IsUseful *bool   `json:"is_useful,omitempty"`
+	Rating   *int    `json:"rating,omitempty" binding:"omitempty,min=1,max=5"`
+	Feedback *string `json:"feedback,omitempty" binding:"omitempty,max=2000"`
+}
+
+type FlagInsightRequest struct {
+	Reason string `json:"reason,omitempty" binding:"max=500"`
+}
+
+type ChatMessageRequest struct {
+	Message string `json:"message" binding:"required"`
+	Context int    `json:"context,omitempty"`
+}
+
+// NoteFilter narrows the notes a search or bulk request selects. Tags,
+// NoteTypes and MinImportance only apply to notes.
+type NoteFilter struct {
+	VideoID       string     `json:"video_id,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	NoteTypes     []string   `json:"note_types,omitempty" binding:"omitempty,dive,oneof=note highlight question action"`
+	MinImportance int        `json:"min_importance,omitempty" binding:"omitempty,min=1,max=5"`
+	From          *time.Time `json:"from,omitempty"`
+	To            *time.Time `json:"to,omitempty"`
 }
 
 type SearchRequest struct {
-	Query   string `json:"query" binding:"required"`
-	VideoID string `json:"video_id,omitempty"`
-	Limit   int    `json:"limit,omitempty"`
+	Query string `json:"query" binding:"required"`
+	NoteFilter
+	Limit int `json:"limit,omitempty"`
 }
 
-type DeleteMultipleRequest struct {
-	IDs []string `json:"ids" binding:"required"`
+type UnifiedSearchRequest struct {
+	Query string   `json:"query" binding:"required"`
+	Types []string `json:"types,omitempty"`
+	NoteFilter
+	Limit int `json:"limit,omitempty"`
+}
+
+type RefreshTranscriptRequest struct {
+	Language string `json:"language,omitempty"`
 }
 
-type EmbeddingRequest struct {
-	Input          []string `json:"input"`
-	Model          string   `json:"model"`
-	EncodingFormat string   `json:"encoding_format,omitempty"`
+type RechunkTranscriptRequest struct {
+	Strategy string `json:"strategy,omitempty"`
+	Language string `json:"language,omitempty"`
 }
 
-type EmbeddingResponse struct {
-	Data []struct {
-		Embedding []float32 `json:"embedding"`
-		Index     int       `json:"index"`
-	} `json:"data"`
+type ReembedRequest struct {
+	Model    string `json:"model" binding:"required"`
+	AllUsers bool   `json:"all_users,omitempty"`
 }
 
-type ChatRequest struct {
-	Model    string `json:"model"`
-	Messages []struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	} `json:"messages"`
-	MaxTokens   int     `json:"max_tokens,omitempty"`
-	Temperature float64 `json:"temperature,omitempty"`
+// ChunkStrategyStats summarizes the stored chunks of one strategy for a video
+type ChunkStrategyStats struct {
+	Strategy  string    `json:"strategy" bun:"chunk_strategy"`
+	Chunks    int       `json:"chunks" bun:"chunks"`
+	AvgTokens float64   `json:"avg_tokens" bun:"avg_tokens"`
+	MaxTokens int       `json:"max_tokens" bun:"max_tokens"`
+	UpdatedAt time.Time `json:"updated_at" bun:"updated_at"`
+	Active    bool      `json:"active" bun:"-"`
 }
 
-type ChatResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+type DeleteMultipleRequest struct {
+	IDs []string `json:"ids" binding:"required"`
 }
 
 type ScoredTimestamp struct {
@@ -66,6 +200,11 @@ type ScoredTimestamp struct {
 	Score     float32     `json:"score"`
 }
 
+type ScoredTranscriptEmbedding struct {
+	Embedding models.TranscriptEmbedding `json:"embedding"`
+	Score     float64                    `json:"score"`
+}
+
 type VideoInfo struct {
 	VideoID string    `json:"video_id"`
 	Latest  time.Time `json:"latest_timestamp"`
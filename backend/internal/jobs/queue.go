@@ -0,0 +1,293 @@
+// Package jobs is a durable background job queue stored in Postgres.
+// Workers claim due jobs with FOR UPDATE SKIP LOCKED, retry failures with
+// exponential backoff and dead-letter jobs that run out of attempts.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/config"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// enqueueAttempts bounds how often Enqueue retries a deduplicated insert
+// whose conflicting job left the queue meanwhile
+const enqueueAttempts = 3
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobNotRetryable is returned when retrying a job that is not dead
+	ErrJobNotRetryable = errors.New("only dead jobs can be retried")
+	// ErrDuplicateJob is returned when a job with the same dedupe key is already queued
+	ErrDuplicateJob = errors.New("an identical job is already queued")
+)
+
+// Handler performs one job. The returned value is stored as the job result.
+// Returning an error schedules a retry unless it is wrapped with Permanent.
+type Handler func(ctx context.Context, job *models.Job, progress ProgressFunc) (any, error)
+
+// ProgressFunc records how many of total items a job has processed
+type ProgressFunc func(done, total int)
+
+// EnqueueOptions customizes a single job
+type EnqueueOptions struct {
+	UserID *uuid.UUID
+	// DedupeKey skips the enqueue when a job with the same key is still queued
+	DedupeKey   string
+	MaxAttempts int
+	RunAt       time.Time
+}
+
+// ListFilter narrows the jobs returned by List
+type ListFilter struct {
+	Type   string
+	Status models.JobStatus
+	Limit  int
+}
+
+type Queue struct {
+	db       *database.Database
+	cfg      config.JobsConfig
+	workerID string
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewQueue(db *database.Database, cfg *config.JobsConfig) *Queue {
+	return &Queue{
+		db:       db,
+		cfg:      *cfg,
+		workerID: workerID(),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register sets the handler for a job type. Handlers must be registered
+// before Start.
+func (q *Queue) Register(jobType string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = handler
+}
+
+// Enqueue stores a job for the workers. When opts.DedupeKey matches a job
+// that is still queued, that job is returned instead of a new one.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload any, opts EnqueueOptions) (*models.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s job payload: %w", jobType, err)
+	}
+
+	job := &models.Job{
+		UserID:      opts.UserID,
+		Type:        jobType,
+		Status:      models.JobStatusQueued,
+		Payload:     data,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.cfg.MaxAttempts
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if opts.DedupeKey != "" {
+		job.DedupeKey = &opts.DedupeKey
+	}
+
+	// The queued job a dedupe key conflicts with can be claimed before it is
+	// read back, in which case the insert is tried again
+	for attempt := 1; ; attempt++ {
+		result, err := q.db.DB.NewInsert().
+			Model(job).
+			On("CONFLICT (dedupe_key) WHERE status = 'queued' DO NOTHING").
+			Returning("*").
+			Exec(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enqueue %s job: %w", jobType, err)
+		}
+
+		if rows, err := result.RowsAffected(); err != nil || rows > 0 {
+			break
+		}
+
+		existing := new(models.Job)
+		err = q.db.DB.NewSelect().
+			Model(existing).
+			Where("dedupe_key = ? AND status = ?", opts.DedupeKey, models.JobStatusQueued).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) && attempt < enqueueAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queued %s job: %w", jobType, err)
+		}
+		return existing, nil
+	}
+
+	return job, nil
+}
+
+// Get returns one of the user's jobs
+func (q *Queue) Get(ctx context.Context, userID, jobID uuid.UUID) (*models.Job, error) {
+	job := new(models.Job)
+	err := q.db.DB.NewSelect().
+		Model(job).
+		Where("id = ? AND user_id = ?", jobID, userID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job: %w", err)
+	}
+
+	return job, nil
+}
+
+// List returns the user's most recent jobs
+func (q *Queue) List(ctx context.Context, userID uuid.UUID, filter ListFilter) ([]models.Job, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	var jobs []models.Job
+	query := q.db.DB.NewSelect().
+		Model(&jobs).
+		Where("user_id = ?", userID)
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	err := query.
+		Order("created_at DESC").
+		Limit(filter.Limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// Latest returns the user's most recent job of a type, or nil if there is none
+func (q *Queue) Latest(ctx context.Context, userID uuid.UUID, jobType string) (*models.Job, error) {
+	jobs, err := q.List(ctx, userID, ListFilter{Type: jobType, Limit: 1})
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// Pending reports whether a job with the dedupe key is queued or running
+func (q *Queue) Pending(ctx context.Context, dedupeKey string) (bool, error) {
+	exists, err := q.db.DB.NewSelect().
+		Model((*models.Job)(nil)).
+		Where("dedupe_key = ? AND status IN (?)", dedupeKey, bun.In([]models.JobStatus{models.JobStatusQueued, models.JobStatusRunning})).
+		Exists(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to check pending jobs: %w", err)
+	}
+	return exists, nil
+}
+
+// PendingCount counts the user's queued and running jobs of the given types
+func (q *Queue) PendingCount(ctx context.Context, userID uuid.UUID, types ...string) (int, error) {
+	count, err := q.db.DB.NewSelect().
+		Model((*models.Job)(nil)).
+		Where("user_id = ? AND type IN (?)", userID, bun.In(types)).
+		Where("status IN (?)", bun.In([]models.JobStatus{models.JobStatusQueued, models.JobStatusRunning})).
+		Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pending jobs: %w", err)
+	}
+	return count, nil
+}
+
+// Retry requeues a dead job with a fresh set of attempts
+func (q *Queue) Retry(ctx context.Context, userID, jobID uuid.UUID) (*models.Job, error) {
+	job, err := q.Get(ctx, userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.JobStatusDead {
+		return nil, ErrJobNotRetryable
+	}
+
+	_, err = q.db.DB.NewUpdate().
+		Model(job).
+		Set("status = ?", models.JobStatusQueued).
+		Set("attempts = 0").
+		Set("run_at = ?", time.Now()).
+		Set("finished_at = NULL").
+		Set("updated_at = ?", time.Now()).
+		Where("id = ? AND status = ?", job.ID, models.JobStatusDead).
+		Returning("*").
+		Exec(ctx)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateJob
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry job: %w", err)
+	}
+
+	return job, nil
+}
+
+// Decode unmarshals a job payload. A payload that does not decode can never
+// succeed, so the error is permanent.
+func Decode(job *models.Job, v any) error {
+	if err := json.Unmarshal(job.Payload, v); err != nil {
+		return Permanent(fmt.Errorf("invalid %s job payload: %w", job.Type, err))
+	}
+	return nil
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a handler error as not worth retrying; the job is
+// dead-lettered immediately
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr pgdriver.Error
+	return errors.As(err, &pgErr) && pgErr.Field('C') == "23505"
+}
@@ -0,0 +1,313 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/shubhamku044/ytclipper/internal/models"
+	"github.com/uptrace/bun"
+)
+
+const maxRetryBackoff = time.Hour
+
+// Start launches the configured number of workers for the registered job
+// types. Workers stop when ctx is cancelled or Stop is called.
+func (q *Queue) Start(ctx context.Context) {
+	q.mu.RLock()
+	types := make([]string, 0, len(q.handlers))
+	for jobType := range q.handlers {
+		types = append(types, jobType)
+	}
+	q.mu.RUnlock()
+
+	if len(types) == 0 || q.cfg.Workers <= 0 {
+		return
+	}
+
+	ctx, q.cancel = context.WithCancel(ctx)
+	for i := 0; i < q.cfg.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx, fmt.Sprintf("%s-%d", q.workerID, i), types)
+	}
+	log.Printf("Started %d job workers for %v", q.cfg.Workers, types)
+}
+
+// Stop cancels running jobs and waits for the workers to exit. Cancelled
+// jobs are retried by the next worker to claim them.
+func (q *Queue) Stop() {
+	if q.cancel == nil {
+		return
+	}
+	q.cancel()
+	q.wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context, worker string, types []string) {
+	defer q.wg.Done()
+
+	for {
+		job, err := q.claim(ctx, worker, types)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Job worker %s failed to claim a job: %v", worker, err)
+		}
+
+		if job != nil {
+			q.run(ctx, worker, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.cfg.PollInterval):
+		}
+	}
+}
+
+// claim locks the oldest due job, or a running job whose worker stopped
+// renewing its lock, and marks it running for this worker. Stale jobs that
+// are out of attempts are dead-lettered instead, so a job that brings its
+// worker down every time is not reclaimed forever.
+func (q *Queue) claim(ctx context.Context, worker string, types []string) (*models.Job, error) {
+	now := time.Now()
+
+	if err := q.deadLetterStale(ctx, types, now); err != nil {
+		return nil, err
+	}
+
+	var jobs []models.Job
+	err := q.db.DB.NewRaw(`
+		UPDATE jobs SET
+			status = ?,
+			attempts = attempts + 1,
+			locked_at = ?,
+			locked_by = ?,
+			started_at = COALESCE(started_at, ?),
+			updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE type IN (?)
+			AND ((status = ? AND run_at <= ?) OR (status = ? AND locked_at < ? AND attempts < max_attempts))
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.JobStatusRunning, now, worker, now, now,
+		bun.In(types),
+		models.JobStatusQueued, now, models.JobStatusRunning, now.Add(-q.cfg.LockTimeout),
+	).Scan(ctx, &jobs)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+
+	return &jobs[0], nil
+}
+
+// deadLetterStale dead-letters running jobs whose lock expired on their
+// last attempt
+func (q *Queue) deadLetterStale(ctx context.Context, types []string, now time.Time) error {
+	var dead []models.Job
+	err := q.db.DB.NewRaw(`
+		UPDATE jobs SET
+			status = ?,
+			last_error = 'worker stopped during the last attempt',
+			locked_at = NULL,
+			locked_by = NULL,
+			finished_at = ?,
+			updated_at = ?
+		WHERE type IN (?) AND status = ? AND locked_at < ? AND attempts >= max_attempts
+		RETURNING id, type, attempts`,
+		models.JobStatusDead, now, now,
+		bun.In(types), models.JobStatusRunning, now.Add(-q.cfg.LockTimeout),
+	).Scan(ctx, &dead)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range dead {
+		log.Printf("Job %s (%s) dead after %d attempts: worker stopped during the last attempt", job.ID, job.Type, job.Attempts)
+	}
+	return nil
+}
+
+func (q *Queue) run(ctx context.Context, worker string, job *models.Job) {
+	q.mu.RLock()
+	handler := q.handlers[job.Type]
+	q.mu.RUnlock()
+
+	jobCtx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	defer cancel()
+
+	progress := func(done, total int) {
+		_, err := q.db.DB.NewUpdate().
+			Model((*models.Job)(nil)).
+			Set("progress = ?", done).
+			Set("total = ?", total).
+			Set("locked_at = ?", time.Now()).
+			Set("updated_at = ?", time.Now()).
+			Where("id = ? AND locked_by = ?", job.ID, worker).
+			Exec(context.Background())
+		if err != nil {
+			log.Printf("Failed to record progress for job %s: %v", job.ID, err)
+		}
+	}
+
+	result, err := callHandler(jobCtx, handler, job, progress)
+	if err == nil {
+		q.succeed(worker, job, result)
+		return
+	}
+
+	if ctx.Err() != nil {
+		// Shutting down: release the job so it is picked up again without
+		// spending an attempt
+		q.release(worker, job)
+		return
+	}
+
+	q.fail(worker, job, err)
+}
+
+func callHandler(ctx context.Context, handler Handler, job *models.Job, progress ProgressFunc) (result any, err error) {
+	if handler == nil {
+		return nil, Permanent(fmt.Errorf("no handler registered for job type %q", job.Type))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s (%s) panicked: %v\n%s", job.ID, job.Type, r, debug.Stack())
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job, progress)
+}
+
+func (q *Queue) succeed(worker string, job *models.Job, result any) {
+	var encoded []byte
+	if result != nil {
+		var err error
+		if encoded, err = json.Marshal(result); err != nil {
+			log.Printf("Failed to encode result of job %s: %v", job.ID, err)
+		}
+	}
+
+	query := q.db.DB.NewUpdate().
+		Model((*models.Job)(nil))
+	if encoded != nil {
+		query = query.Set("result = ?::jsonb", string(encoded))
+	} else {
+		query = query.Set("result = NULL")
+	}
+
+	now := time.Now()
+	_, err := query.
+		Set("status = ?", models.JobStatusSucceeded).
+		Set("last_error = NULL").
+		Set("locked_at = NULL").
+		Set("locked_by = NULL").
+		Set("finished_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("id = ? AND locked_by = ?", job.ID, worker).
+		Exec(context.Background())
+	if err != nil {
+		log.Printf("Failed to mark job %s succeeded: %v", job.ID, err)
+	}
+}
+
+// fail schedules a retry with exponential backoff, or dead-letters the job
+// once it is out of attempts or the error is permanent
+func (q *Queue) fail(worker string, job *models.Job, jobErr error) {
+	now := time.Now()
+
+	if isPermanent(jobErr) || job.Attempts >= job.MaxAttempts {
+		log.Printf("Job %s (%s) dead after %d attempts: %v", job.ID, job.Type, job.Attempts, jobErr)
+		q.deadLetter(worker, job, jobErr.Error())
+		return
+	}
+
+	runAt := now.Add(retryBackoff(q.cfg.RetryBackoff, job.Attempts))
+	log.Printf("Job %s (%s) attempt %d failed, retrying at %s: %v", job.ID, job.Type, job.Attempts, runAt.Format(time.RFC3339), jobErr)
+
+	_, err := q.db.DB.NewUpdate().
+		Model((*models.Job)(nil)).
+		Set("status = ?", models.JobStatusQueued).
+		Set("last_error = ?", jobErr.Error()).
+		Set("run_at = ?", runAt).
+		Set("locked_at = NULL").
+		Set("locked_by = NULL").
+		Set("updated_at = ?", now).
+		Where("id = ? AND locked_by = ?", job.ID, worker).
+		Exec(context.Background())
+	if isUniqueViolation(err) {
+		// A newer job with the same dedupe key is already queued and will
+		// redo this work
+		q.deadLetter(worker, job, fmt.Sprintf("%s (superseded by a queued job)", jobErr))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to reschedule job %s: %v", job.ID, err)
+	}
+}
+
+func (q *Queue) deadLetter(worker string, job *models.Job, reason string) {
+	now := time.Now()
+	_, err := q.db.DB.NewUpdate().
+		Model((*models.Job)(nil)).
+		Set("status = ?", models.JobStatusDead).
+		Set("last_error = ?", reason).
+		Set("locked_at = NULL").
+		Set("locked_by = NULL").
+		Set("finished_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("id = ? AND locked_by = ?", job.ID, worker).
+		Exec(context.Background())
+	if err != nil {
+		log.Printf("Failed to dead-letter job %s: %v", job.ID, err)
+	}
+}
+
+func (q *Queue) release(worker string, job *models.Job) {
+	_, err := q.db.DB.NewUpdate().
+		Model((*models.Job)(nil)).
+		Set("status = ?", models.JobStatusQueued).
+		Set("attempts = GREATEST(attempts - 1, 0)").
+		Set("locked_at = NULL").
+		Set("locked_by = NULL").
+		Set("updated_at = ?", time.Now()).
+		Where("id = ? AND locked_by = ?", job.ID, worker).
+		Exec(context.Background())
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("Failed to release job %s: %v", job.ID, err)
+	}
+}
+
+// retryBackoff doubles base for every failed attempt, capped at an hour,
+// with up to 20% jitter so retries of a failed batch spread out
+func retryBackoff(base time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, maxRetryBackoff)
+	return backoff + time.Duration(rand.Int63n(int64(backoff)/5+1))
+}
+
+func workerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/config"
+	"github.com/shubhamku044/ytclipper/internal/database/dbtest"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+const testWorker = "test-worker"
+
+// testQueue returns a queue on the test database and a job type of its own,
+// so tests don't claim each other's jobs
+func testQueue(t *testing.T) (*Queue, string) {
+	t.Helper()
+
+	db := dbtest.Open(t)
+	jobType := "test-" + uuid.NewString()
+	t.Cleanup(func() {
+		db.DB.ExecContext(context.Background(), "DELETE FROM jobs WHERE type = ?", jobType)
+	})
+
+	queue := NewQueue(db, &config.JobsConfig{
+		LockTimeout:  time.Minute,
+		MaxAttempts:  3,
+		RetryBackoff: time.Minute,
+	})
+	return queue, jobType
+}
+
+func enqueueTestJob(t *testing.T, queue *Queue, jobType string, maxAttempts int) *models.Job {
+	t.Helper()
+
+	job, err := queue.Enqueue(context.Background(), jobType, struct{}{}, EnqueueOptions{MaxAttempts: maxAttempts})
+	if err != nil {
+		t.Fatalf("Enqueue error: %v", err)
+	}
+	return job
+}
+
+func claimTestJob(t *testing.T, queue *Queue, jobType string) *models.Job {
+	t.Helper()
+
+	job, err := queue.claim(context.Background(), testWorker, []string{jobType})
+	if err != nil {
+		t.Fatalf("claim error: %v", err)
+	}
+	return job
+}
+
+func getTestJob(t *testing.T, queue *Queue, id uuid.UUID) *models.Job {
+	t.Helper()
+
+	job := new(models.Job)
+	if err := queue.db.DB.NewSelect().Model(job).Where("id = ?", id).Scan(context.Background()); err != nil {
+		t.Fatalf("failed to fetch job: %v", err)
+	}
+	return job
+}
+
+// expireLock makes the job's lock look abandoned by its worker
+func expireLock(t *testing.T, queue *Queue, id uuid.UUID) {
+	t.Helper()
+
+	_, err := queue.db.DB.NewUpdate().
+		Model((*models.Job)(nil)).
+		Set("locked_at = ?", time.Now().Add(-2*queue.cfg.LockTimeout)).
+		Where("id = ?", id).
+		Exec(context.Background())
+	if err != nil {
+		t.Fatalf("failed to expire job lock: %v", err)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	queue, jobType := testQueue(t)
+	enqueued := enqueueTestJob(t, queue, jobType, 0)
+
+	job := claimTestJob(t, queue, jobType)
+	if job == nil || job.ID != enqueued.ID {
+		t.Fatalf("claimed %+v, want job %s", job, enqueued.ID)
+	}
+	if job.Status != models.JobStatusRunning || job.Attempts != 1 || job.LockedBy != testWorker {
+		t.Errorf("claimed job has status %s, attempts %d, locked by %q", job.Status, job.Attempts, job.LockedBy)
+	}
+
+	if again := claimTestJob(t, queue, jobType); again != nil {
+		t.Errorf("claimed running job %s again", again.ID)
+	}
+}
+
+func TestFailRetries(t *testing.T) {
+	queue, jobType := testQueue(t)
+	enqueueTestJob(t, queue, jobType, 0)
+
+	job := claimTestJob(t, queue, jobType)
+	queue.fail(testWorker, job, errors.New("boom"))
+
+	retried := getTestJob(t, queue, job.ID)
+	if retried.Status != models.JobStatusQueued || retried.LastError != "boom" {
+		t.Errorf("failed job has status %s and error %q, want queued with boom", retried.Status, retried.LastError)
+	}
+	if !retried.RunAt.After(time.Now()) {
+		t.Errorf("retry runs at %s, want after a backoff", retried.RunAt)
+	}
+	if again := claimTestJob(t, queue, jobType); again != nil {
+		t.Errorf("claimed job %s before its backoff ended", again.ID)
+	}
+}
+
+func TestFailDeadLetters(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxAttempts int
+		err         error
+	}{
+		{"out of attempts", 1, errors.New("boom")},
+		{"permanent error", 3, Permanent(errors.New("boom"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue, jobType := testQueue(t)
+			enqueueTestJob(t, queue, jobType, tt.maxAttempts)
+
+			job := claimTestJob(t, queue, jobType)
+			queue.fail(testWorker, job, tt.err)
+
+			if dead := getTestJob(t, queue, job.ID); dead.Status != models.JobStatusDead {
+				t.Errorf("failed job has status %s, want dead", dead.Status)
+			}
+		})
+	}
+}
+
+func TestClaimStaleJob(t *testing.T) {
+	queue, jobType := testQueue(t)
+	enqueueTestJob(t, queue, jobType, 2)
+
+	job := claimTestJob(t, queue, jobType)
+	expireLock(t, queue, job.ID)
+
+	reclaimed := claimTestJob(t, queue, jobType)
+	if reclaimed == nil || reclaimed.ID != job.ID || reclaimed.Attempts != 2 {
+		t.Fatalf("reclaimed %+v, want job %s on attempt 2", reclaimed, job.ID)
+	}
+
+	// The worker stopped again on the last attempt
+	expireLock(t, queue, job.ID)
+	if again := claimTestJob(t, queue, jobType); again != nil {
+		t.Fatalf("reclaimed job %s after its last attempt", again.ID)
+	}
+	dead := getTestJob(t, queue, job.ID)
+	if dead.Status != models.JobStatusDead || dead.Attempts != 2 {
+		t.Errorf("stale job has status %s after %d attempts, want dead after 2", dead.Status, dead.Attempts)
+	}
+}
@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultBatchInputs    = 256
+	defaultBatchTokens    = 100000
+	defaultMaxInputTokens = 8191 // text-embedding-3 context length
+	maxRetryDelay         = time.Minute
+)
+
+var (
+	ErrEmptyInput    = errors.New("embedding input is empty")
+	ErrInputTooLarge = errors.New("embedding input exceeds the model's token limit")
+)
+
+// EmbedResult is the outcome for one input of a batch
+type EmbedResult struct {
+	Embedding []float32
+	Err       error
+}
+
+// BatchOptions bounds each embedding request. Zero values use the defaults.
+type BatchOptions struct {
+	MaxInputs      int // Inputs per request
+	MaxTokens      int // Estimated tokens per request
+	MaxInputTokens int // Inputs above this fail without being sent
+	MaxRetries     int // Retries of a request after 429 or 5xx responses
+}
+
+// BatchEmbedder packs inputs into as few embedding requests as the limits
+// allow. Requests are paced by a shared RateLimiter; 429 responses pause
+// the limiter for the Retry-After period and the request is retried.
+type BatchEmbedder struct {
+	provider Provider
+	limiter  *RateLimiter
+	opts     BatchOptions
+}
+
+func NewBatchEmbedder(provider Provider, limiter *RateLimiter, opts BatchOptions) *BatchEmbedder {
+	if limiter == nil {
+		limiter = NewRateLimiter(0, 0)
+	}
+	if opts.MaxInputs <= 0 {
+		opts.MaxInputs = defaultBatchInputs
+	}
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = defaultBatchTokens
+	}
+	if opts.MaxInputTokens <= 0 {
+		opts.MaxInputTokens = defaultMaxInputTokens
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	return &BatchEmbedder{
+		provider: provider,
+		limiter:  limiter,
+		opts:     opts,
+	}
+}
+
+// Embed returns one result per input, in input order. An input that fails
+// does not fail the rest: when a request is rejected, the batch is split to
+// find the inputs responsible. progress, if set, receives the number of
+// finished inputs after each request. The returned usage sums every request
+// the provider answered, including those of bisected batches.
+func (b *BatchEmbedder) Embed(ctx context.Context, inputs []string, progress func(done int)) ([]EmbedResult, Usage) {
+	results := make([]EmbedResult, len(inputs))
+	var usage Usage
+
+	var batch []int
+	batchTokens := 0
+	done := 0
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		b.embedBatch(ctx, inputs, batch, results, &usage)
+		done += len(batch)
+		if progress != nil {
+			progress(done)
+		}
+		batch = nil
+		batchTokens = 0
+	}
+
+	for i, input := range inputs {
+		tokens := EstimateTokens(input)
+		switch {
+		case tokens == 0:
+			results[i].Err = ErrEmptyInput
+			done++
+			continue
+		case tokens > b.opts.MaxInputTokens:
+			results[i].Err = fmt.Errorf("%w: about %d tokens", ErrInputTooLarge, tokens)
+			done++
+			continue
+		}
+
+		if len(batch) == b.opts.MaxInputs || (len(batch) > 0 && batchTokens+tokens > b.opts.MaxTokens) {
+			flush()
+		}
+		batch = append(batch, i)
+		batchTokens += tokens
+	}
+	flush()
+
+	return results, usage
+}
+
+// embedBatch embeds inputs[indices] in one request, bisecting the batch when
+// the provider rejects it so only the offending inputs fail
+func (b *BatchEmbedder) embedBatch(ctx context.Context, inputs []string, indices []int, results []EmbedResult, usage *Usage) {
+	texts := make([]string, len(indices))
+	tokens := 0
+	for i, index := range indices {
+		texts[i] = inputs[index]
+		tokens += EstimateTokens(inputs[index])
+	}
+
+	embeddings, used, err := b.send(ctx, texts, tokens)
+	*usage = usage.Add(used)
+	if err == nil {
+		for i, index := range indices {
+			results[index].Embedding = embeddings[i]
+		}
+		return
+	}
+
+	if len(indices) > 1 && isInputError(err) {
+		mid := len(indices) / 2
+		b.embedBatch(ctx, inputs, indices[:mid], results, usage)
+		b.embedBatch(ctx, inputs, indices[mid:], results, usage)
+		return
+	}
+
+	for _, index := range indices {
+		results[index].Err = err
+	}
+}
+
+func (b *BatchEmbedder) send(ctx context.Context, texts []string, tokens int) ([][]float32, Usage, error) {
+	for attempt := 0; ; attempt++ {
+		if err := b.limiter.Wait(ctx, tokens); err != nil {
+			return nil, Usage{}, err
+		}
+
+		embeddings, usage, err := b.provider.Embed(ctx, texts)
+		if err == nil {
+			return embeddings, usage, nil
+		}
+		if ctx.Err() != nil || attempt >= b.opts.MaxRetries {
+			return nil, Usage{}, err
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			// Network failures are retried with backoff
+			if err := sleep(ctx, retryDelay(attempt)); err != nil {
+				return nil, Usage{}, err
+			}
+			continue
+		}
+
+		switch {
+		case apiErr.StatusCode == http.StatusTooManyRequests:
+			delay := apiErr.RetryAfter
+			if delay <= 0 {
+				delay = retryDelay(attempt)
+			}
+			b.limiter.Pause(delay)
+		case apiErr.StatusCode >= http.StatusInternalServerError:
+			if err := sleep(ctx, retryDelay(attempt)); err != nil {
+				return nil, Usage{}, err
+			}
+		default:
+			return nil, Usage{}, err
+		}
+	}
+}
+
+// isInputError reports whether the provider rejected the request contents,
+// as opposed to auth, rate limit or server failures that affect every input
+func isInputError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// retryDelay doubles from one second per attempt up to maxRetryDelay
+func retryDelay(attempt int) time.Duration {
+	return min(time.Second<<attempt, maxRetryDelay)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
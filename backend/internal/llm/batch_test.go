@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubEmbedder embeds each input as its length. Requests containing "bad"
+// are rejected as a bad request. While failures is positive, requests fail
+// with failWith instead.
+type stubEmbedder struct {
+	Provider
+	requests [][]string
+	failures int
+	failWith error
+}
+
+func (s *stubEmbedder) Embed(ctx context.Context, inputs []string) ([][]float32, Usage, error) {
+	s.requests = append(s.requests, inputs)
+	if s.failures > 0 {
+		s.failures--
+		return nil, Usage{}, s.failWith
+	}
+	if slices.ContainsFunc(inputs, func(input string) bool { return strings.Contains(input, "bad") }) {
+		return nil, Usage{}, &APIError{Provider: "stub", StatusCode: http.StatusBadRequest}
+	}
+
+	embeddings := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		embeddings[i] = []float32{float32(len(input))}
+	}
+	return embeddings, Usage{PromptTokens: len(inputs), TotalTokens: len(inputs)}, nil
+}
+
+func (s *stubEmbedder) requestSizes() []int {
+	sizes := make([]int, len(s.requests))
+	for i, request := range s.requests {
+		sizes[i] = len(request)
+	}
+	return sizes
+}
+
+func TestBatchEmbedderPacksInputs(t *testing.T) {
+	stub := &stubEmbedder{}
+	b := NewBatchEmbedder(stub, nil, BatchOptions{MaxInputs: 2})
+
+	var progress []int
+	results, usage := b.Embed(context.Background(), []string{"a", "bb", "ccc", "dddd", "eeeee"}, func(done int) {
+		progress = append(progress, done)
+	})
+
+	if got, want := stub.requestSizes(), []int{2, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("request sizes = %v, want %v", got, want)
+	}
+	if want := []int{2, 4, 5}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+	for i, result := range results {
+		if result.Err != nil || len(result.Embedding) != 1 || result.Embedding[0] != float32(i+1) {
+			t.Errorf("result %d = %+v, want the embedding of input %d", i, result, i)
+		}
+	}
+	if usage.TotalTokens != 5 {
+		t.Errorf("usage = %+v, want 5 total tokens", usage)
+	}
+}
+
+func TestBatchEmbedderTokenLimit(t *testing.T) {
+	stub := &stubEmbedder{}
+	b := NewBatchEmbedder(stub, nil, BatchOptions{MaxTokens: 4})
+
+	// Each input is about two tokens
+	b.Embed(context.Background(), []string{"12345678", "12345678", "12345678"}, nil)
+
+	if got, want := stub.requestSizes(), []int{2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("request sizes = %v, want %v", got, want)
+	}
+}
+
+func TestBatchEmbedderSkipsInvalidInputs(t *testing.T) {
+	stub := &stubEmbedder{}
+	b := NewBatchEmbedder(stub, nil, BatchOptions{MaxInputTokens: 2})
+
+	results, _ := b.Embed(context.Background(), []string{"", "ok", strings.Repeat("x", 9)}, nil)
+
+	if !errors.Is(results[0].Err, ErrEmptyInput) {
+		t.Errorf("empty input error = %v, want ErrEmptyInput", results[0].Err)
+	}
+	if results[1].Err != nil {
+		t.Errorf("valid input error = %v", results[1].Err)
+	}
+	if !errors.Is(results[2].Err, ErrInputTooLarge) {
+		t.Errorf("large input error = %v, want ErrInputTooLarge", results[2].Err)
+	}
+	if want := [][]string{{"ok"}}; !reflect.DeepEqual(stub.requests, want) {
+		t.Errorf("requests = %v, want %v", stub.requests, want)
+	}
+}
+
+func TestBatchEmbedderBisectsRejectedBatch(t *testing.T) {
+	stub := &stubEmbedder{}
+	b := NewBatchEmbedder(stub, nil, BatchOptions{})
+
+	inputs := []string{"a", "b", "c", "d", "e", "bad", "g", "h"}
+	results, usage := b.Embed(context.Background(), inputs, nil)
+
+	for i, result := range results {
+		if i == 5 {
+			var apiErr *APIError
+			if !errors.As(result.Err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+				t.Errorf("result 5 error = %v, want the bad request", result.Err)
+			}
+			continue
+		}
+		if result.Err != nil || len(result.Embedding) == 0 {
+			t.Errorf("result %d = %+v, want an embedding", i, result)
+		}
+	}
+
+	// The rejected half is split until only "bad" is left: 8, 4 (a-d),
+	// 4 (e-h), 2 (e, bad), 1 (e), 1 (bad), 2 (g, h)
+	if got, want := stub.requestSizes(), []int{8, 4, 4, 2, 1, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("request sizes = %v, want %v", got, want)
+	}
+	// Only the answered requests count
+	if usage.TotalTokens != 7 {
+		t.Errorf("usage = %+v, want 7 total tokens", usage)
+	}
+}
+
+func TestBatchEmbedderDoesNotBisectOtherErrors(t *testing.T) {
+	stub := &stubEmbedder{
+		failures: 1,
+		failWith: &APIError{Provider: "stub", StatusCode: http.StatusUnauthorized},
+	}
+	b := NewBatchEmbedder(stub, nil, BatchOptions{MaxRetries: 3})
+
+	results, _ := b.Embed(context.Background(), []string{"a", "b", "c"}, nil)
+
+	for i, result := range results {
+		if result.Err == nil {
+			t.Errorf("result %d succeeded, want the unauthorized error", i)
+		}
+	}
+	if len(stub.requests) != 1 {
+		t.Errorf("sent %d requests, want 1", len(stub.requests))
+	}
+}
+
+func TestBatchEmbedderRetriesRateLimits(t *testing.T) {
+	stub := &stubEmbedder{
+		failures: 2,
+		failWith: &APIError{Provider: "stub", StatusCode: http.StatusTooManyRequests, RetryAfter: time.Millisecond},
+	}
+	b := NewBatchEmbedder(stub, nil, BatchOptions{MaxRetries: 2})
+
+	results, _ := b.Embed(context.Background(), []string{"a", "b"}, nil)
+
+	for i, result := range results {
+		if result.Err != nil {
+			t.Errorf("result %d error = %v, want it to succeed after retrying", i, result.Err)
+		}
+	}
+	if len(stub.requests) != 3 {
+		t.Errorf("sent %d requests, want 3", len(stub.requests))
+	}
+}
@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// FakeProvider is a deterministic, offline provider for CI and local development.
+// Embeddings are hashed bag-of-words vectors, so texts sharing words still score
+// as similar, and completions are derived from the prompt.
+type FakeProvider struct {
+	dimensions int
+}
+
+func NewFakeProvider(dimensions int) *FakeProvider {
+	if dimensions <= 0 {
+		dimensions = 1536
+	}
+	return &FakeProvider{dimensions: dimensions}
+}
+
+func (f *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (f *FakeProvider) EmbeddingModel() string {
+	return fmt.Sprintf("fake-embedding-%d", f.dimensions)
+}
+
+func (f *FakeProvider) ChatModel() string {
+	return "fake-chat"
+}
+
+func (f *FakeProvider) Embed(ctx context.Context, inputs []string) ([][]float32, Usage, error) {
+	embeddings := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		if err := ctx.Err(); err != nil {
+			return nil, Usage{}, err
+		}
+		embeddings[i] = f.embed(input)
+	}
+	return embeddings, EstimateEmbeddingUsage(inputs), nil
+}
+
+func (f *FakeProvider) embed(text string) []float32 {
+	vector := make([]float32, f.dimensions)
+
+	for _, word := range fakeTokens(text) {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+
+		index := int(sum % uint64(f.dimensions))
+		if sum&(1<<63) != 0 {
+			vector[index]--
+		} else {
+			vector[index]++
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm == 0 {
+		vector[0] = 1
+		return vector
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+
+	return vector
+}
+
+func (f *FakeProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(req.Messages) == 0 {
+		return nil, fmt.Errorf("no messages provided")
+	}
+
+	prompt := req.Messages[len(req.Messages)-1].Content
+	words := fakeTokens(prompt)
+
+	h := fnv.New32a()
+	h.Write([]byte(prompt))
+
+	preview := words
+	if len(preview) > 12 {
+		preview = preview[:12]
+	}
+
+	content := fmt.Sprintf("Fake completion %08x for a %d word prompt: %s", h.Sum32(), len(words), strings.Join(preview, " "))
+	if req.JSON {
+		data, err := json.Marshal(map[string]string{"fake_completion": content})
+		if err != nil {
+			return nil, err
+		}
+		content = string(data)
+	}
+
+	return &Completion{
+		Content:      content,
+		FinishReason: "stop",
+		Usage:        EstimateUsage(req, content),
+	}, nil
+}
+
+func (f *FakeProvider) Stream(ctx context.Context, req CompletionRequest, onDelta StreamFunc) (*Completion, error) {
+	completion, err := f.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.SplitAfter(completion.Content, " ")
+	for _, word := range words {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := onDelta(word); err != nil {
+			return nil, err
+		}
+	}
+
+	return completion, nil
+}
+
+func fakeTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,405 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shubhamku044/ytclipper/internal/config"
+)
+
+type embeddingRequest struct {
+	Input          []string `json:"input"`
+	Model          string   `json:"model,omitempty"`
+	EncodingFormat string   `json:"encoding_format,omitempty"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+		Index     int       `json:"index"`
+	} `json:"data"`
+	Usage *Usage `json:"usage"`
+}
+
+type chatRequest struct {
+	Model          string          `json:"model,omitempty"`
+	Messages       []Message       `json:"messages"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	Temperature    float64         `json:"temperature"` // Sent even when 0, which the APIs would otherwise default to 1
+	Stream         bool            `json:"stream,omitempty"`
+	StreamOptions  *streamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type responseFormat struct {
+	Type string `json:"type"`
+}
+
+func newResponseFormat(req CompletionRequest) *responseFormat {
+	if !req.JSON {
+		return nil
+	}
+	return &responseFormat{Type: "json_object"}
+}
+
+type chatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// openAIProvider speaks the OpenAI REST protocol. Azure OpenAI and local
+// servers such as Ollama or vLLM only differ in URLs and auth headers.
+type openAIProvider struct {
+	name           string
+	embeddingModel string
+	chatModel      string
+	// sendModel is false for Azure, where the deployment in the URL picks the model
+	sendModel     bool
+	embeddingsURL string
+	chatURL       string
+	setAuth       func(req *http.Request)
+	client        *http.Client
+	// streamClient has no overall timeout since streams outlive it; the
+	// caller's context bounds them instead
+	streamClient *http.Client
+}
+
+func newOpenAIProvider(cfg *config.OpenAIConfig) *openAIProvider {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+
+	p := newCompatibleProvider(cfg)
+	p.name = ProviderOpenAI
+	p.embeddingsURL = strings.TrimRight(baseURL, "/") + "/embeddings"
+	p.chatURL = strings.TrimRight(baseURL, "/") + "/chat/completions"
+	return p
+}
+
+func newCompatibleProvider(cfg *config.OpenAIConfig) *openAIProvider {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	apiKey := cfg.APIKey
+
+	return &openAIProvider{
+		name:           ProviderCompatible,
+		embeddingModel: cfg.EmbeddingModel,
+		chatModel:      cfg.ChatModel,
+		sendModel:      true,
+		embeddingsURL:  baseURL + "/embeddings",
+		chatURL:        baseURL + "/chat/completions",
+		setAuth: func(req *http.Request) {
+			if apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+apiKey)
+			}
+		},
+		client:       &http.Client{Timeout: cfg.Timeout},
+		streamClient: newStreamClient(cfg.Timeout),
+	}
+}
+
+func newAzureProvider(cfg *config.OpenAIConfig) *openAIProvider {
+	baseURL := strings.TrimRight(cfg.BaseURL, "/")
+	apiKey := cfg.APIKey
+	deploymentURL := func(deployment, operation string) string {
+		return fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s",
+			baseURL, url.PathEscape(deployment), operation, url.QueryEscape(cfg.APIVersion))
+	}
+
+	return &openAIProvider{
+		name:           ProviderAzure,
+		embeddingModel: cfg.EmbeddingModel,
+		chatModel:      cfg.ChatModel,
+		sendModel:      false,
+		embeddingsURL:  deploymentURL(cfg.EmbeddingModel, "embeddings"),
+		chatURL:        deploymentURL(cfg.ChatModel, "chat/completions"),
+		setAuth: func(req *http.Request) {
+			req.Header.Set("api-key", apiKey)
+		},
+		client:       &http.Client{Timeout: cfg.Timeout},
+		streamClient: newStreamClient(cfg.Timeout),
+	}
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) EmbeddingModel() string {
+	return p.embeddingModel
+}
+
+func (p *openAIProvider) ChatModel() string {
+	return p.chatModel
+}
+
+func (p *openAIProvider) Embed(ctx context.Context, inputs []string) ([][]float32, Usage, error) {
+	if len(inputs) == 0 {
+		return nil, Usage{}, nil
+	}
+
+	reqBody := embeddingRequest{
+		Input:          inputs,
+		EncodingFormat: "float",
+	}
+	if p.sendModel {
+		reqBody.Model = p.embeddingModel
+	}
+
+	var embeddingResp embeddingResponse
+	if err := p.post(ctx, p.embeddingsURL, reqBody, &embeddingResp); err != nil {
+		return nil, Usage{}, err
+	}
+
+	usage := EstimateEmbeddingUsage(inputs)
+	if embeddingResp.Usage != nil && embeddingResp.Usage.TotalTokens > 0 {
+		usage = *embeddingResp.Usage
+	}
+
+	if len(embeddingResp.Data) != len(inputs) {
+		return nil, usage, fmt.Errorf("%s returned %d embeddings for %d inputs", p.name, len(embeddingResp.Data), len(inputs))
+	}
+
+	embeddings := make([][]float32, len(inputs))
+	for _, item := range embeddingResp.Data {
+		if item.Index < 0 || item.Index >= len(inputs) {
+			return nil, usage, fmt.Errorf("%s returned embedding with out of range index %d", p.name, item.Index)
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+
+	return embeddings, usage, nil
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, req CompletionRequest) (*Completion, error) {
+	reqBody := chatRequest{
+		Messages:       req.Messages,
+		MaxTokens:      req.MaxTokens,
+		Temperature:    req.Temperature,
+		ResponseFormat: newResponseFormat(req),
+	}
+	if p.sendModel {
+		reqBody.Model = p.chatModel
+	}
+
+	var chatResp chatResponse
+	if err := p.post(ctx, p.chatURL, reqBody, &chatResp); err != nil {
+		return nil, err
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from AI")
+	}
+
+	completion := &Completion{
+		Content:      chatResp.Choices[0].Message.Content,
+		FinishReason: chatResp.Choices[0].FinishReason,
+	}
+	if chatResp.Usage != nil && chatResp.Usage.TotalTokens > 0 {
+		completion.Usage = *chatResp.Usage
+	} else {
+		completion.Usage = EstimateUsage(req, completion.Content)
+	}
+
+	return completion, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, req CompletionRequest, onDelta StreamFunc) (*Completion, error) {
+	reqBody := chatRequest{
+		Messages:       req.Messages,
+		MaxTokens:      req.MaxTokens,
+		Temperature:    req.Temperature,
+		Stream:         true,
+		StreamOptions:  &streamOptions{IncludeUsage: true},
+		ResponseFormat: newResponseFormat(req),
+	}
+	if p.sendModel {
+		reqBody.Model = p.chatModel
+	}
+
+	httpReq, err := p.newRequest(ctx, p.chatURL, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := p.streamClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(p.name, resp)
+	}
+
+	var content strings.Builder
+	completion := &Completion{}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode %s stream chunk: %w", p.name, err)
+		}
+
+		if chunk.Usage != nil {
+			completion.Usage = *chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != nil {
+				completion.FinishReason = *choice.FinishReason
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+
+			content.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	completion.Content = content.String()
+	if completion.Usage.TotalTokens == 0 {
+		completion.Usage = EstimateUsage(req, completion.Content)
+	}
+
+	return completion, nil
+}
+
+func (p *openAIProvider) newRequest(ctx context.Context, endpoint string, body interface{}) (*http.Request, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	p.setAuth(req)
+
+	return req, nil
+}
+
+func (p *openAIProvider) post(ctx context.Context, endpoint string, body, out interface{}) error {
+	req, err := p.newRequest(ctx, endpoint, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(p.name, resp)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func newStreamClient(headerTimeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = headerTimeout
+	return &http.Client{Transport: transport}
+}
+
+// APIError is returned when the provider answers with a non-200 status
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	// RetryAfter is how long the provider asked callers to wait, if it said
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s API error: status %d", e.Provider, e.StatusCode)
+	}
+	return fmt.Sprintf("%s API error: status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+func newAPIError(provider string, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: parseRetryAfter(resp.Header),
+	}
+}
+
+// parseRetryAfter reads OpenAI's retry-after-ms header or the standard
+// Retry-After header, given in seconds or as an HTTP date
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
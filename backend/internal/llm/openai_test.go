@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shubhamku044/ytclipper/internal/config"
+)
+
+func TestCompleteSendsZeroTemperature(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"choices": [{"message": {"content": "ok"}, "finish_reason": "stop"}]}`))
+	}))
+	defer server.Close()
+
+	p := newCompatibleProvider(&config.OpenAIConfig{BaseURL: server.URL, ChatModel: "test", Timeout: time.Second})
+	if _, err := p.Complete(context.Background(), CompletionRequest{Messages: UserMessage("hi")}); err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+
+	if temperature, ok := body["temperature"]; !ok || temperature != 0.0 {
+		t.Errorf("temperature = %v (sent %v), want an explicit 0", temperature, ok)
+	}
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Price is what a model costs in USD per million tokens
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// defaultPrices are OpenAI's list prices. Models not listed, such as
+// self-hosted ones, cost nothing unless OPENAI_MODEL_PRICES prices them.
+var defaultPrices = map[string]Price{
+	"gpt-3.5-turbo":          {Input: 0.50, Output: 1.50},
+	"gpt-4o":                 {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":            {Input: 0.15, Output: 0.60},
+	"gpt-4-turbo":            {Input: 10.00, Output: 30.00},
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.10},
+}
+
+// Pricing estimates the cost of model usage
+type Pricing struct {
+	prices map[string]Price
+}
+
+// NewPricing applies overrides of the form model=input/output, e.g.
+// gpt-4o=2.5/10, or model=input for embedding models, to the default prices
+func NewPricing(overrides []string) (*Pricing, error) {
+	prices := make(map[string]Price, len(defaultPrices)+len(overrides))
+	for model, price := range defaultPrices {
+		prices[model] = price
+	}
+
+	for _, override := range overrides {
+		model, value, ok := strings.Cut(override, "=")
+		if !ok || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("invalid model price %q, expected model=input/output", override)
+		}
+
+		var price Price
+		input, output, hasOutput := strings.Cut(value, "/")
+		var err error
+		if price.Input, err = strconv.ParseFloat(strings.TrimSpace(input), 64); err != nil {
+			return nil, fmt.Errorf("invalid input price in %q: %w", override, err)
+		}
+		if hasOutput {
+			if price.Output, err = strconv.ParseFloat(strings.TrimSpace(output), 64); err != nil {
+				return nil, fmt.Errorf("invalid output price in %q: %w", override, err)
+			}
+		}
+		prices[strings.TrimSpace(model)] = price
+	}
+
+	return &Pricing{prices: prices}, nil
+}
+
+// Price returns the price of model. Dated snapshots such as
+// gpt-4o-2024-08-06 are priced as their base model.
+func (p *Pricing) Price(model string) (Price, bool) {
+	for name := model; name != ""; {
+		if price, ok := p.prices[name]; ok {
+			return price, true
+		}
+		i := strings.LastIndex(name, "-")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return Price{}, false
+}
+
+// Cost estimates the cost of usage in USD
+func (p *Pricing) Cost(model string, usage Usage) float64 {
+	price, ok := p.Price(model)
+	if !ok {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*price.Input + float64(usage.CompletionTokens)*price.Output) / 1e6
+}
@@ -0,0 +1,132 @@
+// Package llm provides the embedding and chat completion providers used by the AI features
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shubhamku044/ytclipper/internal/config"
+)
+
+const (
+	ProviderOpenAI     = "openai"
+	ProviderAzure      = "azure"
+	ProviderCompatible = "compatible"
+	ProviderFake       = "fake"
+)
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type CompletionRequest struct {
+	Messages    []Message
+	MaxTokens   int
+	Temperature float64
+	// JSON asks for a single JSON object as the response. The prompt must
+	// still describe the expected shape.
+	JSON bool `json:",omitempty"`
+}
+
+type Usage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	TotalTokens      int  `json:"total_tokens"`
+	Estimated        bool `json:"estimated,omitempty"`
+}
+
+// Add sums two usages. The sum is estimated if either part is.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		Estimated:        u.Estimated || other.Estimated,
+	}
+}
+
+type Completion struct {
+	Content      string
+	FinishReason string
+	Usage        Usage
+}
+
+// StreamFunc receives each content delta as it is generated. Returning an
+// error aborts the stream and the upstream request.
+type StreamFunc func(delta string) error
+
+// Provider is implemented by every LLM backend the AI service can talk to
+type Provider interface {
+	Name() string
+	EmbeddingModel() string
+	ChatModel() string
+
+	// Embed returns one vector per input, in input order, and the tokens
+	// the request used
+	Embed(ctx context.Context, inputs []string) ([][]float32, Usage, error)
+	Complete(ctx context.Context, req CompletionRequest) (*Completion, error)
+	// Stream generates a completion incrementally. Cancelling ctx stops generation.
+	Stream(ctx context.Context, req CompletionRequest, onDelta StreamFunc) (*Completion, error)
+}
+
+// NewProvider builds the provider selected by cfg.Provider
+func NewProvider(cfg *config.OpenAIConfig) (Provider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", ProviderOpenAI:
+		return newOpenAIProvider(cfg), nil
+	case ProviderAzure:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("azure provider requires OPENAI_BASE_URL")
+		}
+		return newAzureProvider(cfg), nil
+	case ProviderCompatible:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("compatible provider requires OPENAI_BASE_URL")
+		}
+		return newCompatibleProvider(cfg), nil
+	case ProviderFake:
+		return NewFakeProvider(cfg.EmbeddingDimensions), nil
+	default:
+		return nil, fmt.Errorf("unknown AI provider %q", cfg.Provider)
+	}
+}
+
+// UserMessage is a shorthand for a single-turn user prompt
+func UserMessage(prompt string) []Message {
+	return []Message{{Role: "user", Content: prompt}}
+}
+
+// EstimateTokens approximates a token count for providers that don't report usage
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return (len(text) + 3) / 4
+}
+
+// EstimateEmbeddingUsage builds an estimated Usage for embedding inputs
+func EstimateEmbeddingUsage(inputs []string) Usage {
+	tokens := 0
+	for _, input := range inputs {
+		tokens += EstimateTokens(input)
+	}
+	return Usage{PromptTokens: tokens, TotalTokens: tokens, Estimated: true}
+}
+
+// EstimateUsage builds an estimated Usage from the request and generated text
+func EstimateUsage(req CompletionRequest, content string) Usage {
+	prompt := 0
+	for _, message := range req.Messages {
+		prompt += EstimateTokens(message.Content)
+	}
+	completion := EstimateTokens(content)
+
+	return Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+		Estimated:        true,
+	}
+}
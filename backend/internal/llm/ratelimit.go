@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces calls with token buckets for requests and tokens per
+// minute, and can be paused when the provider asks callers to back off.
+// It is safe for concurrent use; every caller shares the same budget.
+type RateLimiter struct {
+	mu          sync.Mutex
+	requests    *tokenBucket
+	tokens      *tokenBucket
+	pausedUntil time.Time
+}
+
+// NewRateLimiter allows requestsPerMinute requests and tokensPerMinute
+// tokens per minute. Zero disables the corresponding limit.
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		requests: newTokenBucket(requestsPerMinute),
+		tokens:   newTokenBucket(tokensPerMinute),
+	}
+}
+
+// Wait blocks until a request costing tokens may be sent or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	l.mu.Lock()
+	now := time.Now()
+	wait := l.pausedUntil.Sub(now)
+	wait = max(wait, l.requests.reserve(1, now))
+	wait = max(wait, l.tokens.reserve(float64(tokens), now))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause holds back every caller for d, e.g. after a 429 with Retry-After
+func (l *RateLimiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// tokenBucket refills continuously at perMinute/60 per second up to a
+// minute's worth. Reservations may drive it negative; later callers then
+// wait for the debt to refill, which keeps waiting callers in order.
+type tokenBucket struct {
+	capacity float64
+	perSec   float64
+	level    float64
+	last     time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		perSec:   float64(perMinute) / 60,
+		level:    float64(perMinute),
+	}
+}
+
+// reserve takes n from the bucket and returns how long the caller must wait
+// before the reservation is covered. A nil bucket never waits.
+func (b *tokenBucket) reserve(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	if !b.last.IsZero() {
+		b.level = min(b.capacity, b.level+now.Sub(b.last).Seconds()*b.perSec)
+	}
+	b.last = now
+
+	// A single request larger than the bucket would otherwise wait forever
+	b.level -= min(n, b.capacity)
+	if b.level >= 0 {
+		return 0
+	}
+	return time.Duration(-b.level / b.perSec * float64(time.Second))
+}
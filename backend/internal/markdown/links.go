@@ -0,0 +1,219 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/clock"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// LinkKind is what a [[...]] link in a note points to
+type LinkKind string
+
+const (
+	// LinkSeek is a point in the note's own video, [[12:34]]
+	LinkSeek LinkKind = "seek"
+	// LinkNote is another note, [[note:<id>]]
+	LinkNote LinkKind = "note"
+	// LinkVideo is a video, optionally at a point, [[video:<id>]] or
+	// [[video:<id>@12:34]]
+	LinkVideo LinkKind = "video"
+)
+
+// Link is a [[target]] or [[target|label]] reference in a note
+type Link struct {
+	Kind    LinkKind `json:"kind"`
+	Seconds *float64 `json:"seconds,omitempty"`
+	NoteID  string   `json:"note_id,omitempty"`
+	VideoID string   `json:"video_id,omitempty"`
+	Label   string   `json:"label,omitempty"`
+}
+
+var (
+	clockPattern   = regexp.MustCompile(`^(\d{1,2}:)?\d{1,3}:\d{2}$`)
+	videoIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{6,20}$`)
+)
+
+// ParseLink parses the inside of a [[...]] link
+func ParseLink(content string) (Link, bool) {
+	target, label, _ := strings.Cut(content, "|")
+	target = strings.TrimSpace(target)
+	link := Link{Label: strings.TrimSpace(label)}
+
+	switch {
+	case strings.HasPrefix(target, "note:"):
+		id, err := uuid.Parse(strings.TrimPrefix(target, "note:"))
+		if err != nil {
+			return Link{}, false
+		}
+		link.Kind = LinkNote
+		link.NoteID = id.String()
+	case strings.HasPrefix(target, "video:"):
+		videoID, at, hasTime := strings.Cut(strings.TrimPrefix(target, "video:"), "@")
+		if !videoIDPattern.MatchString(videoID) {
+			return Link{}, false
+		}
+		link.Kind = LinkVideo
+		link.VideoID = videoID
+		if hasTime {
+			seconds, ok := parseClock(at)
+			if !ok {
+				return Link{}, false
+			}
+			link.Seconds = &seconds
+		}
+	default:
+		seconds, ok := parseClock(target)
+		if !ok {
+			return Link{}, false
+		}
+		link.Kind = LinkSeek
+		link.Seconds = &seconds
+	}
+	return link, true
+}
+
+// parseClock parses m:ss or h:mm:ss into seconds
+func parseClock(value string) (float64, bool) {
+	if !clockPattern.MatchString(value) {
+		return 0, false
+	}
+	seconds, err := clock.Parse(value)
+	return seconds, err == nil
+}
+
+// FormatClock formats seconds as m:ss, or h:mm:ss from an hour
+func FormatClock(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+// Text is the link's label, or a default naming its target
+func (l Link) Text() string {
+	if l.Label != "" {
+		return l.Label
+	}
+	switch l.Kind {
+	case LinkNote:
+		return "note"
+	case LinkVideo:
+		if l.Seconds != nil {
+			return fmt.Sprintf("%s@%s", l.VideoID, FormatClock(*l.Seconds))
+		}
+		return l.VideoID
+	default:
+		return FormatClock(*l.Seconds)
+	}
+}
+
+// KindLink is the kind of LinkNode
+var KindLink = ast.NewNodeKind("NoteLink")
+
+// LinkNode is a [[...]] link. Href and Label are filled in from the render
+// options before the document is rendered; a link without Href renders as
+// plain text.
+type LinkNode struct {
+	ast.BaseInline
+	Link  Link
+	Href  string
+	Label string
+}
+
+func (n *LinkNode) Kind() ast.NodeKind {
+	return KindLink
+}
+
+func (n *LinkNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Kind":   string(n.Link.Kind),
+		"Target": n.Link.NoteID + n.Link.VideoID,
+	}, nil)
+}
+
+// linkParser parses [[...]] links, leaving anything that isn't a valid link
+// to the regular link parser
+type linkParser struct{}
+
+func (p *linkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *linkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if len(line) < 5 || line[1] != '[' {
+		return nil
+	}
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+
+	link, ok := ParseLink(string(line[2 : 2+end]))
+	if !ok {
+		return nil
+	}
+	block.Advance(end + 4)
+	return &LinkNode{Link: link}
+}
+
+// linkRenderer renders LinkNodes as anchors carrying their target in data
+// attributes, so clients can seek or navigate without leaving the page
+type linkRenderer struct{}
+
+func (r *linkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindLink, r.render)
+}
+
+func (r *linkRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*LinkNode)
+
+	if n.Href == "" {
+		_, _ = w.Write(util.EscapeHTML([]byte(n.Label)))
+		return ast.WalkSkipChildren, nil
+	}
+
+	_, _ = w.WriteString(`<a href="`)
+	_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte(n.Href), true)))
+	_, _ = w.WriteString(`"`)
+	if n.Link.Seconds != nil {
+		_, _ = fmt.Fprintf(w, ` data-seek="%d"`, int(*n.Link.Seconds))
+	}
+	if n.Link.NoteID != "" {
+		_, _ = fmt.Fprintf(w, ` data-note-id="%s"`, n.Link.NoteID)
+	}
+	if n.Link.VideoID != "" {
+		_, _ = fmt.Fprintf(w, ` data-video-id="%s"`, n.Link.VideoID)
+	}
+	_, _ = w.WriteString(">")
+	_, _ = w.Write(util.EscapeHTML([]byte(n.Label)))
+	_, _ = w.WriteString("</a>")
+	return ast.WalkSkipChildren, nil
+}
+
+// linkExtension adds [[...]] links to a goldmark parser and renderer
+type linkExtension struct{}
+
+func (e *linkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(
+		// Before the regular link parser, which also triggers on [
+		util.Prioritized(&linkParser{}, 199),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&linkRenderer{}, 500),
+	))
+}
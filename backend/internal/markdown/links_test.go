@@ -0,0 +1,52 @@
+package markdown
+
+import "testing"
+
+func TestParseLink(t *testing.T) {
+	seconds := func(s float64) *float64 { return &s }
+
+	tests := []struct {
+		content string
+		want    Link
+		ok      bool
+	}{
+		{"1:05", Link{Kind: LinkSeek, Seconds: seconds(65)}, true},
+		{"125:00", Link{Kind: LinkSeek, Seconds: seconds(7500)}, true},
+		{"1:02:03|recap", Link{Kind: LinkSeek, Seconds: seconds(3723), Label: "recap"}, true},
+		{"99:99", Link{}, false},
+		{"1:60:00", Link{}, false},
+		{"12", Link{}, false},
+		{"note:6de85cd6-74a1-48f3-8d17-44b375398d6e", Link{Kind: LinkNote, NoteID: "6de85cd6-74a1-48f3-8d17-44b375398d6e"}, true},
+		{"note:not-a-uuid", Link{}, false},
+		{"video:dQw4w9WgXcQ", Link{Kind: LinkVideo, VideoID: "dQw4w9WgXcQ"}, true},
+		{"video:dQw4w9WgXcQ@2:30", Link{Kind: LinkVideo, VideoID: "dQw4w9WgXcQ", Seconds: seconds(150)}, true},
+		{"video:dQw4w9WgXcQ@2:75", Link{}, false},
+		{"video:bad id", Link{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			got, ok := ParseLink(tt.content)
+			if ok != tt.ok {
+				t.Fatalf("ParseLink(%q) ok = %v, want %v", tt.content, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if got.Kind != tt.want.Kind || got.NoteID != tt.want.NoteID || got.VideoID != tt.want.VideoID || got.Label != tt.want.Label {
+				t.Errorf("ParseLink(%q) = %+v, want %+v", tt.content, got, tt.want)
+			}
+			if (got.Seconds == nil) != (tt.want.Seconds == nil) || (got.Seconds != nil && *got.Seconds != *tt.want.Seconds) {
+				t.Errorf("ParseLink(%q) seconds = %v, want %v", tt.content, got.Seconds, tt.want.Seconds)
+			}
+		})
+	}
+}
+
+func TestFormatClock(t *testing.T) {
+	tests := map[float64]string{0: "0:00", 65: "1:05", 3599: "59:59", 3723: "1:02:03"}
+	for seconds, want := range tests {
+		if got := FormatClock(seconds); got != want {
+			t.Errorf("FormatClock(%v) = %q, want %q", seconds, got, want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package markdown sanitizes, renders and flattens the Markdown notes are
+// written in. Besides GitHub flavoured Markdown, notes support [[...]] links
+// to a point in their video, to other notes and to videos, and images stored
+// as attachments.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	stdhtml "html"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// AttachmentScheme prefixes image destinations that refer to a note
+// attachment, ![diagram](attachment:<id>)
+const AttachmentScheme = "attachment:"
+
+var noteIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// htmlTextPolicy keeps only the text of raw HTML in notes
+var htmlTextPolicy = bluemonday.StrictPolicy()
+
+// md parses notes. The renderer is not in unsafe mode, so raw HTML is never
+// rendered as HTML: inline tags are dropped and HTML blocks render as their
+// text.
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, &linkExtension{}, &htmlTextExtension{}),
+	goldmark.WithRendererOptions(html.WithHardWraps()),
+)
+
+// RenderOptions resolve the links in a note
+type RenderOptions struct {
+	// VideoID is the video the note belongs to, the target of seek links
+	VideoID string
+	// NoteTitles are the titles of the notes the note may link to, by ID.
+	// Links to other notes render as plain text.
+	NoteTitles map[string]string
+	// VideoTitles are the titles of linked videos, by YouTube ID
+	VideoTitles map[string]string
+}
+
+// Renderer renders notes to sanitized HTML
+type Renderer struct {
+	policy        *bluemonday.Policy
+	attachmentURL string
+}
+
+// NewRenderer creates a renderer serving attachment images from
+// attachmentURL followed by the attachment ID
+func NewRenderer(attachmentURL string) *Renderer {
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("data-seek").Matching(regexp.MustCompile(`^\d+$`)).OnElements("a")
+	policy.AllowAttrs("data-note-id").Matching(noteIDPattern).OnElements("a")
+	policy.AllowAttrs("data-video-id").Matching(videoIDPattern).OnElements("a")
+	// GFM task list items
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+
+	return &Renderer{
+		policy:        policy,
+		attachmentURL: attachmentURL,
+	}
+}
+
+// Render renders a note to HTML that is safe to embed in a page
+func (r *Renderer) Render(source string, opts RenderOptions) (string, error) {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	err := ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *LinkNode:
+			n.Href, n.Label = resolveLink(n.Link, opts)
+		case *ast.Image:
+			if id, ok := attachmentID(string(n.Destination)); ok {
+				n.Destination = []byte(r.attachmentURL + id)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, src, doc); err != nil {
+		return "", fmt.Errorf("failed to render note: %w", err)
+	}
+	return r.policy.Sanitize(buf.String()), nil
+}
+
+// resolveLink returns the href and text of a link. A link to a note not in
+// the options gets no href.
+func resolveLink(link Link, opts RenderOptions) (string, string) {
+	switch link.Kind {
+	case LinkNote:
+		title, ok := opts.NoteTitles[link.NoteID]
+		if !ok {
+			return "", link.Text()
+		}
+		if link.Label == "" && title != "" {
+			return "#note-" + link.NoteID, title
+		}
+		return "#note-" + link.NoteID, link.Text()
+	case LinkVideo:
+		label := link.Text()
+		if title := opts.VideoTitles[link.VideoID]; link.Label == "" && title != "" {
+			label = title
+			if link.Seconds != nil {
+				label = fmt.Sprintf("%s @ %s", title, FormatClock(*link.Seconds))
+			}
+		}
+		return watchURL(link.VideoID, link.Seconds), label
+	default:
+		if opts.VideoID == "" {
+			return fmt.Sprintf("#t=%d", int(*link.Seconds)), link.Text()
+		}
+		return watchURL(opts.VideoID, link.Seconds), link.Text()
+	}
+}
+
+func watchURL(videoID string, seconds *float64) string {
+	url := "https://www.youtube.com/watch?v=" + videoID
+	if seconds != nil {
+		url += fmt.Sprintf("&t=%ds", int(*seconds))
+	}
+	return url
+}
+
+// attachmentID returns the attachment an image destination refers to
+func attachmentID(destination string) (string, bool) {
+	if !strings.HasPrefix(destination, AttachmentScheme) {
+		return "", false
+	}
+	id, err := uuid.Parse(strings.TrimPrefix(destination, AttachmentScheme))
+	if err != nil {
+		return "", false
+	}
+	return id.String(), true
+}
+
+// Links returns the [[...]] links in a note, in order
+func Links(source string) []Link {
+	var links []Link
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if n, ok := node.(*LinkNode); ok && entering {
+			links = append(links, n.Link)
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// Sanitize normalizes a note before it is stored: line endings are made
+// \n and invalid UTF-8 and control characters are dropped. Raw HTML is kept
+// as written; it is never rendered as HTML, see Render.
+func Sanitize(source string) string {
+	source = strings.ToValidUTF8(source, "")
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	source = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r == '\r' {
+			return '\n'
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, source)
+	return strings.TrimSpace(source)
+}
+
+// htmlText returns the text of raw HTML without its tags and comments,
+// escaped for HTML
+func htmlText(raw []byte) string {
+	return strings.TrimSpace(htmlTextPolicy.Sanitize(string(raw)))
+}
+
+// PlainText flattens a note to its text, for embedding and prompts. Links
+// are replaced by their labels and images by their alt text.
+func PlainText(source string) string {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	var b strings.Builder
+	newline := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if node.Type() == ast.TypeBlock {
+				newline()
+			}
+			return ast.WalkContinue, nil
+		}
+
+		switch n := node.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(src))
+			if n.HardLineBreak() {
+				b.WriteByte('\n')
+			} else if n.SoftLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *ast.AutoLink:
+			b.Write(n.Label(src))
+			return ast.WalkSkipChildren, nil
+		case *LinkNode:
+			b.WriteString(n.Link.Text())
+			return ast.WalkSkipChildren, nil
+		case *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.HTMLBlock:
+			newline()
+			b.WriteString(stdhtml.UnescapeString(htmlText(htmlBlockSource(n, src))))
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeBlock, *ast.FencedCodeBlock:
+			newline()
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				b.Write(segment.Value(src))
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// htmlBlockSource returns the source of an HTML block, closing line included
+func htmlBlockSource(n *ast.HTMLBlock, source []byte) []byte {
+	var b bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		segment := lines.At(i)
+		b.Write(segment.Value(source))
+	}
+	if n.HasClosure() {
+		b.Write(n.ClosureLine.Value(source))
+	}
+	return b.Bytes()
+}
+
+// htmlTextRenderer renders HTML blocks as a paragraph of their text, so
+// notes written with HTML don't render blank
+type htmlTextRenderer struct{}
+
+func (r *htmlTextRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHTMLBlock, r.renderHTMLBlock)
+}
+
+func (r *htmlTextRenderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	var lines []string
+	for _, line := range strings.Split(htmlText(htmlBlockSource(node.(*ast.HTMLBlock), source)), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > 0 {
+		_, _ = w.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// htmlTextExtension renders HTML blocks with htmlTextRenderer
+type htmlTextExtension struct{}
+
+func (e *htmlTextExtension) Extend(m goldmark.Markdown) {
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		// Before the HTML renderer, which omits HTML blocks entirely
+		util.Prioritized(&htmlTextRenderer{}, 500),
+	))
+}
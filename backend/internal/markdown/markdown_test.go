@@ -0,0 +1,157 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"plain text", "just a note", "just a note"},
+		{"line endings", "one\r\ntwo\rthree", "one\ntwo\nthree"},
+		{"control characters", "a\x00b\x07c\td", "abc\td"},
+		{"invalid utf-8", "ok\xffok", "okok"},
+		{"surrounding space", "  \n note \n\n", "note"},
+		{"html block kept", "<details>\nsummary of lecture\n</details>\nafter", "<details>\nsummary of lecture\n</details>\nafter"},
+		{"comment kept", "<!-- todo --> remember to rewatch 12:00", "<!-- todo --> remember to rewatch 12:00"},
+		{"unclosed div kept", "<div>\nMy important paragraph\nline 2\n\nkept", "<div>\nMy important paragraph\nline 2\n\nkept"},
+		{"inline html kept", "a <b>bold</b> word", "a <b>bold</b> word"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.source); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	const noteID = "6de85cd6-74a1-48f3-8d17-44b375398d6e"
+	opts := RenderOptions{
+		VideoID:    "dQw4w9WgXcQ",
+		NoteTitles: map[string]string{noteID: "Intro"},
+	}
+
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "emphasis",
+			source: "some *text*",
+			want:   []string{"<p>some <em>text</em></p>"},
+		},
+		{
+			name:   "seek link",
+			source: "see [[1:05]]",
+			want:   []string{`href="https://www.youtube.com/watch?v=dQw4w9WgXcQ&amp;t=65s"`, `data-seek="65"`, ">1:05</a>"},
+		},
+		{
+			name:   "known note link",
+			source: "[[note:" + noteID + "]]",
+			want:   []string{`href="#note-` + noteID + `"`, ">Intro</a>"},
+		},
+		{
+			name:    "unknown note link",
+			source:  "[[note:00000000-0000-0000-0000-000000000000|other]]",
+			want:    []string{"other"},
+			notWant: []string{"<a"},
+		},
+		{
+			name:    "attachment image",
+			source:  "![chart](attachment:" + noteID + ")",
+			want:    []string{`src="/attachments/` + noteID + `"`, `alt="chart"`},
+			notWant: []string{"attachment:"},
+		},
+		{
+			name:    "html block text",
+			source:  "<details>\nsummary of lecture\n</details>\nafter",
+			want:    []string{"summary of lecture"},
+			notWant: []string{"<details>"},
+		},
+		{
+			name:    "comment text",
+			source:  "<!-- todo --> remember to rewatch 12:00",
+			want:    []string{"remember to rewatch 12:00"},
+			notWant: []string{"todo"},
+		},
+		{
+			name:    "unclosed div text",
+			source:  "<div>\nMy important paragraph\nline 2\n\nkept",
+			want:    []string{"My important paragraph", "line 2", "<p>kept</p>"},
+			notWant: []string{"<div>"},
+		},
+		{
+			name:    "script",
+			source:  "<script>alert(1)</script>\n\ntext",
+			want:    []string{"<p>text</p>"},
+			notWant: []string{"<script", "alert"},
+		},
+		{
+			name:    "inline html",
+			source:  `a <img src=x onerror="alert(1)"> b`,
+			want:    []string{"a", "b"},
+			notWant: []string{"<img", "onerror"},
+		},
+		{
+			name:    "javascript link",
+			source:  "[click](javascript:alert(1))",
+			want:    []string{"click"},
+			notWant: []string{"javascript:"},
+		},
+	}
+
+	r := NewRenderer("/attachments/")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Render(tt.source, opts)
+			if err != nil {
+				t.Fatalf("Render(%q) error: %v", tt.source, err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Render(%q) = %q, want it to contain %q", tt.source, got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("Render(%q) = %q, want it not to contain %q", tt.source, got, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"emphasis", "some **bold** and *italic*", "some bold and italic"},
+		{"heading and list", "# Title\n\n- one\n- two", "Title\none\ntwo"},
+		{"seek link", "at [[1:05]] and [[2:00|the demo]]", "at 1:05 and the demo"},
+		{"markdown link", "[docs](https://example.com)", "docs"},
+		{"image alt", "![a chart](attachment:6de85cd6-74a1-48f3-8d17-44b375398d6e)", "a chart"},
+		{"code block", "```\nx := 1\n```", "x := 1"},
+		{"html block text", "<details>\nsummary of lecture\n</details>\nafter", "summary of lecture\nafter"},
+		{"comment", "<!-- todo --> remember to rewatch 12:00", "remember to rewatch 12:00"},
+		{"unclosed div", "<div>\nMy important paragraph\nline 2\n\nkept", "My important paragraph\nline 2\nkept"},
+		{"inline html", "a <b>bold</b> word", "a bold word"},
+		{"entities", "<div>\nQ&amp;A\n</div>", "Q&A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PlainText(tt.source); got != tt.want {
+				t.Errorf("PlainText(%q) = %q, want %q", tt.source, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/uptrace/bun"
+)
+
+// AICacheEntry is a cached embedding or completion, keyed by a hash of the
+// model and input
+type AICacheEntry struct {
+	bun.BaseModel `bun:"table:ai_cache,alias:ac"`
+
+	Key       string           `bun:"key,pk" json:"key"`
+	Kind      string           `bun:"kind,notnull" json:"kind"`
+	Model     string           `bun:"model,notnull" json:"model"`
+	Feature   string           `bun:"feature,notnull" json:"feature"`
+	Content   *string          `bun:"content" json:"content,omitempty"`
+	Embedding *pgvector.Vector `bun:"embedding,type:vector" json:"-"`
+	Hits      int              `bun:"hits,notnull" json:"hits"`
+	CreatedAt time.Time        `bun:"created_at,notnull" json:"created_at"`
+	LastHitAt *time.Time       `bun:"last_hit_at" json:"last_hit_at,omitempty"`
+	ExpiresAt time.Time        `bun:"expires_at,notnull" json:"expires_at"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// AIInsight records one AI output shown to a user, such as a summary or an
+// answer, with the feedback the user gave on it
+type AIInsight struct {
+	bun.BaseModel `bun:"table:ai_insights,alias:ai"`
+
+	ID     uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	UserID uuid.UUID `bun:"user_id,type:uuid,notnull" json:"user_id"`
+
+	// YouTube ID of the video the insight is about, if any
+	VideoID *string `bun:"video_id" json:"video_id"`
+
+	// AI insight details
+	Type        AIInsightType `bun:"type,notnull" json:"type"`
+	Title       string        `bun:"title,notnull" json:"title"`
+	Description string        `bun:"description" json:"description"`
+	Input       string        `bun:"input" json:"input,omitempty"` // Question or message answered
+	Content     string        `bun:"content,notnull" json:"content"`
+
+	// AI metadata
+	Model          string          `bun:"model,notnull" json:"model"`             // AI model used
+	Confidence     *float64        `bun:"confidence" json:"confidence"`           // Confidence score 0-1
+	ProcessingTime int             `bun:"processing_time" json:"processing_time"` // Time taken in ms
+	Metadata       json.RawMessage `bun:"metadata,type:jsonb,nullzero" json:"metadata,omitempty"`
+
+	// Prompt that produced the insight, and the versions of all prompts
+	// behind it for outputs built in several steps
+	PromptName    string            `bun:"prompt_name,nullzero" json:"prompt_name,omitempty"`
+	PromptVersion string            `bun:"prompt_version,nullzero" json:"prompt_version,omitempty"`
+	Prompts       map[string]string `bun:"prompts,type:jsonb,nullzero" json:"prompts,omitempty"`
+
+	// User interaction
+	IsUseful     *bool      `bun:"is_useful" json:"is_useful"`     // User feedback
+	UserRating   *int       `bun:"user_rating" json:"user_rating"` // 1-5 rating
+	UserFeedback string     `bun:"user_feedback" json:"user_feedback"`
+	FlaggedAt    *time.Time `bun:"flagged_at" json:"flagged_at"`
+	FlagReason   string     `bun:"flag_reason" json:"flag_reason,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+// AIInsightType represents different types of AI insights
+type AIInsightType string
+
+const (
+	AIInsightTypeSummary     AIInsightType = "summary"
+	AIInsightTypeAnswer      AIInsightType = "answer"
+	AIInsightTypeKeyPoints   AIInsightType = "key_points"
+	AIInsightTypeTranscript  AIInsightType = "transcript"
+	AIInsightTypeTopics      AIInsightType = "topics"
+	AIInsightTypeSentiment   AIInsightType = "sentiment"
+	AIInsightTypeLanguage    AIInsightType = "language"
+	AIInsightTypeCategory    AIInsightType = "category"
+	AIInsightTypeSuggestions AIInsightType = "suggestions"
+	AIInsightTypeQuestions   AIInsightType = "questions"
+	AIInsightTypeActionItems AIInsightType = "action_items"
+)
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// AITokenUsage rolls up the tokens a user's AI calls used in one day, per
+// feature and model
+type AITokenUsage struct {
+	bun.BaseModel `bun:"table:ai_token_usage,alias:atu"`
+
+	ID        int64     `bun:"id,pk,autoincrement" json:"id"`
+	UserID    uuid.UUID `bun:"user_id,type:uuid,notnull" json:"user_id"`
+	UsageDate time.Time `bun:"usage_date,type:date,notnull" json:"usage_date"`
+	Feature   string    `bun:"feature,notnull" json:"feature"`
+	Model     string    `bun:"model,notnull" json:"model"`
+
+	Requests         int   `bun:"requests,notnull" json:"requests"`
+	PromptTokens     int64 `bun:"prompt_tokens,notnull" json:"prompt_tokens"`
+	CompletionTokens int64 `bun:"completion_tokens,notnull" json:"completion_tokens"`
+	TotalTokens      int64 `bun:"total_tokens,notnull" json:"total_tokens"`
+	// EstimatedTokens were counted by estimate because the provider didn't
+	// report usage
+	EstimatedTokens int64   `bun:"estimated_tokens,notnull" json:"estimated_tokens"`
+	CostUSD         float64 `bun:"cost_usd,notnull" json:"cost_usd"`
+
+	CreatedAt time.Time `bun:"created_at,notnull" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,notnull" json:"updated_at"`
+}
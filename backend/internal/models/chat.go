@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ChatSession is a persisted, multi-turn Q&A conversation about a video
+type ChatSession struct {
+	bun.BaseModel `bun:"table:chat_sessions,alias:cs"`
+
+	ID      uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	UserID  uuid.UUID `bun:"user_id,type:uuid,notnull" json:"user_id"`
+	VideoID string    `bun:"video_id,notnull" json:"video_id"`
+	Title   string    `bun:"title,notnull" json:"title"`
+
+	// History that no longer fits the model context is folded into Summary.
+	// Messages with Position < SummarizedCount are covered by it.
+	Summary         string `bun:"summary" json:"-"`
+	SummarizedCount int    `bun:"summarized_count,notnull,default:0" json:"summarized_count"`
+	MessageCount    int    `bun:"message_count,notnull,default:0" json:"message_count"`
+
+	LastMessageAt *time.Time `bun:"last_message_at" json:"last_message_at"`
+	CreatedAt     time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+	DeletedAt     *time.Time `bun:"deleted_at,soft_delete,nullzero" json:"-"`
+
+	// Relationships
+	Messages []ChatMessage `bun:"rel:has-many,join:id=session_id" json:"messages,omitempty"`
+}
+
+// ChatMessageRole represents who authored a chat message
+type ChatMessageRole string
+
+const (
+	ChatMessageRoleUser      ChatMessageRole = "user"
+	ChatMessageRoleAssistant ChatMessageRole = "assistant"
+)
+
+// ChatMessage is a single turn within a ChatSession
+type ChatMessage struct {
+	bun.BaseModel `bun:"table:chat_messages,alias:cm"`
+
+	ID         uuid.UUID       `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	SessionID  uuid.UUID       `bun:"session_id,type:uuid,notnull" json:"session_id"`
+	Position   int             `bun:"position,notnull" json:"position"`
+	Role       ChatMessageRole `bun:"role,notnull" json:"role"`
+	Content    string          `bun:"content,notnull" json:"content"`
+	Citations  json.RawMessage `bun:"citations,type:jsonb,nullzero" json:"citations,omitempty"`
+	TokenCount int             `bun:"token_count,notnull,default:0" json:"token_count"`
+	CreatedAt  time.Time       `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+func (cs *ChatSession) BeforeInsert(ctx context.Context) error {
+	if cs.ID == uuid.Nil {
+		cs.ID = uuid.New()
+	}
+	now := time.Now()
+	cs.CreatedAt = now
+	cs.UpdatedAt = now
+	return nil
+}
+
+func (cs *ChatSession) BeforeUpdate(ctx context.Context) error {
+	cs.UpdatedAt = time.Now()
+	return nil
+}
+
+func (cm *ChatMessage) BeforeInsert(ctx context.Context) error {
+	if cm.ID == uuid.Nil {
+		cm.ID = uuid.New()
+	}
+	if cm.CreatedAt.IsZero() {
+		cm.CreatedAt = time.Now()
+	}
+	return nil
+}
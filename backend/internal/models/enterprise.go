@@ -106,53 +106,6 @@ type WorkspacePermissions struct {
 	CanExportData      bool `gorm:"default:true" json:"can_export_data"`
 }
 
-// AIInsight represents AI-generated insights for videos and clips
-type AIInsight struct {
-	ID       uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	UserID   uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	
-	// Target resource (one of these will be populated)
-	VideoID  *uuid.UUID `gorm:"type:uuid" json:"video_id"`
-	ClipID   *uuid.UUID `gorm:"type:uuid" json:"clip_id"`
-	PlaylistID *uuid.UUID `gorm:"type:uuid" json:"playlist_id"`
-	
-	// AI insight details
-	Type        AIInsightType `gorm:"not null" json:"type"`
-	Title       string        `gorm:"not null" json:"title"`
-	Description string        `json:"description"`
-	Content     string        `gorm:"type:text" json:"content"`
-	
-	// AI metadata
-	Model       string  `json:"model"`        // AI model used
-	Confidence  float64 `json:"confidence"`   // Confidence score 0-1
-	ProcessingTime int  `json:"processing_time"` // Time taken in ms
-	
-	// User interaction
-	IsUseful    *bool   `json:"is_useful"`     // User feedback
-	UserRating  *int    `json:"user_rating"`   // 1-5 rating
-	UserFeedback string `json:"user_feedback"`
-	
-	// Timestamps
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
-// AIInsightType represents different types of AI insights
-type AIInsightType string
-
-const (
-	AIInsightTypeSummary     AIInsightType = "summary"
-	AIInsightTypeKeyPoints   AIInsightType = "key_points"
-	AIInsightTypeTranscript  AIInsightType = "transcript"
-	AIInsightTypeTopics      AIInsightType = "topics"
-	AIInsightTypeSentiment   AIInsightType = "sentiment"
-	AIInsightTypeLanguage    AIInsightType = "language"
-	AIInsightTypeCategory    AIInsightType = "category"
-	AIInsightTypeSuggestions AIInsightType = "suggestions"
-	AIInsightTypeQuestions   AIInsightType = "questions"
-	AIInsightTypeActionItems AIInsightType = "action_items"
-)
-
 // Notification represents system notifications for users
 type Notification struct {
 	ID         uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
@@ -324,13 +277,6 @@ func (wm *WorkspaceMember) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-func (ai *AIInsight) BeforeCreate(tx *gorm.DB) error {
-	if ai.ID == uuid.Nil {
-		ai.ID = uuid.New()
-	}
-	return nil
-}
-
 func (n *Notification) BeforeCreate(tx *gorm.DB) error {
 	if n.ID == uuid.Nil {
 		n.ID = uuid.New()
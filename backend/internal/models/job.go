@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// JobStatus tracks a background job through the queue
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	// JobStatusDead marks a job that ran out of attempts or failed permanently
+	JobStatusDead JobStatus = "dead"
+)
+
+// Job is a unit of background work persisted in the jobs table and claimed
+// by workers with SELECT ... FOR UPDATE SKIP LOCKED
+type Job struct {
+	bun.BaseModel `bun:"table:jobs,alias:j"`
+
+	ID     uuid.UUID  `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	UserID *uuid.UUID `bun:"user_id,type:uuid" json:"user_id,omitempty"`
+	Type   string     `bun:"type,notnull" json:"type"`
+	Status JobStatus  `bun:"status,notnull" json:"status"`
+	// DedupeKey prevents enqueuing the same work twice while a job with the
+	// key is still queued or running
+	DedupeKey *string         `bun:"dedupe_key" json:"-"`
+	Payload   json.RawMessage `bun:"payload,type:jsonb,notnull" json:"payload"`
+	Result    json.RawMessage `bun:"result,type:jsonb,nullzero" json:"result,omitempty"`
+
+	Attempts    int    `bun:"attempts,notnull,default:0" json:"attempts"`
+	MaxAttempts int    `bun:"max_attempts,notnull" json:"max_attempts"`
+	LastError   string `bun:"last_error,nullzero" json:"last_error,omitempty"`
+
+	// Progress is reported by the handler as it works through Total items
+	Progress int `bun:"progress,notnull,default:0" json:"progress"`
+	Total    int `bun:"total,notnull,default:0" json:"total"`
+
+	RunAt      time.Time  `bun:"run_at,notnull" json:"run_at"`
+	LockedAt   *time.Time `bun:"locked_at" json:"-"`
+	LockedBy   string     `bun:"locked_by,nullzero" json:"-"`
+	StartedAt  *time.Time `bun:"started_at" json:"started_at,omitempty"`
+	FinishedAt *time.Time `bun:"finished_at" json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt  time.Time  `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func (j *Job) BeforeInsert(ctx context.Context) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	now := time.Now()
+	if j.RunAt.IsZero() {
+		j.RunAt = now
+	}
+	j.CreatedAt = now
+	j.UpdatedAt = now
+	return nil
+}
+
+func (j *Job) BeforeUpdate(ctx context.Context) error {
+	j.UpdatedAt = time.Now()
+	return nil
+}
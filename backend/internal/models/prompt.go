@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// PromptTemplate adds or reweights a version of a named prompt without a
+// deploy. A row without a body reweights the embedded template of the same
+// version; weight 0 takes a version out of rotation.
+type PromptTemplate struct {
+	bun.BaseModel `bun:"table:prompt_templates,alias:pt"`
+
+	ID          int64     `bun:"id,pk,autoincrement" json:"id"`
+	Name        string    `bun:"name,notnull" json:"name"`
+	Version     string    `bun:"version,notnull" json:"version"`
+	Body        *string   `bun:"body" json:"body,omitempty"`
+	Weight      int       `bun:"weight,notnull" json:"weight"`
+	Description string    `bun:"description" json:"description"`
+	CreatedAt   time.Time `bun:"created_at,notnull" json:"created_at"`
+	UpdatedAt   time.Time `bun:"updated_at,notnull" json:"updated_at"`
+}
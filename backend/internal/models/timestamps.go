@@ -6,14 +6,22 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pgvector/pgvector-go"
+	"github.com/uptrace/bun"
 )
 
+// Tag is one of a user's tags. Nested tags are named by their full path,
+// such as ml/transformers.
 type Tag struct {
-	ID         uuid.UUID   `json:"id" bun:"id,pk,type:uuid,default:uuid_generate_v4()"`
-	Name       string      `json:"name" bun:"name,notnull,unique"`
-	CreatedAt  time.Time   `json:"created_at" bun:"created_at,notnull"`
-	UpdatedAt  time.Time   `json:"updated_at" bun:"updated_at,notnull"`
-	Timestamps []Timestamp `json:"-" bun:"m2m:timestamp_tags"`
+	ID     uuid.UUID `json:"id" bun:"id,pk,type:uuid,default:uuid_generate_v4()"`
+	UserID uuid.UUID `json:"-" bun:"user_id,type:uuid,notnull"`
+	Name   string    `json:"name" bun:"name,notnull"`
+	// Color is a hex color such as #1e90ff, when set
+	Color       *string     `json:"color" bun:"color"`
+	Description string      `json:"description" bun:"description,notnull"`
+	CreatedAt   time.Time   `json:"created_at" bun:"created_at,notnull"`
+	UpdatedAt   time.Time   `json:"updated_at" bun:"updated_at,notnull"`
+	Timestamps  []Timestamp `json:"-" bun:"m2m:timestamp_tags"`
 }
 
 func (Tag) TableName() string {
@@ -38,18 +46,41 @@ func (t *Tag) BeforeUpdate(ctx context.Context) error {
 	return nil
 }
 
+// Timestamp is a note at an instant of a video, or over the segment from
+// Timestamp to EndTime when EndTime is set
 type Timestamp struct {
-	ID        uuid.UUID `json:"id" bun:"id,pk,type:uuid,default:uuid_generate_v4()"`
-	VideoID   string    `json:"video_id" bun:"video_id,notnull"`
-	UserID    uuid.UUID `json:"user_id" bun:"user_id,type:uuid,notnull"`
-	Timestamp float64   `json:"timestamp" bun:"timestamp,notnull"`
-	Title     string    `json:"title"`
-	Note      string    `json:"note"`
-	Tags      []Tag     `json:"tags" bun:"m2m:timestamp_tags"`
-	Embedding []float32 `json:"-" bun:"embedding,nullzero"`
-	CreatedAt time.Time `json:"created_at" bun:"created_at,notnull"`
-	UpdatedAt time.Time `json:"updated_at" bun:"updated_at,notnull"`
-	DeletedAt time.Time `json:"-" bun:"deleted_at,soft_delete,nullzero"`
+	ID         uuid.UUID `json:"id" bun:"id,pk,type:uuid,default:uuid_generate_v4()"`
+	VideoID    string    `json:"video_id" bun:"video_id,notnull"`
+	UserID     uuid.UUID `json:"user_id" bun:"user_id,type:uuid,notnull"`
+	Timestamp  float64   `json:"timestamp" bun:"timestamp,notnull"`
+	EndTime    *float64  `json:"end_time,omitempty" bun:"end_time"`
+	Type       ClipType  `json:"type" bun:"type,notnull,default:'note'"`
+	Importance int       `json:"importance" bun:"importance,notnull,default:3"` // 1-5 scale
+	Title      string    `json:"title"`
+	Note       string    `json:"note"`
+	Tags       []Tag     `json:"tags" bun:"m2m:timestamp_tags"`
+	CreatedAt  time.Time `json:"created_at" bun:"created_at,notnull"`
+	UpdatedAt  time.Time `json:"updated_at" bun:"updated_at,notnull"`
+	DeletedAt  time.Time `json:"-" bun:"deleted_at,soft_delete,nullzero"`
+	// Context holds the transcript chunks overlapping a range note, when
+	// loaded
+	Context []TranscriptEmbedding `json:"context,omitempty" bun:"-"`
+	// NoteHTML is Note rendered from Markdown, when requested
+	NoteHTML string `json:"note_html,omitempty" bun:"-"`
+}
+
+// TimestampTypes are the clip types a note can have
+var TimestampTypes = []ClipType{ClipTypeNote, ClipTypeHighlight, ClipTypeQuestion, ClipTypeAction}
+
+const DefaultTimestampImportance = 3
+
+// Overlaps reports whether start to end overlaps the note's range or, for a
+// note without one, contains its timestamp
+func (t *Timestamp) Overlaps(start, end float64) bool {
+	if t.EndTime == nil {
+		return start <= t.Timestamp && t.Timestamp < end
+	}
+	return start < *t.EndTime && end > t.Timestamp
 }
 
 func (Timestamp) TableName() string {
@@ -98,6 +129,21 @@ func (tt *TimestampTag) BeforeInsert(ctx context.Context) error {
 	return nil
 }
 
+// TimestampEmbedding is a note's vector from one embedding model. A note has
+// one per model, so a corpus can be re-embedded while the old vectors serve
+// search.
+type TimestampEmbedding struct {
+	bun.BaseModel `bun:"table:timestamp_embeddings,alias:tse"`
+
+	TimestampID uuid.UUID       `json:"timestamp_id" bun:"timestamp_id,pk,type:uuid"`
+	Model       string          `json:"model" bun:"model,pk"`
+	UserID      uuid.UUID       `json:"user_id" bun:"user_id,type:uuid,notnull"`
+	Dimensions  int             `json:"dimensions" bun:"dimensions,notnull"`
+	Embedding   pgvector.Vector `json:"-" bun:"embedding,type:vector,notnull"`
+	CreatedAt   time.Time       `json:"created_at" bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt   time.Time       `json:"updated_at" bun:"updated_at,notnull,default:current_timestamp"`
+}
+
 func (t *Tag) MarshalJSON() ([]byte, error) {
 	type Alias Tag
 	if t.Timestamps == nil {
@@ -127,3 +173,61 @@ func (t *Timestamp) AfterScan(ctx context.Context) error {
 	}
 	return nil
 }
+
+// TimestampRevision is the state of a note after one change to it, made by
+// UserID. ChangedFields lists the fields that differ from the previous
+// revision.
+type TimestampRevision struct {
+	bun.BaseModel `bun:"table:timestamp_revisions,alias:tsr"`
+
+	ID            int64          `json:"-" bun:"id,pk,autoincrement"`
+	TimestampID   uuid.UUID      `json:"timestamp_id" bun:"timestamp_id,type:uuid,notnull"`
+	Revision      int            `json:"revision" bun:"revision,notnull"`
+	UserID        uuid.UUID      `json:"user_id" bun:"user_id,type:uuid,notnull"`
+	Action        RevisionAction `json:"action" bun:"action,notnull"`
+	ChangedFields []string       `json:"changed_fields" bun:"changed_fields,array"`
+	RestoredFrom  *int           `json:"restored_from,omitempty" bun:"restored_from"`
+
+	VideoID    string   `json:"video_id" bun:"video_id,notnull"`
+	Timestamp  float64  `json:"timestamp" bun:"timestamp,notnull"`
+	EndTime    *float64 `json:"end_time,omitempty" bun:"end_time"`
+	Type       ClipType `json:"type" bun:"type,notnull"`
+	Importance int      `json:"importance" bun:"importance,notnull"`
+	Title      string   `json:"title" bun:"title"`
+	Note       string   `json:"note" bun:"note"`
+	Tags       []string `json:"tags" bun:"tags,array"`
+
+	CreatedAt time.Time `json:"created_at" bun:"created_at,notnull"`
+}
+
+// TimestampAttachment is an image attached to a note, referenced from its
+// Markdown as ![alt](attachment:<id>). The content lives in blob storage
+// under StorageKey.
+type TimestampAttachment struct {
+	bun.BaseModel `bun:"table:timestamp_attachments,alias:tsa"`
+
+	ID          uuid.UUID `json:"id" bun:"id,pk,type:uuid,default:uuid_generate_v4()"`
+	TimestampID uuid.UUID `json:"timestamp_id" bun:"timestamp_id,type:uuid,notnull"`
+	UserID      uuid.UUID `json:"user_id" bun:"user_id,type:uuid,notnull"`
+	StorageKey  string    `json:"-" bun:"storage_key,notnull"`
+	Filename    string    `json:"filename" bun:"filename,notnull"`
+	ContentType string    `json:"content_type" bun:"content_type,notnull"`
+	SizeBytes   int64     `json:"size_bytes" bun:"size_bytes,notnull"`
+	Width       int       `json:"width" bun:"width,notnull"`
+	Height      int       `json:"height" bun:"height,notnull"`
+	CreatedAt   time.Time `json:"created_at" bun:"created_at,notnull,default:current_timestamp"`
+
+	// URL is where the content is served from
+	URL string `json:"url" bun:"-"`
+}
+
+// RevisionAction is the change a note revision records
+type RevisionAction string
+
+const (
+	RevisionActionCreated   RevisionAction = "created"
+	RevisionActionUpdated   RevisionAction = "updated"
+	RevisionActionDeleted   RevisionAction = "deleted"
+	RevisionActionUndeleted RevisionAction = "undeleted"
+	RevisionActionRestored  RevisionAction = "restored"
+)
@@ -30,22 +30,32 @@ const (
 	VideoVisibilityShared  VideoVisibility = "shared"
 )
 
-// VideoTranscript represents a video transcript
+// TranscriptSegment is a single caption line of a transcript, in seconds
+type TranscriptSegment struct {
+	Start    float64 `json:"start"`
+	Duration float64 `json:"duration"`
+	Text     string  `json:"text"`
+}
+
+// VideoTranscript is the raw transcript of a YouTube video in one language.
+// Fetched transcripts are keyed by the YouTube video ID and shared across
+// users; uploaded ones belong to the uploader and take precedence for them.
 type VideoTranscript struct {
 	bun.BaseModel `bun:"table:video_transcripts,alias:vt"`
 
-	ID       uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
-	VideoID  uuid.UUID `bun:"video_id,type:uuid,notnull" json:"video_id"`
-	Language string    `bun:"language,notnull" json:"language"`
-	Content  string    `bun:"content,type:text" json:"content"`
-	IsAuto   bool      `bun:"is_auto,default:false" json:"is_auto"` // Auto-generated vs manual
+	ID           uuid.UUID           `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	VideoID      string              `bun:"video_id,notnull" json:"video_id"`           // YouTube video ID
+	UserID       *uuid.UUID          `bun:"user_id,type:uuid" json:"user_id,omitempty"` // Set for user uploads, nil when shared
+	Language     string              `bun:"language,notnull" json:"language"`
+	LanguageName string              `bun:"language_name" json:"language_name"`
+	IsAuto       bool                `bun:"is_auto,default:false" json:"is_auto"` // Auto-generated vs manual
+	Source       string              `bun:"source,notnull,default:'youtube'" json:"source"`
+	Segments     []TranscriptSegment `bun:"segments,type:jsonb,notnull" json:"segments"`
+	FetchedAt    time.Time           `bun:"fetched_at,nullzero,notnull,default:current_timestamp" json:"fetched_at"`
 
 	// Timestamps
 	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
-
-	// Relationships
-	Video *Video `bun:"rel:belongs-to,join:video_id=id" json:"video,omitempty"`
 }
 
 // VideoAnalytics represents video analytics data
@@ -191,3 +201,21 @@ type Video struct {
 	// Relationships
 	User *User `bun:"rel:belongs-to,join:user_id=id" json:"user,omitempty"`
 }
+
+// VideoSummarySection is an intermediate summary of a long video. Level 0
+// summarizes a span of the transcript and each higher level combines
+// consecutive summaries of the level below, so level 0 reads as the video's
+// chapters.
+type VideoSummarySection struct {
+	bun.BaseModel `bun:"table:video_summary_sections,alias:vss"`
+
+	ID           int64     `bun:"id,pk,autoincrement" json:"id"`
+	UserID       uuid.UUID `bun:"user_id,type:uuid,notnull" json:"user_id"`
+	VideoID      string    `bun:"video_id,notnull" json:"video_id"`
+	Level        int       `bun:"level,notnull" json:"level"`
+	SectionIndex int       `bun:"section_index,notnull" json:"section_index"`
+	StartTime    float64   `bun:"start_time,notnull" json:"start_time"`
+	EndTime      float64   `bun:"end_time,notnull" json:"end_time"`
+	Summary      string    `bun:"summary,notnull" json:"summary"`
+	CreatedAt    time.Time `bun:"created_at,notnull" json:"created_at"`
+}
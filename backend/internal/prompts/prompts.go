@@ -0,0 +1,99 @@
+// Package prompts is a registry of named, versioned prompt templates.
+// Versions are embedded in the binary from templates/<name>/<version>.tmpl
+// and can be added or reweighted in the prompt_templates table without a
+// deploy. When several versions of a prompt are in rotation, each user or
+// request is assigned one in proportion to the versions' weights.
+package prompts
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Prompts rendered by the AI features
+const (
+	Summary        = "summary"         // Final summary of a video
+	SummarySection = "summary-section" // Summary of a span of a long transcript
+	SummaryCombine = "summary-combine" // Summary of consecutive section summaries
+	Question       = "question"        // One-off question about notes and transcripts
+	Library        = "library"         // Question across all of a user's videos
+	Chat           = "chat"            // System prompt of a chat session
+	ChatSummary    = "chat-summary"    // Rolling summary of older chat turns
+	Suggestions    = "suggestions"     // Chapter and key moment suggestions
+	Tags           = "tags"            // New tags for a draft note
+)
+
+// Sources of a template version
+const (
+	SourceEmbedded = "embedded"
+	SourceDB       = "db"
+)
+
+// Template is one version of a named prompt
+type Template struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Weight  int    `json:"weight"`
+	Source  string `json:"source"`
+	Body    string `json:"body"`
+
+	tmpl *template.Template
+}
+
+func newTemplate(name, version, body string, weight int, source string) (*Template, error) {
+	tmpl, err := template.New(name + "/" + version).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt %s/%s: %w", name, version, err)
+	}
+
+	return &Template{
+		Name:    name,
+		Version: version,
+		Weight:  weight,
+		Source:  source,
+		Body:    body,
+		tmpl:    tmpl,
+	}, nil
+}
+
+// Render executes the template with data, usually a struct of the prompt's
+// variables
+func (t *Template) Render(data any) (string, error) {
+	var out bytes.Buffer
+	if err := t.tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s/%s: %w", t.Name, t.Version, err)
+	}
+	return out.String(), nil
+}
+
+// Versions maps the names of the prompts behind an AI output to the
+// versions used
+type Versions map[string]string
+
+// Add records the version of t, ignoring a nil template
+func (v Versions) Add(t *Template) {
+	if t != nil {
+		v[t.Name] = t.Version
+	}
+}
+
+// sortVersions orders versions oldest first, comparing the numbers of
+// versions like v2 and v10 numerically
+func sortVersions(templates []*Template) {
+	sort.Slice(templates, func(i, j int) bool {
+		return versionLess(templates[i].Version, templates[j].Version)
+	})
+}
+
+func versionLess(a, b string) bool {
+	an, aErr := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	bn, bErr := strconv.Atoi(strings.TrimPrefix(b, "v"))
+	if aErr == nil && bErr == nil && an != bn {
+		return an < bn
+	}
+	return a < b
+}
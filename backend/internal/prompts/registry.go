@@ -0,0 +1,299 @@
+package prompts
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/config"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+// promptLoadTimeout bounds a reload of prompt_templates
+const promptLoadTimeout = 10 * time.Second
+
+// Assignment modes of PROMPT_ASSIGNMENT
+const (
+	// AssignByUser keeps a user on the same version, so feedback on a
+	// version isn't muddied by users seeing both
+	AssignByUser = "user"
+	// AssignByRequest draws a version for every request
+	AssignByRequest = "request"
+)
+
+// Registry serves the versions of every prompt. It is safe for concurrent
+// use.
+type Registry struct {
+	db       *database.Database
+	cfg      config.PromptConfig
+	embedded map[string][]*Template
+
+	mu        sync.Mutex
+	templates map[string][]*Template
+	loadedAt  time.Time
+	loading   bool       // A reload of prompt_templates is running
+	rand      *rand.Rand // Draws versions assigned per request
+}
+
+// NewRegistry parses the embedded templates. db may be nil to serve only
+// those.
+func NewRegistry(db *database.Database, cfg *config.PromptConfig) (*Registry, error) {
+	if cfg.Assignment != AssignByUser && cfg.Assignment != AssignByRequest {
+		return nil, fmt.Errorf("unknown prompt assignment %q", cfg.Assignment)
+	}
+
+	templates, err := loadEmbedded()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Registry{
+		db:        db,
+		cfg:       *cfg,
+		embedded:  templates,
+		templates: templates,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+func loadEmbedded() (map[string][]*Template, error) {
+	templates := make(map[string][]*Template)
+	err := fs.WalkDir(embedded, "templates", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(file) != ".tmpl" {
+			return err
+		}
+
+		body, err := embedded.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		name := path.Base(path.Dir(file))
+		version := strings.TrimSuffix(path.Base(file), ".tmpl")
+		template, err := newTemplate(name, version, string(body), 1, SourceEmbedded)
+		if err != nil {
+			return err
+		}
+		templates[name] = append(templates[name], template)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, versions := range templates {
+		sortVersions(versions)
+	}
+	return templates, nil
+}
+
+// Assignment is AssignByUser or AssignByRequest
+func (r *Registry) Assignment() string {
+	return r.cfg.Assignment
+}
+
+// Select assigns a version of the named prompt among those in rotation
+func (r *Registry) Select(ctx context.Context, name string, userID uuid.UUID) (*Template, error) {
+	versions := r.current()[name]
+
+	total := 0
+	for _, version := range versions {
+		total += version.Weight
+	}
+	if total == 0 {
+		// Every version was taken out of rotation; serve the newest
+		// embedded one rather than fail
+		return r.fallback(name)
+	}
+
+	var n int
+	if r.cfg.Assignment == AssignByRequest || userID == uuid.Nil {
+		n = r.intn(total)
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write(userID[:])
+		n = int(h.Sum32() % uint32(total))
+	}
+
+	for _, version := range versions {
+		if n < version.Weight {
+			return version, nil
+		}
+		n -= version.Weight
+	}
+	return versions[len(versions)-1], nil
+}
+
+// intn draws from r.rand, which isn't safe for concurrent use on its own
+func (r *Registry) intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rand.Intn(n)
+}
+
+// Render selects a version of the named prompt, renders it with data and
+// adds the version to used, which may be nil. Prompts rendered with the same
+// used keep the version chosen first, so the prompts behind one output
+// don't mix versions. A version from the database that fails to render
+// falls back to the newest embedded one.
+func (r *Registry) Render(ctx context.Context, name string, userID uuid.UUID, data any, used Versions) (string, error) {
+	template := r.version(name, used[name])
+	if template == nil {
+		var err error
+		if template, err = r.Select(ctx, name, userID); err != nil {
+			return "", err
+		}
+	}
+
+	prompt, err := template.Render(data)
+	if err != nil && template.Source == SourceDB {
+		log.Printf("Warning: %v; using the embedded prompt", err)
+		if template, err = r.fallback(name); err == nil {
+			prompt, err = template.Render(data)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if used != nil {
+		used.Add(template)
+	}
+	return prompt, nil
+}
+
+// List returns every version of every prompt, including those out of
+// rotation, by name and version
+func (r *Registry) List(ctx context.Context) []*Template {
+	var templates []*Template
+	for _, versions := range r.current() {
+		templates = append(templates, versions...)
+	}
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// version returns the named version of a prompt, or nil
+func (r *Registry) version(name, version string) *Template {
+	if version == "" {
+		return nil
+	}
+	for _, template := range r.current()[name] {
+		if template.Version == version {
+			return template
+		}
+	}
+	return nil
+}
+
+func (r *Registry) fallback(name string) (*Template, error) {
+	versions := r.embedded[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("unknown prompt %q", name)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// current returns the templates, reloading prompt_templates once
+// RefreshInterval has passed. The reload runs without the lock, so callers
+// meanwhile keep getting the previous templates. A failed reload keeps them
+// until the next interval.
+func (r *Registry) current() map[string][]*Template {
+	r.mu.Lock()
+	fresh := !r.loadedAt.IsZero() && (r.cfg.RefreshInterval <= 0 || time.Since(r.loadedAt) < r.cfg.RefreshInterval)
+	if r.db == nil || r.loading || fresh {
+		templates := r.templates
+		r.mu.Unlock()
+		return templates
+	}
+	r.loading = true
+	r.mu.Unlock()
+
+	// Not the caller's context, so a cancelled request can't fail the reload
+	ctx, cancel := context.WithTimeout(context.Background(), promptLoadTimeout)
+	defer cancel()
+	templates, err := r.loadDB(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loading = false
+	r.loadedAt = time.Now()
+	if err != nil {
+		log.Printf("Failed to load prompt templates: %v", err)
+		return r.templates
+	}
+	r.templates = templates
+	return templates
+}
+
+// loadDB merges the rows of prompt_templates into the embedded templates
+func (r *Registry) loadDB(ctx context.Context) (map[string][]*Template, error) {
+	var rows []models.PromptTemplate
+	err := r.db.DB.NewSelect().
+		Model(&rows).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]map[string]*Template)
+	for name, versions := range r.embedded {
+		byVersion[name] = make(map[string]*Template, len(versions))
+		for _, version := range versions {
+			byVersion[name][version.Version] = version
+		}
+	}
+
+	for _, row := range rows {
+		var template *Template
+		if row.Body == nil {
+			base := byVersion[row.Name][row.Version]
+			if base == nil || base.Source != SourceEmbedded {
+				log.Printf("Warning: prompt %s/%s has no body and no embedded version", row.Name, row.Version)
+				continue
+			}
+			reweighted := *base
+			reweighted.Weight = row.Weight
+			template = &reweighted
+		} else {
+			template, err = newTemplate(row.Name, row.Version, *row.Body, row.Weight, SourceDB)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+		}
+
+		if byVersion[row.Name] == nil {
+			byVersion[row.Name] = make(map[string]*Template)
+		}
+		byVersion[row.Name][row.Version] = template
+	}
+
+	templates := make(map[string][]*Template, len(byVersion))
+	for name, versions := range byVersion {
+		for _, version := range versions {
+			templates[name] = append(templates[name], version)
+		}
+		sortVersions(templates[name])
+	}
+	return templates, nil
+}
@@ -0,0 +1,215 @@
+package prompts
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/config"
+)
+
+// newTestRegistry serves the embedded templates with the versions of name
+// replaced by versions, as if loaded from prompt_templates. Versions drawn
+// per request come from a source seeded with 1.
+func newTestRegistry(t *testing.T, assignment, name string, versions ...*Template) *Registry {
+	t.Helper()
+
+	r, err := NewRegistry(nil, &config.PromptConfig{Assignment: assignment})
+	if err != nil {
+		t.Fatalf("NewRegistry error: %v", err)
+	}
+	r.rand = rand.New(rand.NewSource(1))
+
+	if len(versions) > 0 {
+		templates := make(map[string][]*Template, len(r.embedded))
+		for n, v := range r.embedded {
+			templates[n] = v
+		}
+		sortVersions(versions)
+		templates[name] = versions
+		r.templates = templates
+	}
+	return r
+}
+
+func dbTemplate(t *testing.T, name, version, body string, weight int) *Template {
+	t.Helper()
+
+	template, err := newTemplate(name, version, body, weight, SourceDB)
+	if err != nil {
+		t.Fatalf("newTemplate error: %v", err)
+	}
+	return template
+}
+
+func TestEmbeddedTemplates(t *testing.T) {
+	r := newTestRegistry(t, AssignByUser, "")
+
+	names := []string{Summary, SummarySection, SummaryCombine, Question, Library, Chat, ChatSummary, Suggestions, Tags}
+	for _, name := range names {
+		template, err := r.Select(context.Background(), name, uuid.New())
+		if err != nil {
+			t.Errorf("Select(%q) error: %v", name, err)
+			continue
+		}
+		if template.Source != SourceEmbedded || template.Weight != 1 {
+			t.Errorf("Select(%q) = %s/%s from %s with weight %d, want an embedded version with weight 1", name, template.Name, template.Version, template.Source, template.Weight)
+		}
+	}
+}
+
+func TestSelectByRequestFollowsWeights(t *testing.T) {
+	draw := func() map[string]int {
+		r := newTestRegistry(t, AssignByRequest, Chat,
+			dbTemplate(t, Chat, "v1", "one", 1),
+			dbTemplate(t, Chat, "v2", "two", 3),
+			dbTemplate(t, Chat, "v3", "three", 0),
+		)
+
+		counts := make(map[string]int)
+		for range 4000 {
+			template, err := r.Select(context.Background(), Chat, uuid.New())
+			if err != nil {
+				t.Fatalf("Select error: %v", err)
+			}
+			counts[template.Version]++
+		}
+		return counts
+	}
+
+	counts := draw()
+	if counts["v3"] != 0 {
+		t.Errorf("v3 was selected %d times, want never with weight 0", counts["v3"])
+	}
+	// v2 weighs three times as much as v1
+	if counts["v1"] < 900 || counts["v1"] > 1100 || counts["v2"] < 2900 || counts["v2"] > 3100 {
+		t.Errorf("counts = %v, want about 1000 of v1 and 3000 of v2", counts)
+	}
+
+	// The same seed draws the same versions
+	if again := draw(); again["v1"] != counts["v1"] || again["v2"] != counts["v2"] {
+		t.Errorf("counts = %v, then %v with the same seed", counts, again)
+	}
+}
+
+func TestSelectByUserIsStable(t *testing.T) {
+	r := newTestRegistry(t, AssignByUser, Chat,
+		dbTemplate(t, Chat, "v1", "one", 1),
+		dbTemplate(t, Chat, "v2", "two", 1),
+	)
+	ctx := context.Background()
+
+	counts := make(map[string]int)
+	for range 200 {
+		userID := uuid.New()
+		first, err := r.Select(ctx, Chat, userID)
+		if err != nil {
+			t.Fatalf("Select error: %v", err)
+		}
+		for range 3 {
+			if again, _ := r.Select(ctx, Chat, userID); again != first {
+				t.Fatalf("user %s got %s, then %s", userID, first.Version, again.Version)
+			}
+		}
+		counts[first.Version]++
+	}
+
+	if counts["v1"] == 0 || counts["v2"] == 0 {
+		t.Errorf("counts = %v, want users spread over both versions", counts)
+	}
+}
+
+func TestSelectFallsBackToEmbedded(t *testing.T) {
+	// Every row in prompt_templates is out of rotation
+	r := newTestRegistry(t, AssignByRequest, Chat,
+		dbTemplate(t, Chat, "v1", "one", 0),
+		dbTemplate(t, Chat, "v2", "two", 0),
+	)
+
+	template, err := r.Select(context.Background(), Chat, uuid.Nil)
+	if err != nil {
+		t.Fatalf("Select error: %v", err)
+	}
+	if template.Source != SourceEmbedded || template.Version != "v1" {
+		t.Errorf("Select = %s/%s from %s, want the embedded v1", template.Name, template.Version, template.Source)
+	}
+
+	if _, err := r.Select(context.Background(), "missing", uuid.Nil); err == nil {
+		t.Error("Select of an unknown prompt succeeded, want an error")
+	}
+}
+
+func TestRenderFallsBackOnBrokenDBTemplate(t *testing.T) {
+	r := newTestRegistry(t, AssignByRequest, Chat,
+		dbTemplate(t, Chat, "v2", "{{.Missing}}", 1),
+	)
+
+	used := Versions{}
+	prompt, err := r.Render(context.Background(), Chat, uuid.Nil, struct{ Context string }{"CONTEXT"}, used)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if !strings.HasPrefix(prompt, "You are") || !strings.HasSuffix(prompt, "CONTEXT") {
+		t.Errorf("Render = %q, want the embedded chat prompt", prompt)
+	}
+	if used[Chat] != "v1" {
+		t.Errorf("used = %v, want the embedded v1", used)
+	}
+}
+
+func TestRenderKeepsUsedVersion(t *testing.T) {
+	r := newTestRegistry(t, AssignByRequest, Chat,
+		dbTemplate(t, Chat, "v1", "one", 1),
+		dbTemplate(t, Chat, "v2", "two", 1000),
+	)
+
+	used := Versions{Chat: "v1"}
+	for range 10 {
+		prompt, err := r.Render(context.Background(), Chat, uuid.Nil, nil, used)
+		if err != nil {
+			t.Fatalf("Render error: %v", err)
+		}
+		if prompt != "one" {
+			t.Fatalf("Render = %q, want v1 which was already used", prompt)
+		}
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"v1", "v2", true},
+		{"v2", "v1", false},
+		{"v2", "v10", true},
+		{"v10", "v9", false},
+		{"v1", "v1", false},
+		{"3", "v12", true},
+		{"v01", "v1", true}, // Equal numbers fall back to the names
+		{"v1", "v1-short", true},
+		{"v2-short", "v10", false}, // Only whole numbers compare numerically
+		{"beta", "v1", true},
+	}
+	for _, tt := range tests {
+		if got := versionLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("versionLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortVersions(t *testing.T) {
+	templates := []*Template{{Version: "v10"}, {Version: "v2"}, {Version: "v1"}, {Version: "v9"}}
+	sortVersions(templates)
+
+	var got []string
+	for _, template := range templates {
+		got = append(got, template.Version)
+	}
+	if want := []string{"v1", "v2", "v9", "v10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted = %v, want %v", got, want)
+	}
+}
@@ -11,13 +11,15 @@ import (
 	"github.com/shubhamku044/ytclipper/internal/handlers"
 	authhandlers "github.com/shubhamku044/ytclipper/internal/handlers/auth"
 	"github.com/shubhamku044/ytclipper/internal/handlers/dashboard"
+	jobhandlers "github.com/shubhamku044/ytclipper/internal/handlers/jobs"
 	"github.com/shubhamku044/ytclipper/internal/handlers/subscription"
 	"github.com/shubhamku044/ytclipper/internal/handlers/timestamps"
 	"github.com/shubhamku044/ytclipper/internal/handlers/videos"
+	"github.com/shubhamku044/ytclipper/internal/jobs"
 	"github.com/shubhamku044/ytclipper/internal/middleware"
 )
 
-func SetupRouter(db *database.Database, cfg *config.Config) *gin.Engine {
+func SetupRouter(db *database.Database, cfg *config.Config, jobQueue *jobs.Queue) *gin.Engine {
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
@@ -47,10 +49,11 @@ func SetupRouter(db *database.Database, cfg *config.Config) *gin.Engine {
 	authMiddleware := authhandlers.NewAuthMiddleware(jwtService, &cfg.Auth, db)
 	authHandlers := authhandlers.NewAuthHandlers(authMiddleware, jwtService, emailService, db)
 	oauthHandlers := authhandlers.NewOAuthHandlers(&cfg.Google, &cfg.Auth, jwtService, db, &cfg.Server)
-	timestampHandlers := timestamps.NewTimestampsHandlers(db, &cfg.OpenAI)
+	timestampHandlers := timestamps.NewTimestampsHandlers(db, &cfg.OpenAI, &cfg.AICache, &cfg.Prompts, &cfg.Transcript, &cfg.Storage, &cfg.Jobs, &cfg.Auth, jobQueue)
 	videoHandlers := videos.NewVideoHandlers(db)
 	dashboardHandlers := dashboard.NewDashboardHandlers(db)
 	subscriptionHandlers := subscription.NewSubscriptionHandlers(db)
+	jobHandlers := jobhandlers.NewJobHandlers(jobQueue)
 
 	r.NoRoute(func(c *gin.Context) {
 		middleware.RespondWithError(c, http.StatusNotFound, "NOT_FOUND", "The requested resource could not be found", gin.H{
@@ -102,9 +105,11 @@ func SetupRouter(db *database.Database, cfg *config.Config) *gin.Engine {
 		protected.Use(authMiddleware.RequireAuth())
 		{
 			timestamps.SetupTimestampRoutes(protected, timestampHandlers, authMiddleware)
+			timestamps.SetupSearchRoutes(protected, timestampHandlers, authMiddleware)
 			videos.SetupVideoRoutes(protected, videoHandlers, authMiddleware)
 			dashboard.SetupDashboardRoutes(protected, dashboardHandlers, authMiddleware)
 			subscription.SetupSubscriptionRoutes(protected, subscriptionHandlers, authMiddleware)
+			jobhandlers.SetupJobRoutes(protected, jobHandlers, authMiddleware)
 		}
 	}
 
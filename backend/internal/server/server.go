@@ -9,6 +9,7 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/shubhamku044/ytclipper/internal/config"
 	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/jobs"
 	"github.com/shubhamku044/ytclipper/internal/router"
 )
 
@@ -29,6 +30,7 @@ type Server struct {
 	http   *http.Server
 	config *config.Config
 	db     *database.Database
+	jobs   *jobs.Queue
 }
 
 func NewServer(cfg *config.Config) *Server {
@@ -52,12 +54,19 @@ func NewServer(cfg *config.Config) *Server {
 		gin.SetMode(gin.DebugMode)
 	}
 
-	r := router.SetupRouter(db, cfg)
+	jobQueue := jobs.NewQueue(db, &cfg.Jobs)
+	r := router.SetupRouter(db, cfg, jobQueue)
+
+	// Handlers register their job types while the router is set up
+	if db != nil {
+		jobQueue.Start(context.Background())
+	}
 
 	srv := &Server{
 		router: r,
 		config: cfg,
 		db:     db,
+		jobs:   jobQueue,
 		http: &http.Server{
 			Addr:    ":" + cfg.Server.Port,
 			Handler: r,
@@ -72,6 +81,7 @@ func (s *Server) ListenAndServe() error {
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.jobs.Stop()
 	if s.db != nil {
 		s.db.Close()
 	}
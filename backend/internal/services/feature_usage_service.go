@@ -21,12 +21,17 @@ func NewFeatureUsageService(db *database.Database) *FeatureUsageService {
 }
 
 func (s *FeatureUsageService) IncrementUsage(ctx context.Context, userID uuid.UUID, featureName string, videoID ...string) error {
+	return s.IncrementUsageBy(ctx, userID, featureName, 1)
+}
+
+// IncrementUsageBy records count uses of the feature at once
+func (s *FeatureUsageService) IncrementUsageBy(ctx context.Context, userID uuid.UUID, featureName string, count int) error {
 	now := time.Now().UTC()
 
 	// For all features, use the original logic
 	result, err := s.db.DB.NewUpdate().
 		Model((*models.FeatureUsage)(nil)).
-		Set("current_usage = current_usage + 1").
+		Set("current_usage = current_usage + ?", count).
 		Set("updated_at = ?", now).
 		Where("user_id = ? AND feature_name = ?", userID, featureName).
 		Exec(ctx)
@@ -60,7 +65,7 @@ func (s *FeatureUsageService) IncrementUsage(ctx context.Context, userID uuid.UU
 		featureUsage := &models.FeatureUsage{
 			UserID:       userID,
 			FeatureName:  featureName,
-			CurrentUsage: 1,
+			CurrentUsage: count,
 			UsageLimit:   limit,
 			ResetDate:    &now,
 			CreatedAt:    now,
@@ -94,6 +99,12 @@ func (s *FeatureUsageService) GetUsage(ctx context.Context, userID uuid.UUID, fe
 }
 
 func (s *FeatureUsageService) CheckUsageLimit(ctx context.Context, userID uuid.UUID, featureName string, videoID ...string) (bool, error) {
+	return s.CheckUsageAvailable(ctx, userID, featureName, 1, videoID...)
+}
+
+// CheckUsageAvailable reports whether count more uses of the feature fit
+// within the user's limit
+func (s *FeatureUsageService) CheckUsageAvailable(ctx context.Context, userID uuid.UUID, featureName string, count int, videoID ...string) (bool, error) {
 	// For notes_per_video, we need to check per video
 	if featureName == "notes_per_video" && len(videoID) > 0 {
 		videoSpecificFeature := fmt.Sprintf("notes_per_video_%s", videoID[0])
@@ -122,7 +133,7 @@ func (s *FeatureUsageService) CheckUsageLimit(ctx context.Context, userID uuid.U
 					return true, nil
 				}
 
-				return 0 < limit, nil
+				return count <= limit, nil
 			}
 			return false, err
 		}
@@ -132,7 +143,7 @@ func (s *FeatureUsageService) CheckUsageLimit(ctx context.Context, userID uuid.U
 			return true, nil
 		}
 
-		return usage.CurrentUsage < usage.UsageLimit, nil
+		return usage.CurrentUsage+count <= usage.UsageLimit, nil
 	}
 
 	// For other features, use the original logic
@@ -161,7 +172,7 @@ func (s *FeatureUsageService) CheckUsageLimit(ctx context.Context, userID uuid.U
 				return true, nil
 			}
 
-			return 0 < limit, nil
+			return count <= limit, nil
 		}
 		return false, err
 	}
@@ -171,7 +182,7 @@ func (s *FeatureUsageService) CheckUsageLimit(ctx context.Context, userID uuid.U
 		return true, nil
 	}
 
-	return usage.CurrentUsage < usage.UsageLimit, nil
+	return usage.CurrentUsage+count <= usage.UsageLimit, nil
 }
 
 func (s *FeatureUsageService) ResetUsage(ctx context.Context, userID uuid.UUID) error {
@@ -226,3 +237,14 @@ func getPlanLimits(planType string) map[string]int {
 		}
 	}
 }
+
+// getPlanTokenBudget is the number of AI tokens a plan may use per calendar
+// month; 0 means unlimited
+func getPlanTokenBudget(planType string) int64 {
+	switch planType {
+	case "monthly", "quarterly", "annual":
+		return 5_000_000
+	default:
+		return 200_000
+	}
+}
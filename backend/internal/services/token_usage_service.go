@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shubhamku044/ytclipper/internal/database"
+	"github.com/shubhamku044/ytclipper/internal/llm"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+type tokenUserKey struct{}
+
+// WithTokenUser attributes the AI calls made with the returned context to
+// userID
+func WithTokenUser(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, tokenUserKey{}, userID)
+}
+
+// TokenUser returns the user AI calls made with ctx are attributed to
+func TokenUser(ctx context.Context) (uuid.UUID, bool) {
+	userID, ok := ctx.Value(tokenUserKey{}).(uuid.UUID)
+	return userID, ok && userID != uuid.Nil
+}
+
+// TokenUsageService records the tokens AI calls use per user and enforces
+// the monthly token budgets of plans. A nil *TokenUsageService records
+// nothing and reports unlimited budgets.
+type TokenUsageService struct {
+	db      *database.Database
+	pricing *llm.Pricing
+}
+
+func NewTokenUsageService(db *database.Database, pricing *llm.Pricing) *TokenUsageService {
+	return &TokenUsageService{
+		db:      db,
+		pricing: pricing,
+	}
+}
+
+// Record adds usage of model by feature to the user the context is
+// attributed to. Usage without a user, e.g. from maintenance jobs, is only
+// logged.
+func (s *TokenUsageService) Record(ctx context.Context, feature, model string, usage llm.Usage) {
+	if s == nil || usage.TotalTokens == 0 {
+		return
+	}
+
+	userID, ok := TokenUser(ctx)
+	if !ok {
+		log.Printf("Unattributed AI usage: %s/%s used %d tokens", feature, model, usage.TotalTokens)
+		return
+	}
+
+	// Tokens were spent even if the request that spent them was cancelled
+	if err := s.record(context.WithoutCancel(ctx), userID, feature, model, usage); err != nil {
+		log.Printf("Failed to record AI usage for user %s: %v", userID, err)
+	}
+}
+
+func (s *TokenUsageService) record(ctx context.Context, userID uuid.UUID, feature, model string, usage llm.Usage) error {
+	now := time.Now().UTC()
+	row := &models.AITokenUsage{
+		UserID:           userID,
+		UsageDate:        now.Truncate(24 * time.Hour),
+		Feature:          feature,
+		Model:            model,
+		Requests:         1,
+		PromptTokens:     int64(usage.PromptTokens),
+		CompletionTokens: int64(usage.CompletionTokens),
+		TotalTokens:      int64(usage.TotalTokens),
+		CostUSD:          s.pricing.Cost(model, usage),
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if usage.Estimated {
+		row.EstimatedTokens = row.TotalTokens
+	}
+
+	_, err := s.db.DB.NewInsert().
+		Model(row).
+		On("CONFLICT (user_id, usage_date, feature, model) DO UPDATE").
+		Set("requests = atu.requests + EXCLUDED.requests").
+		Set("prompt_tokens = atu.prompt_tokens + EXCLUDED.prompt_tokens").
+		Set("completion_tokens = atu.completion_tokens + EXCLUDED.completion_tokens").
+		Set("total_tokens = atu.total_tokens + EXCLUDED.total_tokens").
+		Set("estimated_tokens = atu.estimated_tokens + EXCLUDED.estimated_tokens").
+		Set("cost_usd = atu.cost_usd + EXCLUDED.cost_usd").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	return err
+}
+
+// TokenBudget is a user's token spend in the current calendar month (UTC)
+// against their plan's budget
+type TokenBudget struct {
+	PlanType    string    `json:"plan_type"`
+	Limit       int64     `json:"limit"` // 0 means unlimited
+	Used        int64     `json:"used"`
+	Remaining   int64     `json:"remaining"`
+	CostUSD     float64   `json:"cost_usd"`
+	PeriodStart time.Time `json:"period_start"`
+	ResetsAt    time.Time `json:"resets_at"`
+}
+
+// Exceeded reports whether the budget is used up
+func (b *TokenBudget) Exceeded() bool {
+	return b.Limit > 0 && b.Used >= b.Limit
+}
+
+// Budget returns the user's token spend this month against their plan's
+// monthly budget
+func (s *TokenUsageService) Budget(ctx context.Context, userID uuid.UUID) (*TokenBudget, error) {
+	now := time.Now().UTC()
+	budget := &TokenBudget{
+		PlanType:    "free",
+		PeriodStart: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC),
+	}
+	budget.ResetsAt = budget.PeriodStart.AddDate(0, 1, 0)
+	if s == nil {
+		return budget, nil
+	}
+
+	var subscription models.Subscription
+	err := s.db.DB.NewSelect().
+		Model(&subscription).
+		Column("plan_type").
+		Where("user_id = ? AND status = 'active'", userID).
+		Order("created_at DESC").
+		Limit(1).
+		Scan(ctx)
+	if err == nil {
+		budget.PlanType = subscription.PlanType
+	}
+	budget.Limit = getPlanTokenBudget(budget.PlanType)
+
+	err = s.db.DB.NewSelect().
+		Model((*models.AITokenUsage)(nil)).
+		ColumnExpr("COALESCE(SUM(total_tokens), 0)").
+		ColumnExpr("COALESCE(SUM(cost_usd), 0)").
+		Where("user_id = ?", userID).
+		Where("usage_date >= ?", budget.PeriodStart).
+		Scan(ctx, &budget.Used, &budget.CostUSD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum token usage: %w", err)
+	}
+
+	if budget.Limit > 0 {
+		budget.Remaining = max(budget.Limit-budget.Used, 0)
+	}
+	return budget, nil
+}
+
+// Spend intervals and breakdowns accepted by TokenSpendFilter
+const (
+	SpendIntervalDay   = "day"
+	SpendIntervalMonth = "month"
+
+	SpendByFeature = "feature"
+	SpendByModel   = "model"
+)
+
+// TokenSpendFilter selects the spend reported by Spend
+type TokenSpendFilter struct {
+	From     time.Time // Inclusive
+	To       time.Time // Exclusive
+	Interval string    // SpendIntervalDay or SpendIntervalMonth
+	By       string    // Optional SpendByFeature or SpendByModel breakdown
+}
+
+// TokenSpend is the usage of one interval, and of one feature or model when
+// broken down
+type TokenSpend struct {
+	Period           time.Time `bun:"period" json:"period"`
+	Feature          string    `bun:"feature" json:"feature,omitempty"`
+	Model            string    `bun:"model" json:"model,omitempty"`
+	Requests         int64     `bun:"requests" json:"requests"`
+	PromptTokens     int64     `bun:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int64     `bun:"completion_tokens" json:"completion_tokens"`
+	TotalTokens      int64     `bun:"total_tokens" json:"total_tokens"`
+	EstimatedTokens  int64     `bun:"estimated_tokens" json:"estimated_tokens"`
+	CostUSD          float64   `bun:"cost_usd" json:"cost_usd"`
+}
+
+// Spend returns the user's usage per interval, oldest first
+func (s *TokenUsageService) Spend(ctx context.Context, userID uuid.UUID, filter TokenSpendFilter) ([]TokenSpend, error) {
+	spend := []TokenSpend{}
+	if s == nil {
+		return spend, nil
+	}
+	if filter.Interval != SpendIntervalDay && filter.Interval != SpendIntervalMonth {
+		return nil, fmt.Errorf("unknown interval %q", filter.Interval)
+	}
+
+	query := s.db.DB.NewSelect().
+		Model((*models.AITokenUsage)(nil)).
+		ColumnExpr("date_trunc(?, usage_date)::date AS period", filter.Interval).
+		ColumnExpr("SUM(requests) AS requests").
+		ColumnExpr("SUM(prompt_tokens) AS prompt_tokens").
+		ColumnExpr("SUM(completion_tokens) AS completion_tokens").
+		ColumnExpr("SUM(total_tokens) AS total_tokens").
+		ColumnExpr("SUM(estimated_tokens) AS estimated_tokens").
+		ColumnExpr("SUM(cost_usd) AS cost_usd").
+		Where("user_id = ?", userID).
+		Where("usage_date >= ?", filter.From).
+		Where("usage_date < ?", filter.To).
+		GroupExpr("period").
+		OrderExpr("period ASC")
+
+	switch filter.By {
+	case "":
+	case SpendByFeature, SpendByModel:
+		query = query.Column(filter.By).Group(filter.By).Order(filter.By)
+	default:
+		return nil, fmt.Errorf("unknown breakdown %q", filter.By)
+	}
+
+	if err := query.Scan(ctx, &spend); err != nil {
+		return nil, fmt.Errorf("failed to load token spend: %w", err)
+	}
+	return spend, nil
+}
@@ -0,0 +1,42 @@
+// Package storage stores binary objects, such as note attachments, behind an
+// interface so the backend can be swapped without touching callers.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/shubhamku044/ytclipper/internal/config"
+)
+
+const (
+	DriverLocal = "local"
+)
+
+// ErrNotFound is returned when no object is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore is implemented by every object storage backend. Keys are
+// slash-separated paths made of letters, digits, '-', '_' and '.'.
+type BlobStore interface {
+	// Put stores the content of r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the object stored under key. The caller closes it.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key. Deleting a missing
+	// object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore builds the store selected by cfg.Driver
+func NewBlobStore(cfg *config.StorageConfig) (BlobStore, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "", DriverLocal:
+		return NewLocalStore(cfg.LocalPath)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
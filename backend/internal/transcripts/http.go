@@ -0,0 +1,104 @@
+package transcripts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// maxHTTPTranscriptSize is the largest transcript response read
+const maxHTTPTranscriptSize = 20 << 20
+
+// HTTPSource fetches transcripts from an external service at
+// GET {baseURL}/{videoID}?language={language}. The service may answer with
+// JSON ({"language", "language_name", "is_auto", "segments"}) or with an
+// SRT, WebVTT or timestamped text body.
+type HTTPSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+type httpTranscriptResponse struct {
+	Language     string                     `json:"language"`
+	LanguageName string                     `json:"language_name"`
+	IsAuto       bool                       `json:"is_auto"`
+	Segments     []models.TranscriptSegment `json:"segments"`
+}
+
+func NewHTTPSource(baseURL string, timeout time.Duration) *HTTPSource {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &HTTPSource{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *HTTPSource) Name() string {
+	return SourceHTTP
+}
+
+func (s *HTTPSource) Fetch(ctx context.Context, videoID, language string) (*models.VideoTranscript, error) {
+	endpoint := s.baseURL + "/" + url.PathEscape(videoID)
+	if language != "" {
+		endpoint += "?language=" + url.QueryEscape(language)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcript request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transcript for video %s: %w", videoID, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPTranscriptSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript response: %w", err)
+	}
+	if len(body) > maxHTTPTranscriptSize {
+		return nil, fmt.Errorf("transcript response for video %s is larger than %d bytes", videoID, maxHTTPTranscriptSize)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: transcript service has no transcript for video %s", ErrNoTranscript, videoID)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transcript service returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		var payload httpTranscriptResponse
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("failed to decode transcript response: %w", err)
+		}
+		if len(payload.Segments) == 0 {
+			return nil, fmt.Errorf("%w: transcript service returned no segments for video %s", ErrNoTranscript, videoID)
+		}
+		if payload.Language == "" {
+			payload.Language = language
+		}
+
+		transcript := newTranscript(videoID, payload.Language, SourceHTTP, payload.Segments)
+		transcript.LanguageName = payload.LanguageName
+		transcript.IsAuto = payload.IsAuto
+		return transcript, nil
+	}
+
+	segments, err := Parse(DetectFormat("", body), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse transcript response: %w", err)
+	}
+	return newTranscript(videoID, language, SourceHTTP, segments), nil
+}
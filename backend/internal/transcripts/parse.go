@@ -0,0 +1,196 @@
+package transcripts
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/shubhamku044/ytclipper/internal/clock"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+const (
+	FormatSRT  = "srt"
+	FormatVTT  = "vtt"
+	FormatText = "text"
+)
+
+var (
+	// "00:01:02,500 --> 00:01:05,000" (SRT) or "01:02.500 --> 01:05.000 align:start" (WebVTT)
+	cueTimingPattern = regexp.MustCompile(`^((?:\d+:)?\d{1,2}:\d{2}(?:[.,]\d{1,3})?)\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}(?:[.,]\d{1,3})?)`)
+	// "[00:01:02] text", "1:02 text" or "(01:02) - text"
+	textLinePattern = regexp.MustCompile(`^[\[(]?((?:\d+:)?\d{1,2}:\d{2}(?:[.,]\d{1,3})?)[\])]?\s*[-–:]?\s*(.*)$`)
+	markupPattern   = regexp.MustCompile(`<[^>]*>`)
+)
+
+const utf8BOM = "\ufeff"
+
+// DetectFormat guesses the transcript format from the file name and contents
+func DetectFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".srt":
+		return FormatSRT
+	case ".vtt":
+		return FormatVTT
+	}
+
+	content := strings.TrimSpace(strings.TrimPrefix(string(data), utf8BOM))
+	if strings.HasPrefix(content, "WEBVTT") {
+		return FormatVTT
+	}
+	if cueTimingPattern.MatchString(secondLine(content)) {
+		return FormatSRT
+	}
+	return FormatText
+}
+
+// Parse converts a transcript file of the given format into segments
+func Parse(format string, data []byte) ([]models.TranscriptSegment, error) {
+	content := strings.ReplaceAll(strings.TrimPrefix(string(data), utf8BOM), "\r\n", "\n")
+
+	var segments []models.TranscriptSegment
+	var err error
+	switch format {
+	case FormatSRT, FormatVTT:
+		segments, err = parseCues(content)
+	case FormatText:
+		segments, err = parseTimestampedText(content)
+	default:
+		return nil, fmt.Errorf("unsupported transcript format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("%w: %s file contains no segments", ErrNoTranscript, format)
+	}
+	return segments, nil
+}
+
+// parseCues reads SRT and WebVTT files, which share the same cue layout:
+// an optional identifier line, a timing line, then one or more text lines
+func parseCues(content string) ([]models.TranscriptSegment, error) {
+	var segments []models.TranscriptSegment
+
+	for _, lines := range splitBlocks(content) {
+		timing := -1
+		for i, line := range lines {
+			if cueTimingPattern.MatchString(strings.TrimSpace(line)) {
+				timing = i
+				break
+			}
+		}
+		// Headers, NOTE, STYLE and REGION blocks have no timing line
+		if timing == -1 {
+			continue
+		}
+
+		match := cueTimingPattern.FindStringSubmatch(strings.TrimSpace(lines[timing]))
+		start, err := clock.Parse(match[1])
+		if err != nil {
+			return nil, err
+		}
+		end, err := clock.Parse(match[2])
+		if err != nil {
+			return nil, err
+		}
+
+		text := cleanCueText(lines[timing+1:])
+		if text == "" {
+			continue
+		}
+
+		segments = append(segments, models.TranscriptSegment{
+			Start:    start,
+			Duration: max(end-start, 0),
+			Text:     text,
+		})
+	}
+
+	return segments, nil
+}
+
+// parseTimestampedText reads plain text where lines start with a timestamp.
+// Lines without one continue the previous segment.
+func parseTimestampedText(content string) ([]models.TranscriptSegment, error) {
+	var segments []models.TranscriptSegment
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		match := textLinePattern.FindStringSubmatch(line)
+		if match == nil {
+			if len(segments) > 0 {
+				segments[len(segments)-1].Text += " " + line
+			}
+			continue
+		}
+
+		start, err := clock.Parse(match[1])
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, models.TranscriptSegment{
+			Start: start,
+			Text:  strings.TrimSpace(match[2]),
+		})
+	}
+
+	// Each line lasts until the next one starts
+	for i := 0; i+1 < len(segments); i++ {
+		segments[i].Duration = max(segments[i+1].Start-segments[i].Start, 0)
+	}
+
+	kept := segments[:0]
+	for _, segment := range segments {
+		if segment.Text != "" {
+			kept = append(kept, segment)
+		}
+	}
+	return kept, nil
+}
+
+func cleanCueText(lines []string) string {
+	var parts []string
+	for _, line := range lines {
+		line = strings.TrimSpace(markupPattern.ReplaceAllString(line, ""))
+		if line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitBlocks groups lines into blocks separated by lines that are empty or
+// hold only whitespace
+func splitBlocks(content string) [][]string {
+	var blocks [][]string
+	var block []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(block) > 0 {
+				blocks = append(blocks, block)
+				block = nil
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if len(block) > 0 {
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func secondLine(content string) string {
+	lines := strings.SplitN(content, "\n", 3)
+	if len(lines) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(lines[1])
+}
@@ -0,0 +1,142 @@
+package transcripts
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		want     string
+	}{
+		{"srt extension", "talk.SRT", "anything", FormatSRT},
+		{"vtt extension", "talk.vtt", "anything", FormatVTT},
+		{"vtt header", "talk.txt", "\ufeffWEBVTT\n\n00:01.000 --> 00:02.000\nHi", FormatVTT},
+		{"srt timing on the second line", "", "\ufeff1\r\n00:00:01,000 --> 00:00:02,000\r\nHi", FormatSRT},
+		{"timestamped text", "notes.txt", "[00:01] Hi\n[00:02] There", FormatText},
+		{"empty", "", "", FormatText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.filename, []byte(tt.data)); got != tt.want {
+				t.Errorf("DetectFormat(%q) = %q, want %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   []models.TranscriptSegment
+	}{
+		{
+			name:   "srt with bom and crlf",
+			format: FormatSRT,
+			data:   "\ufeff1\r\n00:00:01,000 --> 00:00:03,500\r\nHello <i>world</i>\r\n\r\n2\r\n00:00:04,000 --> 00:00:06,000\r\nSecond line\r\ncontinues\r\n",
+			want: []models.TranscriptSegment{
+				{Start: 1, Duration: 2.5, Text: "Hello world"},
+				{Start: 4, Duration: 2, Text: "Second line continues"},
+			},
+		},
+		{
+			name:   "srt blocks separated by whitespace",
+			format: FormatSRT,
+			data:   "1\n00:00:01,000 --> 00:00:02,000\nFirst\n \t\n2\n00:00:03,000 --> 00:00:04,000\nSecond\n\n\n\n",
+			want: []models.TranscriptSegment{
+				{Start: 1, Duration: 1, Text: "First"},
+				{Start: 3, Duration: 1, Text: "Second"},
+			},
+		},
+		{
+			name:   "srt end before start",
+			format: FormatSRT,
+			data:   "1\n00:00:05,000 --> 00:00:04,000\nBackwards",
+			want:   []models.TranscriptSegment{{Start: 5, Duration: 0, Text: "Backwards"}},
+		},
+		{
+			name:   "vtt with header, note, style and cue settings",
+			format: FormatVTT,
+			data: "WEBVTT - Lecture\nKind: captions\n\n" +
+				"NOTE This is a comment\nspanning lines\n\n" +
+				"STYLE\n::cue { color: yellow }\n\n" +
+				"intro\n00:01.000 --> 00:04.000 align:start position:10%\n<v Speaker>Welcome</v> to <b>the</b> talk\n\n" +
+				"00:02.000 --> 00:03.000\n<i></i>\n\n" +
+				"01:00:05.250 --> 01:00:07.000\n<c.yellow>Bye</c>\n",
+			want: []models.TranscriptSegment{
+				{Start: 1, Duration: 3, Text: "Welcome to the talk"},
+				{Start: 3605.25, Duration: 1.75, Text: "Bye"},
+			},
+		},
+		{
+			name:   "timestamped text",
+			format: FormatText,
+			data: "Transcript of the talk\n" +
+				"[00:05] Intro\n" +
+				"(1:00:10) - Deep dive\n" +
+				"  still talking  \n" +
+				"\n" +
+				"1:15:00: Wrap up\n" +
+				"[1:15:30]\n",
+			want: []models.TranscriptSegment{
+				{Start: 5, Duration: 3605, Text: "Intro"},
+				{Start: 3610, Duration: 890, Text: "Deep dive still talking"},
+				{Start: 4500, Duration: 30, Text: "Wrap up"},
+			},
+		},
+		{
+			name:   "text with fractional seconds",
+			format: FormatText,
+			data:   "00:01.5 – One\r\n00:03,5 Two",
+			want: []models.TranscriptSegment{
+				{Start: 1.5, Duration: 2, Text: "One"},
+				{Start: 3.5, Duration: 0, Text: "Two"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.format, []byte(tt.data))
+			if err != nil {
+				t.Fatalf("Parse error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNoTranscript(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"empty srt", FormatSRT, ""},
+		{"vtt without cues", FormatVTT, "WEBVTT\n\nNOTE nothing here\n"},
+		{"cues without text", FormatVTT, "WEBVTT\n\n00:01.000 --> 00:02.000\n<i> </i>\n"},
+		{"text without timestamps", FormatText, "Just some notes\nwithout any times\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.format, []byte(tt.data)); !errors.Is(err, ErrNoTranscript) {
+				t.Errorf("Parse error = %v, want ErrNoTranscript", err)
+			}
+		})
+	}
+}
+
+func TestParseUnsupportedFormat(t *testing.T) {
+	if _, err := Parse("docx", []byte("[00:01] Hi")); err == nil || errors.Is(err, ErrNoTranscript) {
+		t.Errorf("Parse error = %v, want an unsupported format error", err)
+	}
+}
@@ -0,0 +1,65 @@
+// Package transcripts acquires video transcripts from YouTube captions,
+// uploaded subtitle files and external HTTP services
+package transcripts
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shubhamku044/ytclipper/internal/config"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+const (
+	SourceYouTube = "youtube"
+	SourceHTTP    = "http"
+	SourceUpload  = "upload"
+)
+
+// ErrNoTranscript is returned when a source has no transcript for a video
+var ErrNoTranscript = errors.New("no transcript available")
+
+// Source fetches the transcript of a video. An empty language lets the
+// source pick its best track.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context, videoID, language string) (*models.VideoTranscript, error)
+}
+
+// Language describes a transcript language a source can provide
+type Language struct {
+	Language string `json:"language"`
+	Name     string `json:"name"`
+	IsAuto   bool   `json:"is_auto"`
+}
+
+// LanguageLister is implemented by sources that can enumerate their languages
+type LanguageLister interface {
+	ListLanguages(ctx context.Context, videoID string) ([]Language, error)
+}
+
+// NewSources returns the shared sources in the order they should be tried
+func NewSources(cfg *config.TranscriptConfig) []Source {
+	sources := []Source{NewYouTubeSource()}
+	if cfg != nil && cfg.HTTPSourceURL != "" {
+		sources = append(sources, NewHTTPSource(cfg.HTTPSourceURL, cfg.HTTPSourceTimeout))
+	}
+	return sources
+}
+
+// LanguageUndetermined is stored when a source does not report a language
+const LanguageUndetermined = "und"
+
+func newTranscript(videoID, language, source string, segments []models.TranscriptSegment) *models.VideoTranscript {
+	if language == "" {
+		language = LanguageUndetermined
+	}
+	return &models.VideoTranscript{
+		VideoID:   videoID,
+		Language:  language,
+		Source:    source,
+		Segments:  segments,
+		FetchedAt: time.Now().UTC(),
+	}
+}
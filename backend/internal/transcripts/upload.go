@@ -0,0 +1,49 @@
+package transcripts
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// UploadSource serves a transcript file supplied by a user, such as an SRT
+// or WebVTT export or a pasted plain-text transcript with timestamps
+type UploadSource struct {
+	format   string
+	language string
+	data     []byte
+}
+
+// NewUploadSource wraps uploaded transcript data. An empty format is
+// detected from the file name and contents.
+func NewUploadSource(filename, format, language string, data []byte) *UploadSource {
+	if format == "" {
+		format = DetectFormat(filename, data)
+	}
+	return &UploadSource{
+		format:   format,
+		language: language,
+		data:     data,
+	}
+}
+
+func (s *UploadSource) Name() string {
+	return SourceUpload
+}
+
+func (s *UploadSource) Format() string {
+	return s.format
+}
+
+func (s *UploadSource) Fetch(ctx context.Context, videoID, language string) (*models.VideoTranscript, error) {
+	segments, err := Parse(s.format, s.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s transcript: %w", s.format, err)
+	}
+
+	if language == "" {
+		language = s.language
+	}
+	return newTranscript(videoID, language, SourceUpload, segments), nil
+}
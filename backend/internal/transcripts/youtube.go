@@ -0,0 +1,123 @@
+package transcripts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kkdai/youtube/v2"
+	"github.com/shubhamku044/ytclipper/internal/models"
+)
+
+// YouTubeSource reads the caption tracks YouTube publishes for a video
+type YouTubeSource struct{}
+
+func NewYouTubeSource() *YouTubeSource {
+	return &YouTubeSource{}
+}
+
+func (s *YouTubeSource) Name() string {
+	return SourceYouTube
+}
+
+func (s *YouTubeSource) Fetch(ctx context.Context, videoID, language string) (*models.VideoTranscript, error) {
+	client := youtube.Client{}
+
+	video, err := client.GetVideoContext(ctx, videoID)
+	if err != nil {
+		return nil, videoError(videoID, err)
+	}
+
+	if len(video.CaptionTracks) == 0 {
+		return nil, fmt.Errorf("%w: no captions available for video %s", ErrNoTranscript, videoID)
+	}
+
+	tracks := orderCaptionTracks(video.CaptionTracks, language)
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("%w: no %s captions available for video %s", ErrNoTranscript, language, videoID)
+	}
+
+	var transcriptErr error
+	for _, track := range tracks {
+		entries, err := client.GetTranscriptCtx(ctx, video, track.LanguageCode)
+		if err != nil {
+			transcriptErr = err
+			continue
+		}
+
+		segments := make([]models.TranscriptSegment, 0, len(entries))
+		for _, entry := range entries {
+			text := strings.TrimSpace(entry.Text)
+			if text == "" {
+				continue
+			}
+			segments = append(segments, models.TranscriptSegment{
+				Start:    float64(entry.StartMs) / 1000.0,
+				Duration: float64(entry.Duration) / 1000.0,
+				Text:     text,
+			})
+		}
+
+		if len(segments) == 0 {
+			transcriptErr = fmt.Errorf("%w: transcript is empty for video %s", ErrNoTranscript, videoID)
+			continue
+		}
+
+		transcript := newTranscript(videoID, track.LanguageCode, SourceYouTube, segments)
+		transcript.LanguageName = track.Name.SimpleText
+		transcript.IsAuto = track.Kind == "asr"
+		return transcript, nil
+	}
+
+	return nil, fmt.Errorf("failed to get transcript for video %s: %w", videoID, transcriptErr)
+}
+
+func (s *YouTubeSource) ListLanguages(ctx context.Context, videoID string) ([]Language, error) {
+	client := youtube.Client{}
+
+	video, err := client.GetVideoContext(ctx, videoID)
+	if err != nil {
+		return nil, videoError(videoID, err)
+	}
+
+	languages := make([]Language, 0, len(video.CaptionTracks))
+	for _, track := range video.CaptionTracks {
+		languages = append(languages, Language{
+			Language: track.LanguageCode,
+			Name:     track.Name.SimpleText,
+			IsAuto:   track.Kind == "asr",
+		})
+	}
+
+	return languages, nil
+}
+
+// orderCaptionTracks returns the tracks worth trying, best first: tracks in
+// the requested language (if any) with manual captions ahead of auto-generated ones
+func orderCaptionTracks(tracks []youtube.CaptionTrack, language string) []youtube.CaptionTrack {
+	var manual, auto []youtube.CaptionTrack
+	for _, track := range tracks {
+		if !MatchesLanguage(track.LanguageCode, language) {
+			continue
+		}
+		if track.Kind == "asr" {
+			auto = append(auto, track)
+		} else {
+			manual = append(manual, track)
+		}
+	}
+	return append(manual, auto...)
+}
+
+// MatchesLanguage reports whether code satisfies the requested language.
+// "en" also matches regional codes such as "en-US"; an empty request matches anything.
+func MatchesLanguage(code, requested string) bool {
+	return requested == "" || code == requested || strings.HasPrefix(code, requested+"-")
+}
+
+func videoError(videoID string, err error) error {
+	if strings.Contains(err.Error(), "400") {
+		return fmt.Errorf("video not accessible (400 error) - possible reasons: private video, region-restricted, or transcript disabled for video %s", videoID)
+	}
+	return fmt.Errorf("failed to get video info for video %s: %w", videoID, err)
+}
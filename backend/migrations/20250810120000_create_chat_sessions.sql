@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Persisted multi-turn Q&A sessions per user and video
+CREATE TABLE IF NOT EXISTS chat_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    video_id VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    summary TEXT,
+    summarized_count INTEGER NOT NULL DEFAULT 0,
+    message_count INTEGER NOT NULL DEFAULT 0,
+    last_message_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_sessions_user_video
+ON chat_sessions(user_id, video_id) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_chat_sessions_last_message_at
+ON chat_sessions(user_id, last_message_at DESC);
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    session_id UUID NOT NULL REFERENCES chat_sessions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('user', 'assistant')),
+    content TEXT NOT NULL,
+    citations JSONB,
+    token_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    -- Positions are unique and ordered within a session
+    UNIQUE(session_id, position)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_messages;
+DROP INDEX IF EXISTS idx_chat_sessions_last_message_at;
+DROP INDEX IF EXISTS idx_chat_sessions_user_video;
+DROP TABLE IF EXISTS chat_sessions;
+-- +goose StatementEnd
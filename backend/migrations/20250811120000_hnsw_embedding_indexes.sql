@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- The ivfflat indexes were built on empty tables, so their lists never fit the
+-- data. HNSW needs no training step and keeps recall as rows are added.
+DROP INDEX IF EXISTS idx_timestamps_embedding;
+CREATE INDEX IF NOT EXISTS idx_timestamps_embedding_hnsw
+ON timestamps USING hnsw (embedding vector_cosine_ops);
+
+DROP INDEX IF EXISTS idx_transcript_embeddings_vector;
+CREATE INDEX IF NOT EXISTS idx_transcript_embeddings_embedding_hnsw
+ON transcript_embeddings USING hnsw (embedding vector_cosine_ops);
+
+-- Filters applied alongside vector search
+CREATE INDEX IF NOT EXISTS idx_timestamps_user_video_created
+ON timestamps(user_id, video_id, created_at) WHERE deleted_at IS NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_timestamps_user_video_created;
+
+DROP INDEX IF EXISTS idx_transcript_embeddings_embedding_hnsw;
+CREATE INDEX IF NOT EXISTS idx_transcript_embeddings_vector
+ON transcript_embeddings USING ivfflat (embedding vector_cosine_ops);
+
+DROP INDEX IF EXISTS idx_timestamps_embedding_hnsw;
+CREATE INDEX IF NOT EXISTS idx_timestamps_embedding
+ON timestamps USING ivfflat (embedding vector_cosine_ops);
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Full-text search vectors used alongside embeddings by the unified search
+ALTER TABLE timestamps
+ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english'::regconfig, COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english'::regconfig, COALESCE(note, '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_timestamps_search_vector
+ON timestamps USING gin (search_vector);
+
+ALTER TABLE transcript_embeddings
+ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('english'::regconfig, COALESCE(text, ''))
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_transcript_embeddings_search_vector
+ON transcript_embeddings USING gin (search_vector);
+
+ALTER TABLE videos
+ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    to_tsvector('english'::regconfig, COALESCE(title, ''))
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_videos_search_vector
+ON videos USING gin (search_vector);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_videos_search_vector;
+ALTER TABLE videos DROP COLUMN IF EXISTS search_vector;
+
+DROP INDEX IF EXISTS idx_transcript_embeddings_search_vector;
+ALTER TABLE transcript_embeddings DROP COLUMN IF EXISTS search_vector;
+
+DROP INDEX IF EXISTS idx_timestamps_search_vector;
+ALTER TABLE timestamps DROP COLUMN IF EXISTS search_vector;
+-- +goose StatementEnd
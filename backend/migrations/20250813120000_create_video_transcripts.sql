@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Raw, segment-level transcripts stored once per YouTube video and language
+CREATE TABLE IF NOT EXISTS video_transcripts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    video_id VARCHAR(255) NOT NULL,
+    language VARCHAR(35) NOT NULL,
+    language_name VARCHAR(255),
+    is_auto BOOLEAN DEFAULT FALSE,
+    source VARCHAR(50) NOT NULL DEFAULT 'youtube',
+    segments JSONB NOT NULL DEFAULT '[]'::jsonb,
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(video_id, language)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS video_transcripts;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Uploaded transcripts belong to a user; fetched ones stay shared (user_id NULL)
+ALTER TABLE video_transcripts
+ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE video_transcripts DROP CONSTRAINT IF EXISTS video_transcripts_video_id_language_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_video_transcripts_shared
+ON video_transcripts(video_id, language) WHERE user_id IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_video_transcripts_user
+ON video_transcripts(video_id, language, user_id) WHERE user_id IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM video_transcripts WHERE user_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_video_transcripts_user;
+DROP INDEX IF EXISTS idx_video_transcripts_shared;
+
+ALTER TABLE video_transcripts ADD CONSTRAINT video_transcripts_video_id_language_key UNIQUE (video_id, language);
+ALTER TABLE video_transcripts DROP COLUMN IF EXISTS user_id;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Chunks record the versioned strategy that produced them so a video can
+-- hold chunks from several strategies for comparison
+ALTER TABLE transcript_embeddings
+ADD COLUMN IF NOT EXISTS chunk_strategy VARCHAR(50) NOT NULL DEFAULT 'segment-v1',
+ADD COLUMN IF NOT EXISTS token_count INT NOT NULL DEFAULT 0;
+
+ALTER TABLE transcript_embeddings ALTER COLUMN chunk_strategy DROP DEFAULT;
+
+ALTER TABLE transcript_embeddings DROP CONSTRAINT IF EXISTS transcript_embeddings_video_id_user_id_chunk_index_key;
+
+ALTER TABLE transcript_embeddings
+ADD CONSTRAINT transcript_embeddings_video_user_strategy_chunk_key UNIQUE (video_id, user_id, chunk_strategy, chunk_index);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM transcript_embeddings WHERE chunk_strategy <> 'segment-v1';
+
+ALTER TABLE transcript_embeddings DROP CONSTRAINT IF EXISTS transcript_embeddings_video_user_strategy_chunk_key;
+
+ALTER TABLE transcript_embeddings
+ADD CONSTRAINT transcript_embeddings_video_id_user_id_chunk_index_key UNIQUE (video_id, user_id, chunk_index);
+
+ALTER TABLE transcript_embeddings
+DROP COLUMN IF EXISTS token_count,
+DROP COLUMN IF EXISTS chunk_strategy;
+-- +goose StatementEnd
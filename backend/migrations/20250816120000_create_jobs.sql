@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Durable background job queue, claimed with FOR UPDATE SKIP LOCKED
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'dead')),
+    dedupe_key VARCHAR(255),
+    payload JSONB NOT NULL DEFAULT '{}',
+    result JSONB,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT,
+    progress INTEGER NOT NULL DEFAULT 0,
+    total INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    locked_at TIMESTAMP,
+    locked_by VARCHAR(255),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Workers poll for due jobs
+CREATE INDEX IF NOT EXISTS idx_jobs_due
+ON jobs(run_at) WHERE status = 'queued';
+
+-- Stale running jobs are reclaimed after their lock expires
+CREATE INDEX IF NOT EXISTS idx_jobs_running
+ON jobs(locked_at) WHERE status = 'running';
+
+CREATE INDEX IF NOT EXISTS idx_jobs_user_created
+ON jobs(user_id, created_at DESC);
+
+-- Only one queued job per dedupe key; a running job may have a successor queued
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_dedupe_key
+ON jobs(dedupe_key) WHERE status = 'queued';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jobs;
+-- +goose StatementEnd
@@ -0,0 +1,102 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- WARNING: every existing vector is labelled text-embedding-3-small, the
+-- default OPENAI_EMBEDDING_MODEL. The old columns didn't record the model,
+-- and other 1536-dimension models such as text-embedding-ada-002 can't be
+-- told apart from it. Deployments that embedded with another model MUST
+-- relabel the rows right after migrating, before serving searches, or the
+-- two models' vectors are indexed and compared as one:
+--
+--   UPDATE timestamp_embeddings SET model = '<model>'
+--   WHERE model = 'text-embedding-3-small';
+--   UPDATE transcript_embeddings SET embedding_model = '<model>'
+--   WHERE embedding_model = 'text-embedding-3-small';
+
+CREATE TABLE IF NOT EXISTS timestamp_embeddings (
+    timestamp_id UUID NOT NULL REFERENCES timestamps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    model VARCHAR(100) NOT NULL,
+    dimensions INTEGER NOT NULL,
+    embedding VECTOR NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (timestamp_id, model)
+);
+
+CREATE INDEX IF NOT EXISTS idx_timestamp_embeddings_user_model
+ON timestamp_embeddings(user_id, model);
+
+INSERT INTO timestamp_embeddings (timestamp_id, user_id, model, dimensions, embedding, created_at, updated_at)
+SELECT id, user_id, 'text-embedding-3-small', 1536, embedding, updated_at, updated_at
+FROM timestamps
+WHERE embedding IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+DROP INDEX IF EXISTS idx_timestamps_embedding_hnsw;
+ALTER TABLE timestamps DROP COLUMN IF EXISTS embedding;
+
+-- Transcript chunks carry the model alongside the chunking strategy
+ALTER TABLE transcript_embeddings
+ADD COLUMN IF NOT EXISTS embedding_model VARCHAR(100) NOT NULL DEFAULT 'text-embedding-3-small',
+ADD COLUMN IF NOT EXISTS embedding_dimensions INTEGER NOT NULL DEFAULT 1536;
+
+ALTER TABLE transcript_embeddings
+ALTER COLUMN embedding_model DROP DEFAULT,
+ALTER COLUMN embedding_dimensions DROP DEFAULT;
+
+ALTER TABLE transcript_embeddings DROP CONSTRAINT IF EXISTS transcript_embeddings_video_user_strategy_chunk_key;
+
+ALTER TABLE transcript_embeddings
+ADD CONSTRAINT transcript_embeddings_video_user_strategy_model_chunk_key UNIQUE (video_id, user_id, chunk_strategy, embedding_model, chunk_index);
+
+-- Columns without a fixed dimension can't be indexed directly. Each model
+-- gets a partial HNSW index over the vectors cast to its dimension; indexes
+-- for new models are created by the re-embedding job. The indexes hold every
+-- user's vectors, so searches scan them iteratively (hnsw.iterative_scan),
+-- which needs pgvector 0.8 or later.
+DROP INDEX IF EXISTS idx_transcript_embeddings_embedding_hnsw;
+ALTER TABLE transcript_embeddings ALTER COLUMN embedding TYPE VECTOR;
+
+CREATE INDEX IF NOT EXISTS idx_timestamp_embeddings_text_embedding_3_small_1536
+ON timestamp_embeddings USING hnsw ((embedding::vector(1536)) vector_cosine_ops)
+WHERE model = 'text-embedding-3-small' AND dimensions = 1536;
+
+CREATE INDEX IF NOT EXISTS idx_transcript_embeddings_text_embedding_3_small_1536
+ON transcript_embeddings USING hnsw ((embedding::vector(1536)) vector_cosine_ops)
+WHERE embedding_model = 'text-embedding-3-small' AND embedding_dimensions = 1536;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_transcript_embeddings_text_embedding_3_small_1536;
+
+DELETE FROM transcript_embeddings
+WHERE embedding_model <> 'text-embedding-3-small' OR embedding_dimensions <> 1536;
+
+ALTER TABLE transcript_embeddings ALTER COLUMN embedding TYPE VECTOR(1536);
+
+ALTER TABLE transcript_embeddings DROP CONSTRAINT IF EXISTS transcript_embeddings_video_user_strategy_model_chunk_key;
+
+ALTER TABLE transcript_embeddings
+ADD CONSTRAINT transcript_embeddings_video_user_strategy_chunk_key UNIQUE (video_id, user_id, chunk_strategy, chunk_index);
+
+ALTER TABLE transcript_embeddings
+DROP COLUMN IF EXISTS embedding_dimensions,
+DROP COLUMN IF EXISTS embedding_model;
+
+CREATE INDEX IF NOT EXISTS idx_transcript_embeddings_embedding_hnsw
+ON transcript_embeddings USING hnsw (embedding vector_cosine_ops);
+
+ALTER TABLE timestamps ADD COLUMN IF NOT EXISTS embedding VECTOR(1536);
+
+UPDATE timestamps t SET embedding = te.embedding::vector(1536)
+FROM timestamp_embeddings te
+WHERE te.timestamp_id = t.id AND te.model = 'text-embedding-3-small' AND te.dimensions = 1536;
+
+CREATE INDEX IF NOT EXISTS idx_timestamps_embedding_hnsw
+ON timestamps USING hnsw (embedding vector_cosine_ops);
+
+DROP TABLE IF EXISTS timestamp_embeddings;
+-- +goose StatementEnd
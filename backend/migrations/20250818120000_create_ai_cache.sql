@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Cached embeddings and completions, see internal/aicache
+CREATE TABLE IF NOT EXISTS ai_cache (
+    key CHAR(64) PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('embedding', 'completion')),
+    model VARCHAR(100) NOT NULL,
+    feature VARCHAR(50) NOT NULL,
+    content TEXT,
+    embedding VECTOR,
+    hits INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_hit_at TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Expired entries are pruned periodically
+CREATE INDEX IF NOT EXISTS idx_ai_cache_expires_at
+ON ai_cache(expires_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ai_cache;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Section summaries of long videos, replaced when the summary is regenerated
+CREATE TABLE IF NOT EXISTS video_summary_sections (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    video_id VARCHAR(255) NOT NULL,
+    level INTEGER NOT NULL DEFAULT 0,
+    section_index INTEGER NOT NULL,
+    start_time DOUBLE PRECISION NOT NULL,
+    end_time DOUBLE PRECISION NOT NULL,
+    summary TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(user_id, video_id, level, section_index)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS video_summary_sections;
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Every AI output shown to a user, with the user's feedback on it
+CREATE TABLE IF NOT EXISTS ai_insights (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    video_id VARCHAR(255),
+    type VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    input TEXT,
+    content TEXT NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    confidence DOUBLE PRECISION CHECK (confidence BETWEEN 0 AND 1),
+    processing_time INTEGER,
+    metadata JSONB,
+    is_useful BOOLEAN,
+    user_rating INTEGER CHECK (user_rating BETWEEN 1 AND 5),
+    user_feedback TEXT,
+    flagged_at TIMESTAMP,
+    flag_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_insights_user_video
+ON ai_insights(user_id, video_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_ai_insights_type
+ON ai_insights(type, created_at DESC);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_ai_insights_type;
+DROP INDEX IF EXISTS idx_ai_insights_user_video;
+DROP TABLE IF EXISTS ai_insights;
+-- +goose StatementEnd
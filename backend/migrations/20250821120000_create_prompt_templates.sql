@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    version VARCHAR(50) NOT NULL,
+    body TEXT,
+    weight INTEGER NOT NULL DEFAULT 1 CHECK (weight >= 0),
+    description TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(name, version)
+);
+
+ALTER TABLE ai_insights
+ADD COLUMN IF NOT EXISTS prompt_name VARCHAR(100),
+ADD COLUMN IF NOT EXISTS prompt_version VARCHAR(50),
+ADD COLUMN IF NOT EXISTS prompts JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_ai_insights_prompt
+ON ai_insights(prompt_name, prompt_version);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_ai_insights_prompt;
+ALTER TABLE ai_insights
+DROP COLUMN IF EXISTS prompts,
+DROP COLUMN IF EXISTS prompt_version,
+DROP COLUMN IF EXISTS prompt_name;
+DROP TABLE IF EXISTS prompt_templates;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- A note may cover a segment of the video from timestamp to end_time, and
+-- carries a type and a 1-5 importance like clips
+ALTER TABLE timestamps
+ADD COLUMN IF NOT EXISTS end_time DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS type VARCHAR(20) NOT NULL DEFAULT 'note',
+ADD COLUMN IF NOT EXISTS importance SMALLINT NOT NULL DEFAULT 3;
+
+ALTER TABLE timestamps
+ADD CONSTRAINT timestamps_end_time_check CHECK (end_time IS NULL OR end_time > timestamp),
+ADD CONSTRAINT timestamps_type_check CHECK (type IN ('note', 'highlight', 'question', 'action')),
+ADD CONSTRAINT timestamps_importance_check CHECK (importance BETWEEN 1 AND 5);
+
+CREATE INDEX IF NOT EXISTS idx_timestamps_user_type_importance
+ON timestamps(user_id, type, importance) WHERE deleted_at IS NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_timestamps_user_type_importance;
+
+ALTER TABLE timestamps
+DROP CONSTRAINT IF EXISTS timestamps_importance_check,
+DROP CONSTRAINT IF EXISTS timestamps_type_check,
+DROP CONSTRAINT IF EXISTS timestamps_end_time_check;
+
+ALTER TABLE timestamps
+DROP COLUMN IF EXISTS importance,
+DROP COLUMN IF EXISTS type,
+DROP COLUMN IF EXISTS end_time;
+-- +goose StatementEnd
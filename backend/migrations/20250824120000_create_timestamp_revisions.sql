@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- The state of a note after each change to it, numbered per note. Deleted
+-- notes keep their revisions so they can be undeleted with their tags.
+CREATE TABLE IF NOT EXISTS timestamp_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    timestamp_id UUID NOT NULL REFERENCES timestamps(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL,
+    changed_fields TEXT[] NOT NULL DEFAULT '{}',
+    restored_from INTEGER,
+    timestamp DOUBLE PRECISION NOT NULL,
+    end_time DOUBLE PRECISION,
+    type VARCHAR(20) NOT NULL,
+    importance SMALLINT NOT NULL,
+    title VARCHAR(255),
+    note TEXT,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE(timestamp_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_timestamp_revisions_user_id ON timestamp_revisions(user_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS timestamp_revisions;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Images attached to notes. The content is kept in blob storage under
+-- storage_key; rows go with their note, blobs are removed by the API.
+CREATE TABLE IF NOT EXISTS timestamp_attachments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    timestamp_id UUID NOT NULL REFERENCES timestamps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_timestamp_attachments_timestamp_id ON timestamp_attachments(timestamp_id);
+CREATE INDEX IF NOT EXISTS idx_timestamp_attachments_user_id ON timestamp_attachments(user_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS timestamp_attachments;
+-- +goose StatementEnd
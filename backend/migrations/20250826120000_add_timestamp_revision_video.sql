@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Notes can be moved between videos, so revisions record the video too
+ALTER TABLE timestamp_revisions ADD COLUMN IF NOT EXISTS video_id VARCHAR(255);
+
+UPDATE timestamp_revisions AS r
+SET video_id = t.video_id
+FROM timestamps AS t
+WHERE t.id = r.timestamp_id AND r.video_id IS NULL;
+
+ALTER TABLE timestamp_revisions ALTER COLUMN video_id SET NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE timestamp_revisions DROP COLUMN IF EXISTS video_id;
+-- +goose StatementEnd
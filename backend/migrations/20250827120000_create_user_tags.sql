@@ -0,0 +1,89 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Tags belong to a user, so each user can rename, merge and color their own
+ALTER TABLE tags
+    ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS color VARCHAR(7),
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE tags DROP CONSTRAINT IF EXISTS tags_name_key;
+DROP INDEX IF EXISTS idx_tags_name_lower;
+
+-- Matches normalizeTagName in the timestamps handlers: no leading #, lower
+-- case, inner whitespace collapsed and no empty / segments. Names left empty
+-- are dropped with the shared tags below.
+CREATE FUNCTION pg_temp.normalize_tag_name(TEXT) RETURNS TEXT AS $$
+    SELECT COALESCE(string_agg(segment, '/' ORDER BY ord), '')
+    FROM (
+        SELECT btrim(regexp_replace(LOWER(part), '\s+', ' ', 'g')) AS segment, ord
+        FROM unnest(string_to_array(regexp_replace($1, '^\s*#?', ''), '/')) WITH ORDINALITY AS parts(part, ord)
+    ) AS segments
+    WHERE segment <> ''
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Shared tags with the same normalized name become one tag per user, so drop
+-- the relations that would then be duplicated
+DELETE FROM timestamp_tags AS tt
+USING tags AS t, timestamp_tags AS other, tags AS other_tag
+WHERE t.id = tt.tag_id
+  AND other.timestamp_id = tt.timestamp_id
+  AND other_tag.id = other.tag_id
+  AND pg_temp.normalize_tag_name(other_tag.name) = pg_temp.normalize_tag_name(t.name)
+  AND other_tag.id < t.id;
+
+-- Copy every shared tag to each user whose notes carry it
+INSERT INTO tags (id, user_id, name, created_at, updated_at)
+SELECT uuid_generate_v4(), ts.user_id, pg_temp.normalize_tag_name(t.name), MIN(t.created_at), CURRENT_TIMESTAMP
+FROM tags AS t
+JOIN timestamp_tags AS tt ON tt.tag_id = t.id
+JOIN timestamps AS ts ON ts.id = tt.timestamp_id
+WHERE t.user_id IS NULL
+  AND pg_temp.normalize_tag_name(t.name) <> ''
+GROUP BY ts.user_id, pg_temp.normalize_tag_name(t.name);
+
+UPDATE timestamp_tags AS tt
+SET tag_id = copy.id
+FROM tags AS shared, timestamps AS ts, tags AS copy
+WHERE shared.id = tt.tag_id
+  AND shared.user_id IS NULL
+  AND ts.id = tt.timestamp_id
+  AND copy.user_id = ts.user_id
+  AND copy.name = pg_temp.normalize_tag_name(shared.name);
+
+DELETE FROM tags WHERE user_id IS NULL;
+
+DROP FUNCTION pg_temp.normalize_tag_name(TEXT);
+
+ALTER TABLE tags ALTER COLUMN user_id SET NOT NULL;
+
+-- Nested tags are stored by their full path, such as ml/transformers
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags(user_id, name);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+
+-- Tags with the same name are merged back into one shared tag. A timestamp
+-- only carries its owner's tags, so no relation is duplicated.
+UPDATE timestamp_tags AS tt
+SET tag_id = keep.id
+FROM tags AS t,
+    LATERAL (SELECT k.id FROM tags AS k WHERE k.name = t.name ORDER BY k.id LIMIT 1) AS keep
+WHERE t.id = tt.tag_id AND keep.id <> t.id;
+
+DELETE FROM tags AS t
+WHERE EXISTS (SELECT 1 FROM tags AS k WHERE k.name = t.name AND k.id < t.id);
+
+DROP INDEX IF EXISTS idx_tags_user_name;
+
+ALTER TABLE tags
+    DROP COLUMN IF EXISTS user_id,
+    DROP COLUMN IF EXISTS color,
+    DROP COLUMN IF EXISTS description;
+
+ALTER TABLE tags ADD CONSTRAINT tags_name_key UNIQUE (name);
+CREATE INDEX IF NOT EXISTS idx_tags_name_lower ON tags(LOWER(name));
+
+-- +goose StatementEnd